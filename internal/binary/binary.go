@@ -0,0 +1,225 @@
+package binary
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	// LibcGlibc is the C library of most distributions, eg: debian and ubuntu.
+	LibcGlibc = "glibc"
+	// LibcMusl is the C library of alpine.
+	LibcMusl = "musl"
+	// LibcNone is the C library of images without one, eg: scratch. Only static binaries run on them.
+	LibcNone = "none"
+)
+
+// elfArchs are the architectures of ELF binaries, named as GOARCH, like the kubernetes.io/arch label of nodes.
+var elfArchs = map[elf.Machine]string{
+	elf.EM_X86_64:  "amd64",
+	elf.EM_AARCH64: "arm64",
+	elf.EM_386:     "386",
+	elf.EM_ARM:     "arm",
+	elf.EM_PPC64:   "ppc64le",
+	elf.EM_S390:    "s390x",
+	elf.EM_RISCV:   "riscv64",
+}
+
+// machoArchs are the architectures of Mach-O binaries, named as GOARCH.
+var machoArchs = map[macho.Cpu]string{
+	macho.CpuAmd64: "amd64",
+	macho.CpuArm64: "arm64",
+	macho.Cpu386:   "386",
+	macho.CpuArm:   "arm",
+}
+
+// elfOS are the OS of ELF binaries not built for linux, named as GOOS.
+var elfOS = map[elf.OSABI]string{
+	elf.ELFOSABI_FREEBSD: "freebsd",
+	elf.ELFOSABI_NETBSD:  "netbsd",
+	elf.ELFOSABI_OPENBSD: "openbsd",
+	elf.ELFOSABI_SOLARIS: "solaris",
+}
+
+// Binary represents an executable or shared library and the platform it's built for.
+type Binary struct {
+	Path string
+	// OS is what the binary runs on, named as GOOS. eg: 'linux' or 'darwin'
+	OS string
+	// Arch is the architecture the binary runs on, named as GOARCH. eg: 'amd64'. A universal Mach-O binary lists
+	// its architectures, eg: 'amd64,arm64'.
+	Arch string
+	// Libc is the C library a dynamically linked binary needs: LibcGlibc or LibcMusl. Empty for static binaries.
+	Libc string
+}
+
+// Platform returns the OS and architecture of the binary. eg: 'linux/amd64'
+func (b Binary) Platform() string {
+	return b.OS + "/" + b.Arch
+}
+
+// RunsWith returns true if the binary can run with the given C library.
+func (b Binary) RunsWith(libc string) bool {
+	return b.Libc == "" || b.Libc == libc
+}
+
+// Inspect reads the platform of the binary at path. It returns nil when the file isn't an executable or shared
+// library, eg: a text file or an object file.
+func Inspect(path string) (*Binary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return nil, nil
+	}
+
+	switch {
+	case bytes.Equal(magic, []byte(elf.ELFMAG)):
+		return inspectELF(f, path), nil
+	case binary.BigEndian.Uint32(magic) == macho.MagicFat:
+		return inspectFat(f, path), nil
+	case slices.Contains([]uint32{macho.Magic32, macho.Magic64}, binary.LittleEndian.Uint32(magic)),
+		slices.Contains([]uint32{macho.Magic32, macho.Magic64}, binary.BigEndian.Uint32(magic)):
+		return inspectMachO(f, path), nil
+	}
+
+	return nil, nil
+}
+
+func inspectELF(r io.ReaderAt, path string) *Binary {
+	// files that can't be parsed aren't binaries the app runs
+	f, err := elf.NewFile(r)
+	if err != nil || (f.Type != elf.ET_EXEC && f.Type != elf.ET_DYN) {
+		return nil
+	}
+
+	b := &Binary{Path: path, OS: "linux", Arch: elfArchs[f.Machine]}
+	if goos, ok := elfOS[f.OSABI]; ok {
+		b.OS = goos
+	}
+	if b.Arch == "" {
+		b.Arch = strings.ToLower(strings.TrimPrefix(f.Machine.String(), "EM_"))
+	}
+
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+		interpreter, _ := io.ReadAll(prog.Open())
+		b.Libc = LibcGlibc
+		// eg: '/lib/ld-musl-x86_64.so.1'
+		if strings.Contains(string(interpreter), "ld-musl") {
+			b.Libc = LibcMusl
+		}
+		return b
+	}
+
+	// shared libraries have no interpreter, but still need the C library they're linked against
+	libraries, _ := f.ImportedLibraries()
+	for _, library := range libraries {
+		switch {
+		case strings.HasPrefix(library, "libc.musl"):
+			b.Libc = LibcMusl
+		case strings.HasPrefix(library, "libc.so"):
+			b.Libc = LibcGlibc
+		}
+	}
+	return b
+}
+
+func inspectMachO(r io.ReaderAt, path string) *Binary {
+	f, err := macho.NewFile(r)
+	if err != nil || !slices.Contains([]macho.Type{macho.TypeExec, macho.TypeDylib, macho.TypeBundle}, f.Type) {
+		return nil
+	}
+	return &Binary{Path: path, OS: "darwin", Arch: machoArch(f.Cpu)}
+}
+
+func inspectFat(r io.ReaderAt, path string) *Binary {
+	// java class files share the magic of universal binaries
+	f, err := macho.NewFatFile(r)
+	if err != nil {
+		return nil
+	}
+
+	archs := []string{}
+	for _, arch := range f.Arches {
+		archs = append(archs, machoArch(arch.Cpu))
+	}
+	return &Binary{Path: path, OS: "darwin", Arch: strings.Join(archs, ",")}
+}
+
+func machoArch(cpu macho.Cpu) string {
+	if arch, ok := machoArchs[cpu]; ok {
+		return arch
+	}
+	return strings.ToLower(strings.TrimPrefix(cpu.String(), "Cpu"))
+}
+
+// Scan returns the binaries of the file or folder at root, leaving out .git folders and what skip returns true
+// for, given their slash separated path relative to root.
+func Scan(root string, skip func(name string) bool) ([]Binary, error) {
+	binaries := []Binary{}
+	root = filepath.Clean(root)
+	err := filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." && ((skip != nil && skip(rel)) || (d.IsDir() && d.Name() == ".git")) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		b, err := Inspect(file)
+		if err != nil {
+			return err
+		}
+		if b != nil {
+			binaries = append(binaries, *b)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+
+	return binaries, nil
+}
+
+// ImageLibc guesses the C library of an image from its name: musl for alpine based images, none for scratch and the
+// static distroless images, and glibc otherwise.
+func ImageLibc(image string) string {
+	name, _, _ := strings.Cut(strings.ToLower(image), "@")
+	switch {
+	case name == "scratch" || strings.Contains(name, "distroless/static"):
+		return LibcNone
+	case strings.Contains(name, "alpine"):
+		return LibcMusl
+	default:
+		return LibcGlibc
+	}
+}
@@ -0,0 +1,140 @@
+package binary_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	k8runbinary "github.com/lucasvmiguel/k8run/internal/binary"
+)
+
+// writeELF writes a 64 bits ELF file of the given type and machine, with the interpreter if it isn't empty.
+func writeELF(t *testing.T, path string, typ elf.Type, machine elf.Machine, interpreter string) {
+	t.Helper()
+	header := elf.Header64{
+		Type:      uint16(typ),
+		Machine:   uint16(machine),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     64,
+		Ehsize:    64,
+		Phentsize: 56,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	progs := []elf.Prog64{}
+	if interpreter != "" {
+		header.Phnum = 1
+		progs = append(progs, elf.Prog64{Type: uint32(elf.PT_INTERP), Off: 64 + 56, Filesz: uint64(len(interpreter) + 1)})
+	}
+
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.LittleEndian, header)
+	_ = binary.Write(buf, binary.LittleEndian, progs)
+	if interpreter != "" {
+		buf.WriteString(interpreter + "\x00")
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeMachO writes a 64 bits Mach-O executable for the given cpu.
+func writeMachO(t *testing.T, path string, cpu macho.Cpu) {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.LittleEndian, macho.FileHeader{Magic: macho.Magic64, Cpu: cpu, Type: macho.TypeExec})
+	// reserved field of 64 bits headers
+	_ = binary.Write(buf, binary.LittleEndian, uint32(0))
+	if err := os.WriteFile(path, buf.Bytes(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	dir := t.TempDir()
+	writeELF(t, filepath.Join(dir, "glibc"), elf.ET_EXEC, elf.EM_X86_64, "/lib64/ld-linux-x86-64.so.2")
+	writeELF(t, filepath.Join(dir, "musl"), elf.ET_DYN, elf.EM_AARCH64, "/lib/ld-musl-aarch64.so.1")
+	writeELF(t, filepath.Join(dir, "static"), elf.ET_EXEC, elf.EM_X86_64, "")
+	writeELF(t, filepath.Join(dir, "object.o"), elf.ET_REL, elf.EM_X86_64, "")
+	writeMachO(t, filepath.Join(dir, "darwin"), macho.CpuArm64)
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# app\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		expected *k8runbinary.Binary
+	}{
+		{name: "glibc", expected: &k8runbinary.Binary{OS: "linux", Arch: "amd64", Libc: k8runbinary.LibcGlibc}},
+		{name: "musl", expected: &k8runbinary.Binary{OS: "linux", Arch: "arm64", Libc: k8runbinary.LibcMusl}},
+		{name: "static", expected: &k8runbinary.Binary{OS: "linux", Arch: "amd64"}},
+		{name: "object.o", expected: nil},
+		{name: "darwin", expected: &k8runbinary.Binary{OS: "darwin", Arch: "arm64"}},
+		{name: "README.md", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			b, err := k8runbinary.Inspect(path)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if tt.expected == nil {
+				if b != nil {
+					t.Errorf("expected no binary, got %+v", b)
+				}
+				return
+			}
+			tt.expected.Path = path
+			if b == nil || *b != *tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, b)
+			}
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"bin", ".git", "node_modules"} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeELF(t, filepath.Join(dir, "bin", "server"), elf.ET_EXEC, elf.EM_X86_64, "")
+	writeELF(t, filepath.Join(dir, ".git", "hook"), elf.ET_EXEC, elf.EM_X86_64, "")
+	writeELF(t, filepath.Join(dir, "node_modules", "addon.node"), elf.ET_DYN, elf.EM_X86_64, "")
+
+	binaries, err := k8runbinary.Scan(dir, func(name string) bool { return name == "node_modules" })
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(binaries) != 1 || binaries[0].Path != filepath.Join(dir, "bin", "server") {
+		t.Errorf("expected only the binary outside .git and what's skipped, got %+v", binaries)
+	}
+}
+
+func TestImageLibc(t *testing.T) {
+	tests := map[string]string{
+		"node:20":                            k8runbinary.LibcGlibc,
+		"node:20-alpine":                     k8runbinary.LibcMusl,
+		"alpine@sha256:abc":                  k8runbinary.LibcMusl,
+		"scratch":                            k8runbinary.LibcNone,
+		"gcr.io/distroless/static-debian12":  k8runbinary.LibcNone,
+		"gcr.io/distroless/base-debian12":    k8runbinary.LibcGlibc,
+		"registry.example.com/team/app:v1.2": k8runbinary.LibcGlibc,
+	}
+
+	for image, expected := range tests {
+		if libc := k8runbinary.ImageLibc(image); libc != expected {
+			t.Errorf("expected %s to ship %s, got %s", image, expected, libc)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package command
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasvmiguel/k8run/internal/detect"
+	"github.com/lucasvmiguel/k8run/internal/logging"
+)
+
+// detect infers what isn't set from the files of the first copy, keeping the inferences applied.
+func (c *DeploymentCommand) detect() error {
+	if c.NoCopy || c.GitRepo != "" {
+		return fmt.Errorf("Auto requires Copy, the folder the app is detected from")
+	}
+	source := c.source()
+	if info, err := os.Stat(source); err != nil || !info.IsDir() {
+		return fmt.Errorf("Auto requires the first copy to be a folder, got %s", source)
+	}
+
+	app, err := detect.Detect(source)
+	if err != nil {
+		return fmt.Errorf("Failed to detect the app in %s: %s", source, err)
+	}
+	if app.GoBinary != "" && c.CopyGitRef != "" {
+		return fmt.Errorf("Auto can't compile the Go app in %s at CopyGitRef", source)
+	}
+
+	applied := []detect.Inference{}
+	for _, inference := range app.Inferences {
+		apply := false
+		switch inference.Field {
+		case "image":
+			apply = c.Image == ""
+			c.Image = cmp.Or(c.Image, app.Image)
+		case "entrypoint":
+			apply = len(c.Entrypoint) == 0
+			if apply {
+				c.Entrypoint = app.Entrypoint
+			}
+		case "container port":
+			apply = c.ContainerPort == 0
+			if apply {
+				c.ContainerPort = app.ContainerPort
+			}
+		case "setup":
+			apply = c.Setup == ""
+			c.Setup = cmp.Or(c.Setup, app.Setup)
+		case "setup cache":
+			// the caches only make sense with the setup they were inferred with
+			apply = len(c.SetupCache) == 0 && c.Setup == app.Setup
+			if apply {
+				c.SetupCache = app.SetupCache
+			}
+		case "env":
+			name, value, _ := strings.Cut(inference.Value, "=")
+			_, set := c.Env[name]
+			apply = !set
+			if apply {
+				env := map[string]string{name: value}
+				maps.Copy(env, c.Env)
+				c.Env = env
+			}
+		default:
+			apply = true
+		}
+		if apply {
+			applied = append(applied, inference)
+		}
+	}
+
+	// the entrypoint is relative to the folder of the app, wherever it lands
+	_, mounts := c.copyLayout()
+	if c.WorkDir == "" && mounts[0].Path != copyTo {
+		c.WorkDir = mounts[0].Path
+		applied = append(applied, detect.Inference{Field: "workdir", Value: c.WorkDir, Source: "where " + source + " lands"})
+	}
+
+	app.Inferences = applied
+	c.detected = app
+	return nil
+}
+
+// Inferences returns what was inferred from the copied folder with Auto, once validated.
+func (c *DeploymentCommand) Inferences() []detect.Inference {
+	if c.detected == nil {
+		return nil
+	}
+	return c.detected.Inferences
+}
+
+// compileGo cross-compiles the Go app detected in dir for linux and the architecture of the nodes, into the folder
+// out.
+func (c *DeploymentCommand) compileGo(ctx context.Context, dir string, out string) error {
+	logging.FromContext(ctx).With("package", c.detected.GoPackage, "os", "linux", "arch", c.goArch()).Info("Compiling Go app...")
+
+	cmd := exec.CommandContext(ctx, "go", "build", "-trimpath", "-o", filepath.Join(out, c.detected.GoBinary), c.detected.GoPackage)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH="+c.goArch(), "CGO_ENABLED=0")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package command
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+)
+
+// testAutoCommand returns a deployment command with Auto copying a folder named foobar with the given files, and
+// nothing else to infer set.
+func testAutoCommand(t *testing.T, files map[string]string) *DeploymentCommand {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "foobar")
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := testDeploymentCommand()
+	c.Auto = true
+	c.Copy = dir
+	c.Image, c.Entrypoint, c.ContainerPort = "", nil, 0
+	return c
+}
+
+func TestDeploymentCommand_ValidateAuto(t *testing.T) {
+	c := testAutoCommand(t, map[string]string{"package.json": `{"scripts": {"start": "node index.js"}}`, "package-lock.json": "{}"})
+	c.Image = "node:18-alpine"
+	if err := c.Validate(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if c.Image != "node:18-alpine" || !slices.Equal(c.Entrypoint, []string{"npm", "start"}) || c.ContainerPort != 3000 {
+		t.Errorf("expected what isn't set to be inferred, got %s %v %d", c.Image, c.Entrypoint, c.ContainerPort)
+	}
+	if c.Setup != "npm ci" || !slices.Equal(c.SetupCache, []string{"node_modules"}) || c.WorkDir != "/app/foobar" {
+		t.Errorf("expected the setup to run where the folder lands, got %q %v in %s", c.Setup, c.SetupCache, c.WorkDir)
+	}
+	for _, inference := range c.Inferences() {
+		if inference.Field == "image" {
+			t.Errorf("expected the image set to be kept, got %s", inference)
+		}
+	}
+
+	c = testAutoCommand(t, map[string]string{"README.md": ""})
+	if err := c.Validate(); err == nil {
+		t.Errorf("expected an error without an app to detect")
+	}
+	c = testAutoCommand(t, nil)
+	c.Copy, c.NoCopy = "", true
+	if err := c.Validate(); err == nil {
+		t.Errorf("expected an error without a copy")
+	}
+}
+
+func TestDeploymentCommand_AutoGo(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go isn't installed")
+	}
+
+	c := testAutoCommand(t, map[string]string{"go.mod": "module example.com/server\n\ngo 1.21\n", "main.go": "package main\n\nfunc main() {}\n"})
+	if err := c.Validate(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	copier := &k8s.MemoryCopier{}
+	c.Copier = copier
+	if err := c.copyCode(context.Background(), "test-pod"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if files := copier.Files(); !slices.Equal(files, []string{"/app/foobar/server"}) {
+		t.Errorf("expected the binary to be copied instead of the sources, got %v", files)
+	}
+	if !slices.Equal(c.Entrypoint, []string{"./server"}) || c.WorkDir != "/app/foobar" {
+		t.Errorf("expected the binary to run from where it lands, got %v in %s", c.Entrypoint, c.WorkDir)
+	}
+}
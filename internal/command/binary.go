@@ -0,0 +1,170 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/lucasvmiguel/k8run/internal/binary"
+	"github.com/lucasvmiguel/k8run/internal/doctor"
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// defaultArch is the architecture detected Go apps are compiled for when the nodes can't tell.
+const defaultArch = "amd64"
+
+// nodeArchs are the architectures NodeArch can pin, as GOARCH and the kubernetes.io/arch label of nodes.
+var nodeArchs = []string{"amd64", "arm64", "arm", "386", "ppc64le", "s390x", "riscv64"}
+
+// scanBinaries returns the executables and shared libraries of the local files and folders copied. Archives, git
+// refs and the sources of detected Go apps aren't copied as they are, and the setup caches are left out.
+func (c *DeploymentCommand) scanBinaries() ([]binary.Binary, error) {
+	if c.NoCopy || c.GitRepo != "" {
+		return nil, nil
+	}
+
+	found := []binary.Binary{}
+	sources, mounts := c.copyLayout()
+	for i, source := range sources {
+		if source.Archive != "" || (i == 0 && (c.CopyGitRef != "" || c.compilesGo())) {
+			continue
+		}
+		binaries, err := binary.Scan(source.Path, c.skipCaches(mounts[i].Path))
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, binaries...)
+	}
+	return found, nil
+}
+
+// nodeArchsCheck lists the architectures of the nodes, the ones detected Go apps are compiled for, and checks
+// NodeArch is one of them. Without the nodes, detected Go apps are compiled for NodeArch or amd64.
+func (c *DeploymentCommand) nodeArchsCheck(ctx context.Context, clientset kubernetes.Interface) doctor.Check {
+	archs, err := k8s.ListNodeArchs(ctx, clientset)
+	c.nodeArchs = archs
+
+	check := doctor.Check{Name: "node architectures", Status: doctor.Pass, Message: strings.Join(archs, ", ")}
+	unknown := ""
+	if c.compilesGo() && c.NodeArch == "" {
+		unknown = fmt.Sprintf(", the Go app is compiled for linux/%s, set --node-arch if they run another architecture", defaultArch)
+	}
+	switch {
+	case err != nil:
+		check.Status = doctor.Warn
+		check.Message = fmt.Sprintf("they can't be listed: %s%s", err, unknown)
+	case len(archs) == 0:
+		check.Status = doctor.Warn
+		check.Message = "no schedulable linux node was found" + unknown
+	case c.NodeArch != "" && !slices.Contains(archs, c.NodeArch):
+		check.Status = doctor.Fail
+		check.Message = fmt.Sprintf("no schedulable node runs %s, the nodes run %s", c.NodeArch, strings.Join(archs, ", "))
+	}
+	return check
+}
+
+// binaryChecks checks the binaries copied can run on the nodes the app is scheduled on, with the C library of its
+// image, along with the architectures of the nodes.
+func (c *DeploymentCommand) binaryChecks(ctx context.Context, clientset kubernetes.Interface) []doctor.Check {
+	checks := []doctor.Check{}
+	binaries, err := c.scanBinaries()
+	if err != nil {
+		checks = append(checks, doctor.Check{Name: "binaries of the copy", Status: doctor.Warn, Message: fmt.Sprintf("they can't be scanned: %s", err)})
+	}
+	if len(binaries) == 0 && !c.compilesGo() && c.NodeArch == "" {
+		return checks
+	}
+
+	// without the nodes, the binaries are only checked against NodeArch
+	checks = append(checks, c.nodeArchsCheck(ctx, clientset))
+	archs := c.nodeArchs
+	if c.NodeArch != "" {
+		archs = []string{c.NodeArch}
+	}
+	if c.compilesGo() && len(archs) > 1 {
+		checks = append(checks, doctor.Check{
+			Name:    fmt.Sprintf("Go app compiled for linux/%s", c.goArch()),
+			Status:  doctor.Warn,
+			Message: fmt.Sprintf("the nodes run %s, pin the ones it runs on with --node-arch %s", strings.Join(archs, ", "), c.goArch()),
+		})
+	}
+
+	// binaries with the same problem are reported together
+	problems := []doctor.Check{}
+	paths := map[doctor.Check][]string{}
+	for _, b := range binaries {
+		problem, ok := c.binaryProblem(b, archs)
+		if !ok {
+			continue
+		}
+		if _, seen := paths[problem]; !seen {
+			problems = append(problems, problem)
+		}
+		paths[problem] = append(paths[problem], b.Path)
+	}
+	for _, problem := range problems {
+		problem.Name = "binary " + summarize(paths[problem])
+		if problem.Status == doctor.Fail {
+			problem.Message += ", use --skip-binary-check if the app doesn't run it"
+		}
+		checks = append(checks, problem)
+	}
+
+	return checks
+}
+
+// binaryProblem returns why the binary can't run on the nodes of the given architectures or with the C library of
+// the image, if it can't.
+func (c *DeploymentCommand) binaryProblem(b binary.Binary, archs []string) (doctor.Check, bool) {
+	target := "linux"
+	if len(archs) == 1 {
+		target += "/" + archs[0]
+	}
+
+	libc := binary.ImageLibc(c.Image)
+	switch {
+	case b.OS != "linux":
+		return doctor.Check{Status: doctor.Fail, Message: fmt.Sprintf("is built for %s and can't run on linux nodes, build it for %s", b.Platform(), target)}, true
+	case len(archs) > 0 && !slices.Contains(archs, b.Arch):
+		return doctor.Check{Status: doctor.Fail, Message: fmt.Sprintf("is built for %s, but the nodes run %s, so it would fail with 'exec format error', build it for %s", b.Platform(), strings.Join(archs, ", "), target)}, true
+	case len(archs) > 1:
+		return doctor.Check{Status: doctor.Warn, Message: fmt.Sprintf("is built for %s and only runs on some nodes, pin them with --node-arch %s", b.Platform(), b.Arch)}, true
+	case !b.RunsWith(libc) && libc == binary.LibcNone:
+		return doctor.Check{Status: doctor.Fail, Message: fmt.Sprintf("is linked against %s, but %s has no C library, build it statically, eg: with CGO_ENABLED=0", b.Libc, c.Image)}, true
+	case !b.RunsWith(libc):
+		return doctor.Check{Status: doctor.Fail, Message: fmt.Sprintf("is linked against %s, but %s ships %s, use an image with %s or build it statically", b.Libc, c.Image, libc, b.Libc)}, true
+	}
+	return doctor.Check{}, false
+}
+
+// compilesGo returns true when the first copy is a detected Go app, compiled before being copied.
+func (c *DeploymentCommand) compilesGo() bool {
+	return c.detected != nil && c.detected.GoBinary != ""
+}
+
+// goArch returns the architecture detected Go apps are compiled for: NodeArch, the one of the nodes, or amd64 when
+// they run several.
+func (c *DeploymentCommand) goArch() string {
+	switch {
+	case c.NodeArch != "":
+		return c.NodeArch
+	case len(c.nodeArchs) == 1:
+		return c.nodeArchs[0]
+	case len(c.nodeArchs) > 1 && !slices.Contains(c.nodeArchs, defaultArch):
+		return c.nodeArchs[0]
+	default:
+		return defaultArch
+	}
+}
+
+// summarize returns the first paths and how many others there are. eg: 'bin/a, bin/b and 3 more'
+func summarize(paths []string) string {
+	const shown = 3
+	if len(paths) <= shown {
+		return strings.Join(paths, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(paths[:shown], ", "), len(paths)-shown)
+}
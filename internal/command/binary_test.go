@@ -0,0 +1,153 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/detect"
+	"github.com/lucasvmiguel/k8run/internal/doctor"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// writeExecutable writes a 64 bits linux executable for the given machine, dynamically linked when the interpreter
+// isn't empty.
+func writeExecutable(t *testing.T, path string, machine elf.Machine, interpreter string) {
+	t.Helper()
+	header := elf.Header64{Type: uint16(elf.ET_EXEC), Machine: uint16(machine), Version: 1, Phoff: 64, Ehsize: 64, Phentsize: 56}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS], header.Ident[elf.EI_DATA], header.Ident[elf.EI_VERSION] = 2, 1, 1
+
+	buf := &bytes.Buffer{}
+	if interpreter != "" {
+		header.Phnum = 1
+		_ = binary.Write(buf, binary.LittleEndian, header)
+		_ = binary.Write(buf, binary.LittleEndian, elf.Prog64{Type: uint32(elf.PT_INTERP), Off: 64 + 56, Filesz: uint64(len(interpreter))})
+		buf.WriteString(interpreter)
+	} else {
+		_ = binary.Write(buf, binary.LittleEndian, header)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testArchNode(name, arch string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   name,
+		Labels: map[string]string{corev1.LabelOSStable: "linux", corev1.LabelArchStable: arch},
+	}}
+}
+
+// failures returns the names of the checks that failed.
+func failures(checks []doctor.Check) []string {
+	names := []string{}
+	for _, check := range checks {
+		if check.Status == doctor.Fail {
+			names = append(names, check.Name)
+		}
+	}
+	return names
+}
+
+func TestDeploymentCommand_BinaryChecks(t *testing.T) {
+	dir := t.TempDir()
+	writeExecutable(t, filepath.Join(dir, "bin", "server"), elf.EM_AARCH64, "")
+	writeExecutable(t, filepath.Join(dir, "bin", "worker"), elf.EM_X86_64, "/lib64/ld-linux-x86-64.so.2")
+	writeExecutable(t, filepath.Join(dir, "node_modules", "tool"), elf.EM_AARCH64, "")
+
+	c := testDeploymentCommand()
+	c.Copy = dir
+	c.WorkDir = "/app/" + filepath.Base(dir)
+	c.Image = "node:20-alpine"
+	c.Setup, c.SetupCache = "npm ci", []string{"node_modules"}
+	clientset := fake.NewSimpleClientset(testArchNode("node-1", "amd64"))
+
+	checks := c.binaryChecks(context.Background(), clientset)
+	failed := failures(checks)
+	if len(failed) != 2 {
+		t.Fatalf("expected the arm64 binary and the glibc one to fail, got %+v", checks)
+	}
+	for _, check := range checks {
+		switch {
+		case check.Name == "binary "+filepath.Join(dir, "bin", "server") && !strings.Contains(check.Message, "exec format error"):
+			t.Errorf("expected the arm64 binary not to run on amd64 nodes, got %s", check.Message)
+		case check.Name == "binary "+filepath.Join(dir, "bin", "worker") && !strings.Contains(check.Message, "musl"):
+			t.Errorf("expected the glibc binary not to run on alpine, got %s", check.Message)
+		case strings.Contains(check.Name, "node_modules"):
+			t.Errorf("expected the setup cache not to be scanned, got %s", check.Name)
+		}
+	}
+
+	// pinned to arm64 nodes, the arm64 binary runs and the amd64 one doesn't
+	c.NodeArch = "arm64"
+	c.Image = "node:20"
+	clientset = fake.NewSimpleClientset(testArchNode("node-1", "amd64"), testArchNode("node-2", "arm64"))
+	failed = failures(c.binaryChecks(context.Background(), clientset))
+	if len(failed) != 1 || failed[0] != "binary "+filepath.Join(dir, "bin", "worker") {
+		t.Errorf("expected only the amd64 binary to fail, got %v", failed)
+	}
+
+	c.NodeArch = "s390x"
+	failed = failures(c.binaryChecks(context.Background(), clientset))
+	if len(failed) != 3 || failed[0] != "node architectures" {
+		t.Errorf("expected no node to run s390x, got %v", failed)
+	}
+}
+
+func TestDeploymentCommand_BinaryChecksMixedNodes(t *testing.T) {
+	dir := t.TempDir()
+	writeExecutable(t, filepath.Join(dir, "server"), elf.EM_X86_64, "")
+
+	c := testDeploymentCommand()
+	c.Copy = dir
+	c.Image = "scratch"
+	clientset := fake.NewSimpleClientset(testArchNode("node-1", "amd64"), testArchNode("node-2", "arm64"))
+
+	checks := c.binaryChecks(context.Background(), clientset)
+	if len(failures(checks)) != 0 || len(checks) != 2 || checks[1].Status != doctor.Warn || !strings.Contains(checks[1].Message, "--node-arch amd64") {
+		t.Errorf("expected a warning to pin the nodes the static binary runs on, got %+v", checks)
+	}
+}
+
+func TestDeploymentCommand_ValidateNodeArch(t *testing.T) {
+	c := testDeploymentCommand()
+	c.NodeArch = "arm64"
+	if err := c.Validate(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if params := c.deploymentParams("test-release"); params.NodeArch != "arm64" {
+		t.Errorf("expected the pods to be pinned to arm64 nodes, got %q", params.NodeArch)
+	}
+
+	c.NodeArch = "x86_64"
+	if err := c.Validate(); err == nil {
+		t.Errorf("expected an error with an architecture nodes don't use")
+	}
+}
+
+func TestDeploymentCommand_GoArchSkipBinaryCheck(t *testing.T) {
+	c := testDeploymentCommand()
+	c.Copy = t.TempDir()
+	c.SkipBinaryCheck = true
+	c.detected = &detect.App{Runtime: detect.Go, GoBinary: "server", GoPackage: "."}
+	clientset := readyClientset(append(readyObjects(), testArchNode("node-1", "arm64")))
+
+	if err := c.preflight(context.Background(), clientset); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if arch := c.goArch(); arch != "arm64" {
+		t.Errorf("expected the Go app to be compiled for the arm64 nodes without the binary check, got %s", arch)
+	}
+}
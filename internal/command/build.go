@@ -0,0 +1,282 @@
+package command
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/logging"
+	"github.com/lucasvmiguel/k8run/internal/plan"
+
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewBuildCommandParams represents the parameters to create a new build command.
+type NewBuildCommandParams struct {
+	Registry       string
+	RegistrySecret string
+	Dockerfile     string
+	Output         io.Writer
+	Deployment     *DeploymentCommand
+}
+
+// BuildCommand represents a command to build the image of an app in the cluster from a Dockerfile and deploy it.
+// The context of the build is copied into a job, which builds the image without a docker daemon, pushes it to the
+// registry and writes its digest. The image is then deployed by its digest, as the image of a deployment command.
+type BuildCommand struct {
+	// Registry is the repository the image is pushed to, as '<Registry>/<name>'. eg: 'ghcr.io/org'
+	Registry string
+	// RegistrySecret is the docker config secret the image is pushed and pulled with.
+	RegistrySecret string
+	// Dockerfile is the path of the Dockerfile, relative to the context. Defaults to 'Dockerfile'.
+	Dockerfile string
+	// Output receives the logs of the build. Defaults to os.Stderr.
+	Output io.Writer
+	// Deployment deploys the image built. Its copy is the context of the build, and its image is set once it's built.
+	Deployment *DeploymentCommand
+}
+
+// NewBuildCommand creates a new build command.
+func NewBuildCommand(params NewBuildCommandParams) *BuildCommand {
+	return &BuildCommand{
+		Registry:       params.Registry,
+		RegistrySecret: params.RegistrySecret,
+		Dockerfile:     params.Dockerfile,
+		Output:         params.Output,
+		Deployment:     params.Deployment,
+	}
+}
+
+// Validate validates the build and the deployment of the image built.
+func (c *BuildCommand) Validate() error {
+	d := c.Deployment
+	if c.Registry == "" {
+		return fmt.Errorf("Registry is required")
+	}
+	if strings.Contains(c.Registry, "://") || strings.HasSuffix(c.Registry, "/") {
+		return fmt.Errorf("Registry must be a repository prefix, eg: 'ghcr.io/org'")
+	}
+	if d.Image != "" {
+		return fmt.Errorf("Image can't be used with build, the image is built from the Dockerfile")
+	}
+	if d.NoCopy || d.GitRepo != "" {
+		return fmt.Errorf("Copy is required")
+	}
+	if d.Setup != "" {
+		return fmt.Errorf("Setup can't be used with build, run it in the Dockerfile")
+	}
+	if copies := d.copies(); len(copies) != 1 || copies[0].Dest != "" || copies[0].ReadOnly {
+		return fmt.Errorf("Build takes a single Copy without destination, the context of the build")
+	}
+
+	c.Dockerfile = cmp.Or(c.Dockerfile, "Dockerfile")
+	if dockerfile := path.Clean(filepath.ToSlash(c.Dockerfile)); path.IsAbs(dockerfile) || strings.HasPrefix(dockerfile, "../") || dockerfile == ".." {
+		return fmt.Errorf("Dockerfile must be relative to the context")
+	}
+	// archives, stdin and git refs are only read once the build runs
+	source := d.source()
+	if info, err := os.Stat(source); err == nil && info.IsDir() && d.CopyGitRef == "" {
+		if _, err := os.Stat(filepath.Join(source, c.Dockerfile)); err != nil {
+			return fmt.Errorf("Dockerfile %s not found in %s", c.Dockerfile, source)
+		}
+	}
+
+	// the image is deployed by its digest once it's built
+	if err := d.resolvePreview(); err != nil {
+		return err
+	}
+	if d.Name == "" {
+		return fmt.Errorf("Name is required")
+	}
+	d.Image = c.repository()
+
+	return d.Validate()
+}
+
+// Result returns the outcome of the deployment of the image built.
+func (c *BuildCommand) Result() *Result {
+	return c.Deployment.Result()
+}
+
+// Run builds the image, streaming the logs of the build, and deploys it.
+func (c *BuildCommand) Run(ctx context.Context) error {
+	d := c.Deployment
+	logging.FromContext(ctx).With("registry", c.Registry, "dockerfile", c.Dockerfile).Info("Starting build...")
+	err := d.resolve()
+	if err != nil {
+		return err
+	}
+
+	// the context is copied into the build job, and the deployment runs the image as it is
+	source := *d
+	c.deployImage(d)
+
+	err = c.build(ctx, &source)
+	if err != nil {
+		return err
+	}
+
+	return d.Run(ctx)
+}
+
+// Plan returns the changes the deployment of the image built will make to the cluster. The build job is left out,
+// as it's only kept for a few minutes once finished.
+func (c *BuildCommand) Plan(ctx context.Context) (*plan.Plan, error) {
+	d := *c.Deployment
+	c.deployImage(&d)
+	return d.Plan(ctx)
+}
+
+// deployImage turns d into the deployment of the image built, without a copy.
+func (c *BuildCommand) deployImage(d *DeploymentCommand) {
+	d.Copy, d.Copies, d.CopyGitRef, d.Stdin = "", nil, "", nil
+	d.NoCopy = true
+	d.imagePullSecret = c.RegistrySecret
+}
+
+// build runs the build job of the context and sets the image of the deployment to the image pushed.
+func (c *BuildCommand) build(ctx context.Context, source *DeploymentCommand) error {
+	d := c.Deployment
+	ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+	defer cancel()
+
+	clientset, err := newClientset(d.Kube)
+	if err != nil {
+		return err
+	}
+
+	err = checkAccess(ctx, clientset, d.Namespace, c.permissions())
+	if err != nil {
+		return err
+	}
+
+	err = d.ensureNamespace(ctx, clientset)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	releaseIdentifier := rand.String(10)
+	job := c.buildParams(releaseIdentifier)
+	err = k8s.CreateBuildImageJob(ctx, clientset, job)
+	if err != nil {
+		return fmt.Errorf("Failed to create build job: %w", err)
+	}
+
+	pod, err := k8s.WaitForRunningInitContainer(ctx, clientset, k8s.WaitForRunningInitContainerParams{
+		Namespace:         d.Namespace,
+		Name:              job.Name,
+		InitContainerName: initContainerName,
+		ReleaseIdentifier: releaseIdentifier,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to wait for init container: %w", err)
+	}
+
+	err = source.copyCode(ctx, pod.Name)
+	if err != nil {
+		return err
+	}
+
+	_, err = k8s.WaitForContainerToStart(ctx, clientset, k8s.WaitForContainerToStartParams{
+		Namespace: d.Namespace,
+		PodName:   pod.Name,
+		Container: k8s.BuilderContainerName,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to build image (see 'kubectl logs -n %s job/%s'): %w", d.Namespace, job.Name, err)
+	}
+
+	// the logs end when the builder exits
+	err = k8s.StreamLogs(ctx, clientset, k8s.LogsParams{
+		Namespace: d.Namespace,
+		PodName:   pod.Name,
+		Container: k8s.BuilderContainerName,
+		Follow:    true,
+	}, c.output())
+	if err != nil {
+		logging.FromContext(ctx).With("error", err).Warn("Failed to stream the logs of the build")
+	}
+
+	err = k8s.WaitForJobToComplete(ctx, clientset, k8s.GetParams{Name: job.Name, Namespace: d.Namespace})
+	if err != nil {
+		return fmt.Errorf("Failed to build image (see 'kubectl logs -n %s job/%s'): %w", d.Namespace, job.Name, err)
+	}
+
+	digest, err := c.readDigest(ctx, clientset, releaseIdentifier)
+	if err != nil {
+		return err
+	}
+
+	d.Image = c.repository() + "@" + digest
+	d.build = &BuildResult{
+		Image:           d.Image,
+		Digest:          digest,
+		DurationSeconds: time.Since(start).Seconds(),
+	}
+	logging.FromContext(ctx).With("image", d.Image, "duration", time.Since(start).Round(time.Second)).Info("Image built")
+
+	return nil
+}
+
+// readDigest reads the digest of the image pushed, written by the builder as its termination message.
+func (c *BuildCommand) readDigest(ctx context.Context, clientset kubernetes.Interface, releaseIdentifier string) (string, error) {
+	pods, err := k8s.ListPods(ctx, clientset, k8s.ListParams{
+		Namespace:     c.Deployment.Namespace,
+		LabelSelector: fmt.Sprintf("%s=%s", k8s.LabelNameReleaseIdentifier, releaseIdentifier),
+	})
+	if err != nil {
+		return "", fmt.Errorf("Failed to list pods: %w", err)
+	}
+
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name != k8s.BuilderContainerName || status.State.Terminated == nil {
+				continue
+			}
+			if digest := strings.TrimSpace(status.State.Terminated.Message); strings.HasPrefix(digest, "sha256:") {
+				return digest, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("Failed to read the digest of the image built")
+}
+
+// repository returns the repository of the image built, named after the app.
+func (c *BuildCommand) repository() string {
+	return c.Registry + "/" + c.Deployment.Name
+}
+
+func (c *BuildCommand) buildParams(releaseIdentifier string) k8s.BuildImageParams {
+	d := c.Deployment
+	return k8s.BuildImageParams{
+		Name:                 fmt.Sprintf("%s-build-%s", d.Name, releaseIdentifier),
+		Namespace:            d.Namespace,
+		App:                  d.Name,
+		ReleaseIdentifier:    releaseIdentifier,
+		InitContainerName:    initContainerName,
+		InitContainerCommand: waitForCopyCommand(),
+		ContextPath:          copyTo,
+		Dockerfile:           filepath.ToSlash(c.Dockerfile),
+		// every build is pushed under a tag of its own, and deployed by digest
+		Destination:    c.repository() + ":" + releaseIdentifier,
+		RegistrySecret: c.RegistrySecret,
+		NodeArch:       d.NodeArch,
+	}
+}
+
+func (c *BuildCommand) output() io.Writer {
+	if c.Output == nil {
+		return os.Stderr
+	}
+	return c.Output
+}
@@ -0,0 +1,121 @@
+package command
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestBuildCommand_Validate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM node:20\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		registry   string
+		dockerfile string
+		edit       func(d *DeploymentCommand)
+		wantErr    bool
+	}{
+		{name: "valid build", registry: "ghcr.io/org", wantErr: false},
+		{name: "missing registry", registry: "", wantErr: true},
+		{name: "registry with scheme", registry: "https://ghcr.io/org", wantErr: true},
+		{name: "missing dockerfile", registry: "ghcr.io/org", dockerfile: "Dockerfile.prod", wantErr: true},
+		{name: "dockerfile outside the context", registry: "ghcr.io/org", dockerfile: "../Dockerfile", wantErr: true},
+		{name: "image", registry: "ghcr.io/org", edit: func(d *DeploymentCommand) { d.Image = "node:20" }, wantErr: true},
+		{name: "no copy", registry: "ghcr.io/org", edit: func(d *DeploymentCommand) { d.Copy = ""; d.NoCopy = true }, wantErr: true},
+		{
+			name:     "several copies",
+			registry: "ghcr.io/org",
+			edit:     func(d *DeploymentCommand) { d.Copies = []CopyEntry{{Source: dir, Dest: "/etc/app"}} },
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := testDeploymentCommand()
+			d.Image = ""
+			d.Copy = dir
+			if tt.edit != nil {
+				tt.edit(d)
+			}
+
+			c := NewBuildCommand(NewBuildCommandParams{Registry: tt.registry, Dockerfile: tt.dockerfile, Deployment: d})
+			err := c.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && d.Image != "ghcr.io/org/test" {
+				t.Errorf("expected the image to be pushed to the registry, got %s", d.Image)
+			}
+		})
+	}
+}
+
+func TestBuildCommand_BuildParams(t *testing.T) {
+	d := testDeploymentCommand()
+	c := NewBuildCommand(NewBuildCommandParams{Registry: "ghcr.io/org", Dockerfile: "docker/Dockerfile", RegistrySecret: "registry", Deployment: d})
+
+	job := k8s.BuildImageJob(c.buildParams("release"))
+	builder := job.Spec.Template.Spec.Containers[0]
+	want := []string{"--context=dir:///app", "--dockerfile=/app/docker/Dockerfile", "--destination=ghcr.io/org/test:release", "--digest-file=/dev/termination-log"}
+	if len(builder.Args) != len(want) {
+		t.Fatalf("expected args %v, got %v", want, builder.Args)
+	}
+	for i := range want {
+		if builder.Args[i] != want[i] {
+			t.Errorf("expected args %v, got %v", want, builder.Args)
+		}
+	}
+	if job.Spec.Template.Spec.InitContainers[0].Name != initContainerName {
+		t.Errorf("expected the init container to wait for the copy, got %v", job.Spec.Template.Spec.InitContainers)
+	}
+}
+
+func TestBuildCommand_DeployImage(t *testing.T) {
+	d := testDeploymentCommand()
+	c := NewBuildCommand(NewBuildCommandParams{Registry: "ghcr.io/org", RegistrySecret: "registry", Deployment: d})
+	clientset := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-build-1", Namespace: "default", Labels: map[string]string{k8s.LabelNameCreatedBy: k8s.LabelValueCreatedBy, k8s.LabelNameReleaseIdentifier: "release"}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:  k8s.BuilderContainerName,
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: "sha256:abc123"}},
+		}}},
+	})
+
+	digest, err := c.readDigest(context.Background(), clientset, "release")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if digest != "sha256:abc123" {
+		t.Errorf("expected the digest written by the builder, got %s", digest)
+	}
+	if _, err := c.readDigest(context.Background(), clientset, "other"); err == nil {
+		t.Errorf("expected an error without a digest")
+	}
+
+	c.deployImage(d)
+	d.Image = "ghcr.io/org/test@" + digest
+	d.build = &BuildResult{Image: d.Image, Digest: digest, DurationSeconds: 92.4}
+	deployment := k8s.BuildDeployment(d.deploymentParams("release"))
+	if deployment.Annotations[k8s.AnnotationNameImageDigest] != digest || deployment.Annotations[k8s.AnnotationNameBuildDuration] != "1m32s" {
+		t.Errorf("expected the build to be recorded, got %v", deployment.Annotations)
+	}
+	spec := deployment.Spec.Template.Spec
+	if len(spec.InitContainers) != 0 || len(spec.Volumes) != 0 || spec.Containers[0].Image != d.Image {
+		t.Errorf("expected the image built to run as it is, got %+v", spec)
+	}
+	if len(spec.ImagePullSecrets) != 1 || spec.ImagePullSecrets[0].Name != "registry" {
+		t.Errorf("expected the image to be pulled with the registry secret, got %v", spec.ImagePullSecrets)
+	}
+}
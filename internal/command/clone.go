@@ -0,0 +1,154 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lucasvmiguel/k8run/internal/git"
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/logging"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// cloneScript clones the repository $1 at the ref $2 into the folder $0, with the credentials in the folder $3, if
+// any, and prints the commit checked out to stdout and to the file $4, the termination message of the container.
+// The setup caches in the folder are kept.
+const cloneScript = `set -e
+export HOME=/tmp
+find "$0" -mindepth 1 -maxdepth 1 ! -name ` + setupCacheFolder + ` -exec rm -rf {} +
+if [ -f "$3/ssh-privatekey" ]; then
+  export GIT_SSH_COMMAND="ssh -i $3/ssh-privatekey -o StrictHostKeyChecking=accept-new -o UserKnownHostsFile=/tmp/known_hosts"
+fi
+if [ -f "$3/password" ]; then
+  git config --global credential.helper "!f() { echo username=\$(cat $3/username 2>/dev/null || echo x-access-token); echo password=\$(cat $3/password); }; f"
+fi
+git init -q "$0"
+cd "$0"
+git remote add origin "$1"
+git fetch -q --depth 1 origin "$2"
+git -c advice.detachedHead=false checkout -q FETCH_HEAD
+git rev-parse HEAD | tee "$4"`
+
+// resolveRemoteRef resolves a ref of a remote repository, it's replaced in tests.
+var resolveRemoteRef = git.ResolveRemote
+
+// cloneCommand returns the command of the init container cloning GitRepo into the PVC, at the pinned commit once
+// resolved.
+func (c *DeploymentCommand) cloneCommand() []string {
+	ref := c.gitRef()
+	if c.gitCommit != "" {
+		ref = c.gitCommit
+	}
+	return []string{"sh", "-c", cloneScript, copyTo, c.GitRepo, ref, k8s.InitContainerSecretPath, "/dev/termination-log"}
+}
+
+// pinGitCommit resolves the commit GitRef points to, so every pod of the release clones the same one. When it can't
+// be resolved from here, eg: the credentials are only in GitSecret, the commit is pinned once cloned, see
+// recordGitCommit.
+func (c *DeploymentCommand) pinGitCommit(ctx context.Context) {
+	if c.GitRepo == "" || c.gitCommit != "" {
+		return
+	}
+	commit, err := resolveRemoteRef(c.GitRepo, c.gitRef())
+	if err != nil {
+		logging.FromContext(ctx).With("ref", c.gitRef(), "error", err).Info("Failed to resolve the git ref, pinning the commit once cloned")
+		return
+	}
+	c.gitCommit = commit
+}
+
+// gitRef returns the ref of GitRepo cloned, its default branch unless GitRef is set.
+func (c *DeploymentCommand) gitRef() string {
+	if c.GitRef == "" {
+		return "HEAD"
+	}
+	return c.GitRef
+}
+
+func (c *DeploymentCommand) validateGitRepo() error {
+	if c.GitRepo == "" {
+		if c.GitRef != "" || c.GitSecret != "" {
+			return fmt.Errorf("GitRef and GitSecret require GitRepo")
+		}
+		return nil
+	}
+
+	if len(c.copies()) > 0 || c.NoCopy {
+		return fmt.Errorf("GitRepo can't be used with Copy nor NoCopy")
+	}
+	if c.CopyGitRef != "" {
+		return fmt.Errorf("GitRepo can't be used with CopyGitRef, use GitRef")
+	}
+	// the repository is in the deployment, readable by anyone who can read it
+	if u, err := url.Parse(c.GitRepo); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			return fmt.Errorf("GitRepo must not contain credentials, use GitSecret")
+		}
+	}
+	if strings.HasPrefix(c.GitRepo, "-") {
+		return fmt.Errorf("GitRepo %s is not a valid repository", c.GitRepo)
+	}
+	if strings.HasPrefix(c.gitRef(), "-") {
+		return fmt.Errorf("GitRef %s is not a valid ref", c.GitRef)
+	}
+
+	return nil
+}
+
+// recordGitCommit reads the commit cloned by the init container of the release and records it on the deployment.
+func (c *DeploymentCommand) recordGitCommit(ctx context.Context, clientset kubernetes.Interface, releaseIdentifier string) error {
+	pods, err := k8s.ListPods(ctx, clientset, k8s.ListParams{
+		Namespace:     c.Namespace,
+		LabelSelector: fmt.Sprintf("%s=%s", k8s.LabelNameReleaseIdentifier, releaseIdentifier),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to list pods: %w", err)
+	}
+
+	commit := ""
+	for _, pod := range pods {
+		for _, status := range pod.Status.InitContainerStatuses {
+			if status.Name == initContainerName && status.State.Terminated != nil {
+				commit = strings.TrimSpace(status.State.Terminated.Message)
+			}
+		}
+		if commit != "" {
+			break
+		}
+	}
+	if commit == "" {
+		logging.FromContext(ctx).Warn("Failed to read the commit cloned")
+		return nil
+	}
+
+	c.git = &git.Info{Commit: commit, Remote: git.Redact(c.GitRepo)}
+	annotations, _ := c.gitMetadata()
+	err = k8s.AnnotateDeployment(ctx, clientset, k8s.AnnotateDeploymentParams{
+		Name:        c.Name,
+		Namespace:   c.Namespace,
+		Annotations: annotations,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to record the commit: %w", err)
+	}
+
+	// pods replaced later, eg: on another node, clone the same commit instead of wherever the ref moved to
+	if c.gitCommit == "" {
+		c.gitCommit = commit
+		err = k8s.SetInitContainerCommand(ctx, clientset, k8s.SetInitContainerCommandParams{
+			Name:          c.Name,
+			Namespace:     c.Namespace,
+			ContainerName: initContainerName,
+			Command:       c.cloneCommand(),
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to pin the commit: %w", err)
+		}
+	}
+
+	logging.FromContext(ctx).With("commit", commit, "ref", c.gitRef()).Info("Cloned git repository")
+	return nil
+}
@@ -0,0 +1,177 @@
+package command
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// testRepo creates a git repository with a commit tagged 'v1' and a newer one on main, its default branch, returning
+// its file:// URL and the commits.
+func testRepo(t *testing.T) (url, first, second string) {
+	t.Helper()
+	dir := t.TempDir()
+	gitCommand := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=k8run", "-c", "user.email=k8run@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %s: %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	gitCommand("init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, "index.js"), []byte("console.log(1)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommand("add", ".")
+	gitCommand("commit", "-m", "first")
+	gitCommand("tag", "v1")
+	first = gitCommand("rev-parse", "HEAD")
+
+	if err := os.WriteFile(filepath.Join(dir, "index.js"), []byte("console.log(2)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommand("commit", "-am", "second")
+	second = gitCommand("rev-parse", "HEAD")
+
+	return "file://" + dir, first, second
+}
+
+func TestDeploymentCommand_CloneCommand(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	url, first, second := testRepo(t)
+
+	tests := []struct {
+		ref        string
+		wantCommit string
+		wantIndex  string
+	}{
+		{ref: "", wantCommit: second, wantIndex: "console.log(2)"},
+		{ref: "v1", wantCommit: first, wantIndex: "console.log(1)"},
+		{ref: first, wantCommit: first, wantIndex: "console.log(1)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			c := testDeploymentCommand()
+			c.Copy = ""
+			c.GitRepo = url
+			c.GitRef = tt.ref
+
+			// the folders of the pod, with a leftover of the previous release
+			app, secret, message := t.TempDir(), t.TempDir(), filepath.Join(t.TempDir(), "termination-log")
+			if err := os.WriteFile(filepath.Join(app, ".old"), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			args := c.cloneCommand()
+			args[3], args[6], args[7] = app, secret, message
+
+			cmd := exec.Command(args[0], args[1:]...)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("expected no error, got %v: %s", err, out)
+			}
+
+			if content, _ := os.ReadFile(filepath.Join(app, "index.js")); string(content) != tt.wantIndex {
+				t.Errorf("expected %q to be cloned, got %q", tt.wantIndex, content)
+			}
+			if _, err := os.Stat(filepath.Join(app, ".old")); !os.IsNotExist(err) {
+				t.Errorf("expected the previous release to be removed")
+			}
+			if commit, _ := os.ReadFile(message); strings.TrimSpace(string(commit)) != tt.wantCommit {
+				t.Errorf("expected commit %s in the termination message, got %q", tt.wantCommit, commit)
+			}
+		})
+	}
+}
+
+func TestDeploymentCommand_PinGitCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	url, _, second := testRepo(t)
+
+	c := testDeploymentCommand()
+	c.Copy = ""
+	c.GitRepo = url
+	c.GitRef = "main"
+	c.pinGitCommit(context.Background())
+	args := c.cloneCommand()
+	if args[5] != second {
+		t.Fatalf("expected the init container to clone commit %s, got %s", second, args[5])
+	}
+
+	// a commit pushed to the branch after the deploy
+	dir := strings.TrimPrefix(url, "file://")
+	if err := os.WriteFile(filepath.Join(dir, "index.js"), []byte("console.log(3)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", "-C", dir, "-c", "user.name=k8run", "-c", "user.email=k8run@example.com", "commit", "-qam", "third")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git commit: %s: %s", err, out)
+	}
+
+	// the pod restarts, its init container clones again
+	app, secret, message := t.TempDir(), t.TempDir(), filepath.Join(t.TempDir(), "termination-log")
+	args[3], args[6], args[7] = app, secret, message
+	cmd = exec.Command(args[0], args[1:]...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("expected no error, got %v: %s", err, out)
+	}
+	if content, _ := os.ReadFile(filepath.Join(app, "index.js")); string(content) != "console.log(2)" {
+		t.Errorf("expected the commit deployed to be cloned again, got %q", content)
+	}
+	if commit, _ := os.ReadFile(message); strings.TrimSpace(string(commit)) != second {
+		t.Errorf("expected commit %s in the termination message, got %q", second, commit)
+	}
+}
+
+func TestDeploymentCommand_RecordGitCommit(t *testing.T) {
+	c := testDeploymentCommand()
+	c.Copy = ""
+	c.GitRepo = "https://github.com/org/repo.git"
+	c.resolveGit()
+
+	params := c.deploymentParams("release")
+	if params.InitContainerImage != k8s.GitImage || params.InitContainerCommand[4] != c.GitRepo || params.InitContainerCommand[5] != "HEAD" {
+		t.Errorf("expected the init container to clone the default branch, got %+v", params)
+	}
+
+	clientset := fake.NewSimpleClientset(
+		k8s.BuildDeployment(params),
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-1", Namespace: "default", Labels: map[string]string{k8s.LabelNameCreatedBy: k8s.LabelValueCreatedBy, k8s.LabelNameReleaseIdentifier: "release"}},
+			Status: corev1.PodStatus{InitContainerStatuses: []corev1.ContainerStatus{{
+				Name:  initContainerName,
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: "abc123\n"}},
+			}}},
+		},
+	)
+
+	if err := c.recordGitCommit(context.Background(), clientset, "release"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	deployment, err := k8s.GetDeployment(context.Background(), clientset, k8s.GetParams{Name: "test", Namespace: "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployment.Annotations[k8s.AnnotationNameGitCommit] != "abc123" || deployment.Annotations[k8s.AnnotationNameGitRemote] != c.GitRepo {
+		t.Errorf("expected the commit cloned to be recorded, got %v", deployment.Annotations)
+	}
+	// the commit couldn't be resolved before the deploy, the pods replaced later clone the one recorded
+	if command := deployment.Spec.Template.Spec.InitContainers[0].Command; command[5] != "abc123" {
+		t.Errorf("expected the init container to be pinned to the commit cloned, got %v", command)
+	}
+}
@@ -1,7 +1,36 @@
 package command
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/lucasvmiguel/k8run/internal/kube"
+
+	"k8s.io/client-go/kubernetes"
+)
 
 func pvcName(name string) string {
 	return fmt.Sprintf("%s-app-pvc", name)
 }
+
+// newClientset builds a k8s clientset from the given config.
+func newClientset(config kube.Config) (kubernetes.Interface, error) {
+	clientset, err := config.Clientset()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to k8s: %w", err)
+	}
+
+	return clientset, nil
+}
+
+// resolveNamespace returns the given namespace or, when it's empty, the namespace of the kubeconfig context.
+func resolveNamespace(config kube.Config, namespace string) (string, error) {
+	if namespace != "" {
+		return namespace, nil
+	}
+
+	namespace, err := config.Namespace()
+	if err != nil {
+		return "", fmt.Errorf("Failed to resolve namespace: %w", err)
+	}
+	return namespace, nil
+}
@@ -0,0 +1,124 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/compose"
+	"github.com/lucasvmiguel/k8run/internal/kube"
+	"github.com/lucasvmiguel/k8run/internal/logging"
+	"github.com/lucasvmiguel/k8run/internal/plan"
+)
+
+// NewComposeCommandParams represents the parameters to create a new compose command.
+type NewComposeCommandParams struct {
+	File        string
+	Namespace   string
+	Timeout     time.Duration
+	Strict      bool
+	Down        bool
+	Owner       string
+	Takeover    bool
+	WaitForLock bool
+	Kube        kube.Config
+}
+
+// ComposeCommand represents a command to deploy (or destroy, when Down is set) the services of a docker-compose file.
+type ComposeCommand struct {
+	File      string
+	Namespace string
+	Timeout   time.Duration
+	// Strict fails when the compose file uses keys that can't be translated, instead of only reporting them.
+	Strict bool
+	Down   bool
+	// Owner is who deploys or destroys the services. Defaults to the git email or the kubeconfig user.
+	Owner string
+	// Takeover allows replacing or destroying services deployed by someone else.
+	Takeover bool
+	// WaitForLock waits for other deployments or destroys of the services to finish instead of failing.
+	WaitForLock bool
+	// Kube is how to reach the cluster.
+	Kube kube.Config
+
+	// Warnings are the keys of the compose file that can't be translated, logged by Plan or Run.
+	Warnings []compose.Warning
+
+	up     *UpCommand
+	down   *DownCommand
+	warned bool
+}
+
+// NewComposeCommand creates a new compose command.
+func NewComposeCommand(params NewComposeCommandParams) *ComposeCommand {
+	return &ComposeCommand{
+		File:        params.File,
+		Namespace:   params.Namespace,
+		Timeout:     params.Timeout,
+		Strict:      params.Strict,
+		Down:        params.Down,
+		Owner:       params.Owner,
+		Takeover:    params.Takeover,
+		WaitForLock: params.WaitForLock,
+		Kube:        params.Kube,
+	}
+}
+
+// Validate translates the compose file and validates the resulting apps.
+func (c *ComposeCommand) Validate() error {
+	if c.File == "" {
+		return fmt.Errorf("File is required")
+	}
+
+	cfg, warnings, err := compose.Translate(c.File, c.Namespace)
+	if err != nil {
+		return fmt.Errorf("Failed to translate compose file: %w", err)
+	}
+
+	c.Warnings = warnings
+	if c.Strict && len(warnings) > 0 {
+		lines := []string{}
+		for _, w := range warnings {
+			lines = append(lines, "  "+w.String())
+		}
+		return fmt.Errorf("Compose file uses unsupported keys:\n%s", strings.Join(lines, "\n"))
+	}
+
+	if c.Down {
+		c.down = &DownCommand{File: c.File, Timeout: c.Timeout, Owner: c.Owner, Takeover: c.Takeover, WaitForLock: c.WaitForLock, Kube: c.Kube}
+		return c.down.validateConfig(cfg)
+	}
+
+	c.up = &UpCommand{File: c.File, Timeout: c.Timeout, Owner: c.Owner, Takeover: c.Takeover, WaitForLock: c.WaitForLock, Kube: c.Kube}
+	return c.up.validateConfig(cfg)
+}
+
+// Plan returns the changes the compose command will make to the cluster.
+func (c *ComposeCommand) Plan(ctx context.Context) (*plan.Plan, error) {
+	c.warn(ctx)
+	if c.Down {
+		return c.down.Plan(ctx)
+	}
+	return c.up.Plan(ctx)
+}
+
+// Run runs the compose command.
+func (c *ComposeCommand) Run(ctx context.Context) error {
+	c.warn(ctx)
+	if c.Down {
+		return c.down.Run(ctx)
+	}
+	return c.up.Run(ctx)
+}
+
+// warn logs the warnings of the translation, once whether the changes are planned before being run or not.
+func (c *ComposeCommand) warn(ctx context.Context) {
+	if c.warned {
+		return
+	}
+	c.warned = true
+	for _, w := range c.Warnings {
+		logging.FromContext(ctx).Warn(w.String())
+	}
+}
@@ -0,0 +1,103 @@
+package command_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/command"
+	"github.com/lucasvmiguel/k8run/internal/logging"
+)
+
+func writeCompose(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestComposeCommand_Validate(t *testing.T) {
+	tests := []struct {
+		name         string
+		compose      string
+		strict       bool
+		down         bool
+		wantWarnings int
+		wantErr      bool
+	}{
+		{
+			name:    "valid compose file",
+			compose: "services: {web: {image: nginx, ports: ['8080:80']}}",
+			wantErr: false,
+		},
+		{
+			name:         "unsupported keys",
+			compose:      "services: {web: {image: nginx, ports: ['80'], restart: always}}",
+			wantWarnings: 1,
+			wantErr:      false,
+		},
+		{
+			name:         "unsupported keys in strict mode",
+			compose:      "services: {web: {image: nginx, ports: ['80'], restart: always}}",
+			strict:       true,
+			wantWarnings: 1,
+			wantErr:      true,
+		},
+		{
+			name:    "down",
+			compose: "services: {web: {image: nginx, ports: ['80']}}",
+			down:    true,
+			wantErr: false,
+		},
+		{
+			name:    "invalid compose file",
+			compose: "services: {web: {command: nginx}}",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := command.NewComposeCommand(command.NewComposeCommandParams{
+				File:    writeCompose(t, tt.compose),
+				Timeout: time.Minute,
+				Strict:  tt.strict,
+				Down:    tt.down,
+			})
+			err := c.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(c.Warnings) != tt.wantWarnings {
+				t.Errorf("expected %d warnings, got %v", tt.wantWarnings, c.Warnings)
+			}
+		})
+	}
+}
+
+func TestComposeCommand_Warnings(t *testing.T) {
+	c := command.NewComposeCommand(command.NewComposeCommandParams{
+		File:    writeCompose(t, "services: {web: {image: nginx, ports: ['80'], restart: always}}"),
+		Timeout: time.Minute,
+		Owner:   "test",
+		Kube:    forbiddenCluster(t),
+	})
+	if err := c.Validate(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	logs := &bytes.Buffer{}
+	ctx := logging.NewContext(context.Background(), slog.New(slog.NewTextHandler(logs, nil)))
+	_, _ = c.Plan(ctx)
+	_ = c.Run(ctx)
+	if count := strings.Count(logs.String(), "restart: is not supported"); count != 1 {
+		t.Errorf("expected the warning to be logged once by the logger of the run, got %d in %s", count, logs)
+	}
+}
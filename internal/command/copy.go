@@ -0,0 +1,210 @@
+package command
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/lucasvmiguel/k8run/internal/git"
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+)
+
+// stdinSource is the source of a copy reading a tar archive from stdin. eg: 'git archive HEAD | k8run ...'
+const stdinSource = "-"
+
+// CopyEntry represents a file, folder or archive copied into the container.
+type CopyEntry struct {
+	// Source is the local file or folder copied. A '.tar', '.tar.gz', '.tgz' or '.zip' file is extracted, and '-'
+	// extracts a tar archive read from stdin.
+	Source string
+	// Dest is where the source lands in the container. Defaults to a file or folder named after it in /app.
+	Dest string
+	// ReadOnly mounts the copy read-only in the app container.
+	ReadOnly bool
+}
+
+// copies returns what is copied into the container, Copy first.
+func (c *DeploymentCommand) copies() []CopyEntry {
+	if c.Copy == "" {
+		return c.Copies
+	}
+	return append([]CopyEntry{{Source: c.Copy}}, c.Copies...)
+}
+
+// source returns the first file or folder copied, the one the app is deployed from. eg: for its git branch
+func (c *DeploymentCommand) source() string {
+	copies := c.copies()
+	if len(copies) == 0 {
+		return ""
+	}
+	return copies[0].Source
+}
+
+func (c *DeploymentCommand) validateCopies() error {
+	_, mounts := c.copyLayout()
+	seen := map[string]bool{}
+	for _, mount := range mounts {
+		if seen[mount.Path] {
+			return fmt.Errorf("Copy destination %s is used more than once", mount.Path)
+		}
+		seen[mount.Path] = true
+	}
+
+	stdin := 0
+	for _, entry := range c.copies() {
+		if entry.Source == "" {
+			return fmt.Errorf("Copy source must not be empty")
+		}
+		if entry.Source == stdinSource {
+			stdin++
+		}
+		if entry.Dest != "" && !path.IsAbs(entry.Dest) {
+			return fmt.Errorf("Copy destination %s must be an absolute path", entry.Dest)
+		}
+	}
+	if stdin > 1 {
+		return fmt.Errorf("Only one copy can read stdin")
+	}
+
+	if c.CopyGitRef != "" {
+		if c.NoCopy {
+			return fmt.Errorf("CopyGitRef can't be used with NoCopy")
+		}
+		if source := c.source(); source == stdinSource || k8s.DetectArchive(source) != "" {
+			return fmt.Errorf("CopyGitRef requires the first copy to be a folder, got %s", source)
+		}
+	}
+
+	return nil
+}
+
+// copyLayout returns the sources copied into the PVC and where each of them is mounted in the app container, in the
+// order of the copies.
+func (c *DeploymentCommand) copyLayout() ([]k8s.Source, []k8s.CopyMount) {
+	copies := c.copies()
+	sources := make([]k8s.Source, 0, len(copies))
+	mounts := make([]k8s.CopyMount, 0, len(copies))
+	for i, entry := range copies {
+		source := k8s.Source{Path: entry.Source, Archive: k8s.DetectArchive(entry.Source)}
+		if entry.Source == stdinSource {
+			source.Archive = k8s.ArchiveTar
+		}
+		mount := k8s.CopyMount{ReadOnly: entry.ReadOnly}
+		if entry.Dest != "" {
+			// lands in a folder of its own in the PVC, mounted at its destination
+			source.Name = fmt.Sprintf("k8run-copy-%d", i)
+			mount.Path = path.Clean(entry.Dest)
+			mount.SubPath = source.Name
+		} else if name := filepath.Base(filepath.Clean(entry.Source)); source.Archive != "" || name == "." || name == string(filepath.Separator) {
+			// the content of the source lands in /app itself
+			mount.Path = copyTo
+		} else {
+			mount.Path = path.Join(copyTo, name)
+			mount.SubPath = name
+		}
+
+		sources = append(sources, source)
+		mounts = append(mounts, mount)
+	}
+
+	return sources, mounts
+}
+
+// copyMounts returns the mounts of the copies in the app container or, when none has a destination or mode of its
+// own, nil to mount the whole PVC at /app.
+func (c *DeploymentCommand) copyMounts() []k8s.CopyMount {
+	for _, entry := range c.copies() {
+		if entry.Dest != "" || entry.ReadOnly {
+			_, mounts := c.copyLayout()
+			sort.Slice(mounts, func(i, j int) bool { return mounts[i].Path < mounts[j].Path })
+			return mounts
+		}
+	}
+	return nil
+}
+
+// spoolSources writes the archives read from stdin or from git to temporary files, so they can be sized before
+// being sent, and replaces a detected Go app with its binary. The returned func removes them.
+func (c *DeploymentCommand) spoolSources(ctx context.Context, sources []k8s.Source) ([]k8s.Source, func(), error) {
+	files := []string{}
+	cleanup := func() {
+		for _, file := range files {
+			os.RemoveAll(file)
+		}
+	}
+
+	spool := func(write func(w io.Writer) error) (string, error) {
+		f, err := os.CreateTemp("", "k8run-copy-*.tar")
+		if err != nil {
+			return "", err
+		}
+		files = append(files, f.Name())
+		if err := write(f); err != nil {
+			f.Close()
+			return "", err
+		}
+		return f.Name(), f.Close()
+	}
+
+	spooled := make([]k8s.Source, len(sources))
+	for i, source := range sources {
+		switch {
+		case source.Path == stdinSource:
+			var stdin io.Reader = os.Stdin
+			if c.Stdin != nil {
+				stdin = c.Stdin
+			}
+			file, err := spool(func(w io.Writer) error {
+				_, err := io.Copy(w, stdin)
+				return err
+			})
+			if err != nil {
+				cleanup()
+				return nil, nil, fmt.Errorf("Failed to read stdin: %w", err)
+			}
+			source.Path = file
+		case i == 0 && c.CopyGitRef != "":
+			file, err := spool(func(w io.Writer) error { return git.Archive(source.Path, c.CopyGitRef, w) })
+			if err != nil {
+				cleanup()
+				return nil, nil, fmt.Errorf("Failed to read %s at %s: %w", source.Path, c.CopyGitRef, err)
+			}
+			// the folder keeps landing where it would without the ref
+			source.Name = cmp.Or(source.Name, landingName(source.Path))
+			source.Path = file
+			source.Archive = k8s.ArchiveTar
+		case i == 0 && c.detected != nil && c.detected.GoBinary != "":
+			dir, err := os.MkdirTemp("", "k8run-build-*")
+			if err != nil {
+				cleanup()
+				return nil, nil, fmt.Errorf("Failed to compile %s: %w", source.Path, err)
+			}
+			files = append(files, dir)
+			err = c.compileGo(ctx, source.Path, dir)
+			if err != nil {
+				cleanup()
+				return nil, nil, fmt.Errorf("Failed to compile %s: %w", source.Path, err)
+			}
+			// the binary lands where the folder would, in place of its sources
+			source.Name = cmp.Or(source.Name, landingName(source.Path))
+			source.Path = dir
+		}
+		spooled[i] = source
+	}
+
+	return spooled, cleanup, nil
+}
+
+// landingName returns the name a folder lands under, like 'kubectl cp': its base name, or '.' for its content.
+func landingName(dir string) string {
+	name := filepath.Base(filepath.Clean(dir))
+	if name == string(filepath.Separator) {
+		return "."
+	}
+	return name
+}
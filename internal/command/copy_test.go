@@ -0,0 +1,153 @@
+package command
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+)
+
+func TestDeploymentCommand_CopyLayout(t *testing.T) {
+	tests := []struct {
+		name        string
+		copy        string
+		copies      []CopyEntry
+		wantSources []k8s.Source
+		wantMounts  []k8s.CopyMount
+	}{
+		{
+			name:        "single copy",
+			copy:        "/test/foobar",
+			wantSources: []k8s.Source{{Path: "/test/foobar"}},
+		},
+		{
+			name: "copies with destinations",
+			copies: []CopyEntry{
+				{Source: "./dist", Dest: "/app"},
+				{Source: "./config/dev.yaml", Dest: "/etc/myapp/config.yaml", ReadOnly: true},
+				{Source: "../shared"},
+			},
+			wantSources: []k8s.Source{
+				{Path: "./dist", Name: "k8run-copy-0"},
+				{Path: "./config/dev.yaml", Name: "k8run-copy-1"},
+				{Path: "../shared"},
+			},
+			wantMounts: []k8s.CopyMount{
+				{Path: "/app", SubPath: "k8run-copy-0"},
+				{Path: "/app/shared", SubPath: "shared"},
+				{Path: "/etc/myapp/config.yaml", SubPath: "k8run-copy-1", ReadOnly: true},
+			},
+		},
+		{
+			name: "archives",
+			copies: []CopyEntry{
+				{Source: "./dist.tar.gz"},
+				{Source: "-", Dest: "/srv/assets"},
+			},
+			wantSources: []k8s.Source{
+				{Path: "./dist.tar.gz", Archive: k8s.ArchiveTarGzip},
+				{Path: "-", Name: "k8run-copy-1", Archive: k8s.ArchiveTar},
+			},
+			wantMounts: []k8s.CopyMount{
+				{Path: "/app"},
+				{Path: "/srv/assets", SubPath: "k8run-copy-1"},
+			},
+		},
+		{
+			name:        "read-only copy",
+			copies:      []CopyEntry{{Source: ".", ReadOnly: true}},
+			wantSources: []k8s.Source{{Path: "."}},
+			wantMounts:  []k8s.CopyMount{{Path: "/app", ReadOnly: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testDeploymentCommand()
+			c.Copy = tt.copy
+			c.Copies = tt.copies
+
+			sources, _ := c.copyLayout()
+			if !reflect.DeepEqual(sources, tt.wantSources) {
+				t.Errorf("expected sources %+v, got %+v", tt.wantSources, sources)
+			}
+			if mounts := c.deploymentParams("release").CopyMounts; !reflect.DeepEqual(mounts, tt.wantMounts) {
+				t.Errorf("expected mounts %+v, got %+v", tt.wantMounts, mounts)
+			}
+		})
+	}
+}
+
+func TestDeploymentCommand_CopyCodeFromStdin(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	if err := tw.WriteHeader(&tar.Header{Name: "index.js", Mode: 0o644, Size: 2, Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	copier := &k8s.MemoryCopier{}
+	c := testDeploymentCommand()
+	c.Copy = "-"
+	c.Stdin = &archive
+	c.Copier = copier
+	if err := c.copyCode(context.Background(), "test-pod"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if content, ok := copier.File("/app/index.js"); !ok || string(content) != "hi" {
+		t.Errorf("expected stdin to be extracted in /app, got %v", copier.Files())
+	}
+	if c.result.Copy.SizeBytes != 2 {
+		t.Errorf("expected 2 bytes to be copied, got %+v", c.result.Copy)
+	}
+}
+
+func TestDeploymentCommand_CopyCodeAtGitRef(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := filepath.Join(t.TempDir(), "foobar")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gitCommand := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=k8run", "-c", "user.email=k8run@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s: %s", args, err, out)
+		}
+	}
+	gitCommand("init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, "index.js"), []byte("console.log(1)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCommand("add", ".")
+	gitCommand("commit", "-m", "initial")
+	if err := os.WriteFile(filepath.Join(dir, "index.js"), []byte("console.log(2)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	copier := &k8s.MemoryCopier{}
+	c := testDeploymentCommand()
+	c.Copy = dir
+	c.CopyGitRef = "main"
+	c.Copier = copier
+	if err := c.copyCode(context.Background(), "test-pod"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if content, ok := copier.File("/app/foobar/index.js"); !ok || string(content) != "console.log(1)" {
+		t.Errorf("expected the committed index.js in /app/foobar, got %q in %v", content, copier.Files())
+	}
+}
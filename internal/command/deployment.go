@@ -3,16 +3,32 @@ package command
 import (
 	"cmp"
 	"context"
+	"errors"
 	"fmt"
-	"log/slog"
-	"os"
+	"io"
+	"path"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/lucasvmiguel/k8run/internal/detect"
+	"github.com/lucasvmiguel/k8run/internal/git"
 	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/kube"
+	"github.com/lucasvmiguel/k8run/internal/logging"
+	"github.com/lucasvmiguel/k8run/internal/plan"
 
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	copyTo            = "/app"
+	initContainerName = "wait-to-copy-app"
 )
 
 // NewDeploymentCommandParams represents the parameters to create a new deployment command.
@@ -30,6 +46,138 @@ type NewDeploymentCommandParams struct {
 	Image         string
 	Replicas      int32
 	Timeout       time.Duration
+	Env           map[string]string
+	Resources     Resources
+	// Copies are files or folders copied along with Copy, each with a destination and mode of its own.
+	Copies []CopyEntry
+	// CopyGitRef ships the first copy as committed at the ref instead of the working tree. eg: 'main' or 'v1.2.0'
+	CopyGitRef string
+	// Stdin is the tar archive read by a '-' copy. Defaults to os.Stdin.
+	Stdin io.Reader
+	// GitRepo is cloned into the container by the init container instead of copying local files. eg:
+	// 'https://github.com/org/repo.git' or 'git@github.com:org/repo.git'
+	GitRepo string
+	// GitRef is the branch, tag or commit of GitRepo cloned. Defaults to the default branch.
+	GitRef string
+	// GitSecret is the secret with the credentials of GitRepo: a deploy key in 'ssh-privatekey' or a token in
+	// 'password', with an optional 'username'.
+	GitSecret string
+	// NoCopy deploys the image as it is, without copying anything into the container.
+	NoCopy bool
+	// WorkDir is the working dir of the container. Defaults to /app, where the copies land.
+	WorkDir string
+	// Setup is run with sh once the code is copied, by an init container with the image of the app. eg: 'npm ci'
+	Setup string
+	// SetupCache are folders kept across releases for Setup, eg: 'node_modules', relative to the working dir. The
+	// setup is skipped while the lockfiles don't change.
+	SetupCache []string
+	// Auto infers the image, entrypoint, port and setup left unset from the files of the first copy. eg: a
+	// package.json. A Go module is cross-compiled locally and its binary copied instead of its sources.
+	Auto bool
+	// NodeArch schedules the app on the nodes of the architecture, eg: 'arm64', the one the copied binaries and
+	// detected Go apps are checked and compiled for.
+	NodeArch string
+	// SkipBinaryCheck deploys binaries that can't run on the nodes or with the C library of the image, eg: a macOS
+	// build that the app doesn't run.
+	SkipBinaryCheck bool
+	// CreateNamespace creates the namespace when it doesn't exist.
+	CreateNamespace bool
+	// Isolated deploys into a namespace of its own, named after the app and deleted when the app is destroyed.
+	Isolated bool
+	// NamespaceLimits are the quota and container defaults of the isolated namespace.
+	NamespaceLimits NamespaceLimits
+	// TTL is how long the app lives before 'k8run gc' destroys it. Zero keeps it forever.
+	TTL time.Duration
+	// Owner is who deploys the app. Defaults to the git email or the kubeconfig user.
+	Owner string
+	// Takeover allows replacing an app deployed by someone else.
+	Takeover bool
+	// WaitForLock waits for another deployment or destroy of the app to finish instead of failing.
+	WaitForLock bool
+	// Preview names the app after the git branch of the copied folder, prefixed by Name if set.
+	Preview bool
+	// PreviewBranch is the branch of a preview app, eg: in CI, where HEAD is often detached.
+	PreviewBranch string
+	// PreviewDomain exposes a preview app on '<name>.<domain>' with an ingress.
+	PreviewDomain string
+	// Copier copies the code into the pod. Defaults to the copier of Kube.CopyTransport.
+	Copier k8s.Copier
+	// CopyProgress, when set, is called with the progress of the copy a few times per second.
+	CopyProgress func(k8s.CopyProgress)
+	// Kube is how to reach the cluster.
+	Kube kube.Config
+}
+
+// Resources represents the compute resources requested by and limited for the app container. eg: {"cpu": "100m"}
+type Resources struct {
+	Requests map[string]string
+	Limits   map[string]string
+}
+
+func (r Resources) requirements() (corev1.ResourceRequirements, error) {
+	requests, err := resourceList(r.Requests)
+	if err != nil {
+		return corev1.ResourceRequirements{}, fmt.Errorf("Invalid resource requests: %w", err)
+	}
+
+	limits, err := resourceList(r.Limits)
+	if err != nil {
+		return corev1.ResourceRequirements{}, fmt.Errorf("Invalid resource limits: %w", err)
+	}
+
+	return corev1.ResourceRequirements{Requests: requests, Limits: limits}, nil
+}
+
+func resourceList(m map[string]string) (corev1.ResourceList, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+
+	list := corev1.ResourceList{}
+	for name, value := range m {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s=%s: %w", name, value, err)
+		}
+		list[corev1.ResourceName(name)] = quantity
+	}
+	return list, nil
+}
+
+// NamespaceLimits represents the resource quota and the default container resources of a namespace.
+// eg: {"requests.cpu": "2", "pods": "10"}
+type NamespaceLimits struct {
+	Quota           map[string]string
+	DefaultRequests map[string]string
+	DefaultLimits   map[string]string
+}
+
+func (l NamespaceLimits) isZero() bool {
+	return len(l.Quota) == 0 && len(l.DefaultRequests) == 0 && len(l.DefaultLimits) == 0
+}
+
+func (l NamespaceLimits) params(namespace string) (k8s.NamespaceLimitsParams, error) {
+	quota, err := resourceList(l.Quota)
+	if err != nil {
+		return k8s.NamespaceLimitsParams{}, fmt.Errorf("Invalid quota: %w", err)
+	}
+
+	requests, err := resourceList(l.DefaultRequests)
+	if err != nil {
+		return k8s.NamespaceLimitsParams{}, fmt.Errorf("Invalid default requests: %w", err)
+	}
+
+	limits, err := resourceList(l.DefaultLimits)
+	if err != nil {
+		return k8s.NamespaceLimitsParams{}, fmt.Errorf("Invalid default limits: %w", err)
+	}
+
+	return k8s.NamespaceLimitsParams{
+		Namespace:       namespace,
+		Quota:           quota,
+		DefaultRequests: requests,
+		DefaultLimits:   limits,
+	}, nil
 }
 
 // DeploymentCommand represents a command to deploy an application and its related resources in a Kubernetes cluster.
@@ -47,44 +195,220 @@ type DeploymentCommand struct {
 	Image         string
 	Replicas      int32
 	Timeout       time.Duration
+	Env           map[string]string
+	Resources     Resources
+	// Copies are files or folders copied along with Copy, each with a destination and mode of its own.
+	Copies []CopyEntry
+	// CopyGitRef ships the first copy as committed at the ref instead of the working tree. eg: 'main' or 'v1.2.0'
+	CopyGitRef string
+	// Stdin is the tar archive read by a '-' copy. Defaults to os.Stdin.
+	Stdin io.Reader
+	// GitRepo is cloned into the container by the init container instead of copying local files. eg:
+	// 'https://github.com/org/repo.git' or 'git@github.com:org/repo.git'
+	GitRepo string
+	// GitRef is the branch, tag or commit of GitRepo cloned. Defaults to the default branch.
+	GitRef string
+	// GitSecret is the secret with the credentials of GitRepo: a deploy key in 'ssh-privatekey' or a token in
+	// 'password', with an optional 'username'.
+	GitSecret string
+	// NoCopy deploys the image as it is, without copying anything into the container.
+	NoCopy bool
+	// WorkDir is the working dir of the container. Defaults to /app, where the copies land.
+	WorkDir string
+	// Setup is run with sh once the code is copied, by an init container with the image of the app. eg: 'npm ci'
+	Setup string
+	// SetupCache are folders kept across releases for Setup, eg: 'node_modules', relative to the working dir. The
+	// setup is skipped while the lockfiles don't change.
+	SetupCache []string
+	// Auto infers the image, entrypoint, port and setup left unset from the files of the first copy. eg: a
+	// package.json. A Go module is cross-compiled locally and its binary copied instead of its sources.
+	Auto bool
+	// NodeArch schedules the app on the nodes of the architecture, eg: 'arm64', the one the copied binaries and
+	// detected Go apps are checked and compiled for.
+	NodeArch string
+	// SkipBinaryCheck deploys binaries that can't run on the nodes or with the C library of the image, eg: a macOS
+	// build that the app doesn't run.
+	SkipBinaryCheck bool
+	// CreateNamespace creates the namespace when it doesn't exist.
+	CreateNamespace bool
+	// Isolated deploys into a namespace of its own, named after the app and deleted when the app is destroyed.
+	Isolated bool
+	// NamespaceLimits are the quota and container defaults of the isolated namespace.
+	NamespaceLimits NamespaceLimits
+	// TTL is how long the app lives before 'k8run gc' destroys it. Zero keeps it forever.
+	TTL time.Duration
+	// Owner is who deploys the app. Defaults to the git email or the kubeconfig user.
+	Owner string
+	// Takeover allows replacing an app deployed by someone else.
+	Takeover bool
+	// WaitForLock waits for another deployment or destroy of the app to finish instead of failing.
+	WaitForLock bool
+	// Preview names the app after the git branch of the copied folder, prefixed by Name if set.
+	Preview bool
+	// PreviewBranch is the branch of a preview app, eg: in CI, where HEAD is often detached.
+	PreviewBranch string
+	// PreviewDomain exposes a preview app on '<name>.<domain>' with an ingress.
+	PreviewDomain string
+	// Copier copies the code into the pod. Defaults to the copier of Kube.CopyTransport.
+	Copier k8s.Copier
+	// CopyProgress, when set, is called with the progress of the copy a few times per second.
+	CopyProgress func(k8s.CopyProgress)
+	// Kube is how to reach the cluster.
+	Kube kube.Config
+
+	// expiresAt is computed once from TTL, so every resource of a release expires at the same time.
+	expiresAt time.Time
+	// previewed is true once the name of a preview app is derived from its branch.
+	previewed bool
+	// git is the git metadata of the copied folder, if it's in a repository.
+	git *git.Info
+	// gitCommit is the commit of GitRepo the init container clones, pinned so pods replaced later don't clone a
+	// newer one. Empty until it's resolved.
+	gitCommit string
+	// detected is the app inferred from the first copy with Auto.
+	detected *detect.App
+	// nodeArchs are the architectures of the nodes the app can be scheduled on, once listed by the preflight.
+	nodeArchs []string
+	// build is the image built in the cluster and deployed, see BuildCommand.
+	build *BuildResult
+	// imagePullSecret is the secret the image built is pulled with.
+	imagePullSecret string
+	// result is the outcome of the last run.
+	result Result
 }
 
 // NewDeploymentCommand creates a new deployment command.
 func NewDeploymentCommand(params NewDeploymentCommandParams) *DeploymentCommand {
 	return &DeploymentCommand{
-		Name:          params.Name,
-		Entrypoint:    params.Entrypoint,
-		Copy:          params.Copy,
-		ContainerPort: params.ContainerPort,
-		Port:          params.Port,
-		Service:       params.Service,
-		Ingress:       params.Ingress,
-		IngressHost:   params.IngressHost,
-		IngressClass:  params.IngressClass,
-		Namespace:     params.Namespace,
-		Image:         params.Image,
-		Replicas:      params.Replicas,
-		Timeout:       params.Timeout,
+		Name:            params.Name,
+		Entrypoint:      params.Entrypoint,
+		Copy:            params.Copy,
+		Copies:          params.Copies,
+		CopyGitRef:      params.CopyGitRef,
+		GitRepo:         params.GitRepo,
+		GitRef:          params.GitRef,
+		GitSecret:       params.GitSecret,
+		Stdin:           params.Stdin,
+		ContainerPort:   params.ContainerPort,
+		Port:            params.Port,
+		Service:         params.Service,
+		Ingress:         params.Ingress,
+		IngressHost:     params.IngressHost,
+		IngressClass:    params.IngressClass,
+		Namespace:       params.Namespace,
+		Image:           params.Image,
+		Replicas:        params.Replicas,
+		Timeout:         params.Timeout,
+		Env:             params.Env,
+		Resources:       params.Resources,
+		NoCopy:          params.NoCopy,
+		WorkDir:         params.WorkDir,
+		Setup:           params.Setup,
+		SetupCache:      params.SetupCache,
+		Auto:            params.Auto,
+		NodeArch:        params.NodeArch,
+		SkipBinaryCheck: params.SkipBinaryCheck,
+		CreateNamespace: params.CreateNamespace,
+		Isolated:        params.Isolated,
+		NamespaceLimits: params.NamespaceLimits,
+		TTL:             params.TTL,
+		Owner:           params.Owner,
+		Takeover:        params.Takeover,
+		WaitForLock:     params.WaitForLock,
+		Preview:         params.Preview,
+		PreviewBranch:   params.PreviewBranch,
+		PreviewDomain:   params.PreviewDomain,
+		Copier:          params.Copier,
+		CopyProgress:    params.CopyProgress,
+		Kube:            params.Kube,
 	}
 }
 
 // Validate validates the parameters of the deployment command.
 func (c *DeploymentCommand) Validate() error {
+	if !c.Preview && (c.PreviewBranch != "" || c.PreviewDomain != "") {
+		return fmt.Errorf("PreviewBranch and PreviewDomain require Preview")
+	}
+	if c.PreviewDomain != "" && c.IngressHost != "" && !c.previewed {
+		return fmt.Errorf("PreviewDomain can't be used with IngressHost")
+	}
+	if err := c.resolvePreview(); err != nil {
+		return err
+	}
 	if c.Name == "" {
 		return fmt.Errorf("Name is required")
 	}
+	if c.Auto && c.detected == nil {
+		if err := c.detect(); err != nil {
+			return err
+		}
+	}
 	if c.Image == "" {
 		return fmt.Errorf("Image is required")
 	}
-	if c.Copy == "" {
+	if len(c.copies()) == 0 && !c.NoCopy && c.GitRepo == "" {
 		return fmt.Errorf("Copy is required")
 	}
+	if len(c.copies()) > 0 && c.NoCopy {
+		return fmt.Errorf("Copy can't be used with NoCopy")
+	}
+	if err := c.validateCopies(); err != nil {
+		return err
+	}
+	if err := c.validateGitRepo(); err != nil {
+		return err
+	}
+	if err := c.validateSetup(); err != nil {
+		return err
+	}
+	if transport := k8s.CopyTransport(c.Kube.CopyTransport); transport != "" && !slices.Contains(k8s.CopyTransports, transport) {
+		return fmt.Errorf("Copy transport must be one of %v", k8s.CopyTransports)
+	}
+	if compression := k8s.CopyCompression(c.Kube.CopyCompression); compression != "" && !slices.Contains(k8s.CopyCompressions, compression) {
+		return fmt.Errorf("Copy compression must be one of %v", k8s.CopyCompressions)
+	}
+	if c.NodeArch != "" && !slices.Contains(nodeArchs, c.NodeArch) {
+		return fmt.Errorf("NodeArch must be one of %v", nodeArchs)
+	}
+	if c.WorkDir != "" && !path.IsAbs(c.WorkDir) {
+		return fmt.Errorf("WorkDir must be an absolute path")
+	}
 	if c.Replicas < 1 {
 		return fmt.Errorf("Replicas must be greater than 0")
 	}
 	if c.Timeout < 10*time.Second {
 		return fmt.Errorf("Timeout must be greater than 10s")
 	}
+	if c.TTL < 0 {
+		return fmt.Errorf("TTL must not be negative")
+	}
+	for name := range c.Env {
+		if name == "" {
+			return fmt.Errorf("Env names must not be empty")
+		}
+		if strings.HasPrefix(name, "K8RUN_") {
+			return fmt.Errorf("Env %q uses the reserved prefix K8RUN_", name)
+		}
+	}
+	if _, err := c.Resources.requirements(); err != nil {
+		return err
+	}
+	if c.Isolated {
+		if c.Namespace != "" {
+			return fmt.Errorf("Isolated can't be used with Namespace")
+		}
+		if errs := validation.IsDNS1123Label(isolatedNamespace(c.Name)); len(errs) > 0 {
+			return fmt.Errorf("Name can't be used as the isolated namespace %s: %s", isolatedNamespace(c.Name), strings.Join(errs, ", "))
+		}
+	}
+	if !c.NamespaceLimits.isZero() {
+		if !c.Isolated {
+			return fmt.Errorf("NamespaceLimits require Isolated, as they apply to every app of the namespace")
+		}
+		if _, err := c.NamespaceLimits.params(""); err != nil {
+			return err
+		}
+	}
 	if c.Service {
 		if c.Port < 0 {
 			return fmt.Errorf("Port must be greater than 0")
@@ -110,111 +434,537 @@ func (c *DeploymentCommand) Validate() error {
 }
 
 // Run runs the deployment command.
-func (c *DeploymentCommand) Run(ctx context.Context) error {
-	slog.Info("Starting deployment...")
-	c.Namespace = cmp.Or(c.Namespace, "default")
+func (c *DeploymentCommand) Run(ctx context.Context) (err error) {
+	logging.FromContext(ctx).Info("Starting deployment...")
+	c.result = Result{}
+	defer c.finish(time.Now())
+
+	err = c.resolve()
+	if err != nil {
+		return err
+	}
 
 	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 	defer cancel()
+	defer func() { err = timedOut(ctx, err) }()
 
-	copyTo := "/app"
-	initContainerName := "wait-to-copy-app"
+	clientset, err := newClientset(c.Kube)
+	if err != nil {
+		return err
+	}
 
-	config, err := clientcmd.BuildConfigFromFlags("", os.Getenv("KUBECONFIG"))
+	start := time.Now()
+	err = checkAccess(ctx, clientset, c.Namespace, c.permissions())
 	if err != nil {
-		return fmt.Errorf("Failed to build k8s config: %s", err)
+		return err
 	}
 
-	clientset, err := kubernetes.NewForConfig(config)
+	err = c.preflight(ctx, clientset)
 	if err != nil {
-		return fmt.Errorf("Failed to create k8s clientset: %s", err)
+		return err
 	}
+	c.result.step("preflight", start)
 
-	pvcName := pvcName(c.Name)
-	err = k8s.CreatePVCIfNotExists(ctx, clientset, k8s.CreatePVCIfNotExistsParams{
-		Name:      pvcName,
-		Namespace: c.Namespace,
-	})
+	start = time.Now()
+	c.pinGitCommit(ctx)
+	changes, err := c.plan(ctx, clientset)
 	if err != nil {
-		return fmt.Errorf("Failed to create PVC: %s", err)
+		return err
 	}
+	c.result.addResources(changes)
+	c.result.step("plan", start)
 
-	releaseIdentifier := rand.String(10)
-	err = k8s.CreateOrUpdateDeployment(ctx, clientset, k8s.CreateOrUpdateDeploymentParams{
-		Name:              c.Name,
-		Namespace:         c.Namespace,
-		Entrypoint:        c.Entrypoint,
-		ContainerPort:     int32(c.ContainerPort),
-		Image:             c.Image,
-		CopyTo:            copyTo,
-		Replicas:          c.Replicas,
-		PVCName:           pvcName,
-		InitContainerName: initContainerName,
-		ReleaseIdentifier: releaseIdentifier,
-		InitContainerCommand: []string{
-			"sh", "-c", fmt.Sprintf(
-				`rm -rf %s/* && until [ -n "$(ls -A %s)" ]; do echo "Waiting for folder to be non-empty"; sleep 5; done; sleep 2; exit 0`,
-				copyTo, copyTo),
-		},
-	})
+	start = time.Now()
+	err = c.ensureNamespace(ctx, clientset)
 	if err != nil {
-		return fmt.Errorf("Failed to create or update deployment: %s", err)
+		return err
 	}
+	c.result.step("namespace", start)
 
-	pod, err := k8s.WaitForRunningInitContainer(ctx, clientset, k8s.WaitForRunningInitContainerParams{
-		Namespace:         c.Namespace,
-		Name:              c.Name,
-		InitContainerName: initContainerName,
-		ReleaseIdentifier: releaseIdentifier,
-	})
+	start = time.Now()
+	locked, unlock, err := lock(ctx, clientset, c.lockParams())
 	if err != nil {
-		return fmt.Errorf("Failed to wait for init container: %s", err)
+		return err
 	}
+	defer unlock()
+	ctx = locked
+	defer func() { err = lockLost(ctx, err) }()
 
-	err = k8s.CopyToPod(k8s.CopyToPodParams{
-		LocalPath:         c.Copy,
-		PodName:           pod.Name,
-		ContainerPath:     copyTo,
-		InitContainerName: initContainerName,
-		Namespace:         c.Namespace,
-	})
+	err = checkOwner(ctx, clientset, c.Namespace, c.Name, c.Owner, c.Takeover)
 	if err != nil {
-		return fmt.Errorf("Failed to copy folder to pod: %s", err)
+		return err
 	}
+	c.result.step("lock", start)
 
-	if c.Service {
-		err = k8s.CreateOrUpdateService(ctx, clientset, k8s.CreateOrUpdateServiceParams{
-			Name:              c.Name,
+	if !c.NoCopy {
+		start = time.Now()
+		err = k8s.CreatePVCIfNotExists(ctx, clientset, c.pvcParams())
+		if err != nil {
+			return fmt.Errorf("Failed to create PVC: %w", err)
+		}
+		c.result.step("pvc", start)
+	}
+
+	start = time.Now()
+	releaseIdentifier := rand.String(10)
+	c.result.Release = releaseIdentifier
+	err = k8s.CreateOrUpdateDeployment(ctx, clientset, c.deploymentParams(releaseIdentifier))
+	if err != nil {
+		return fmt.Errorf("Failed to create or update deployment: %w", err)
+	}
+	c.result.step("deployment", start)
+
+	if !c.NoCopy && c.GitRepo == "" {
+		start = time.Now()
+		pod, err := k8s.WaitForRunningInitContainer(ctx, clientset, k8s.WaitForRunningInitContainerParams{
 			Namespace:         c.Namespace,
-			Port:              int32(c.Port),
-			ContainerPort:     int32(c.ContainerPort),
+			Name:              c.Name,
+			InitContainerName: initContainerName,
 			ReleaseIdentifier: releaseIdentifier,
 		})
 		if err != nil {
-			return fmt.Errorf("Failed to create or update service: %s", err)
+			return fmt.Errorf("Failed to wait for init container: %w", err)
+		}
+
+		err = c.copyCode(ctx, pod.Name)
+		if err != nil {
+			return err
+		}
+		c.result.step("copy", start)
+	}
+
+	if c.Service {
+		start = time.Now()
+		err = k8s.CreateOrUpdateService(ctx, clientset, c.serviceParams(releaseIdentifier))
+		if err != nil {
+			return fmt.Errorf("Failed to create or update service: %w", err)
 		}
+		c.result.step("service", start)
 	}
 
 	if c.Ingress {
-		err = k8s.CreateOrUpdateIngress(ctx, clientset, k8s.CreateOrUpdateIngressParams{
-			Name:         c.Name,
-			Namespace:    c.Namespace,
-			IngressClass: &c.IngressClass,
-			IngressHost:  c.IngressHost,
-			Port:         int32(c.Port),
-		})
+		start = time.Now()
+		err = k8s.CreateOrUpdateIngress(ctx, clientset, c.ingressParams())
 		if err != nil {
-			return fmt.Errorf("Failed to create or update ingress: %s", err)
+			return fmt.Errorf("Failed to create or update ingress: %w", err)
 		}
+		c.result.step("ingress", start)
 	}
 
+	start = time.Now()
 	err = k8s.WaitForDeploymentToBeReady(ctx, clientset, k8s.WaitForDeploymentToBeReadyParams{
 		Namespace:         c.Namespace,
 		Name:              c.Name,
 		ReleaseIdentifier: releaseIdentifier,
 	})
+	if errors.Is(err, k8s.ErrAppCrashed) {
+		return withKind(ErrAppCrash, fmt.Errorf("App %s crashed: %w", c.Name, err))
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to wait for deployment to be ready: %w", err)
+	}
+	c.result.step("rollout", start)
 
-	slog.Info("Deployment finished!")
+	if c.GitRepo != "" {
+		if err := c.recordGitCommit(ctx, clientset, releaseIdentifier); err != nil {
+			return err
+		}
+	}
+
+	err = c.describeRelease(ctx, clientset, releaseIdentifier)
+	if err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("Deployment finished!")
 
 	return nil
 }
+
+// copyCode copies the code into the init container of the given pod, recording its size in the result.
+func (c *DeploymentCommand) copyCode(ctx context.Context, podName string) error {
+	sources, mounts := c.copyLayout()
+	for i := range sources {
+		sources[i].Skip = c.skipCaches(mounts[i].Path)
+	}
+	sources, cleanup, err := c.spoolSources(ctx, sources)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	var total, filtered int64
+	for _, source := range sources {
+		sourceTotal, sourceFiltered, err := source.Size()
+		if err != nil {
+			return fmt.Errorf("Failed to read %s: %w", source.Path, err)
+		}
+		total += sourceTotal
+		filtered += sourceFiltered
+	}
+	logging.FromContext(ctx).With("size", k8s.FormatBytes(total), "filtered", k8s.FormatBytes(filtered)).Info("Copying code...")
+	c.result.Copy = &CopyResult{SizeBytes: total, FilteredBytes: filtered}
+
+	copier, err := c.copier()
+	if err != nil {
+		return err
+	}
+	err = copier.Copy(ctx, k8s.CopyParams{
+		Sources:       sources,
+		Namespace:     c.Namespace,
+		PodName:       podName,
+		ContainerName: initContainerName,
+		ContainerPath: copyTo,
+		Compression:   k8s.CopyCompression(c.Kube.CopyCompression),
+		Progress: func(progress k8s.CopyProgress) {
+			c.result.Copy.Compression = string(progress.Compression)
+			c.result.Copy.SentBytes = progress.Sent
+			if c.CopyProgress != nil {
+				c.CopyProgress(progress)
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to copy folder to pod: %w", err)
+	}
+
+	return nil
+}
+
+// copier returns the copier of the command or, without one, the copier of the copy transport.
+func (c *DeploymentCommand) copier() (k8s.Copier, error) {
+	if c.Copier != nil {
+		return c.Copier, nil
+	}
+
+	config, err := c.Kube.RESTConfig()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to k8s: %w", err)
+	}
+	return k8s.NewCopier(k8s.NewCopierParams{
+		Transport:    k8s.CopyTransport(c.Kube.CopyTransport),
+		Config:       config,
+		KubectlFlags: c.Kube.KubectlFlags(),
+	})
+}
+
+// Result returns the outcome of the last run of the deployment command.
+func (c *DeploymentCommand) Result() *Result {
+	return &c.result
+}
+
+// finish completes the result of a run that started at start.
+func (c *DeploymentCommand) finish(start time.Time) {
+	c.result.Name = c.Name
+	c.result.Namespace = c.Namespace
+	c.result.DurationSeconds = time.Since(start).Seconds()
+}
+
+// describeRelease records the pods, the service cluster IP and the ingress URL of the ready release.
+func (c *DeploymentCommand) describeRelease(ctx context.Context, clientset kubernetes.Interface, releaseIdentifier string) error {
+	pods, err := k8s.ListPods(ctx, clientset, k8s.ListParams{
+		Namespace:     c.Namespace,
+		LabelSelector: fmt.Sprintf("%s=%s", k8s.LabelNameReleaseIdentifier, releaseIdentifier),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to list pods: %w", err)
+	}
+	for _, pod := range pods {
+		if pod.DeletionTimestamp == nil {
+			c.result.Pods = append(c.result.Pods, pod.Name)
+		}
+	}
+	if c.git != nil {
+		c.result.Commit = c.git.Commit
+	}
+	c.result.Build = c.build
+
+	if c.Service {
+		service, err := k8s.GetService(ctx, clientset, k8s.GetParams{Name: c.Name, Namespace: c.Namespace})
+		if err != nil {
+			return fmt.Errorf("Failed to get service: %w", err)
+		}
+		c.result.ServiceClusterIP = service.Spec.ClusterIP
+	}
+
+	if c.Ingress {
+		ingress, err := k8s.GetIngress(ctx, clientset, k8s.GetParams{Name: c.Name, Namespace: c.Namespace})
+		if err != nil {
+			return fmt.Errorf("Failed to get ingress: %w", err)
+		}
+		c.result.IngressURL = ingressURL(ingress)
+	}
+
+	return nil
+}
+
+// ingressURL returns the URL the ingress exposes the app on: its host or, without one, the address of its load
+// balancer. It's empty while the load balancer has no address yet.
+func ingressURL(ingress *networkingv1.Ingress) string {
+	host := ""
+	if len(ingress.Spec.Rules) > 0 {
+		host = ingress.Spec.Rules[0].Host
+	}
+	if host == "" && len(ingress.Status.LoadBalancer.Ingress) > 0 {
+		host = cmp.Or(ingress.Status.LoadBalancer.Ingress[0].Hostname, ingress.Status.LoadBalancer.Ingress[0].IP)
+	}
+	if host == "" {
+		return ""
+	}
+
+	scheme := "http"
+	if len(ingress.Spec.TLS) > 0 {
+		scheme = "https"
+	}
+	return scheme + "://" + host
+}
+
+// Plan returns the changes the deployment command will make to the cluster.
+func (c *DeploymentCommand) Plan(ctx context.Context) (*plan.Plan, error) {
+	err := c.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	clientset, err := newClientset(c.Kube)
+	if err != nil {
+		return nil, err
+	}
+
+	c.pinGitCommit(ctx)
+	return c.plan(ctx, clientset)
+}
+
+func (c *DeploymentCommand) plan(ctx context.Context, clientset kubernetes.Interface) (*plan.Plan, error) {
+	err := checkOwner(ctx, clientset, c.Namespace, c.Name, c.Owner, c.Takeover)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &plan.Plan{}
+	changes, err := c.planNamespace(ctx, clientset)
+	if err != nil {
+		return nil, err
+	}
+	p.Add(changes...)
+
+	if !c.NoCopy {
+		change, err := c.planPVC(ctx, clientset)
+		if err != nil {
+			return nil, err
+		}
+		p.Add(change)
+	}
+
+	change, err := planDeployment(ctx, clientset, c.deploymentParams(""))
+	if err != nil {
+		return nil, err
+	}
+	p.Add(change)
+
+	changes, err = c.planExposure(ctx, clientset)
+	if err != nil {
+		return nil, err
+	}
+	p.Add(changes...)
+
+	return p, nil
+}
+
+func (c *DeploymentCommand) planPVC(ctx context.Context, clientset kubernetes.Interface) (plan.Change, error) {
+	pvc, err := k8s.GetPVC(ctx, clientset, k8s.GetParams{Name: pvcName(c.Name), Namespace: c.Namespace})
+	change, err := planApply(k8s.BuildPVC(c.pvcParams()), pvc, err)
+	if err != nil {
+		return change, fmt.Errorf("Failed to plan PVC: %w", err)
+	}
+
+	// an existing PVC is kept as it is, only its content is replaced by the new copy
+	if change.Action != plan.Create && change.Note == "" {
+		change.Action = plan.Unchanged
+		change.Fields = nil
+		change.Recreate = false
+		change.Note = "content replaced by the new copy"
+	}
+	return change, nil
+}
+
+// planExposure plans the service and ingress of the deployment command.
+func (c *DeploymentCommand) planExposure(ctx context.Context, clientset kubernetes.Interface) ([]plan.Change, error) {
+	changes := []plan.Change{}
+	get := k8s.GetParams{Name: c.Name, Namespace: c.Namespace}
+
+	if c.Service {
+		service, err := k8s.GetService(ctx, clientset, get)
+		change, err := planApply(k8s.BuildService(c.serviceParams("")), service, err)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to plan service: %w", err)
+		}
+		changes = append(changes, change)
+	}
+
+	if c.Ingress {
+		ingress, err := k8s.GetIngress(ctx, clientset, get)
+		change, err := planApply(k8s.BuildIngress(c.ingressParams()), ingress, err)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to plan ingress: %w", err)
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+func planDeployment(ctx context.Context, clientset kubernetes.Interface, params k8s.CreateOrUpdateDeploymentParams) (plan.Change, error) {
+	deployment, err := k8s.GetDeployment(ctx, clientset, k8s.GetParams{Name: params.Name, Namespace: params.Namespace})
+	change, err := planApply(k8s.BuildDeployment(params), deployment, err)
+	if err != nil {
+		return change, fmt.Errorf("Failed to plan deployment: %w", err)
+	}
+
+	// every release rolls the pods out, so they pick up the new copy
+	change.Restart = change.Action != plan.Create
+	return change, nil
+}
+
+func (c *DeploymentCommand) lockParams() lockParams {
+	return lockParams{App: c.Name, Namespace: c.Namespace, Owner: c.Owner, Wait: c.WaitForLock}
+}
+
+func (c *DeploymentCommand) pvcParams() k8s.CreatePVCIfNotExistsParams {
+	return k8s.CreatePVCIfNotExistsParams{
+		Name:      pvcName(c.Name),
+		Namespace: c.Namespace,
+	}
+}
+
+func (c *DeploymentCommand) deploymentParams(releaseIdentifier string) k8s.CreateOrUpdateDeploymentParams {
+	// resources are already checked by Validate
+	resources, _ := c.Resources.requirements()
+
+	params := k8s.CreateOrUpdateDeploymentParams{
+		Name:                 c.Name,
+		Namespace:            c.Namespace,
+		Entrypoint:           c.Entrypoint,
+		ContainerPort:        int32(c.ContainerPort),
+		Image:                c.Image,
+		CopyTo:               copyTo,
+		CopyMounts:           c.copyMounts(),
+		WorkingDir:           c.WorkDir,
+		Replicas:             c.Replicas,
+		PVCName:              pvcName(c.Name),
+		InitContainerName:    initContainerName,
+		ReleaseIdentifier:    releaseIdentifier,
+		Env:                  c.env(),
+		Resources:            resources,
+		Annotations:          c.annotations(),
+		InitContainerCommand: waitForCopyCommand(),
+		ImagePullSecret:      c.imagePullSecret,
+		NodeArch:             c.NodeArch,
+	}
+
+	// every revision of the deployment keeps the image it was built as, see 'kubectl rollout history'
+	if c.build != nil {
+		if params.Annotations == nil {
+			params.Annotations = map[string]string{}
+		}
+		params.Annotations[k8s.AnnotationNameImageDigest] = c.build.Digest
+		params.Annotations[k8s.AnnotationNameBuildDuration] = time.Duration(c.build.DurationSeconds * float64(time.Second)).Round(time.Second).String()
+		params.Annotations[k8s.AnnotationNameChangeCause] = "k8run build " + c.build.Image
+	}
+
+	if c.Setup != "" {
+		params.SetupCommand = c.setupCommand(releaseIdentifier)
+		params.SetupMounts, params.CacheMounts = c.setupMounts()
+	}
+
+	if c.GitRepo != "" {
+		params.InitContainerImage = k8s.GitImage
+		params.InitContainerSecret = c.GitSecret
+		params.InitContainerCommand = c.cloneCommand()
+	}
+
+	if c.NoCopy {
+		params.CopyTo = ""
+		params.PVCName = ""
+		params.InitContainerName = ""
+		params.InitContainerCommand = nil
+	}
+
+	return params
+}
+
+// waitForCopyCommand returns the command of the init container emptying the copy folder and waiting for the copy.
+// The setup caches are neither emptied nor taken for the copy.
+func waitForCopyCommand() []string {
+	return []string{
+		"sh", "-c", fmt.Sprintf(
+			`rm -rf %s/* && until [ -n "$(ls -A %s | grep -vx %s)" ]; do echo "Waiting for folder to be non-empty"; sleep 5; done; sleep 2; exit 0`,
+			copyTo, copyTo, setupCacheFolder),
+	}
+}
+
+func (c *DeploymentCommand) serviceParams(releaseIdentifier string) k8s.CreateOrUpdateServiceParams {
+	return k8s.CreateOrUpdateServiceParams{
+		Name:              c.Name,
+		Namespace:         c.Namespace,
+		Port:              int32(c.Port),
+		ContainerPort:     int32(c.ContainerPort),
+		ReleaseIdentifier: releaseIdentifier,
+		Annotations:       c.annotations(),
+	}
+}
+
+func (c *DeploymentCommand) ingressParams() k8s.CreateOrUpdateIngressParams {
+	return k8s.CreateOrUpdateIngressParams{
+		Name:         c.Name,
+		Namespace:    c.Namespace,
+		IngressClass: &c.IngressClass,
+		IngressHost:  c.IngressHost,
+		Port:         int32(c.Port),
+		Annotations:  c.annotations(),
+	}
+}
+
+// env returns the env vars of the app: the given ones and the git metadata of the release.
+func (c *DeploymentCommand) env() map[string]string {
+	_, gitEnv := c.gitMetadata()
+	if len(gitEnv) == 0 {
+		return c.Env
+	}
+
+	env := map[string]string{}
+	for name, value := range c.Env {
+		env[name] = value
+	}
+	for name, value := range gitEnv {
+		env[name] = value
+	}
+	return env
+}
+
+// annotations returns the annotations of the resources of the deployment command: their owner, expiry and
+// git metadata.
+func (c *DeploymentCommand) annotations() map[string]string {
+	annotations, _ := c.gitMetadata()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if c.Owner != "" {
+		annotations[k8s.AnnotationNameOwner] = c.Owner
+	}
+
+	if c.TTL != 0 {
+		if c.expiresAt.IsZero() {
+			c.expiresAt = time.Now().Add(c.TTL).UTC()
+		}
+		annotations[k8s.AnnotationNameExpiresAt] = c.expiresAt.Format(time.RFC3339)
+	}
+
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
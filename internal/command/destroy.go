@@ -1,25 +1,32 @@
 package command
 
 import (
-	"cmp"
 	"context"
 	"errors"
 	"fmt"
-	"log/slog"
-	"os"
 	"sync"
 	"time"
 
 	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/kube"
+	"github.com/lucasvmiguel/k8run/internal/logging"
+	"github.com/lucasvmiguel/k8run/internal/plan"
+
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
 // NewDestroyCommandParams represents the parameters to create a new destroy command.
 type NewDestroyCommandParams struct {
-	Name      string
-	Namespace string
-	Timeout   time.Duration
+	Name          string
+	Namespace     string
+	Timeout       time.Duration
+	Isolated      bool
+	Owner         string
+	Takeover      bool
+	WaitForLock   bool
+	Preview       bool
+	PreviewBranch string
+	Kube          kube.Config
 }
 
 // DestroyCommand represents a command to destroy an application and its related resources in a Kubernetes cluster.
@@ -27,19 +34,51 @@ type DestroyCommand struct {
 	Name      string
 	Namespace string
 	Timeout   time.Duration
+	// Isolated destroys an app deployed into a namespace of its own.
+	Isolated bool
+	// Owner is who destroys the app. Defaults to the git email or the kubeconfig user.
+	Owner string
+	// Takeover allows destroying an app deployed by someone else.
+	Takeover bool
+	// WaitForLock waits for another deployment or destroy of the app to finish instead of failing.
+	WaitForLock bool
+	// Preview destroys the preview app of the git branch checked out in the current folder, prefixed by Name if set.
+	Preview bool
+	// PreviewBranch is the branch of the preview app, eg: in CI, where HEAD is often detached.
+	PreviewBranch string
+	// Kube is how to reach the cluster.
+	Kube kube.Config
+
+	// previewed is true once the name of a preview app is derived from its branch.
+	previewed bool
+	// result is the outcome of the last run.
+	result Result
 }
 
 // NewDestroyCommand creates a new destroy command.
 func NewDestroyCommand(params NewDestroyCommandParams) *DestroyCommand {
 	return &DestroyCommand{
-		Name:      params.Name,
-		Namespace: params.Namespace,
-		Timeout:   params.Timeout,
+		Name:          params.Name,
+		Namespace:     params.Namespace,
+		Timeout:       params.Timeout,
+		Isolated:      params.Isolated,
+		Owner:         params.Owner,
+		Takeover:      params.Takeover,
+		WaitForLock:   params.WaitForLock,
+		Preview:       params.Preview,
+		PreviewBranch: params.PreviewBranch,
+		Kube:          params.Kube,
 	}
 }
 
 // Validate validates the parameters of the destroy command.
 func (c *DestroyCommand) Validate() error {
+	if !c.Preview && c.PreviewBranch != "" {
+		return fmt.Errorf("PreviewBranch requires Preview")
+	}
+	if err := c.resolvePreview(); err != nil {
+		return err
+	}
 	if c.Name == "" {
 		return fmt.Errorf("Name is required")
 	}
@@ -47,27 +86,58 @@ func (c *DestroyCommand) Validate() error {
 	if c.Timeout < 10*time.Second {
 		return fmt.Errorf("Timeout must be greater than 10s")
 	}
+	if c.Isolated && c.Namespace != "" {
+		return fmt.Errorf("Isolated can't be used with Namespace")
+	}
 	return nil
 }
 
 // Run runs the destroy command.
-func (c *DestroyCommand) Run(ctx context.Context) error {
-	slog.Info("Starting destroying...")
-	c.Namespace = cmp.Or(c.Namespace, "default")
+func (c *DestroyCommand) Run(ctx context.Context) (err error) {
+	logging.FromContext(ctx).Info("Starting destroying...")
+	c.result = Result{}
+	defer c.finish(time.Now())
+
+	err = c.resolve()
+	if err != nil {
+		return err
+	}
 
 	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
 	defer cancel()
+	defer func() { err = timedOut(ctx, err) }()
 
-	config, err := clientcmd.BuildConfigFromFlags("", os.Getenv("KUBECONFIG"))
+	clientset, err := newClientset(c.Kube)
 	if err != nil {
-		return fmt.Errorf("Failed to build k8s config: %s", err)
+		return err
 	}
 
-	clientset, err := kubernetes.NewForConfig(config)
+	start := time.Now()
+	locked, unlock, err := lock(ctx, clientset, lockParams{App: c.Name, Namespace: c.Namespace, Owner: c.Owner, Wait: c.WaitForLock})
 	if err != nil {
-		return fmt.Errorf("Failed to create k8s clientset: %s", err)
+		return err
 	}
+	defer unlock()
+	ctx = locked
+	defer func() { err = lockLost(ctx, err) }()
+	c.result.step("lock", start)
 
+	start = time.Now()
+	// checks the owner too
+	changes, err := c.plan(ctx, clientset)
+	if err != nil {
+		return err
+	}
+	c.result.addResources(changes)
+	c.result.step("plan", start)
+
+	// decided before the app is deleted, as its namespace is kept when other apps use it
+	namespace, err := c.ownedNamespace(ctx, clientset)
+	if err != nil {
+		return err
+	}
+
+	start = time.Now()
 	wg := sync.WaitGroup{}
 	deletingDeployment := false
 	deletingPVC := false
@@ -80,15 +150,20 @@ func (c *DestroyCommand) Run(ctx context.Context) error {
 	})
 	if err != nil {
 		if errors.Is(err, k8s.ErrResourceNotFound) {
-			slog.With("name", c.Name, "namespace", c.Namespace).Info("Deployment not found")
+			logging.FromContext(ctx).With("name", c.Name, "namespace", c.Namespace).Info("Deployment not found")
 		} else {
-			return fmt.Errorf("Failed to delete deployment: %s", err)
+			return fmt.Errorf("Failed to delete deployment: %w", err)
 		}
 	} else {
 		deletingDeployment = true
 		wg.Add(1)
 	}
 
+	err = c.deleteProcesses(ctx, clientset)
+	if err != nil {
+		return err
+	}
+
 	pvcName := pvcName(c.Name)
 	err = k8s.DeletePVC(ctx, clientset, k8s.DeletePVCParams{
 		Name:      pvcName,
@@ -96,9 +171,9 @@ func (c *DestroyCommand) Run(ctx context.Context) error {
 	})
 	if err != nil {
 		if errors.Is(err, k8s.ErrResourceNotFound) {
-			slog.With("name", c.Name, "namespace", c.Namespace).Info("PVC not found")
+			logging.FromContext(ctx).With("name", c.Name, "namespace", c.Namespace).Info("PVC not found")
 		} else {
-			return fmt.Errorf("Failed to delete PVC: %s", err)
+			return fmt.Errorf("Failed to delete PVC: %w", err)
 		}
 	} else {
 		deletingPVC = true
@@ -111,9 +186,9 @@ func (c *DestroyCommand) Run(ctx context.Context) error {
 	})
 	if err != nil {
 		if errors.Is(err, k8s.ErrResourceNotFound) {
-			slog.With("name", c.Name, "namespace", c.Namespace).Info("Service not found")
+			logging.FromContext(ctx).With("name", c.Name, "namespace", c.Namespace).Info("Service not found")
 		} else {
-			return fmt.Errorf("Failed to delete service: %s", err)
+			return fmt.Errorf("Failed to delete service: %w", err)
 		}
 	} else {
 		deletingService = true
@@ -126,9 +201,9 @@ func (c *DestroyCommand) Run(ctx context.Context) error {
 	})
 	if err != nil {
 		if errors.Is(err, k8s.ErrResourceNotFound) {
-			slog.With("name", c.Name, "namespace", c.Namespace).Info("Ingress not found")
+			logging.FromContext(ctx).With("name", c.Name, "namespace", c.Namespace).Info("Ingress not found")
 		} else {
-			return fmt.Errorf("Failed to delete ingress: %s", err)
+			return fmt.Errorf("Failed to delete ingress: %w", err)
 		}
 	} else {
 		deletingIngress = true
@@ -144,11 +219,11 @@ func (c *DestroyCommand) Run(ctx context.Context) error {
 					Namespace: c.Namespace,
 				})
 				if errors.Is(err, k8s.ErrResourceNotFound) {
-					slog.With("name", c.Name, "namespace", c.Namespace).Info("Deployment deleted")
+					logging.FromContext(ctx).With("name", c.Name, "namespace", c.Namespace).Info("Deployment deleted")
 					return
 				}
 				time.Sleep(2 * time.Second)
-				slog.With("name", c.Name, "namespace", c.Namespace).Info("Waiting for deployment deletion...")
+				logging.FromContext(ctx).With("name", c.Name, "namespace", c.Namespace).Info("Waiting for deployment deletion...")
 			}
 		}()
 	}
@@ -162,11 +237,11 @@ func (c *DestroyCommand) Run(ctx context.Context) error {
 					Namespace: c.Namespace,
 				})
 				if errors.Is(err, k8s.ErrResourceNotFound) {
-					slog.With("name", pvcName, "namespace", c.Namespace).Info("PVC deleted")
+					logging.FromContext(ctx).With("name", pvcName, "namespace", c.Namespace).Info("PVC deleted")
 					return
 				}
 				time.Sleep(2 * time.Second)
-				slog.With("name", pvcName, "namespace", c.Namespace).Info("Waiting for PVC deletion...")
+				logging.FromContext(ctx).With("name", pvcName, "namespace", c.Namespace).Info("Waiting for PVC deletion...")
 			}
 		}()
 	}
@@ -180,11 +255,11 @@ func (c *DestroyCommand) Run(ctx context.Context) error {
 					Namespace: c.Namespace,
 				})
 				if errors.Is(err, k8s.ErrResourceNotFound) {
-					slog.With("name", c.Name, "namespace", c.Namespace).Info("Service deleted")
+					logging.FromContext(ctx).With("name", c.Name, "namespace", c.Namespace).Info("Service deleted")
 					return
 				}
 				time.Sleep(2 * time.Second)
-				slog.With("name", c.Name, "namespace", c.Namespace).Info("Waiting for service deletion...")
+				logging.FromContext(ctx).With("name", c.Name, "namespace", c.Namespace).Info("Waiting for service deletion...")
 			}
 		}()
 	}
@@ -198,15 +273,18 @@ func (c *DestroyCommand) Run(ctx context.Context) error {
 					Namespace: c.Namespace,
 				})
 				if errors.Is(err, k8s.ErrResourceNotFound) {
-					slog.With("name", c.Name, "namespace", c.Namespace).Info("Ingress deleted")
+					logging.FromContext(ctx).With("name", c.Name, "namespace", c.Namespace).Info("Ingress deleted")
 					return
 				}
 				time.Sleep(2 * time.Second)
-				slog.With("name", c.Name, "namespace", c.Namespace).Info("Waiting for ingress deletion...")
+				logging.FromContext(ctx).With("name", c.Name, "namespace", c.Namespace).Info("Waiting for ingress deletion...")
 			}
 		}()
 	}
 
+	c.result.step("delete", start)
+
+	start = time.Now()
 	done := make(chan struct{})
 	go func() {
 		wg.Wait()
@@ -217,10 +295,155 @@ func (c *DestroyCommand) Run(ctx context.Context) error {
 	case <-done:
 		break
 	case <-ctx.Done():
-		return fmt.Errorf("Timeout while waiting for resource deletion")
+		return withKind(ErrTimeout, fmt.Errorf("Timeout while waiting for resource deletion"))
+	}
+	c.result.step("wait", start)
+
+	if namespace != nil {
+		start = time.Now()
+		err = k8s.DeleteNamespace(ctx, clientset, k8s.DeleteNamespaceParams{Name: namespace.Name})
+		if err != nil && !errors.Is(err, k8s.ErrResourceNotFound) {
+			return fmt.Errorf("Failed to delete namespace: %w", err)
+		}
+		c.result.step("namespace", start)
+	}
+
+	logging.FromContext(ctx).Info("Destroy finished!")
+
+	return nil
+}
+
+// Result returns the outcome of the last run of the destroy command.
+func (c *DestroyCommand) Result() *Result {
+	return &c.result
+}
+
+// finish completes the result of a run that started at start.
+func (c *DestroyCommand) finish(start time.Time) {
+	c.result.Name = c.Name
+	c.result.Namespace = c.Namespace
+	c.result.DurationSeconds = time.Since(start).Seconds()
+}
+
+// Plan returns the resources the destroy command will delete.
+func (c *DestroyCommand) Plan(ctx context.Context) (*plan.Plan, error) {
+	err := c.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	clientset, err := newClientset(c.Kube)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.plan(ctx, clientset)
+}
+
+func (c *DestroyCommand) plan(ctx context.Context, clientset kubernetes.Interface) (*plan.Plan, error) {
+	err := checkOwner(ctx, clientset, c.Namespace, c.Name, c.Owner, c.Takeover)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &plan.Plan{}
+	get := k8s.GetParams{Name: c.Name, Namespace: c.Namespace}
+	add := func(change *plan.Change, err error) error {
+		if change != nil {
+			p.Add(*change)
+		}
+		return err
+	}
+
+	deployment, err := k8s.GetDeployment(ctx, clientset, get)
+	if err := add(planDelete("Deployment", deployment, err)); err != nil {
+		return nil, fmt.Errorf("Failed to plan deployment: %w", err)
+	}
+
+	selector := fmt.Sprintf("%s=%s", k8s.LabelNameApp, c.Name)
+	processes, err := k8s.ListDeployments(ctx, clientset, k8s.ListParams{Namespace: c.Namespace, LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list process deployments: %w", err)
+	}
+	for i := range processes {
+		if processes[i].Name != c.Name {
+			if err := add(planDelete("Deployment", &processes[i], nil)); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	slog.Info("Destroy finished!")
+	jobs, err := k8s.ListJobs(ctx, clientset, k8s.ListParams{Namespace: c.Namespace, LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list release jobs: %w", err)
+	}
+	for i := range jobs {
+		if err := add(planDelete("Job", &jobs[i], nil)); err != nil {
+			return nil, err
+		}
+	}
+
+	pvc, err := k8s.GetPVC(ctx, clientset, k8s.GetParams{Name: pvcName(c.Name), Namespace: c.Namespace})
+	if err := add(planDelete("PersistentVolumeClaim", pvc, err)); err != nil {
+		return nil, fmt.Errorf("Failed to plan PVC: %w", err)
+	}
+
+	service, err := k8s.GetService(ctx, clientset, get)
+	if err := add(planDelete("Service", service, err)); err != nil {
+		return nil, fmt.Errorf("Failed to plan service: %w", err)
+	}
+
+	ingress, err := k8s.GetIngress(ctx, clientset, get)
+	if err := add(planDelete("Ingress", ingress, err)); err != nil {
+		return nil, fmt.Errorf("Failed to plan ingress: %w", err)
+	}
+
+	namespace, err := c.ownedNamespace(ctx, clientset)
+	if err != nil {
+		return nil, err
+	}
+	if namespace != nil {
+		change, err := plan.Remove("Namespace", namespace)
+		if err != nil {
+			return nil, err
+		}
+		change.Note = "created by k8run for the app, deleted with everything in it"
+		p.Add(change)
+	}
+
+	return p, nil
+}
+
+// deleteProcesses deletes the other deployments and the release jobs of an app deployed from a Procfile.
+func (c *DestroyCommand) deleteProcesses(ctx context.Context, clientset kubernetes.Interface) error {
+	selector := fmt.Sprintf("%s=%s", k8s.LabelNameApp, c.Name)
+
+	deployments, err := k8s.ListDeployments(ctx, clientset, k8s.ListParams{Namespace: c.Namespace, LabelSelector: selector})
+	if err != nil {
+		return fmt.Errorf("Failed to list process deployments: %w", err)
+	}
+
+	for _, deployment := range deployments {
+		if deployment.Name == c.Name {
+			continue
+		}
+
+		err = k8s.DeleteDeployment(ctx, clientset, k8s.DeleteDeploymentParams{
+			Name:      deployment.Name,
+			Namespace: c.Namespace,
+		})
+		if err != nil && !errors.Is(err, k8s.ErrResourceNotFound) {
+			return fmt.Errorf("Failed to delete deployment: %w", err)
+		}
+	}
+
+	err = k8s.DeleteJobs(ctx, clientset, k8s.ListParams{Namespace: c.Namespace, LabelSelector: selector})
+	if err != nil {
+		return fmt.Errorf("Failed to delete release jobs: %w", err)
+	}
 
 	return nil
 }
@@ -40,6 +40,16 @@ func TestDestroyCommand_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "isolated with namespace",
+			command: &command.DestroyCommand{
+				Name:      "test",
+				Namespace: "default",
+				Timeout:   15 * time.Second,
+				Isolated:  true,
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,92 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/doctor"
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/kube"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewDoctorCommandParams represents the parameters to create a new doctor command.
+type NewDoctorCommandParams struct {
+	Namespace    string
+	IngressClass string
+	Timeout      time.Duration
+	Kube         kube.Config
+}
+
+// DoctorCommand represents a command to check whether the cluster is ready for k8run: the permissions of the
+// current user and what k8run assumes about the cluster.
+type DoctorCommand struct {
+	Namespace string
+	// IngressClass is the ingress class deployments will use. Defaults to the default ingress class of the cluster.
+	IngressClass string
+	Timeout      time.Duration
+	// Kube is how to reach the cluster.
+	Kube kube.Config
+}
+
+// NewDoctorCommand creates a new doctor command.
+func NewDoctorCommand(params NewDoctorCommandParams) *DoctorCommand {
+	return &DoctorCommand{
+		Namespace:    params.Namespace,
+		IngressClass: params.IngressClass,
+		Timeout:      params.Timeout,
+		Kube:         params.Kube,
+	}
+}
+
+// Validate validates the parameters of the doctor command.
+func (c *DoctorCommand) Validate() error {
+	if c.Timeout < time.Second {
+		return fmt.Errorf("Timeout must be greater than 1s")
+	}
+	return nil
+}
+
+// Report runs the checks and returns their report.
+func (c *DoctorCommand) Report(ctx context.Context) (*doctor.Report, error) {
+	namespace, err := resolveNamespace(c.Kube, c.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	c.Namespace = namespace
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	clientset, err := newClientset(c.Kube)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.report(ctx, clientset)
+}
+
+func (c *DoctorCommand) report(ctx context.Context, clientset kubernetes.Interface) (*doctor.Report, error) {
+	report := &doctor.Report{}
+
+	// every permission a deployment may need, with a service and an ingress
+	deployment := &DeploymentCommand{Service: true, Ingress: true}
+	checks, err := accessChecks(ctx, clientset, c.Namespace, deployment.permissions())
+	if err != nil {
+		return nil, err
+	}
+	report.Add(checks...)
+
+	report.Add(clusterChecks(ctx, clientset, clusterCheckParams{
+		Namespace:    c.Namespace,
+		Copy:         true,
+		Kubectl:      c.Kube.CopyTransport == string(k8s.CopyTransportKubectl),
+		Ingress:      true,
+		IngressClass: c.IngressClass,
+		Probe:        true,
+	})...)
+
+	return report, nil
+}
@@ -0,0 +1,252 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/doctor"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// readyClientset returns a clientset of a cluster ready for k8run, with the given objects, allowing everything
+// but the given resources.
+func readyClientset(objects []runtime.Object, denied ...string) *fake.Clientset {
+	clientset := fake.NewSimpleClientset(objects...)
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{GroupVersion: "networking.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "ingresses"}}},
+	}
+
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		resource := attributes.Resource
+		if attributes.Subresource != "" {
+			resource += "/" + attributes.Subresource
+		}
+		review.Status.Allowed = true
+		for _, d := range denied {
+			if d == resource {
+				review.Status.Allowed = false
+			}
+		}
+		return true, review, nil
+	})
+	// the probe PVC binds and its pod completes right away
+	clientset.PrependReactor("create", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		action.(k8stesting.CreateAction).GetObject().(*corev1.PersistentVolumeClaim).Status.Phase = corev1.ClaimBound
+		return false, nil, nil
+	})
+	clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		action.(k8stesting.CreateAction).GetObject().(*corev1.Pod).Status.Phase = corev1.PodSucceeded
+		return false, nil, nil
+	})
+
+	return clientset
+}
+
+func readyObjects() []runtime.Object {
+	return []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{
+			Name:        "standard",
+			Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"},
+		}},
+		&networkingv1.IngressClass{ObjectMeta: metav1.ObjectMeta{Name: "nginx"}},
+	}
+}
+
+func withKubectl(t *testing.T, found bool) {
+	t.Helper()
+	original := lookPath
+	lookPath = func(file string) (string, error) {
+		if !found {
+			return "", errors.New("not found")
+		}
+		return "/usr/bin/" + file, nil
+	}
+	t.Cleanup(func() { lookPath = original })
+}
+
+func TestDoctorCommand_Report(t *testing.T) {
+	withKubectl(t, true)
+	c := &DoctorCommand{Namespace: "default", IngressClass: "nginx", Timeout: time.Minute}
+
+	report, err := c.report(context.Background(), readyClientset(readyObjects()))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if report.Failed() || report.Count(doctor.Warn) != 0 {
+		t.Errorf("expected every check to pass, got %+v", report.Checks)
+	}
+
+	report, err = c.report(context.Background(), readyClientset(readyObjects(), "ingresses", "pods/exec"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// get, create and update on ingresses, and create on pods/exec
+	if report.Count(doctor.Fail) != 4 {
+		t.Errorf("expected 4 failed checks, got %+v", report.Checks)
+	}
+}
+
+func TestClusterChecks(t *testing.T) {
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		kubectl bool
+		params  clusterCheckParams
+		check   string
+		want    doctor.Status
+	}{
+		{
+			name:    "missing namespace",
+			objects: readyObjects()[1:],
+			kubectl: true,
+			params:  clusterCheckParams{Namespace: "default"},
+			check:   "namespace default exists",
+			want:    doctor.Fail,
+		},
+		{
+			name:    "no kubectl",
+			objects: readyObjects(),
+			params:  clusterCheckParams{Namespace: "default", Copy: true, Kubectl: true},
+			check:   "kubectl is on PATH",
+			want:    doctor.Fail,
+		},
+		{
+			name:    "no default storage class",
+			objects: []runtime.Object{readyObjects()[0]},
+			kubectl: true,
+			params:  clusterCheckParams{Namespace: "default", Copy: true},
+			check:   "default storage class",
+			want:    doctor.Warn,
+		},
+		{
+			name:    "missing ingress class",
+			objects: readyObjects(),
+			kubectl: true,
+			params:  clusterCheckParams{Namespace: "default", Ingress: true, IngressClass: "traefik"},
+			check:   "ingress class traefik",
+			want:    doctor.Fail,
+		},
+		{
+			name:    "no default ingress class",
+			objects: readyObjects(),
+			kubectl: true,
+			params:  clusterCheckParams{Namespace: "default", Ingress: true},
+			check:   "ingress class",
+			want:    doctor.Warn,
+		},
+		{
+			name:    "probe",
+			objects: readyObjects(),
+			kubectl: true,
+			params:  clusterCheckParams{Namespace: "default", Probe: true},
+			check:   "test PVC binds",
+			want:    doctor.Pass,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withKubectl(t, tt.kubectl)
+
+			checks := clusterChecks(context.Background(), readyClientset(tt.objects), tt.params)
+			for _, check := range checks {
+				if check.Name == tt.check {
+					if check.Status != tt.want {
+						t.Errorf("expected %s, got %+v", tt.want, check)
+					}
+					return
+				}
+			}
+			t.Errorf("expected check %q, got %+v", tt.check, checks)
+		})
+	}
+}
+
+func TestDeploymentCommand_Preflight(t *testing.T) {
+	withKubectl(t, false)
+	c := testDeploymentCommand()
+	c.Ingress = true
+	c.IngressClass = "traefik"
+	c.Kube.CopyTransport = "kubectl"
+
+	err := c.preflight(context.Background(), readyClientset(readyObjects()))
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	for _, failed := range []string{"kubectl is on PATH", "ingress class traefik"} {
+		if !strings.Contains(err.Error(), failed) {
+			t.Errorf("expected %q to be reported, got %v", failed, err)
+		}
+	}
+
+	withKubectl(t, true)
+	c.IngressClass = "nginx"
+	if err := c.preflight(context.Background(), readyClientset(readyObjects())); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	// the other transports copy with the exec API
+	withKubectl(t, false)
+	c.Kube.CopyTransport = "auto"
+	if err := c.preflight(context.Background(), readyClientset(readyObjects())); err != nil {
+		t.Errorf("expected no error without kubectl, got %v", err)
+	}
+}
+
+func TestCheckAccess(t *testing.T) {
+	c := testDeploymentCommand()
+	c.Ingress = true
+
+	err := checkAccess(context.Background(), readyClientset(nil), "default", c.permissions())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	err = checkAccess(context.Background(), readyClientset(nil, "services", "pods/exec"), "default", c.permissions())
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	for _, missing := range []string{"create pods/exec", "get services", "create services", "update services"} {
+		if !strings.Contains(err.Error(), missing) {
+			t.Errorf("expected %q to be reported, got %v", missing, err)
+		}
+	}
+
+	c.NoCopy = true
+	c.Service = false
+	err = checkAccess(context.Background(), readyClientset(nil, "services", "pods/exec"), "default", c.permissions())
+	if err != nil {
+		t.Errorf("expected no error without copy nor service, got %v", err)
+	}
+
+	// the rollout is still waited for by watching the pods
+	err = checkAccess(context.Background(), readyClientset(nil, "pods"), "default", c.permissions())
+	if err == nil {
+		t.Fatalf("expected an error without access to the pods")
+	}
+	for _, missing := range []string{"list pods", "watch pods", "get pods"} {
+		if !strings.Contains(err.Error(), missing) {
+			t.Errorf("expected %q to be reported without copy, got %v", missing, err)
+		}
+	}
+	c.NoCopy, c.GitRepo = false, "https://github.com/org/repo.git"
+	err = checkAccess(context.Background(), readyClientset(nil, "pods"), "default", c.permissions())
+	if err == nil || !strings.Contains(err.Error(), "list pods") {
+		t.Errorf("expected the pods to be required with a git repository, got %v", err)
+	}
+}
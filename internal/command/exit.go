@@ -0,0 +1,123 @@
+package command
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Exit codes of k8run, so scripts can tell why it failed. They're documented in the README.
+const (
+	ExitFailure    = 1
+	ExitValidation = 2
+	ExitAuth       = 3
+	ExitTimeout    = 4
+	ExitConflict   = 5
+	ExitAppCrash   = 6
+)
+
+// The kinds of the errors returned by the commands, matched with errors.Is.
+var (
+	// ErrValidation is the kind of the errors caused by invalid flags or files.
+	ErrValidation = errors.New("validation")
+	// ErrAuth is the kind of the errors caused by missing credentials or permissions.
+	ErrAuth = errors.New("auth")
+	// ErrTimeout is the kind of the errors caused by the command running out of time.
+	ErrTimeout = errors.New("timeout")
+	// ErrConflict is the kind of the errors caused by someone else owning or locking the app.
+	ErrConflict = errors.New("conflict")
+	// ErrAppCrash is the kind of the errors caused by the app crashing instead of becoming ready.
+	ErrAppCrash = errors.New("app-crash")
+)
+
+// exitCodes maps each kind of error to its exit code, the first kind an error matches wins.
+var exitCodes = []struct {
+	kind error
+	code int
+}{
+	{ErrValidation, ExitValidation},
+	{ErrAuth, ExitAuth},
+	{ErrConflict, ExitConflict},
+	{ErrAppCrash, ExitAppCrash},
+	{ErrTimeout, ExitTimeout},
+}
+
+// kindError is an error of a known kind. It reads as the error it wraps, so messages don't change.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.err, e.kind}
+}
+
+// withKind marks the error as being of the given kind.
+func withKind(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// Invalid marks the error as a validation error, eg: the error of Validate.
+func Invalid(err error) error {
+	return withKind(ErrValidation, err)
+}
+
+// timedOut marks the error returned while running with the given context as a timeout when the context expired,
+// unless it already has a kind.
+func timedOut(ctx context.Context, err error) error {
+	if err == nil || ExitCode(err) != ExitFailure || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return withKind(ErrTimeout, err)
+}
+
+// kindOf returns the kind of the error: the one it was marked with or, for the errors of the cluster wherever they
+// happen, auth for a 401 or 403 and conflict for a 409 or a resource not created by k8run. It's nil for errors of
+// no known kind.
+func kindOf(err error) error {
+	for _, exitCode := range exitCodes {
+		if errors.Is(err, exitCode.kind) {
+			return exitCode.kind
+		}
+	}
+
+	switch {
+	case k8serrors.IsUnauthorized(err) || k8serrors.IsForbidden(err):
+		return ErrAuth
+	case k8serrors.IsConflict(err) || errors.Is(err, k8s.ErrNotCreatedByK8run):
+		return ErrConflict
+	}
+	return nil
+}
+
+// ExitCode returns the code k8run exits with for the given error.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	kind := kindOf(err)
+	for _, exitCode := range exitCodes {
+		if exitCode.kind == kind {
+			return exitCode.code
+		}
+	}
+	return ExitFailure
+}
+
+// errorKind returns the name of the kind of the error, eg: 'timeout'. It's empty for errors of no known kind.
+func errorKind(err error) string {
+	if kind := kindOf(err); kind != nil {
+		return kind.Error()
+	}
+	return ""
+}
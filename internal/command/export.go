@@ -0,0 +1,191 @@
+package command
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/export"
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/kube"
+	"github.com/lucasvmiguel/k8run/internal/logging"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewExportCommandParams represents the parameters to create a new export command.
+type NewExportCommandParams struct {
+	Name            string
+	Namespace       string
+	Format          string
+	Out             string
+	Dockerfile      bool
+	DockerfileImage string
+	Copy            string
+	Timeout         time.Duration
+	Render          *DeploymentCommand
+	Kube            kube.Config
+}
+
+// ExportCommand represents a command to export an application as plain YAML, a Kustomize base or a Helm chart.
+type ExportCommand struct {
+	Name            string
+	Namespace       string
+	Format          string
+	Out             string
+	Dockerfile      bool
+	DockerfileImage string
+	Copy            string
+	Timeout         time.Duration
+	// Render, when set, renders the resources from the deployment flags instead of reading the live ones.
+	Render *DeploymentCommand
+	// Kube is how to reach the cluster.
+	Kube kube.Config
+}
+
+// NewExportCommand creates a new export command.
+func NewExportCommand(params NewExportCommandParams) *ExportCommand {
+	return &ExportCommand{
+		Name:            params.Name,
+		Namespace:       params.Namespace,
+		Format:          params.Format,
+		Out:             params.Out,
+		Dockerfile:      params.Dockerfile,
+		DockerfileImage: params.DockerfileImage,
+		Copy:            params.Copy,
+		Timeout:         params.Timeout,
+		Render:          params.Render,
+		Kube:            params.Kube,
+	}
+}
+
+// Validate validates the parameters of the export command.
+func (c *ExportCommand) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("Name is required")
+	}
+	if !slices.Contains(export.Formats, export.Format(c.Format)) {
+		return fmt.Errorf("Format must be one of %v", export.Formats)
+	}
+	if c.Out == "" {
+		return fmt.Errorf("Out is required")
+	}
+	if c.Timeout < 10*time.Second {
+		return fmt.Errorf("Timeout must be greater than 10s")
+	}
+	if c.Dockerfile && c.copySource() == "" {
+		return fmt.Errorf("Copy is required to generate a Dockerfile")
+	}
+	if c.Render != nil {
+		return c.Render.Validate()
+	}
+	return nil
+}
+
+// Run runs the export command.
+func (c *ExportCommand) Run(ctx context.Context) error {
+	logging.FromContext(ctx).Info("Starting export...")
+	namespace, err := resolveNamespace(c.Kube, c.Namespace)
+	if err != nil {
+		return err
+	}
+	c.Namespace = namespace
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	params := export.WriteParams{
+		Name:      c.Name,
+		Namespace: c.Namespace,
+		Format:    export.Format(c.Format),
+		Dir:       c.Out,
+	}
+
+	if c.Render != nil {
+		c.Render.Name = c.Name
+		c.Render.Namespace = c.Namespace
+		if !c.Render.NoCopy {
+			params.PVC = k8s.BuildPVC(c.Render.pvcParams())
+		}
+		params.Deployment = k8s.BuildDeployment(c.Render.deploymentParams(""))
+		if c.Render.Service {
+			params.Service = k8s.BuildService(c.Render.serviceParams(""))
+		}
+		if c.Render.Ingress {
+			params.Ingress = k8s.BuildIngress(c.Render.ingressParams())
+		}
+	} else {
+		clientset, err := newClientset(c.Kube)
+		if err != nil {
+			return err
+		}
+
+		if err := c.readLive(ctx, clientset, &params); err != nil {
+			return err
+		}
+	}
+
+	if c.Dockerfile {
+		params.Dockerfile = &export.Dockerfile{
+			Image:  cmp.Or(c.DockerfileImage, fmt.Sprintf("%s:latest", c.Name)),
+			Source: c.copySource(),
+		}
+	}
+
+	if err := export.Write(params); err != nil {
+		return fmt.Errorf("Failed to export: %w", err)
+	}
+
+	logging.FromContext(ctx).With("dir", c.Out, "format", c.Format).Info("Export finished!")
+
+	return nil
+}
+
+func (c *ExportCommand) readLive(ctx context.Context, clientset kubernetes.Interface, params *export.WriteParams) error {
+	get := k8s.GetParams{Name: c.Name, Namespace: c.Namespace}
+
+	deployment, err := k8s.GetDeployment(ctx, clientset, get)
+	if err != nil {
+		return fmt.Errorf("Failed to get deployment: %w", err)
+	}
+	if deployment.Labels[k8s.LabelNameCreatedBy] != k8s.LabelValueCreatedBy {
+		return fmt.Errorf("Deployment %q has not been created by k8run", c.Name)
+	}
+	params.Deployment = deployment
+
+	pvc, err := k8s.GetPVC(ctx, clientset, k8s.GetParams{Name: pvcName(c.Name), Namespace: c.Namespace})
+	if err != nil && !errors.Is(err, k8s.ErrResourceNotFound) {
+		return fmt.Errorf("Failed to get PVC: %w", err)
+	}
+	if pvc != nil && pvc.Labels[k8s.LabelNameCreatedBy] == k8s.LabelValueCreatedBy {
+		params.PVC = pvc
+	}
+
+	service, err := k8s.GetService(ctx, clientset, get)
+	if err != nil && !errors.Is(err, k8s.ErrResourceNotFound) {
+		return fmt.Errorf("Failed to get service: %w", err)
+	}
+	if service != nil && service.Labels[k8s.LabelNameCreatedBy] == k8s.LabelValueCreatedBy {
+		params.Service = service
+	}
+
+	ingress, err := k8s.GetIngress(ctx, clientset, get)
+	if err != nil && !errors.Is(err, k8s.ErrResourceNotFound) {
+		return fmt.Errorf("Failed to get ingress: %w", err)
+	}
+	if ingress != nil && ingress.Labels[k8s.LabelNameCreatedBy] == k8s.LabelValueCreatedBy {
+		params.Ingress = ingress
+	}
+
+	return nil
+}
+
+func (c *ExportCommand) copySource() string {
+	if c.Render != nil {
+		return cmp.Or(c.Copy, c.Render.source())
+	}
+	return c.Copy
+}
@@ -0,0 +1,91 @@
+package command_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/command"
+)
+
+func TestExportCommand_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		command *command.ExportCommand
+		wantErr bool
+	}{
+		{
+			name: "valid command",
+			command: &command.ExportCommand{
+				Name:    "test",
+				Format:  "helm",
+				Out:     "./deploy",
+				Timeout: 15 * time.Second,
+			},
+			wantErr: false,
+		},
+		{
+			name: "missing name",
+			command: &command.ExportCommand{
+				Format:  "yaml",
+				Out:     "./deploy",
+				Timeout: 15 * time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid format",
+			command: &command.ExportCommand{
+				Name:    "test",
+				Format:  "jsonnet",
+				Out:     "./deploy",
+				Timeout: 15 * time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing out",
+			command: &command.ExportCommand{
+				Name:    "test",
+				Format:  "yaml",
+				Timeout: 15 * time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "dockerfile without copy",
+			command: &command.ExportCommand{
+				Name:       "test",
+				Format:     "yaml",
+				Out:        "./deploy",
+				Dockerfile: true,
+				Timeout:    15 * time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid render flags",
+			command: &command.ExportCommand{
+				Name:    "test",
+				Format:  "yaml",
+				Out:     "./deploy",
+				Timeout: 15 * time.Second,
+				Render: &command.DeploymentCommand{
+					Name:     "test",
+					Copy:     "/test-folder",
+					Replicas: 1,
+					Timeout:  20 * time.Second,
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.command.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
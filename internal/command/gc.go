@@ -0,0 +1,574 @@
+package command
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/kube"
+	"github.com/lucasvmiguel/k8run/internal/logging"
+	"github.com/lucasvmiguel/k8run/internal/plan"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// orphanGrace is how old a resource must be to be collected as an orphan, so the resources of a deployment that
+// is still running aren't collected before its deployment exists.
+const orphanGrace = 10 * time.Minute
+
+// staleJobAge is how old a job still running must be to be collected as an orphan. Builds and releases run for the
+// timeout of their run, a job running for longer was left behind.
+const staleJobAge = time.Hour
+
+// gcOwner is who the gc command locks the apps as.
+const gcOwner = "k8run gc"
+
+// NewGCCommandParams represents the parameters to create a new gc command.
+type NewGCCommandParams struct {
+	Namespace     string
+	AllNamespaces bool
+	Timeout       time.Duration
+	Kube          kube.Config
+}
+
+// GCCommand represents a command to destroy the expired apps and the orphaned resources created by k8run: the
+// resources of apps that don't exist anymore, the stale locks and the namespaces of isolated apps left empty.
+type GCCommand struct {
+	Namespace string
+	// AllNamespaces collects in every namespace instead of only Namespace.
+	AllNamespaces bool
+	// Timeout is the timeout to destroy each app.
+	Timeout time.Duration
+	// Kube is how to reach the cluster.
+	Kube kube.Config
+
+	// now returns the time apps expire against, it's replaced in tests.
+	now func() time.Time
+}
+
+// NewGCCommand creates a new gc command.
+func NewGCCommand(params NewGCCommandParams) *GCCommand {
+	return &GCCommand{
+		Namespace:     params.Namespace,
+		AllNamespaces: params.AllNamespaces,
+		Timeout:       params.Timeout,
+		Kube:          params.Kube,
+		now:           time.Now,
+	}
+}
+
+// Validate validates the parameters of the gc command.
+func (c *GCCommand) Validate() error {
+	if c.AllNamespaces && c.Namespace != "" {
+		return fmt.Errorf("AllNamespaces can't be used with Namespace")
+	}
+	if c.Timeout < 10*time.Second {
+		return fmt.Errorf("Timeout must be greater than 10s")
+	}
+	return nil
+}
+
+// object is a live resource.
+type object interface {
+	metav1.Object
+	runtime.Object
+}
+
+// orphan is a resource created by k8run whose app doesn't exist anymore, or the lock of an app nobody holds.
+type orphan struct {
+	kind string
+	app  string
+	live object
+}
+
+// lockNamespace returns the namespace of the lease locking the app of the orphan.
+func (o orphan) lockNamespace() string {
+	if o.kind == "Namespace" {
+		return o.live.GetName()
+	}
+	return o.live.GetNamespace()
+}
+
+// note returns why the orphan is collected.
+func (o orphan) note() string {
+	if o.kind == "Lease" {
+		return fmt.Sprintf("stale lock of app %s", o.app)
+	}
+	return fmt.Sprintf("orphaned, app %s doesn't exist", o.app)
+}
+
+// garbage is what the gc command collects.
+type garbage struct {
+	expired []*DestroyCommand
+	orphans []orphan
+}
+
+// Plan returns the resources the gc command will delete.
+func (c *GCCommand) Plan(ctx context.Context) (*plan.Plan, error) {
+	clientset, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := c.collect(ctx, clientset)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &plan.Plan{}
+	for _, destroy := range found.expired {
+		destroyPlan, err := destroy.plan(ctx, clientset)
+		if err != nil {
+			return nil, fmt.Errorf("App %q: %w", destroy.Name, err)
+		}
+		for i := range destroyPlan.Changes {
+			destroyPlan.Changes[i].Note = cmp.Or(destroyPlan.Changes[i].Note, fmt.Sprintf("app %s expired", destroy.Name))
+		}
+		p.Merge(destroyPlan)
+	}
+
+	for _, o := range found.orphans {
+		change, err := plan.Remove(o.kind, o.live)
+		if err != nil {
+			return nil, err
+		}
+		change.Note = o.note()
+		p.Add(change)
+	}
+
+	return p, nil
+}
+
+// Run destroys the expired apps and deletes the orphaned resources.
+func (c *GCCommand) Run(ctx context.Context) (err error) {
+	logging.FromContext(ctx).Info("Starting gc...")
+	defer func() { err = timedOut(ctx, err) }()
+
+	clientset, err := c.connect()
+	if err != nil {
+		return err
+	}
+
+	found, err := c.collect(ctx, clientset)
+	if err != nil {
+		return err
+	}
+
+	// the failures keep their kind, eg: an app that timed out
+	failed := []error{}
+	for _, destroy := range found.expired {
+		logging.FromContext(ctx).With("name", destroy.Name, "namespace", destroy.Namespace).Info("Destroying expired app...")
+		if err := destroy.Run(ctx); err != nil {
+			failed = append(failed, fmt.Errorf("  app %s/%s: %w", destroy.Namespace, destroy.Name, err))
+		}
+	}
+
+	for _, orphans := range groupOrphans(found.orphans) {
+		failed = append(failed, c.deleteOrphans(ctx, clientset, orphans)...)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("Failed to collect:\n%w", errors.Join(failed...))
+	}
+
+	logging.FromContext(ctx).With("apps", len(found.expired), "orphans", len(found.orphans)).Info("GC finished!")
+	return nil
+}
+
+func (c *GCCommand) connect() (kubernetes.Interface, error) {
+	if !c.AllNamespaces {
+		namespace, err := resolveNamespace(c.Kube, c.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		c.Namespace = namespace
+	}
+
+	return newClientset(c.Kube)
+}
+
+// collect finds the expired apps and the orphaned resources.
+func (c *GCCommand) collect(ctx context.Context, clientset kubernetes.Interface) (_ *garbage, err error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	defer func() { err = timedOut(ctx, err) }()
+
+	list := k8s.ListParams{Namespace: c.Namespace}
+	if c.AllNamespaces {
+		list.Namespace = ""
+	}
+
+	deployments, err := k8s.ListDeployments(ctx, clientset, list)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list deployments: %w", err)
+	}
+
+	found := &garbage{}
+	apps := map[string]bool{}
+	for _, app := range groupApps(deployments) {
+		apps[app.Namespace+"/"+app.Name] = true
+
+		if app.ExpiresAt.IsZero() || app.ExpiresAt.After(c.now()) {
+			continue
+		}
+		found.expired = append(found.expired, &DestroyCommand{
+			Name:      app.Name,
+			Namespace: app.Namespace,
+			Timeout:   c.Timeout,
+			// expired apps are destroyed whoever deployed them
+			Takeover: true,
+			Kube:     c.Kube,
+		})
+	}
+
+	candidates := []orphan{}
+	services, err := k8s.ListServices(ctx, clientset, list)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list services: %w", err)
+	}
+	for i := range services {
+		candidates = append(candidates, orphan{kind: "Service", app: services[i].Name, live: &services[i]})
+	}
+
+	ingresses, err := k8s.ListIngresses(ctx, clientset, list)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list ingresses: %w", err)
+	}
+	for i := range ingresses {
+		candidates = append(candidates, orphan{kind: "Ingress", app: ingresses[i].Name, live: &ingresses[i]})
+	}
+
+	pvcs, err := k8s.ListPVCs(ctx, clientset, list)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list PVCs: %w", err)
+	}
+	for i := range pvcs {
+		app := strings.TrimSuffix(pvcs[i].Name, pvcName(""))
+		candidates = append(candidates, orphan{kind: "PersistentVolumeClaim", app: app, live: &pvcs[i]})
+	}
+
+	// the builds and the release jobs of Procfile apps
+	jobs, err := k8s.ListJobs(ctx, clientset, list)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list jobs: %w", err)
+	}
+	for i := range jobs {
+		if jobs[i].Status.Active > 0 && c.now().Sub(jobs[i].CreationTimestamp.Time) < staleJobAge {
+			continue
+		}
+		app := cmp.Or(jobs[i].Labels[k8s.LabelNameApp], jobs[i].Name)
+		candidates = append(candidates, orphan{kind: "Job", app: app, live: &jobs[i]})
+	}
+
+	// the apps locked by a run in progress are left alone, the locks nobody holds are collected whether their app
+	// exists or not
+	locked := map[string]bool{}
+	leases, err := k8s.ListLeases(ctx, clientset, list)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list leases: %w", err)
+	}
+	for i := range leases {
+		app := cmp.Or(leases[i].Labels[k8s.LabelNameApp], strings.TrimPrefix(leases[i].Name, lockName("")))
+		if k8s.LeaseHeld(&leases[i], c.now()) {
+			locked[leases[i].Namespace+"/"+app] = true
+			continue
+		}
+		found.orphans = append(found.orphans, orphan{kind: "Lease", app: app, live: &leases[i]})
+	}
+
+	// the namespaces of isolated apps, only seen from every namespace
+	if c.AllNamespaces {
+		namespaces, err := k8s.ListNamespaces(ctx, clientset, list)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list namespaces: %w", err)
+		}
+		inUse := map[string]bool{}
+		for key := range apps {
+			inUse[strings.SplitN(key, "/", 2)[0]] = true
+		}
+		for i := range namespaces {
+			if inUse[namespaces[i].Name] {
+				continue
+			}
+			app := cmp.Or(namespaces[i].Labels[k8s.LabelNameApp], strings.TrimPrefix(namespaces[i].Name, isolatedNamespace("")))
+			candidates = append(candidates, orphan{kind: "Namespace", app: app, live: &namespaces[i]})
+		}
+	}
+
+	for _, candidate := range candidates {
+		if candidate.kind != "Namespace" && apps[candidate.live.GetNamespace()+"/"+candidate.app] {
+			continue
+		}
+		if locked[candidate.lockNamespace()+"/"+candidate.app] {
+			continue
+		}
+		if c.now().Sub(candidate.live.GetCreationTimestamp().Time) < orphanGrace {
+			continue
+		}
+		found.orphans = append(found.orphans, candidate)
+	}
+
+	return found, nil
+}
+
+// groupOrphans groups the orphans by app, in the order they're found.
+func groupOrphans(orphans []orphan) [][]orphan {
+	groups := [][]orphan{}
+	index := map[string]int{}
+	for _, o := range orphans {
+		key := o.lockNamespace() + "/" + o.app
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], o)
+	}
+	return groups
+}
+
+// deleteOrphans deletes the orphans of an app while holding its lock, so the resources of a run that started since
+// they were found, eg: the PVC of a Procfile app before its deployments exist, aren't deleted. The app is skipped
+// when a run holds its lock or deployed it in the meantime.
+func (c *GCCommand) deleteOrphans(ctx context.Context, clientset kubernetes.Interface, orphans []orphan) (failed []error) {
+	app, namespace := orphans[0].app, orphans[0].lockNamespace()
+	locked, unlock, err := lock(ctx, clientset, lockParams{App: app, Namespace: namespace, Owner: gcOwner})
+	if errors.Is(err, ErrConflict) {
+		logging.FromContext(ctx).With("name", app, "namespace", namespace, "error", err).Info("Skipping locked app")
+		return nil
+	}
+	if err != nil {
+		return []error{fmt.Errorf("  app %s/%s: %w", namespace, app, err)}
+	}
+	// a stale lock, taken over by lock, is deleted once released
+	defer unlock()
+	ctx = locked
+
+	// a stale lock is collected even when its app exists, its namespace only while no app is deployed in it
+	onlyLock := !slices.ContainsFunc(orphans, func(o orphan) bool { return o.kind != "Lease" })
+	isolated := slices.ContainsFunc(orphans, func(o orphan) bool { return o.kind == "Namespace" })
+	if !onlyLock {
+		deployments, err := k8s.ListDeployments(ctx, clientset, k8s.ListParams{Namespace: namespace})
+		if err != nil {
+			return []error{fmt.Errorf("  app %s/%s: %w", namespace, app, err)}
+		}
+		for _, deployed := range groupApps(deployments) {
+			if deployed.Name == app || isolated {
+				logging.FromContext(ctx).With("name", app, "namespace", namespace).Info("Skipping app deployed since")
+				return nil
+			}
+		}
+	}
+
+	for _, o := range orphans {
+		err := lockLost(ctx, c.deleteOrphan(ctx, clientset, o))
+		if err != nil && !errors.Is(err, k8s.ErrResourceNotFound) {
+			failed = append(failed, fmt.Errorf("  %s %s/%s: %w", o.kind, o.live.GetNamespace(), o.live.GetName(), err))
+		}
+	}
+	return failed
+}
+
+func (c *GCCommand) deleteOrphan(ctx context.Context, clientset kubernetes.Interface, o orphan) error {
+	name, namespace := o.live.GetName(), o.live.GetNamespace()
+	switch o.kind {
+	case "Service":
+		return k8s.DeleteService(ctx, clientset, k8s.DeleteServiceParams{Name: name, Namespace: namespace})
+	case "Ingress":
+		return k8s.DeleteIngress(ctx, clientset, k8s.DeleteIngressParams{Name: name, Namespace: namespace})
+	case "PersistentVolumeClaim":
+		return k8s.DeletePVC(ctx, clientset, k8s.DeletePVCParams{Name: name, Namespace: namespace})
+	case "Job":
+		return k8s.DeleteJob(ctx, clientset, k8s.DeleteJobParams{Name: name, Namespace: namespace})
+	case "Namespace":
+		return k8s.DeleteNamespace(ctx, clientset, k8s.DeleteNamespaceParams{Name: name})
+	case "Lease":
+		// released by deleteOrphans, which holds it
+		return nil
+	}
+	return fmt.Errorf("unknown kind %s", o.kind)
+}
+
+const (
+	// gcName is the name of the resources running 'k8run gc' in the cluster.
+	gcName = "k8run-gc"
+	// gcImage is the image the released k8run binary is downloaded into, when no image is given.
+	gcImage = "alpine:3"
+	// gcRelease is the URL of the released k8run binaries.
+	gcRelease = "https://github.com/lucasvmiguel/k8run/releases/download"
+)
+
+// NewGCInstallCommandParams represents the parameters to create a new gc install command.
+type NewGCInstallCommandParams struct {
+	Namespace     string
+	AllNamespaces bool
+	Schedule      string
+	Image         string
+	Version       string
+	Uninstall     bool
+	Timeout       time.Duration
+	Kube          kube.Config
+}
+
+// GCInstallCommand represents a command to install (or uninstall, when Uninstall is set) a CronJob running
+// 'k8run gc' in the cluster, with a service account only allowed to find and destroy apps.
+type GCInstallCommand struct {
+	// Namespace is where the CronJob runs and, without AllNamespaces, the only namespace it collects.
+	Namespace string
+	// AllNamespaces lets the CronJob collect in every namespace, with a cluster role instead of a role.
+	AllNamespaces bool
+	// Schedule is the cron schedule of the CronJob. eg: '0 * * * *'
+	Schedule string
+	// Image is an image with the k8run binary on its PATH. By default, the released binary of Version is downloaded.
+	Image   string
+	Version string
+	// Uninstall deletes the CronJob and its service account, role and binding.
+	Uninstall bool
+	Timeout   time.Duration
+	// Kube is how to reach the cluster.
+	Kube kube.Config
+}
+
+// NewGCInstallCommand creates a new gc install command.
+func NewGCInstallCommand(params NewGCInstallCommandParams) *GCInstallCommand {
+	return &GCInstallCommand{
+		Namespace:     params.Namespace,
+		AllNamespaces: params.AllNamespaces,
+		Schedule:      params.Schedule,
+		Image:         params.Image,
+		Version:       params.Version,
+		Uninstall:     params.Uninstall,
+		Timeout:       params.Timeout,
+		Kube:          params.Kube,
+	}
+}
+
+// Validate validates the parameters of the gc install command.
+func (c *GCInstallCommand) Validate() error {
+	if !c.Uninstall {
+		if fields := strings.Fields(c.Schedule); len(fields) != 5 && !strings.HasPrefix(c.Schedule, "@") {
+			return fmt.Errorf("Schedule must be a cron schedule. eg: '0 * * * *' or '@hourly'")
+		}
+		if c.Image == "" && c.Version == "" {
+			return fmt.Errorf("Version is required without Image")
+		}
+	}
+	if c.Timeout < 10*time.Second {
+		return fmt.Errorf("Timeout must be greater than 10s")
+	}
+	return nil
+}
+
+// Plan returns the changes the gc install command will make to the cluster.
+func (c *GCInstallCommand) Plan(ctx context.Context) (*plan.Plan, error) {
+	clientset, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	live, err := k8s.GetGC(ctx, clientset, c.params())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the gc CronJob: %w", err)
+	}
+
+	p := &plan.Plan{}
+	for i, desired := range k8s.BuildGC(c.params()) {
+		var missing error
+		if live[i] == nil {
+			missing = k8s.ErrResourceNotFound
+		}
+
+		if c.Uninstall {
+			change, err := planDelete(desired.GetObjectKind().GroupVersionKind().Kind, live[i], missing)
+			if err != nil {
+				return nil, err
+			}
+			if change != nil {
+				p.Add(*change)
+			}
+			continue
+		}
+
+		change, err := planApply(desired, live[i], missing)
+		if err != nil {
+			return nil, err
+		}
+		p.Add(change)
+	}
+
+	return p, nil
+}
+
+// Run installs or uninstalls the gc CronJob.
+func (c *GCInstallCommand) Run(ctx context.Context) error {
+	clientset, err := c.connect()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	if c.Uninstall {
+		if err := k8s.DeleteGC(ctx, clientset, c.params()); err != nil {
+			return fmt.Errorf("Failed to uninstall the gc CronJob: %w", err)
+		}
+		return nil
+	}
+
+	if err := k8s.ApplyGC(ctx, clientset, c.params()); err != nil {
+		return fmt.Errorf("Failed to install the gc CronJob: %w", err)
+	}
+	return nil
+}
+
+func (c *GCInstallCommand) connect() (kubernetes.Interface, error) {
+	namespace, err := resolveNamespace(c.Kube, c.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	c.Namespace = namespace
+
+	return newClientset(c.Kube)
+}
+
+func (c *GCInstallCommand) params() k8s.GCParams {
+	args := []string{"gc", "--yes", "--namespace", c.Namespace}
+	if c.AllNamespaces {
+		args = []string{"gc", "--yes", "--all-namespaces"}
+	}
+
+	params := k8s.GCParams{
+		Name:          gcName,
+		Namespace:     c.Namespace,
+		Schedule:      c.Schedule,
+		Image:         c.Image,
+		Command:       append([]string{"k8run"}, args...),
+		AllNamespaces: c.AllNamespaces,
+	}
+
+	if c.Image == "" {
+		// the released binary is downloaded, as there is no k8run image
+		params.Image = gcImage
+		params.Command = []string{"sh", "-c", strings.Join([]string{
+			"set -e",
+			`case "$(uname -m)" in x86_64) arch=amd64 ;; aarch64|arm64) arch=arm64 ;; *) echo "unsupported architecture $(uname -m)"; exit 1 ;; esac`,
+			fmt.Sprintf("wget -qO /tmp/k8run %s/v%s/k8run-linux-$arch", gcRelease, c.Version),
+			"chmod +x /tmp/k8run",
+			"exec /tmp/k8run " + strings.Join(args, " "),
+		}, "\n")}
+	}
+
+	return params
+}
@@ -0,0 +1,255 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/plan"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGCCommand_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		command *GCCommand
+		wantErr bool
+	}{
+		{name: "valid command", command: &GCCommand{Namespace: "default", Timeout: time.Minute}},
+		{name: "all namespaces", command: &GCCommand{AllNamespaces: true, Timeout: time.Minute}},
+		{name: "all namespaces with namespace", command: &GCCommand{Namespace: "default", AllNamespaces: true, Timeout: time.Minute}, wantErr: true},
+		{name: "timeout too short", command: &GCCommand{Timeout: time.Second}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.command.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGCCommand_Collect(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	created := metav1.NewTime(now.Add(-time.Hour))
+	labels := func(app string) map[string]string {
+		l := map[string]string{k8s.LabelNameCreatedBy: k8s.LabelValueCreatedBy}
+		if app != "" {
+			l[k8s.LabelNameApp] = app
+		}
+		return l
+	}
+	deployment := func(name, namespace, app string, expiresAt time.Time) *appsv1.Deployment {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels(app)}}
+		if !expiresAt.IsZero() {
+			d.Annotations = map[string]string{k8s.AnnotationNameExpiresAt: expiresAt.Format(time.RFC3339)}
+		}
+		return d
+	}
+	meta := func(name, namespace string, created metav1.Time) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels(""), CreationTimestamp: created}
+	}
+
+	objects := []runtime.Object{
+		// expired
+		deployment("old", "default", "", now.Add(-time.Minute)),
+		&corev1.Service{ObjectMeta: meta("old", "default", created)},
+		// expired Procfile app, collected once
+		deployment("worker-app", "team-a", "worker-app", now.Add(-time.Minute)),
+		deployment("worker-app-jobs", "team-a", "worker-app", now.Add(-time.Minute)),
+		// not expired yet, or without TTL
+		deployment("fresh", "default", "", now.Add(time.Hour)),
+		deployment("forever", "default", "", time.Time{}),
+		&corev1.PersistentVolumeClaim{ObjectMeta: meta("forever-app-pvc", "default", created)},
+		// orphans, except the one that may belong to a deployment in progress
+		&corev1.PersistentVolumeClaim{ObjectMeta: meta("gone-app-pvc", "default", created)},
+		&corev1.Service{ObjectMeta: meta("gone", "team-a", created)},
+		&corev1.Service{ObjectMeta: meta("starting", "default", metav1.NewTime(now.Add(-time.Minute)))},
+		// orphaned jobs, except a build still running
+		&batchv1.Job{ObjectMeta: meta("gone-build-x1y2z", "default", created)},
+		&batchv1.Job{ObjectMeta: meta("building-build-x1y2z", "default", metav1.NewTime(now.Add(-30*time.Minute))), Status: batchv1.JobStatus{Active: 1}},
+		// a stale lock, and the PVC of a Procfile app locked by a deployment in progress
+		k8s.BuildLease(k8s.LeaseParams{Name: "k8run-forever", Namespace: "default", App: "forever", Holder: "jane", Duration: time.Minute}, now.Add(-time.Hour)),
+		k8s.BuildLease(k8s.LeaseParams{Name: "k8run-web", Namespace: "default", App: "web", Holder: "john", Duration: time.Minute}, now),
+		&corev1.PersistentVolumeClaim{ObjectMeta: meta("web-app-pvc", "default", created)},
+		// the namespace of an isolated app destroyed halfway, and one still used
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "k8run-sandbox", Labels: labels("sandbox"), CreationTimestamp: created}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: labels("worker-app"), CreationTimestamp: created}},
+	}
+
+	tests := []struct {
+		name        string
+		command     *GCCommand
+		wantExpired []string
+		wantOrphans []string
+	}{
+		{
+			name:        "namespace",
+			command:     &GCCommand{Namespace: "default", Timeout: time.Minute},
+			wantExpired: []string{"default/old"},
+			wantOrphans: []string{"default/k8run-forever", "default/gone-app-pvc", "default/gone-build-x1y2z"},
+		},
+		{
+			name:        "all namespaces",
+			command:     &GCCommand{AllNamespaces: true, Timeout: time.Minute},
+			wantExpired: []string{"default/old", "team-a/worker-app"},
+			wantOrphans: []string{"default/k8run-forever", "team-a/gone", "default/gone-app-pvc", "default/gone-build-x1y2z", "/k8run-sandbox"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.command.now = func() time.Time { return now }
+			found, err := tt.command.collect(context.Background(), fake.NewSimpleClientset(objects...))
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			expired := []string{}
+			for _, destroy := range found.expired {
+				expired = append(expired, destroy.Namespace+"/"+destroy.Name)
+			}
+			orphans := []string{}
+			for _, o := range found.orphans {
+				orphans = append(orphans, o.live.GetNamespace()+"/"+o.live.GetName())
+			}
+
+			if strings.Join(expired, ",") != strings.Join(tt.wantExpired, ",") {
+				t.Errorf("expected expired apps %v, got %v", tt.wantExpired, expired)
+			}
+			if strings.Join(orphans, ",") != strings.Join(tt.wantOrphans, ",") {
+				t.Errorf("expected orphans %v, got %v", tt.wantOrphans, orphans)
+			}
+		})
+	}
+}
+
+func TestGCCommand_DeleteOrphans(t *testing.T) {
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+		Name: "web-app-pvc", Namespace: "default", Labels: map[string]string{k8s.LabelNameCreatedBy: k8s.LabelValueCreatedBy},
+	}}
+	lease := func(renewedAt time.Time) *coordinationv1.Lease {
+		return k8s.BuildLease(k8s.LeaseParams{Name: "k8run-web", Namespace: "default", App: "web", Holder: "jane", Duration: 30 * time.Second}, renewedAt)
+	}
+
+	tests := []struct {
+		name       string
+		lease      *coordinationv1.Lease
+		deployment bool
+		wantPVC    bool
+	}{
+		{name: "deleted", wantPVC: false},
+		{name: "deleted once the stale lock is taken over", lease: lease(time.Now().Add(-time.Hour)), wantPVC: false},
+		{name: "kept while a run holds the lock", lease: lease(time.Now()), wantPVC: true},
+		{name: "kept when the app was deployed since", deployment: true, wantPVC: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := []runtime.Object{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, pvc.DeepCopy()}
+			if tt.lease != nil {
+				objects = append(objects, tt.lease)
+			}
+			if tt.deployment {
+				objects = append(objects, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+					Name: "web", Namespace: "default", Labels: map[string]string{k8s.LabelNameCreatedBy: k8s.LabelValueCreatedBy},
+				}})
+			}
+			clientset := fake.NewSimpleClientset(objects...)
+
+			c := &GCCommand{Namespace: "default", Timeout: time.Minute}
+			failed := c.deleteOrphans(context.Background(), clientset, []orphan{{kind: "PersistentVolumeClaim", app: "web", live: pvc}})
+			if len(failed) > 0 {
+				t.Fatalf("expected no error, got %v", failed)
+			}
+
+			_, err := clientset.CoreV1().PersistentVolumeClaims("default").Get(context.Background(), pvc.Name, metav1.GetOptions{})
+			if exists := err == nil; exists != tt.wantPVC {
+				t.Errorf("expected the PVC to exist: %v, got error %v", tt.wantPVC, err)
+			}
+			leases, _ := clientset.CoordinationV1().Leases("default").List(context.Background(), metav1.ListOptions{})
+			if wantLeases := map[bool]int{true: 1, false: 0}[tt.lease != nil && tt.wantPVC]; len(leases.Items) != wantLeases {
+				t.Errorf("expected %d lease left, got %d", wantLeases, len(leases.Items))
+			}
+		})
+	}
+}
+
+func TestGCInstallCommand_Params(t *testing.T) {
+	c := &GCInstallCommand{Namespace: "k8run-system", Schedule: "@hourly", Version: "0.1.0", Timeout: time.Minute}
+	params := c.params()
+	if params.Image != gcImage || !strings.Contains(params.Command[2], "/v0.1.0/k8run-linux-$arch") ||
+		!strings.Contains(params.Command[2], "gc --yes --namespace k8run-system") {
+		t.Errorf("expected the released binary to be downloaded, got %s %v", params.Image, params.Command)
+	}
+
+	c.Image = "registry.example.com/k8run:0.1.0"
+	c.AllNamespaces = true
+	params = c.params()
+	if params.Image != c.Image || strings.Join(params.Command, " ") != "k8run gc --yes --all-namespaces" {
+		t.Errorf("expected k8run to be run from the image, got %s %v", params.Image, params.Command)
+	}
+}
+
+func TestGCInstallCommand_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		command *GCInstallCommand
+		wantErr bool
+	}{
+		{name: "valid command", command: &GCInstallCommand{Schedule: "0 * * * *", Version: "0.1.0", Timeout: time.Minute}},
+		{name: "shortcut schedule", command: &GCInstallCommand{Schedule: "@daily", Image: "k8run", Timeout: time.Minute}},
+		{name: "invalid schedule", command: &GCInstallCommand{Schedule: "hourly", Version: "0.1.0", Timeout: time.Minute}, wantErr: true},
+		{name: "missing version and image", command: &GCInstallCommand{Schedule: "@daily", Timeout: time.Minute}, wantErr: true},
+		{name: "uninstall", command: &GCInstallCommand{Uninstall: true, Timeout: time.Minute}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.command.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeploymentCommand_Annotations(t *testing.T) {
+	c := testDeploymentCommand()
+	if c.deploymentParams("").Annotations != nil {
+		t.Errorf("expected no annotations without TTL")
+	}
+
+	c.TTL = 48 * time.Hour
+	deployment := k8s.BuildDeployment(c.deploymentParams(""))
+	service := k8s.BuildService(c.serviceParams(""))
+	expiresAt, ok := k8s.ExpiresAt(deployment.Annotations)
+	if !ok || expiresAt.Before(time.Now().Add(47*time.Hour)) {
+		t.Errorf("expected the deployment to expire in 48h, got %v", deployment.Annotations)
+	}
+	if service.Annotations[k8s.AnnotationNameExpiresAt] != deployment.Annotations[k8s.AnnotationNameExpiresAt] {
+		t.Errorf("expected every resource to expire at the same time, got %v and %v", service.Annotations, deployment.Annotations)
+	}
+
+	// the expiry isn't part of the plan, as it changes on every release
+	p, err := c.plan(context.Background(), fake.NewSimpleClientset(deployment, service, k8s.BuildPVC(c.pvcParams())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range p.Changes {
+		if change.Action != plan.Unchanged || len(change.Fields) != 0 {
+			t.Errorf("expected %s %s to be unchanged, got %+v", change.Kind, change.Name, change)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// runGraph runs fn concurrently for every node of deps, which maps each node to the nodes it depends on.
+// A node only starts once all of its dependencies succeeded. When reverse is true, the edges are flipped,
+// so a node only starts once every node depending on it succeeded (eg: to tear things down).
+func runGraph(ctx context.Context, deps map[string][]string, reverse bool, fn func(ctx context.Context, name string) error) error {
+	waitFor := map[string][]string{}
+	for name, nodeDeps := range deps {
+		if _, ok := waitFor[name]; !ok {
+			waitFor[name] = nil
+		}
+		for _, dep := range nodeDeps {
+			if reverse {
+				waitFor[dep] = append(waitFor[dep], name)
+			} else {
+				waitFor[name] = append(waitFor[name], dep)
+			}
+		}
+	}
+
+	done := map[string]chan struct{}{}
+	for name := range waitFor {
+		done[name] = make(chan struct{})
+	}
+
+	mu := sync.Mutex{}
+	errs := map[string]error{}
+	wg := sync.WaitGroup{}
+
+	for name, waits := range waitFor {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done[name])
+
+			for _, w := range waits {
+				<-done[w]
+
+				mu.Lock()
+				failed := errs[w] != nil
+				mu.Unlock()
+				if failed {
+					mu.Lock()
+					errs[name] = fmt.Errorf("%s: skipped because %s failed", name, w)
+					mu.Unlock()
+					return
+				}
+			}
+
+			if err := fn(ctx, name); err != nil {
+				mu.Lock()
+				errs[name] = fmt.Errorf("%s: %w", name, err)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	names := []string{}
+	for name := range errs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	joined := []error{}
+	for _, name := range names {
+		joined = append(joined, errs[name])
+	}
+	return errors.Join(joined...)
+}
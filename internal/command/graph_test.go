@@ -0,0 +1,86 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRunGraph_RespectsDependencies(t *testing.T) {
+	deps := map[string][]string{
+		"web":    {"api"},
+		"api":    {"db", "cache"},
+		"db":     nil,
+		"cache":  nil,
+		"worker": {"db"},
+	}
+
+	mu := sync.Mutex{}
+	order := []string{}
+	err := runGraph(context.Background(), deps, false, func(ctx context.Context, name string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for name, nodeDeps := range deps {
+		for _, dep := range nodeDeps {
+			if slices.Index(order, dep) > slices.Index(order, name) {
+				t.Errorf("expected %s to run before %s, got %v", dep, name, order)
+			}
+		}
+	}
+}
+
+func TestRunGraph_Reverse(t *testing.T) {
+	deps := map[string][]string{"api": {"db"}, "db": nil}
+
+	mu := sync.Mutex{}
+	order := []string{}
+	err := runGraph(context.Background(), deps, true, func(ctx context.Context, name string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !slices.Equal(order, []string{"api", "db"}) {
+		t.Errorf("expected dependents to run first, got %v", order)
+	}
+}
+
+func TestRunGraph_SkipsDependentsOfFailures(t *testing.T) {
+	deps := map[string][]string{"api": {"db"}, "db": nil, "cache": nil}
+
+	ran := sync.Map{}
+	err := runGraph(context.Background(), deps, false, func(ctx context.Context, name string) error {
+		ran.Store(name, true)
+		if name == "db" {
+			return fmt.Errorf("boom")
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	if _, ok := ran.Load("api"); ok {
+		t.Errorf("expected api to be skipped")
+	}
+	if _, ok := ran.Load("cache"); !ok {
+		t.Errorf("expected independent app to run")
+	}
+	if !strings.Contains(err.Error(), "api: skipped because db failed") || !strings.Contains(err.Error(), "db: boom") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package command
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasvmiguel/k8run/internal/config"
+	"github.com/lucasvmiguel/k8run/internal/detect"
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/logging"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// NewInitCommandParams represents the parameters to create a new init command.
+type NewInitCommandParams struct {
+	Name  string
+	Copy  string
+	File  string
+	Force bool
+}
+
+// InitCommand represents a command to write a k8run.yaml describing the app of a folder, with what is inferred from
+// its files, for the user to edit and deploy with 'k8run up'.
+type InitCommand struct {
+	// Name is the name of the app. Defaults to the name of the folder.
+	Name string
+	// Copy is the folder of the app. Defaults to the current folder.
+	Copy string
+	// File is the config file written. Defaults to k8run.yaml.
+	File string
+	// Force overwrites File when it exists.
+	Force bool
+}
+
+// NewInitCommand creates a new init command.
+func NewInitCommand(params NewInitCommandParams) *InitCommand {
+	return &InitCommand{
+		Name:  params.Name,
+		Copy:  params.Copy,
+		File:  params.File,
+		Force: params.Force,
+	}
+}
+
+// Validate validates the parameters of the init command.
+func (c *InitCommand) Validate() error {
+	c.Copy = cmp.Or(c.Copy, ".")
+	c.File = cmp.Or(c.File, config.DefaultFile)
+
+	dir, err := filepath.Abs(c.Copy)
+	if err != nil {
+		return fmt.Errorf("Failed to read %s: %s", c.Copy, err)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("Copy must be a folder, got %s", c.Copy)
+	}
+	if _, err := os.Stat(c.File); err == nil && !c.Force {
+		return fmt.Errorf("File %s already exists, use Force to overwrite it", c.File)
+	}
+
+	if c.Name == "" {
+		c.Name = k8s.SanitizeName(filepath.Base(dir), "app")
+	}
+	if errs := validation.IsDNS1123Label(c.Name); len(errs) > 0 {
+		return fmt.Errorf("Name %q is invalid: %s", c.Name, strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// Run detects the app of the folder and writes its config file.
+func (c *InitCommand) Run(ctx context.Context) error {
+	app, err := detect.Detect(c.Copy)
+	if err != nil {
+		return fmt.Errorf("Failed to detect the app in %s: %s", c.Copy, err)
+	}
+
+	logger := logging.FromContext(ctx)
+	for _, inference := range app.Inferences {
+		logger.With("field", inference.Field, "value", inference.Value, "from", inference.Source).Info("Inferred")
+	}
+
+	b, err := c.config(app)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(c.File, b, 0o644)
+	if err != nil {
+		return fmt.Errorf("Failed to write %s: %s", c.File, err)
+	}
+
+	logger.With("file", c.File, "runtime", app.Runtime).Info("Config written, edit it and deploy with 'k8run up'")
+	return nil
+}
+
+// config returns the config file describing the app, commented with where each value comes from.
+func (c *InitCommand) config(app *detect.App) ([]byte, error) {
+	// config files resolve the copy against their own folder
+	copyPath, err := relativeTo(filepath.Dir(c.File), c.Copy)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to resolve %s: %s", c.Copy, err)
+	}
+
+	cfgApp := config.App{
+		Name:          c.Name,
+		Image:         app.Image,
+		Copy:          copyPath,
+		Entrypoint:    app.Entrypoint,
+		Setup:         app.Setup,
+		SetupCache:    app.SetupCache,
+		ContainerPort: app.ContainerPort,
+		Service:       true,
+		Port:          80,
+		// the Go module is cross-compiled on every deploy
+		Auto: app.GoBinary != "",
+	}
+	if name := landingName(copyPath); name != "." {
+		cfgApp.WorkDir = path.Join(copyTo, name)
+	}
+	for name, value := range app.Env {
+		if cfgApp.Env == nil {
+			cfgApp.Env = map[string]config.Scalar{}
+		}
+		cfgApp.Env[name] = config.Scalar(value)
+	}
+
+	b, err := yaml.Marshal(config.Config{Version: config.Version, Apps: []config.App{cfgApp}})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to write config: %s", err)
+	}
+
+	header := &bytes.Buffer{}
+	fmt.Fprintf(header, "# Written by 'k8run init' from the files of %s, edit it to your needs.\n", c.Copy)
+	for _, inference := range app.Inferences {
+		fmt.Fprintf(header, "# - %s\n", inference)
+	}
+	if cfgApp.Auto {
+		fmt.Fprintf(header, "# 'auto' cross-compiles %s into ./%s on every deploy, copied instead of the sources.\n", app.GoPackage, app.GoBinary)
+	}
+	return append(header.Bytes(), b...), nil
+}
+
+// relativeTo returns target relative to dir, slash separated.
+func relativeTo(dir, target string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absDir, absTarget)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
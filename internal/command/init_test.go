@@ -0,0 +1,66 @@
+package command
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/config"
+)
+
+func TestInitCommand_Run(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "My App")
+	if err := os.MkdirAll(filepath.Join(dir, "web"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "web", "Gemfile"), []byte("ruby '3.3.1'\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "web", "config.ru"), []byte(""), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, "k8run.yaml")
+	c := NewInitCommand(NewInitCommandParams{Copy: filepath.Join(dir, "web"), File: file})
+	if err := c.Validate(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	cfg, err := config.Load(file)
+	if err != nil {
+		t.Fatalf("expected the config written to be valid, got %v", err)
+	}
+	app := cfg.Apps[0]
+	if app.Name != "web" || app.Image != "ruby:3.3" || app.Copy != filepath.Join(dir, "web") || app.WorkDir != "/app/web" {
+		t.Errorf("expected the app of the folder, got %+v", app)
+	}
+	if !slices.Equal(app.Entrypoint, []string{"bundle", "exec", "rackup", "--host", "0.0.0.0", "--port", "9292"}) || app.ContainerPort != 9292 {
+		t.Errorf("expected the rack app to be started, got %v on %d", app.Entrypoint, app.ContainerPort)
+	}
+	if app.Env["BUNDLE_PATH"] != "vendor/bundle" || app.Setup != "bundle install" || app.Auto {
+		t.Errorf("expected the gems to be installed by the setup, got %+v", app)
+	}
+
+	c = NewInitCommand(NewInitCommandParams{Copy: filepath.Join(dir, "web"), File: file})
+	if err := c.Validate(); err == nil {
+		t.Errorf("expected an error overwriting the config without Force")
+	}
+	c = NewInitCommand(NewInitCommandParams{Copy: dir, File: file, Force: true})
+	if err := c.Validate(); err != nil || c.Name != "my-app" {
+		t.Errorf("expected the name of the folder as a valid name, got %q and %v", c.Name, err)
+	}
+
+	unnamed := filepath.Join(t.TempDir(), "___")
+	if err := os.Mkdir(unnamed, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	c = NewInitCommand(NewInitCommandParams{Copy: unnamed, File: filepath.Join(unnamed, "k8run.yaml")})
+	if err := c.Validate(); err != nil || c.Name != "app" {
+		t.Errorf("expected a folder without a valid name to fall back to app, got %q and %v", c.Name, err)
+	}
+}
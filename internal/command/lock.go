@@ -0,0 +1,126 @@
+package command
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/logging"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+)
+
+// lockDuration is how long the lock of an app outlives a k8run that stopped renewing it, eg: after a crash.
+var lockDuration = 30 * time.Second
+
+// lockParams represents the parameters to lock an app while deploying or destroying it.
+type lockParams struct {
+	App       string
+	Namespace string
+	Owner     string
+	// Wait queues behind the holder of the lock instead of failing.
+	Wait bool
+}
+
+// lock acquires the lease of the app and renews it in the background until the returned function is called,
+// which releases it. Apps in a namespace that doesn't exist have nothing to race on, so they aren't locked.
+//
+// The run goes on with the returned context, canceled when the lease is lost: when someone else took it over or it
+// couldn't be renewed before expiring. lockLost then tells why the run failed.
+func lock(ctx context.Context, clientset kubernetes.Interface, params lockParams) (context.Context, func(), error) {
+	hostname, _ := os.Hostname()
+	lease := k8s.LeaseParams{
+		Name:      lockName(params.App),
+		Namespace: params.Namespace,
+		App:       params.App,
+		Holder:    fmt.Sprintf("%s on %s (%s)", cmp.Or(params.Owner, "unknown"), cmp.Or(hostname, "unknown"), rand.String(5)),
+		Duration:  lockDuration,
+	}
+
+	for {
+		err := k8s.AcquireLease(ctx, clientset, lease)
+		if k8serrors.IsNotFound(err) {
+			return ctx, func() {}, nil
+		}
+
+		var held *k8s.LeaseHeldError
+		if !errors.As(err, &held) {
+			if err != nil {
+				return nil, nil, fmt.Errorf("Failed to lock app %s: %w", params.App, err)
+			}
+			break
+		}
+
+		age := duration.HumanDuration(time.Since(held.AcquiredAt))
+		if !params.Wait {
+			return nil, nil, withKind(ErrConflict, fmt.Errorf("App %s is locked by %s for %s, use --wait-for-lock to wait for it", params.App, held.Holder, age))
+		}
+
+		logging.FromContext(ctx).With("name", params.App, "namespace", params.Namespace, "holder", held.Holder, "age", age).Info("Waiting for lock...")
+		select {
+		case <-ctx.Done():
+			return nil, nil, withKind(ErrTimeout, fmt.Errorf("Timed out waiting for the lock of app %s held by %s", params.App, held.Holder))
+		case <-time.After(2 * time.Second):
+		}
+	}
+
+	locked, stop := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		renewedAt := time.Now()
+		for {
+			select {
+			case <-locked.Done():
+				return
+			case <-time.After(lockDuration / 3):
+			}
+
+			err := k8s.RenewLease(locked, clientset, lease)
+			switch {
+			case err == nil:
+				renewedAt = time.Now()
+			case locked.Err() != nil:
+				return
+			case errors.Is(err, k8s.ErrLeaseLost) || time.Since(renewedAt) >= lockDuration:
+				// another run may hold the lock by now, so this one stops before they both write the app
+				stop(withKind(ErrConflict, fmt.Errorf("Lost the lock of app %s, another run may be deploying or destroying it: %w", params.App, err)))
+				return
+			default:
+				logging.FromContext(ctx).With("name", params.App, "namespace", params.Namespace, "error", err).Warn("Failed to renew lock")
+			}
+		}
+	}()
+
+	return locked, func() {
+		stop(nil)
+		<-done
+		// released even when the run timed out, so the next run doesn't wait for the lock to expire
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if err := k8s.ReleaseLease(ctx, clientset, lease); err != nil {
+			logging.FromContext(ctx).With("name", params.App, "namespace", params.Namespace, "error", err).Warn("Failed to release lock")
+		}
+	}, nil
+}
+
+// lockLost returns why the run failed when it was stopped by losing the lock of the context returned by lock,
+// instead of the error of the step that was interrupted, eg: 'context canceled'.
+func lockLost(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || context.Cause(ctx) == ctx.Err() {
+		return err
+	}
+	return context.Cause(ctx)
+}
+
+// lockName returns the name of the lease locking an app.
+func lockName(app string) string {
+	return fmt.Sprintf("k8run-%s", app)
+}
@@ -0,0 +1,125 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLock(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	params := lockParams{App: "foo", Namespace: "default", Owner: "jane"}
+
+	_, unlock, err := lock(context.Background(), clientset, params)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	_, _, err = lock(context.Background(), clientset, lockParams{App: "foo", Namespace: "default", Owner: "john"})
+	if err == nil || !strings.Contains(err.Error(), "locked by jane on ") || !strings.Contains(err.Error(), "--wait-for-lock") {
+		t.Errorf("expected the holder to be reported, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, _, err = lock(ctx, clientset, lockParams{App: "foo", Namespace: "default", Owner: "john", Wait: true})
+	if err == nil || !strings.Contains(err.Error(), "Timed out") {
+		t.Errorf("expected to time out waiting for the lock, got %v", err)
+	}
+
+	// the lock of another app is independent
+	_, unlockBar, err := lock(context.Background(), clientset, lockParams{App: "bar", Namespace: "default", Owner: "john"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	unlockBar()
+
+	unlock()
+	_, err = clientset.CoordinationV1().Leases("default").Get(context.Background(), lockName("foo"), metav1.GetOptions{})
+	if !k8serrors.IsNotFound(err) {
+		t.Errorf("expected the lock to be released, got %v", err)
+	}
+}
+
+func TestLock_Renew(t *testing.T) {
+	original := lockDuration
+	lockDuration = 300 * time.Millisecond
+	t.Cleanup(func() { lockDuration = original })
+
+	clientset := fake.NewSimpleClientset()
+	_, unlock, err := lock(context.Background(), clientset, lockParams{App: "foo", Namespace: "default", Owner: "jane"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer unlock()
+
+	time.Sleep(lockDuration)
+	lease, err := clientset.CoordinationV1().Leases("default").Get(context.Background(), lockName("foo"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lease.Spec.RenewTime.After(lease.Spec.AcquireTime.Time) {
+		t.Errorf("expected the lock to be renewed in the background, got %+v", lease.Spec)
+	}
+}
+
+func TestLock_Wait(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	_, unlock, err := lock(context.Background(), clientset, lockParams{App: "foo", Namespace: "default", Owner: "jane"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, unlockJohn, err := lock(ctx, clientset, lockParams{App: "foo", Namespace: "default", Owner: "john", Wait: true})
+	if err != nil {
+		t.Fatalf("expected to acquire the lock once released, got %v", err)
+	}
+	unlockJohn()
+}
+
+func TestLock_Lost(t *testing.T) {
+	original := lockDuration
+	lockDuration = 300 * time.Millisecond
+	t.Cleanup(func() { lockDuration = original })
+
+	clientset := fake.NewSimpleClientset()
+	ctx, unlock, err := lock(context.Background(), clientset, lockParams{App: "foo", Namespace: "default", Owner: "jane"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer unlock()
+
+	// someone took the lock over, eg: after it expired while the cluster was unreachable
+	lease, err := clientset.CoordinationV1().Leases("default").Get(context.Background(), lockName("foo"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	holder := "john on laptop (x1y2z)"
+	lease.Spec.HolderIdentity = &holder
+	if _, err := clientset.CoordinationV1().Leases("default").Update(context.Background(), lease, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(lockDuration * 2):
+		t.Fatalf("expected the run to be stopped once the lock is lost")
+	}
+	err = lockLost(ctx, fmt.Errorf("Failed to copy: %w", ctx.Err()))
+	if ExitCode(err) != ExitConflict || !strings.Contains(err.Error(), "lost to john on laptop") {
+		t.Errorf("expected the lost lock to be reported as a conflict, got %v", err)
+	}
+}
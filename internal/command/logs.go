@@ -0,0 +1,191 @@
+package command
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/kube"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewLogsCommandParams represents the parameters to create a new logs command.
+type NewLogsCommandParams struct {
+	Name      string
+	Namespace string
+	Isolated  bool
+	Follow    bool
+	Since     time.Duration
+	Tail      int64
+	Kube      kube.Config
+}
+
+// LogsCommand represents a command to stream the logs of the pods of an app deployed by k8run.
+type LogsCommand struct {
+	Name      string
+	Namespace string
+	// Isolated streams the logs of an app deployed into a namespace of its own.
+	Isolated bool
+	// Follow keeps streaming new logs until the context is done.
+	Follow bool
+	// Since only streams the logs newer than this duration. Zero streams all of them.
+	Since time.Duration
+	// Tail only streams this number of lines from the end of the logs of each pod. Zero streams all of them.
+	Tail int64
+	// Kube is how to reach the cluster.
+	Kube kube.Config
+}
+
+// NewLogsCommand creates a new logs command.
+func NewLogsCommand(params NewLogsCommandParams) *LogsCommand {
+	return &LogsCommand{
+		Name:      params.Name,
+		Namespace: params.Namespace,
+		Isolated:  params.Isolated,
+		Follow:    params.Follow,
+		Since:     params.Since,
+		Tail:      params.Tail,
+		Kube:      params.Kube,
+	}
+}
+
+// Validate validates the parameters of the logs command.
+func (c *LogsCommand) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("Name is required")
+	}
+	if c.Isolated && c.Namespace != "" {
+		return fmt.Errorf("Isolated can't be used with Namespace")
+	}
+	if c.Since < 0 {
+		return fmt.Errorf("Since can't be negative")
+	}
+	if c.Tail < 0 {
+		return fmt.Errorf("Tail can't be negative")
+	}
+	return nil
+}
+
+// Logs writes the logs of every pod of the app to w, each line prefixed by its pod when there are several.
+func (c *LogsCommand) Logs(ctx context.Context, w io.Writer) error {
+	if c.Isolated {
+		c.Namespace = isolatedNamespace(c.Name)
+	} else {
+		namespace, err := resolveNamespace(c.Kube, c.Namespace)
+		if err != nil {
+			return err
+		}
+		c.Namespace = namespace
+	}
+
+	clientset, err := newClientset(c.Kube)
+	if err != nil {
+		return err
+	}
+
+	return c.logs(ctx, clientset, w)
+}
+
+func (c *LogsCommand) logs(ctx context.Context, clientset kubernetes.Interface, w io.Writer) error {
+	deployments, err := k8s.ListDeployments(ctx, clientset, k8s.ListParams{Namespace: c.Namespace})
+	if err != nil {
+		return fmt.Errorf("Failed to list deployments: %w", err)
+	}
+	deployments = slices.DeleteFunc(deployments, func(d appsv1.Deployment) bool {
+		return cmp.Or(d.Labels[k8s.LabelNameApp], d.Name) != c.Name
+	})
+	if len(deployments) == 0 {
+		return fmt.Errorf("App %s not found in namespace %s", c.Name, c.Namespace)
+	}
+
+	streams := []k8s.LogsParams{}
+	for _, deployment := range deployments {
+		pods, err := k8s.ListPods(ctx, clientset, k8s.ListParams{Namespace: c.Namespace, LabelSelector: "app=" + deployment.Name})
+		if err != nil {
+			return fmt.Errorf("Failed to list pods: %w", err)
+		}
+		for _, pod := range pods {
+			streams = append(streams, k8s.LogsParams{
+				Namespace: c.Namespace,
+				PodName:   pod.Name,
+				Container: deployment.Name,
+				Follow:    c.Follow,
+				Since:     c.Since,
+				Tail:      c.Tail,
+			})
+		}
+	}
+	if len(streams) == 0 {
+		return fmt.Errorf("App %s has no pods", c.Name)
+	}
+
+	if len(streams) == 1 {
+		if err := k8s.StreamLogs(ctx, clientset, streams[0], w); err != nil {
+			return fmt.Errorf("Failed to stream the logs of pod %s: %w", streams[0].PodName, err)
+		}
+		return nil
+	}
+
+	mu := &sync.Mutex{}
+	errs := make([]error, len(streams))
+	wg := sync.WaitGroup{}
+	for i, stream := range streams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lines := &prefixWriter{w: w, mu: mu, prefix: fmt.Sprintf("[%s] ", stream.PodName)}
+			err := k8s.StreamLogs(ctx, clientset, stream, lines)
+			lines.flush()
+			if err != nil {
+				errs[i] = fmt.Errorf("Failed to stream the logs of pod %s: %w", stream.PodName, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// prefixWriter prefixes each line written to it and writes the complete ones to w, holding mu so the lines of
+// concurrent streams don't interleave.
+type prefixWriter struct {
+	w       io.Writer
+	mu      *sync.Mutex
+	prefix  string
+	partial []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.partial = append(p.partial, b...)
+	for {
+		i := bytes.IndexByte(p.partial, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		p.writeLine(p.partial[:i+1])
+		p.partial = p.partial[i+1:]
+	}
+}
+
+// flush writes the last line, even if it doesn't end with a newline.
+func (p *prefixWriter) flush() {
+	if len(p.partial) > 0 {
+		p.writeLine(append(p.partial, '\n'))
+		p.partial = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.w, "%s%s", p.prefix, line)
+}
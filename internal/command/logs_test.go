@@ -0,0 +1,72 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLogsCommand_Validate(t *testing.T) {
+	if err := (&LogsCommand{}).Validate(); err == nil {
+		t.Errorf("expected an error without a name")
+	}
+	if err := (&LogsCommand{Name: "shop", Tail: -1}).Validate(); err == nil {
+		t.Errorf("expected an error for a negative tail")
+	}
+	if err := (&LogsCommand{Name: "shop", Tail: 10}).Validate(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestLogsCommand_Logs(t *testing.T) {
+	c := &LogsCommand{Name: "api", Namespace: "team-a"}
+	objects := append(statusObjects(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-1", Namespace: "team-a", Labels: map[string]string{"app": "api", k8s.LabelNameCreatedBy: k8s.LabelValueCreatedBy}},
+	})
+	out := &bytes.Buffer{}
+	if err := c.logs(context.Background(), fake.NewSimpleClientset(objects...), out); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if out.String() != "fake logs" {
+		t.Errorf("expected the logs of the only pod as they are, got %q", out)
+	}
+
+	c = &LogsCommand{Name: "shop", Namespace: "default"}
+	objects = append(statusObjects(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop-worker-1", Namespace: "default", Labels: map[string]string{"app": "shop-worker", k8s.LabelNameCreatedBy: k8s.LabelValueCreatedBy}},
+	})
+	out.Reset()
+	if err := c.logs(context.Background(), fake.NewSimpleClientset(objects...), out); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for _, want := range []string{"[shop-1] fake logs\n", "[shop-worker-1] fake logs\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+
+	c = &LogsCommand{Name: "missing", Namespace: "default"}
+	if err := c.logs(context.Background(), fake.NewSimpleClientset(statusObjects()...), out); err == nil {
+		t.Errorf("expected an error for a missing app")
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := &prefixWriter{w: out, mu: &sync.Mutex{}, prefix: "[a] "}
+	p.Write([]byte("one\ntw"))
+	p.Write([]byte("o\nthree"))
+	p.flush()
+
+	if want := "[a] one\n[a] two\n[a] three\n"; out.String() != want {
+		t.Errorf("expected %q, got %q", want, out)
+	}
+}
@@ -0,0 +1,168 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/plan"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// isolatedNamespace returns the namespace an isolated app is deployed into.
+func isolatedNamespace(name string) string {
+	return fmt.Sprintf("k8run-%s", name)
+}
+
+// resolve sets the namespace the deployment command targets, who it deploys as and what it deploys.
+func (c *DeploymentCommand) resolve() error {
+	owner, err := resolveOwner(c.Kube, c.Owner)
+	if err != nil {
+		return err
+	}
+	c.Owner = owner
+	c.resolveGit()
+
+	if c.Isolated {
+		c.Namespace = isolatedNamespace(c.Name)
+		return nil
+	}
+
+	namespace, err := resolveNamespace(c.Kube, c.Namespace)
+	if err != nil {
+		return err
+	}
+	c.Namespace = namespace
+	return nil
+}
+
+// createsNamespace returns true if the deployment command creates its namespace when it doesn't exist.
+func (c *DeploymentCommand) createsNamespace() bool {
+	return c.CreateNamespace || c.Isolated
+}
+
+// ensureNamespace creates the namespace, and the limits of an isolated one, when asked to.
+func (c *DeploymentCommand) ensureNamespace(ctx context.Context, clientset kubernetes.Interface) error {
+	if !c.createsNamespace() {
+		return nil
+	}
+
+	err := k8s.CreateNamespaceIfNotExists(ctx, clientset, c.namespaceParams())
+	if err != nil {
+		return fmt.Errorf("Failed to create namespace: %w", err)
+	}
+
+	if c.Isolated {
+		// limits are already checked by Validate
+		params, _ := c.NamespaceLimits.params(c.Namespace)
+		err = k8s.CreateOrUpdateNamespaceLimits(ctx, clientset, params)
+		if err != nil {
+			return fmt.Errorf("Failed to create or update namespace limits: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// planNamespace plans the namespace and the limits of an isolated one.
+func (c *DeploymentCommand) planNamespace(ctx context.Context, clientset kubernetes.Interface) ([]plan.Change, error) {
+	changes := []plan.Change{}
+	if !c.createsNamespace() {
+		return changes, nil
+	}
+
+	// an existing namespace is used as it is
+	_, err := k8s.GetNamespace(ctx, clientset, c.Namespace)
+	if errors.Is(err, k8s.ErrResourceNotFound) {
+		change, err := plan.Compare(k8s.BuildNamespace(c.namespaceParams()), nil)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to plan namespace: %w", err)
+		}
+		changes = append(changes, change)
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to plan namespace: %w", err)
+	}
+
+	if !c.Isolated {
+		return changes, nil
+	}
+
+	params, _ := c.NamespaceLimits.params(c.Namespace)
+	get := k8s.GetParams{Name: k8s.NameNamespaceLimits, Namespace: c.Namespace}
+	if quota := k8s.BuildResourceQuota(params); quota != nil {
+		live, err := k8s.GetResourceQuota(ctx, clientset, get)
+		change, err := planApply(quota, live, err)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to plan resource quota: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	if limitRange := k8s.BuildLimitRange(params); limitRange != nil {
+		live, err := k8s.GetLimitRange(ctx, clientset, get)
+		change, err := planApply(limitRange, live, err)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to plan limit range: %w", err)
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+func (c *DeploymentCommand) namespaceParams() k8s.CreateNamespaceParams {
+	return k8s.CreateNamespaceParams{Name: c.Namespace, App: c.Name}
+}
+
+// resolve sets the namespace the destroy command targets and who it destroys as.
+func (c *DestroyCommand) resolve() error {
+	owner, err := resolveOwner(c.Kube, c.Owner)
+	if err != nil {
+		return err
+	}
+	c.Owner = owner
+
+	if c.Isolated {
+		c.Namespace = isolatedNamespace(c.Name)
+		return nil
+	}
+
+	namespace, err := resolveNamespace(c.Kube, c.Namespace)
+	if err != nil {
+		return err
+	}
+	c.Namespace = namespace
+	return nil
+}
+
+// ownedNamespace returns the namespace of the destroy command when k8run created it for the app being destroyed
+// and no other app deployed by k8run uses it, or nil otherwise.
+func (c *DestroyCommand) ownedNamespace(ctx context.Context, clientset kubernetes.Interface) (*corev1.Namespace, error) {
+	namespace, err := k8s.GetNamespace(ctx, clientset, c.Namespace)
+	// without access to namespaces, eg: 'k8run gc' limited to a namespace, the namespace is kept
+	if errors.Is(err, k8s.ErrResourceNotFound) || k8serrors.IsForbidden(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get namespace: %w", err)
+	}
+
+	if namespace.Labels[k8s.LabelNameCreatedBy] != k8s.LabelValueCreatedBy || namespace.Labels[k8s.LabelNameApp] != c.Name {
+		return nil, nil
+	}
+
+	deployments, err := k8s.ListDeployments(ctx, clientset, k8s.ListParams{Namespace: c.Namespace})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list deployments: %w", err)
+	}
+	for _, deployment := range deployments {
+		if deployment.Name != c.Name && deployment.Labels[k8s.LabelNameApp] != c.Name {
+			return nil, nil
+		}
+	}
+
+	return namespace, nil
+}
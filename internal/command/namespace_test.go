@@ -0,0 +1,117 @@
+package command
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/plan"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDeploymentCommand_PlanIsolated(t *testing.T) {
+	c := testDeploymentCommand()
+	c.Namespace = ""
+	c.Isolated = true
+	c.NamespaceLimits = NamespaceLimits{
+		Quota:           map[string]string{"pods": "10"},
+		DefaultRequests: map[string]string{"cpu": "50m"},
+	}
+	if err := c.resolve(); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := c.plan(context.Background(), fake.NewSimpleClientset())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	kinds := []string{}
+	for _, change := range p.Changes {
+		kinds = append(kinds, change.Kind)
+		if change.Action != plan.Create {
+			t.Errorf("expected %s to be created, got %s", change.Kind, change.Action)
+		}
+	}
+	want := []string{"Namespace", "ResourceQuota", "LimitRange", "PersistentVolumeClaim", "Deployment", "Service"}
+	if len(kinds) != len(want) {
+		t.Fatalf("expected %v, got %v", want, kinds)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, kinds)
+		}
+	}
+
+	if namespaces := p.Namespaces(); len(namespaces) != 1 || namespaces[0] != "k8run-test" {
+		t.Errorf("expected the k8run-test namespace, got %v", namespaces)
+	}
+}
+
+func TestDeploymentCommand_EnsureNamespace(t *testing.T) {
+	c := testDeploymentCommand()
+	c.Namespace = "team-a"
+	c.CreateNamespace = true
+	clientset := fake.NewSimpleClientset()
+
+	if err := c.ensureNamespace(context.Background(), clientset); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	namespace, err := k8s.GetNamespace(context.Background(), clientset, "team-a")
+	if err != nil {
+		t.Fatalf("expected the namespace to be created, got %v", err)
+	}
+	if namespace.Labels[k8s.LabelNameCreatedBy] != k8s.LabelValueCreatedBy || namespace.Labels[k8s.LabelNameApp] != "test" {
+		t.Errorf("expected the namespace to be labeled for the app, got %v", namespace.Labels)
+	}
+
+	// an existing namespace is used as it is
+	if err := c.ensureNamespace(context.Background(), clientset); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestDestroyCommand_OwnedNamespace(t *testing.T) {
+	owned := k8s.BuildNamespace(k8s.CreateNamespaceParams{Name: "k8run-test", App: "test"})
+	other := k8s.BuildNamespace(k8s.CreateNamespaceParams{Name: "k8run-test", App: "other"})
+	deployment := func(name string) *appsv1.Deployment {
+		return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "k8run-test",
+			Labels:    map[string]string{k8s.LabelNameCreatedBy: k8s.LabelValueCreatedBy},
+		}}
+	}
+
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		want    bool
+	}{
+		{name: "created for the app", objects: []runtime.Object{owned, deployment("test")}, want: true},
+		{name: "created for another app", objects: []runtime.Object{other, deployment("test")}, want: false},
+		{name: "used by another app", objects: []runtime.Object{owned, deployment("test"), deployment("other")}, want: false},
+		{name: "missing", objects: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &DestroyCommand{Name: "test", Isolated: true, Timeout: time.Minute}
+			if err := c.resolve(); err != nil {
+				t.Fatal(err)
+			}
+
+			namespace, err := c.ownedNamespace(context.Background(), fake.NewSimpleClientset(tt.objects...))
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if (namespace != nil) != tt.want {
+				t.Errorf("expected owned to be %v, got %v", tt.want, namespace)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lucasvmiguel/k8run/internal/git"
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/kube"
+	"github.com/lucasvmiguel/k8run/internal/logging"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// gitEmail returns the email of the git user, it's replaced in tests.
+var gitEmail = func() string {
+	email, _ := git.Email("")
+	return email
+}
+
+// resolveOwner returns the given owner or, when it's empty, the impersonated user, the git email or, without one,
+// the kubeconfig user. The kubeconfig user is the name of an entry of the kubeconfig, often shared by a whole team,
+// eg: 'kubernetes-admin', so it's the last resort.
+func resolveOwner(config kube.Config, owner string) (string, error) {
+	if owner != "" {
+		return owner, nil
+	}
+	if config.As != "" {
+		return config.As, nil
+	}
+	if email := gitEmail(); email != "" {
+		return email, nil
+	}
+
+	user, err := config.User()
+	if err != nil {
+		return "", fmt.Errorf("Failed to resolve owner: %w", err)
+	}
+	return user, nil
+}
+
+// appOwner returns who deployed the live app: the owner of its deployment or, for a Procfile app, of its
+// processes. It's empty when the app doesn't exist or was deployed before owners were recorded.
+func appOwner(ctx context.Context, clientset kubernetes.Interface, namespace, app string) (string, error) {
+	deployment, err := k8s.GetDeployment(ctx, clientset, k8s.GetParams{Name: app, Namespace: namespace})
+	if err != nil && !errors.Is(err, k8s.ErrResourceNotFound) {
+		return "", err
+	}
+	if deployment != nil && deployment.Annotations[k8s.AnnotationNameOwner] != "" {
+		return deployment.Annotations[k8s.AnnotationNameOwner], nil
+	}
+
+	processes, err := k8s.ListDeployments(ctx, clientset, k8s.ListParams{
+		Namespace:     namespace,
+		LabelSelector: fmt.Sprintf("%s=%s", k8s.LabelNameApp, app),
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, process := range processes {
+		if owner := process.Annotations[k8s.AnnotationNameOwner]; owner != "" {
+			return owner, nil
+		}
+	}
+
+	return "", nil
+}
+
+// checkOwner fails when the live app is owned by someone else than owner, unless takeover is set.
+func checkOwner(ctx context.Context, clientset kubernetes.Interface, namespace, app, owner string, takeover bool) error {
+	current, err := appOwner(ctx, clientset, namespace, app)
+	if err != nil {
+		return fmt.Errorf("Failed to get the owner of app %s: %w", app, err)
+	}
+	if current == "" || current == owner {
+		return nil
+	}
+
+	if !takeover {
+		return withKind(ErrConflict, fmt.Errorf("App %s in namespace %s is owned by %s, use --takeover to take it over", app, namespace, current))
+	}
+	logging.FromContext(ctx).With("name", app, "namespace", namespace, "owner", current).Warn("Taking over app")
+	return nil
+}
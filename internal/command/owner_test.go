@@ -0,0 +1,144 @@
+package command
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/kube"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const ownerKubeconfig = `
+apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev
+  cluster: {server: https://dev.example.com}
+contexts:
+- name: dev
+  context: {cluster: dev, user: jane}
+users:
+- name: jane
+  user: {token: dev-token}
+`
+
+func TestResolveOwner(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(kubeconfig, []byte(ownerKubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(empty, []byte("apiVersion: v1\nkind: Config\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	original := gitEmail
+	t.Cleanup(func() { gitEmail = original })
+
+	tests := []struct {
+		name     string
+		config   kube.Config
+		owner    string
+		gitEmail string
+		want     string
+	}{
+		{name: "override", config: kube.Config{Kubeconfig: kubeconfig}, owner: "ci", gitEmail: "john@example.com", want: "ci"},
+		{name: "impersonated user", config: kube.Config{Kubeconfig: kubeconfig, As: "bob"}, gitEmail: "john@example.com", want: "bob"},
+		{name: "git email over the shared kubeconfig user", config: kube.Config{Kubeconfig: kubeconfig}, gitEmail: "john@example.com", want: "john@example.com"},
+		{name: "kubeconfig user without git email", config: kube.Config{Kubeconfig: kubeconfig}, want: "jane"},
+		{name: "nothing", config: kube.Config{Kubeconfig: empty}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gitEmail = func() string { return tt.gitEmail }
+			owner, err := resolveOwner(tt.config, tt.owner)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if owner != tt.want {
+				t.Errorf("expected owner %q, got %q", tt.want, owner)
+			}
+		})
+	}
+}
+
+func TestCheckOwner(t *testing.T) {
+	deployment := func(name, app, owner string) *appsv1.Deployment {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{k8s.LabelNameCreatedBy: k8s.LabelValueCreatedBy},
+		}}
+		if app != "" {
+			d.Labels[k8s.LabelNameApp] = app
+		}
+		if owner != "" {
+			d.Annotations = map[string]string{k8s.AnnotationNameOwner: owner}
+		}
+		return d
+	}
+	objects := []runtime.Object{
+		deployment("foo", "", "jane"),
+		deployment("legacy", "", ""),
+		deployment("shop-worker", "shop", "jane"),
+	}
+
+	tests := []struct {
+		name     string
+		app      string
+		owner    string
+		takeover bool
+		wantErr  bool
+	}{
+		{name: "new app", app: "bar", owner: "john"},
+		{name: "same owner", app: "foo", owner: "jane"},
+		{name: "app without owner", app: "legacy", owner: "john"},
+		{name: "other owner", app: "foo", owner: "john", wantErr: true},
+		{name: "other owner with takeover", app: "foo", owner: "john", takeover: true},
+		{name: "other owner of a Procfile process", app: "shop", owner: "john", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOwner(context.Background(), fake.NewSimpleClientset(objects...), "default", tt.app, tt.owner, tt.takeover)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkOwner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeploymentCommand_Owner(t *testing.T) {
+	c := testDeploymentCommand()
+	c.Owner = "jane"
+
+	deployment := k8s.BuildDeployment(c.deploymentParams(""))
+	if deployment.Annotations[k8s.AnnotationNameOwner] != "jane" {
+		t.Errorf("expected the deployment to be owned by jane, got %v", deployment.Annotations)
+	}
+
+	c.Owner = "john"
+	if _, err := c.plan(context.Background(), fake.NewSimpleClientset(deployment)); err == nil {
+		t.Errorf("expected an error when replacing an app owned by someone else")
+	}
+
+	c.Takeover = true
+	if _, err := c.plan(context.Background(), fake.NewSimpleClientset(deployment)); err != nil {
+		t.Errorf("expected no error with takeover, got %v", err)
+	}
+
+	destroy := &DestroyCommand{Name: c.Name, Namespace: c.Namespace, Owner: "john", Timeout: time.Minute}
+	if _, err := destroy.plan(context.Background(), fake.NewSimpleClientset(deployment)); err == nil {
+		t.Errorf("expected an error when destroying an app owned by someone else")
+	}
+}
@@ -0,0 +1,62 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/plan"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// noteNotOwned is the note of live resources k8run refuses to touch.
+const noteNotOwned = "exists but was not created by k8run, so k8run will fail"
+
+// planApply compares the desired resource with the live one, as returned by one of the k8s.Get functions.
+func planApply[T runtime.Object](desired runtime.Object, live T, err error) (plan.Change, error) {
+	if errors.Is(err, k8s.ErrResourceNotFound) {
+		return plan.Compare(desired, nil)
+	}
+	if err != nil {
+		return plan.Change{}, err
+	}
+
+	change, err := plan.Compare(desired, live)
+	if err != nil {
+		return change, fmt.Errorf("failed to compare %s: %w", change.Kind, err)
+	}
+	if !ownedByK8run(live) {
+		change.Note = noteNotOwned
+	}
+	return change, nil
+}
+
+// planDelete returns the change deleting the live resource, as returned by one of the k8s.Get functions,
+// or nil when it doesn't exist.
+func planDelete[T runtime.Object](kind string, live T, err error) (*plan.Change, error) {
+	if errors.Is(err, k8s.ErrResourceNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	change, err := plan.Remove(kind, live)
+	if err != nil {
+		return nil, err
+	}
+	if !ownedByK8run(live) {
+		change.Note = noteNotOwned
+	}
+	return &change, nil
+}
+
+func ownedByK8run(obj runtime.Object) bool {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	return accessor.GetLabels()[k8s.LabelNameCreatedBy] == k8s.LabelValueCreatedBy
+}
@@ -0,0 +1,87 @@
+package command
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/plan"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testDeploymentCommand() *DeploymentCommand {
+	return &DeploymentCommand{
+		Name:          "test",
+		Namespace:     "default",
+		Image:         "node:20",
+		Copy:          "/test-folder",
+		Entrypoint:    []string{"node", "index.js"},
+		Replicas:      1,
+		Timeout:       time.Minute,
+		Service:       true,
+		Port:          80,
+		ContainerPort: 3000,
+	}
+}
+
+func TestDeploymentCommand_PlanCreate(t *testing.T) {
+	p, err := testDeploymentCommand().plan(context.Background(), fake.NewSimpleClientset())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(p.Changes) != 3 || p.Count(plan.Create) != 3 {
+		t.Errorf("expected the PVC, deployment and service to be created, got %+v", p.Changes)
+	}
+}
+
+func TestDeploymentCommand_PlanUpdate(t *testing.T) {
+	live := testDeploymentCommand()
+	clientset := fake.NewSimpleClientset(
+		k8s.BuildPVC(live.pvcParams()),
+		k8s.BuildDeployment(live.deploymentParams("old-release")),
+		// a service with the same name not managed by k8run
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+	)
+
+	c := testDeploymentCommand()
+	c.Image = "node:22"
+	p, err := c.plan(context.Background(), clientset)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	pvc, deployment, service := p.Changes[0], p.Changes[1], p.Changes[2]
+	if pvc.Action != plan.Unchanged {
+		t.Errorf("expected the PVC to be kept, got %+v", pvc)
+	}
+	if deployment.Action != plan.Update || !deployment.Restart || len(deployment.Fields) != 1 {
+		t.Errorf("expected the image of the deployment to be updated, got %+v", deployment)
+	}
+	if service.Note != noteNotOwned {
+		t.Errorf("expected the service to be flagged as not owned by k8run, got %+v", service)
+	}
+}
+
+func TestDestroyCommand_Plan(t *testing.T) {
+	live := testDeploymentCommand()
+	clientset := fake.NewSimpleClientset(
+		k8s.BuildPVC(live.pvcParams()),
+		k8s.BuildDeployment(live.deploymentParams("release")),
+		k8s.BuildService(live.serviceParams("release")),
+	)
+
+	c := &DestroyCommand{Name: "test", Namespace: "default", Timeout: time.Minute}
+	p, err := c.plan(context.Background(), clientset)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(p.Changes) != 3 || p.Count(plan.Delete) != 3 {
+		t.Errorf("expected the deployment, PVC and service to be deleted, got %+v", p.Changes)
+	}
+}
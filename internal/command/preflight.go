@@ -0,0 +1,331 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/lucasvmiguel/k8run/internal/doctor"
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/logging"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+)
+
+// lookPath finds executables, it's replaced in tests.
+var lookPath = exec.LookPath
+
+// permissions returns the permissions the deployment command needs to run.
+func (c *DeploymentCommand) permissions() []k8s.Permission {
+	permissions := []k8s.Permission{}
+	if c.createsNamespace() {
+		permissions = append(permissions,
+			k8s.Permission{Verb: "get", Resource: "namespaces", ClusterScoped: true},
+			k8s.Permission{Verb: "create", Resource: "namespaces", ClusterScoped: true},
+		)
+	}
+
+	if c.Isolated {
+		params, _ := c.NamespaceLimits.params(c.Namespace)
+		if k8s.BuildResourceQuota(params) != nil {
+			permissions = append(permissions,
+				k8s.Permission{Verb: "get", Resource: "resourcequotas"},
+				k8s.Permission{Verb: "create", Resource: "resourcequotas"},
+				k8s.Permission{Verb: "update", Resource: "resourcequotas"},
+			)
+		}
+		if k8s.BuildLimitRange(params) != nil {
+			permissions = append(permissions,
+				k8s.Permission{Verb: "get", Resource: "limitranges"},
+				k8s.Permission{Verb: "create", Resource: "limitranges"},
+				k8s.Permission{Verb: "update", Resource: "limitranges"},
+			)
+		}
+	}
+
+	// the app is locked while it's deployed
+	permissions = append(permissions,
+		k8s.Permission{Verb: "get", Group: "coordination.k8s.io", Resource: "leases"},
+		k8s.Permission{Verb: "create", Group: "coordination.k8s.io", Resource: "leases"},
+		k8s.Permission{Verb: "update", Group: "coordination.k8s.io", Resource: "leases"},
+		k8s.Permission{Verb: "delete", Group: "coordination.k8s.io", Resource: "leases"},
+	)
+
+	if !c.NoCopy {
+		permissions = append(permissions,
+			k8s.Permission{Verb: "get", Resource: "persistentvolumeclaims"},
+			k8s.Permission{Verb: "create", Resource: "persistentvolumeclaims"},
+		)
+	}
+
+	permissions = append(permissions,
+		k8s.Permission{Verb: "get", Group: "apps", Resource: "deployments"},
+		k8s.Permission{Verb: "create", Group: "apps", Resource: "deployments"},
+		k8s.Permission{Verb: "update", Group: "apps", Resource: "deployments"},
+	)
+
+	// waiting for the rollout and describing the release, whatever the code comes from
+	permissions = append(permissions,
+		k8s.Permission{Verb: "list", Resource: "pods"},
+		k8s.Permission{Verb: "watch", Resource: "pods"},
+		k8s.Permission{Verb: "get", Resource: "pods"},
+	)
+
+	if !c.NoCopy && c.GitRepo == "" {
+		// copying into the init container with exec
+		permissions = append(permissions, k8s.Permission{Verb: "create", Resource: "pods", Subresource: "exec"})
+	}
+
+	if c.Service {
+		permissions = append(permissions,
+			k8s.Permission{Verb: "get", Resource: "services"},
+			k8s.Permission{Verb: "create", Resource: "services"},
+			k8s.Permission{Verb: "update", Resource: "services"},
+		)
+	}
+
+	if c.Ingress {
+		permissions = append(permissions,
+			k8s.Permission{Verb: "get", Group: "networking.k8s.io", Resource: "ingresses"},
+			k8s.Permission{Verb: "create", Group: "networking.k8s.io", Resource: "ingresses"},
+			k8s.Permission{Verb: "update", Group: "networking.k8s.io", Resource: "ingresses"},
+		)
+	}
+
+	return permissions
+}
+
+// permissions returns the permissions the procfile command needs to run.
+func (c *ProcfileCommand) permissions() []k8s.Permission {
+	return append(c.Deployment.permissions(),
+		k8s.Permission{Verb: "create", Group: "batch", Resource: "jobs"},
+		k8s.Permission{Verb: "get", Group: "batch", Resource: "jobs"},
+		// stale processes are listed and deleted
+		k8s.Permission{Verb: "list", Group: "apps", Resource: "deployments"},
+		k8s.Permission{Verb: "delete", Group: "apps", Resource: "deployments"},
+	)
+}
+
+// permissions returns the permissions the build command needs to build the image. The deployment of the image
+// checks its own.
+func (c *BuildCommand) permissions() []k8s.Permission {
+	permissions := []k8s.Permission{}
+	if c.Deployment.createsNamespace() {
+		permissions = append(permissions,
+			k8s.Permission{Verb: "get", Resource: "namespaces", ClusterScoped: true},
+			k8s.Permission{Verb: "create", Resource: "namespaces", ClusterScoped: true},
+		)
+	}
+
+	return append(permissions,
+		k8s.Permission{Verb: "create", Group: "batch", Resource: "jobs"},
+		k8s.Permission{Verb: "get", Group: "batch", Resource: "jobs"},
+		// waiting for the init container, copying into it and streaming the logs of the build
+		k8s.Permission{Verb: "list", Resource: "pods"},
+		k8s.Permission{Verb: "get", Resource: "pods"},
+		k8s.Permission{Verb: "create", Resource: "pods", Subresource: "exec"},
+		k8s.Permission{Verb: "get", Resource: "pods", Subresource: "log"},
+	)
+}
+
+// accessChecks reviews the given permissions and returns a check for each of them.
+func accessChecks(ctx context.Context, clientset kubernetes.Interface, namespace string, permissions []k8s.Permission) ([]doctor.Check, error) {
+	reviews, err := k8s.ReviewAccess(ctx, clientset, namespace, permissions)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to check permissions: %w", err)
+	}
+
+	checks := []doctor.Check{}
+	for _, review := range reviews {
+		check := doctor.Check{Name: "can " + review.Permission.String(), Status: doctor.Pass}
+		if !review.Allowed {
+			check.Status = doctor.Fail
+			check.Message = review.Reason
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// checkAccess fails, listing every missing permission at once, if the current user lacks any of the given permissions.
+func checkAccess(ctx context.Context, clientset kubernetes.Interface, namespace string, permissions []k8s.Permission) error {
+	reviews, err := k8s.ReviewAccess(ctx, clientset, namespace, permissions)
+	if k8serrors.IsUnauthorized(err) || k8serrors.IsForbidden(err) {
+		return withKind(ErrAuth, fmt.Errorf("Failed to check permissions: %w", err))
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to check permissions: %w", err)
+	}
+
+	missing := []string{}
+	for _, review := range reviews {
+		if !review.Allowed {
+			missing = append(missing, "  "+review.Permission.String())
+		}
+	}
+	if len(missing) > 0 {
+		return withKind(ErrAuth, fmt.Errorf("Missing permissions in namespace %s:\n%s", namespace, strings.Join(missing, "\n")))
+	}
+
+	return nil
+}
+
+// clusterCheckParams represents what the cluster checks look at.
+type clusterCheckParams struct {
+	Namespace string
+	// CreateNamespace is set when a missing namespace will be created.
+	CreateNamespace bool
+	// Copy is set when the code is copied into a PVC.
+	Copy bool
+	// Kubectl is set when the code is copied with kubectl.
+	Kubectl      bool
+	Ingress      bool
+	IngressClass string
+	// Probe creates a test PVC and pod, which is too slow for the deploy preflight.
+	Probe bool
+}
+
+// clusterChecks checks whether the cluster has what k8run assumes: kubectl when it copies the code, the namespace, a default storage class,
+// the ingress API and class, and, when probing, a pullable helper image and a PVC that binds.
+func clusterChecks(ctx context.Context, clientset kubernetes.Interface, params clusterCheckParams) []doctor.Check {
+	checks := []doctor.Check{}
+
+	if params.Kubectl {
+		check := doctor.Check{Name: "kubectl is on PATH", Status: doctor.Pass}
+		if _, err := lookPath("kubectl"); err != nil {
+			check.Status = doctor.Fail
+			check.Message = "kubectl is needed to copy the code"
+		}
+		checks = append(checks, check)
+	}
+
+	check := doctor.Check{Name: fmt.Sprintf("namespace %s exists", params.Namespace), Status: doctor.Pass}
+	if _, err := k8s.GetNamespace(ctx, clientset, params.Namespace); errors.Is(err, k8s.ErrResourceNotFound) {
+		check.Status = doctor.Fail
+		check.Message = "the namespace doesn't exist, use --create-namespace to create it"
+		if params.CreateNamespace {
+			check.Status = doctor.Pass
+			check.Message = "it will be created"
+		}
+	} else if err != nil {
+		check.Status = doctor.Warn
+		check.Message = fmt.Sprintf("it can't be checked: %s", err)
+	}
+	checks = append(checks, check)
+
+	if params.Copy {
+		check := doctor.Check{Name: "default storage class", Status: doctor.Pass}
+		if storageClass, err := k8s.GetDefaultStorageClass(ctx, clientset); errors.Is(err, k8s.ErrResourceNotFound) {
+			check.Status = doctor.Warn
+			check.Message = "there is none, so the PVC only binds to a volume provisioned beforehand"
+		} else if err != nil {
+			check.Status = doctor.Warn
+			check.Message = fmt.Sprintf("it can't be checked: %s", err)
+		} else {
+			check.Message = storageClass.Name
+		}
+		checks = append(checks, check)
+	}
+
+	if params.Ingress {
+		check := doctor.Check{Name: "networking.k8s.io/v1 ingresses are served", Status: doctor.Pass}
+		if ok, err := k8s.SupportsResource(clientset, "networking.k8s.io/v1", "ingresses"); err != nil {
+			check.Status = doctor.Warn
+			check.Message = fmt.Sprintf("it can't be checked: %s", err)
+		} else if !ok {
+			check.Status = doctor.Fail
+			check.Message = "the cluster doesn't serve the API group the ingress is created with"
+		}
+		checks = append(checks, check)
+
+		check = doctor.Check{Name: "ingress class", Status: doctor.Pass}
+		if params.IngressClass != "" {
+			check.Name = fmt.Sprintf("ingress class %s", params.IngressClass)
+		}
+		if ingressClass, err := k8s.GetIngressClass(ctx, clientset, params.IngressClass); errors.Is(err, k8s.ErrResourceNotFound) {
+			check.Status = doctor.Fail
+			check.Message = "it doesn't exist, so no controller will serve the ingress"
+			if params.IngressClass == "" {
+				check.Status = doctor.Warn
+				check.Message = "there is no default one, so --ingress-class must be given"
+			}
+		} else if err != nil {
+			check.Status = doctor.Warn
+			check.Message = fmt.Sprintf("it can't be checked: %s", err)
+		} else {
+			check.Message = fmt.Sprintf("%s, controller %s", ingressClass.Name, ingressClass.Spec.Controller)
+		}
+		checks = append(checks, check)
+	}
+
+	if params.Probe {
+		checks = append(checks, probeChecks(ctx, clientset, params.Namespace)...)
+	}
+
+	return checks
+}
+
+// probeChecks checks, with a test PVC and pod, that the helper image can be pulled and that a PVC binds.
+func probeChecks(ctx context.Context, clientset kubernetes.Interface, namespace string) []doctor.Check {
+	image := doctor.Check{Name: fmt.Sprintf("image %s can be pulled", k8s.HelperImage), Status: doctor.Pass}
+	pvc := doctor.Check{Name: "test PVC binds", Status: doctor.Pass}
+
+	result, err := k8s.Probe(ctx, clientset, k8s.ProbeParams{
+		Name:      fmt.Sprintf("k8run-doctor-%s", rand.String(5)),
+		Namespace: namespace,
+	})
+	if err != nil {
+		image.Status, pvc.Status = doctor.Fail, doctor.Fail
+		image.Message, pvc.Message = err.Error(), err.Error()
+		return []doctor.Check{image, pvc}
+	}
+
+	if !result.ImagePulled {
+		image.Status = doctor.Fail
+		image.Message = result.Message
+	}
+	if !result.PVCBound {
+		pvc.Status = doctor.Fail
+		pvc.Message = result.Message
+	}
+	return []doctor.Check{image, pvc}
+}
+
+// preflight runs the light cluster checks of the deployment command and checks the binaries copied can run on the
+// nodes. Warnings are logged and failures are reported together.
+func (c *DeploymentCommand) preflight(ctx context.Context, clientset kubernetes.Interface) error {
+	checks := clusterChecks(ctx, clientset, clusterCheckParams{
+		Namespace:       c.Namespace,
+		CreateNamespace: c.createsNamespace(),
+		Copy:            !c.NoCopy,
+		Kubectl:         !c.NoCopy && c.GitRepo == "" && c.Copier == nil && c.Kube.CopyTransport == string(k8s.CopyTransportKubectl),
+		Ingress:         c.Ingress,
+		IngressClass:    c.IngressClass,
+	})
+	switch {
+	case !c.SkipBinaryCheck:
+		checks = append(checks, c.binaryChecks(ctx, clientset)...)
+	case c.compilesGo():
+		// detected Go apps are still compiled for the nodes
+		checks = append(checks, c.nodeArchsCheck(ctx, clientset))
+	}
+
+	failed := []string{}
+	for _, check := range checks {
+		switch check.Status {
+		case doctor.Warn:
+			logging.FromContext(ctx).With("check", check.Name).Warn(check.Message)
+		case doctor.Fail:
+			failed = append(failed, fmt.Sprintf("  %s: %s", check.Name, check.Message))
+		}
+	}
+	if len(failed) > 0 {
+		return Invalid(fmt.Errorf("Preflight checks failed:\n%s", strings.Join(failed, "\n")))
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package command
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lucasvmiguel/k8run/internal/git"
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+)
+
+// previewNameMax keeps the names derived from a preview app under the 63 characters of DNS labels, eg: its
+// 'k8run-<name>' isolated namespace or the '<name>-<process>' deployments of a Procfile.
+const previewNameMax = 40
+
+// currentBranch, describeGit and resolveRef read the git repository of a folder, they're replaced in tests.
+var (
+	currentBranch = git.Branch
+	describeGit   = git.Describe
+	resolveRef    = git.Resolve
+)
+
+// previewName derives a DNS-1123 label from the branch, prefixed by the given name, if any. eg: 'Feature/X_y'
+// becomes 'feature-x-y'. Names that are too long are truncated and suffixed by a hash of the branch, so two long
+// branches sharing a prefix get different names.
+func previewName(prefix, branch string) string {
+	name := branch
+	if prefix != "" {
+		name = prefix + "-" + branch
+	}
+	name = k8s.SanitizeName(name, "preview")
+
+	if len(name) > previewNameMax {
+		sum := sha256.Sum256([]byte(branch))
+		hash := hex.EncodeToString(sum[:])[:6]
+		name = strings.TrimRight(name[:previewNameMax-len(hash)-1], "-") + "-" + hash
+	}
+	return strings.TrimRight(name, "-")
+}
+
+// previewBranch returns the given branch or, when it's empty, the branch checked out in the folder at path.
+func previewBranch(branch, path string) (string, error) {
+	if branch != "" {
+		return branch, nil
+	}
+
+	branch, err := currentBranch(gitDir(path))
+	if err != nil {
+		return "", fmt.Errorf("Failed to read the git branch: %w", err)
+	}
+	if branch == "" {
+		return "", fmt.Errorf("Preview requires a branch but HEAD is detached, use --preview-branch")
+	}
+	return branch, nil
+}
+
+// gitDir returns the folder git runs in to describe the given path, which may be a file.
+func gitDir(path string) string {
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return filepath.Dir(path)
+	}
+	return path
+}
+
+// resolvePreview names the app after the branch and, with a preview domain, exposes it on a host named after it.
+func (c *DeploymentCommand) resolvePreview() error {
+	if !c.Preview || c.previewed {
+		return nil
+	}
+
+	branch := c.PreviewBranch
+	if c.GitRepo != "" {
+		// a cloned app is named after the ref cloned, there is no local checkout to read it from
+		if branch = cmp.Or(branch, c.GitRef); branch == "" {
+			return fmt.Errorf("Preview with GitRepo requires GitRef or PreviewBranch")
+		}
+	}
+	branch, err := previewBranch(branch, c.source())
+	if err != nil {
+		return err
+	}
+	c.Name = previewName(c.Name, branch)
+	c.previewed = true
+
+	if c.PreviewDomain != "" {
+		c.Ingress = true
+		c.IngressHost = c.Name + "." + c.PreviewDomain
+	}
+	return nil
+}
+
+// resolveGit reads the git metadata of the copied folder. Folders outside of a git repository have none. With
+// CopyGitRef, the release is the commit of the ref, without uncommitted changes.
+func (c *DeploymentCommand) resolveGit() {
+	if c.NoCopy || c.git != nil {
+		return
+	}
+	// the commit cloned is only known once the init container ran, see recordGitCommit
+	if c.GitRepo != "" {
+		c.git = &git.Info{Remote: git.Redact(c.GitRepo)}
+		return
+	}
+
+	info, err := describeGit(gitDir(c.source()))
+	if err != nil {
+		return
+	}
+	if c.CopyGitRef != "" {
+		commit, err := resolveRef(gitDir(c.source()), c.CopyGitRef)
+		if err != nil {
+			return
+		}
+		resolved := *info
+		resolved.Commit = commit
+		resolved.Dirty = false
+		info = &resolved
+	}
+	c.git = info
+}
+
+// gitMetadata returns the git metadata of the release as annotations and as env vars.
+func (c *DeploymentCommand) gitMetadata() (annotations map[string]string, env map[string]string) {
+	if c.git == nil {
+		return nil, nil
+	}
+
+	annotations = map[string]string{}
+	env = map[string]string{}
+	if c.git.Commit != "" {
+		annotations[k8s.AnnotationNameGitCommit] = c.git.Commit
+		annotations[k8s.AnnotationNameGitDirty] = strconv.FormatBool(c.git.Dirty)
+		env[k8s.EnvVarGitCommit] = c.git.Commit
+		env[k8s.EnvVarGitDirty] = strconv.FormatBool(c.git.Dirty)
+	}
+	if c.git.Branch != "" {
+		annotations[k8s.AnnotationNameGitBranch] = c.git.Branch
+		env[k8s.EnvVarGitBranch] = c.git.Branch
+	}
+	if c.git.Remote != "" {
+		annotations[k8s.AnnotationNameGitRemote] = c.git.Remote
+		env[k8s.EnvVarGitRemote] = c.git.Remote
+	}
+	return annotations, env
+}
+
+// resolvePreview names the app to destroy after the branch checked out in the current folder.
+func (c *DestroyCommand) resolvePreview() error {
+	if !c.Preview || c.previewed {
+		return nil
+	}
+
+	branch, err := previewBranch(c.PreviewBranch, "")
+	if err != nil {
+		return err
+	}
+	c.Name = previewName(c.Name, branch)
+	c.previewed = true
+	return nil
+}
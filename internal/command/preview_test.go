@@ -0,0 +1,95 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/git"
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestPreviewName(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		branch string
+		want   string
+	}{
+		{name: "simple branch", branch: "feature-x", want: "feature-x"},
+		{name: "slashes and case", branch: "Feature/X_y", want: "feature-x-y"},
+		{name: "prefix", prefix: "shop", branch: "fix/cart", want: "shop-fix-cart"},
+		{name: "leading digit", branch: "123-fix", want: "preview-123-fix"},
+		{name: "long branch", branch: "renovate/github.com-kubernetes-client-go-0.x-and-more", want: "renovate-github-com-kubernetes-cl-152383"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := previewName(tt.prefix, tt.branch)
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+			if errs := validation.IsDNS1035Label(isolatedNamespace(got)); len(errs) > 0 {
+				t.Errorf("expected a valid name, got %v", errs)
+			}
+		})
+	}
+}
+
+func TestDeploymentCommand_Preview(t *testing.T) {
+	original := currentBranch
+	currentBranch = func(dir string) (string, error) { return "feature/x", nil }
+	t.Cleanup(func() { currentBranch = original })
+
+	c := testDeploymentCommand()
+	c.Name = ""
+	c.Preview = true
+	c.PreviewDomain = "preview.example.com"
+	c.IngressClass = "nginx"
+	if err := c.Validate(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if c.Name != "feature-x" || !c.Ingress || c.IngressHost != "feature-x.preview.example.com" {
+		t.Errorf("expected the app to be named and exposed after the branch, got %s on %s", c.Name, c.IngressHost)
+	}
+
+	// validating again keeps the name
+	if err := c.Validate(); err != nil || c.Name != "feature-x" {
+		t.Errorf("expected the name to be kept, got %s (%v)", c.Name, err)
+	}
+
+	destroy := &DestroyCommand{Name: "shop", Preview: true, PreviewBranch: "PR-12"}
+	if err := destroy.resolvePreview(); err != nil || destroy.Name != "shop-pr-12" {
+		t.Errorf("expected the app of the branch to be destroyed, got %s (%v)", destroy.Name, err)
+	}
+
+	currentBranch = func(dir string) (string, error) { return "", nil }
+	c = testDeploymentCommand()
+	c.Preview = true
+	if err := c.Validate(); err == nil {
+		t.Errorf("expected an error for a detached HEAD")
+	}
+}
+
+func TestDeploymentCommand_GitMetadata(t *testing.T) {
+	original := describeGit
+	describeGit = func(dir string) (*git.Info, error) {
+		return &git.Info{Commit: "abc123", Branch: "main", Dirty: true, Remote: "https://github.com/org/repo.git"}, nil
+	}
+	t.Cleanup(func() { describeGit = original })
+
+	c := testDeploymentCommand()
+	c.Env = map[string]string{"PORT": "3000"}
+	c.resolveGit()
+
+	params := c.deploymentParams("")
+	if params.Annotations[k8s.AnnotationNameGitCommit] != "abc123" || params.Annotations[k8s.AnnotationNameGitDirty] != "true" {
+		t.Errorf("expected the git metadata to be annotated, got %v", params.Annotations)
+	}
+	if params.Env[k8s.EnvVarGitBranch] != "main" || params.Env[k8s.EnvVarGitRemote] != "https://github.com/org/repo.git" || params.Env["PORT"] != "3000" {
+		t.Errorf("expected the git metadata to be exposed as env vars, got %v", params.Env)
+	}
+	if len(c.Env) != 1 {
+		t.Errorf("expected the env of the command to be left untouched, got %v", c.Env)
+	}
+}
@@ -0,0 +1,419 @@
+package command
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/logging"
+	"github.com/lucasvmiguel/k8run/internal/plan"
+	"github.com/lucasvmiguel/k8run/internal/procfile"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NewProcfileCommandParams represents the parameters to create a new procfile command.
+type NewProcfileCommandParams struct {
+	Procfile   string
+	Deployment *DeploymentCommand
+}
+
+// ProcfileCommand represents a command to deploy every process type of a Procfile from a single copy of the code.
+// The 'web' process gets the service and ingress, and the 'release' process runs as a job before the rollout.
+type ProcfileCommand struct {
+	// Procfile is the path of the Procfile. Defaults to the Procfile inside the copied folder.
+	Procfile string
+	// Deployment holds the flags shared by every process. Its entrypoint is replaced by each process command.
+	Deployment *DeploymentCommand
+
+	processes []procfile.Process
+}
+
+// NewProcfileCommand creates a new procfile command.
+func NewProcfileCommand(params NewProcfileCommandParams) *ProcfileCommand {
+	return &ProcfileCommand{
+		Procfile:   params.Procfile,
+		Deployment: params.Deployment,
+	}
+}
+
+// Validate validates the Procfile and the deployment flags shared by its processes.
+func (c *ProcfileCommand) Validate() error {
+	d := c.Deployment
+	if d.NoCopy || d.GitRepo != "" {
+		return fmt.Errorf("Copy is required")
+	}
+	if d.Setup != "" {
+		return fmt.Errorf("Setup can't be used with a Procfile, run it in the release process")
+	}
+	if err := d.Validate(); err != nil {
+		return err
+	}
+
+	if d.Copy != "" && d.Copy != stdinSource {
+		abs, err := filepath.Abs(d.Copy)
+		if err != nil {
+			return fmt.Errorf("Invalid copy: %w", err)
+		}
+		d.Copy = abs
+	}
+	for i, entry := range d.Copies {
+		if entry.Source == stdinSource {
+			continue
+		}
+		abs, err := filepath.Abs(entry.Source)
+		if err != nil {
+			return fmt.Errorf("Invalid copy: %w", err)
+		}
+		d.Copies[i].Source = abs
+	}
+
+	c.Procfile = cmp.Or(c.Procfile, filepath.Join(d.source(), "Procfile"))
+	processes, err := procfile.Load(c.Procfile)
+	if err != nil {
+		return err
+	}
+	c.processes = processes
+
+	names := map[string]string{}
+	hasWeb, hasDeployment := false, false
+	for _, process := range processes {
+		if process.Name == procfile.Release {
+			continue
+		}
+		hasDeployment = true
+		hasWeb = hasWeb || process.Name == procfile.Web
+
+		name := c.deploymentName(process.Name)
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return fmt.Errorf("Process %q can't be deployed as %q: %s", process.Name, name, strings.Join(errs, ", "))
+		}
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("Processes %q and %q would both be deployed as %q", other, process.Name, name)
+		}
+		names[name] = process.Name
+	}
+
+	if !hasDeployment {
+		return fmt.Errorf("Procfile must declare at least one process besides %q", procfile.Release)
+	}
+	if (d.Service || d.Ingress) && !hasWeb {
+		return fmt.Errorf("Service and ingress require a %q process", procfile.Web)
+	}
+
+	return nil
+}
+
+// Run copies the code once, runs the release process and deploys the other processes.
+func (c *ProcfileCommand) Run(ctx context.Context) (err error) {
+	logging.FromContext(ctx).With("procfile", c.Procfile, "processes", len(c.processes)).Info("Starting deployment...")
+	d := c.Deployment
+	err = d.resolve()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+	defer cancel()
+	defer func() { err = timedOut(ctx, err) }()
+
+	clientset, err := newClientset(d.Kube)
+	if err != nil {
+		return err
+	}
+
+	err = checkAccess(ctx, clientset, d.Namespace, c.permissions())
+	if err != nil {
+		return err
+	}
+
+	err = d.preflight(ctx, clientset)
+	if err != nil {
+		return err
+	}
+
+	err = d.ensureNamespace(ctx, clientset)
+	if err != nil {
+		return err
+	}
+
+	locked, unlock, err := lock(ctx, clientset, d.lockParams())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	ctx = locked
+	defer func() { err = lockLost(ctx, err) }()
+
+	err = checkOwner(ctx, clientset, d.Namespace, d.Name, d.Owner, d.Takeover)
+	if err != nil {
+		return err
+	}
+
+	err = k8s.CreatePVCIfNotExists(ctx, clientset, d.pvcParams())
+	if err != nil {
+		return fmt.Errorf("Failed to create PVC: %w", err)
+	}
+
+	releaseIdentifier := rand.String(10)
+	job := c.jobParams(releaseIdentifier)
+	err = k8s.CreateJob(ctx, clientset, job)
+	if err != nil {
+		return fmt.Errorf("Failed to create release job: %w", err)
+	}
+
+	pod, err := k8s.WaitForRunningInitContainer(ctx, clientset, k8s.WaitForRunningInitContainerParams{
+		Namespace:         d.Namespace,
+		Name:              job.Name,
+		InitContainerName: initContainerName,
+		ReleaseIdentifier: releaseIdentifier,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to wait for init container: %w", err)
+	}
+
+	err = d.copyCode(ctx, pod.Name)
+	if err != nil {
+		return err
+	}
+
+	err = k8s.WaitForJobToComplete(ctx, clientset, k8s.GetParams{Name: job.Name, Namespace: d.Namespace})
+	if err != nil {
+		return fmt.Errorf("Failed to run release (see 'kubectl logs -n %s job/%s'): %w", d.Namespace, job.Name, err)
+	}
+
+	deployments := []string{}
+	for _, process := range c.processes {
+		if process.Name == procfile.Release {
+			continue
+		}
+
+		params := c.deploymentParams(process, releaseIdentifier)
+		err = k8s.CreateOrUpdateDeployment(ctx, clientset, params)
+		if err != nil {
+			return fmt.Errorf("Failed to create or update deployment of process %q: %w", process.Name, err)
+		}
+		deployments = append(deployments, params.Name)
+	}
+
+	stale, err := c.staleProcesses(ctx, clientset)
+	if err != nil {
+		return err
+	}
+	for _, deployment := range stale {
+		err = k8s.DeleteDeployment(ctx, clientset, k8s.DeleteDeploymentParams{Name: deployment.Name, Namespace: d.Namespace})
+		if err != nil && !errors.Is(err, k8s.ErrResourceNotFound) {
+			return fmt.Errorf("Failed to delete deployment of removed process: %w", err)
+		}
+	}
+
+	if d.Service {
+		err = k8s.CreateOrUpdateService(ctx, clientset, d.serviceParams(releaseIdentifier))
+		if err != nil {
+			return fmt.Errorf("Failed to create or update service: %w", err)
+		}
+	}
+
+	if d.Ingress {
+		err = k8s.CreateOrUpdateIngress(ctx, clientset, d.ingressParams())
+		if err != nil {
+			return fmt.Errorf("Failed to create or update ingress: %w", err)
+		}
+	}
+
+	for _, name := range deployments {
+		err = k8s.WaitForDeploymentToBeReady(ctx, clientset, k8s.WaitForDeploymentToBeReadyParams{
+			Namespace:         d.Namespace,
+			Name:              name,
+			ReleaseIdentifier: releaseIdentifier,
+		})
+		if errors.Is(err, k8s.ErrAppCrashed) {
+			return withKind(ErrAppCrash, fmt.Errorf("Deployment %q crashed: %w", name, err))
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to wait for deployment %q: %w", name, err)
+		}
+	}
+
+	logging.FromContext(ctx).Info("Deployment finished!")
+
+	return nil
+}
+
+// Plan returns the changes the procfile command will make to the cluster.
+func (c *ProcfileCommand) Plan(ctx context.Context) (*plan.Plan, error) {
+	d := c.Deployment
+	err := d.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+	defer cancel()
+
+	clientset, err := newClientset(d.Kube)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkOwner(ctx, clientset, d.Namespace, d.Name, d.Owner, d.Takeover)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &plan.Plan{}
+	changes, err := d.planNamespace(ctx, clientset)
+	if err != nil {
+		return nil, err
+	}
+	p.Add(changes...)
+
+	change, err := d.planPVC(ctx, clientset)
+	if err != nil {
+		return nil, err
+	}
+	p.Add(change)
+
+	job := plan.Change{Kind: "Job", Name: d.Name + "-release-<id>", Namespace: d.Namespace, Action: plan.Create, Note: "receives the copy"}
+	for _, process := range c.processes {
+		if process.Name == procfile.Release {
+			job.Note = "receives the copy and runs the release process"
+		}
+	}
+	p.Add(job)
+
+	for _, process := range c.processes {
+		if process.Name == procfile.Release {
+			continue
+		}
+		change, err := planDeployment(ctx, clientset, c.deploymentParams(process, ""))
+		if err != nil {
+			return nil, err
+		}
+		p.Add(change)
+	}
+
+	stale, err := c.staleProcesses(ctx, clientset)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stale {
+		change, err := plan.Remove("Deployment", &stale[i])
+		if err != nil {
+			return nil, err
+		}
+		change.Note = "process removed from the Procfile"
+		p.Add(change)
+	}
+
+	changes, err = d.planExposure(ctx, clientset)
+	if err != nil {
+		return nil, err
+	}
+	p.Add(changes...)
+
+	return p, nil
+}
+
+// staleProcesses returns the deployments of processes that are no longer in the Procfile.
+func (c *ProcfileCommand) staleProcesses(ctx context.Context, clientset kubernetes.Interface) ([]appsv1.Deployment, error) {
+	deployments, err := k8s.ListDeployments(ctx, clientset, k8s.ListParams{
+		Namespace:     c.Deployment.Namespace,
+		LabelSelector: fmt.Sprintf("%s=%s", k8s.LabelNameApp, c.Deployment.Name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list process deployments: %w", err)
+	}
+
+	current := map[string]bool{}
+	for _, process := range c.processes {
+		current[c.deploymentName(process.Name)] = process.Name != procfile.Release
+	}
+
+	stale := []appsv1.Deployment{}
+	for _, deployment := range deployments {
+		if !current[deployment.Name] {
+			stale = append(stale, deployment)
+		}
+	}
+	return stale, nil
+}
+
+// deploymentName returns the name of the deployment of a process. The 'web' process keeps the app name,
+// so the service, ingress and destroy command work as they do for a single deployment.
+func (c *ProcfileCommand) deploymentName(process string) string {
+	if process == procfile.Web {
+		return c.Deployment.Name
+	}
+	return fmt.Sprintf("%s-%s", c.Deployment.Name, strings.ReplaceAll(strings.ToLower(process), "_", "-"))
+}
+
+// workDir returns the folder the first copy lands in, as the Procfile commands run from the root of the code.
+func (c *ProcfileCommand) workDir() string {
+	_, mounts := c.Deployment.copyLayout()
+	return cmp.Or(c.Deployment.WorkDir, mounts[0].Path)
+}
+
+func (c *ProcfileCommand) jobParams(releaseIdentifier string) k8s.CreateJobParams {
+	d := c.Deployment
+	params := k8s.CreateJobParams{
+		Name:              fmt.Sprintf("%s-release-%s", d.Name, releaseIdentifier),
+		Namespace:         d.Namespace,
+		App:               d.Name,
+		Image:             k8s.HelperImage,
+		Entrypoint:        []string{"true"},
+		CopyTo:            copyTo,
+		CopyMounts:        d.copyMounts(),
+		PVCName:           pvcName(d.Name),
+		InitContainerName: initContainerName,
+		ReleaseIdentifier: releaseIdentifier,
+		Env:               d.env(),
+	}
+	params.InitContainerCommand = d.deploymentParams(releaseIdentifier).InitContainerCommand
+
+	// without a release process, the job only receives the copy
+	for _, process := range c.processes {
+		if process.Name == procfile.Release {
+			params.Image = d.Image
+			params.Entrypoint = []string{"sh", "-c", process.Command}
+			params.WorkingDir = c.workDir()
+		}
+	}
+
+	return params
+}
+
+func (c *ProcfileCommand) deploymentParams(process procfile.Process, releaseIdentifier string) k8s.CreateOrUpdateDeploymentParams {
+	params := c.Deployment.deploymentParams(releaseIdentifier)
+	params.Name = c.deploymentName(process.Name)
+	params.App = c.Deployment.Name
+	params.Entrypoint = []string{"sh", "-c", process.Command}
+	params.WorkingDir = c.workDir()
+	// the code is copied once by the release job
+	params.InitContainerName = ""
+	params.InitContainerCommand = nil
+
+	if process.Name != procfile.Web {
+		params.ContainerPort = 0
+		return params
+	}
+
+	// like Heroku, the web process reads the port to listen to from $PORT
+	if _, ok := params.Env["PORT"]; !ok && params.ContainerPort > 0 {
+		env := map[string]string{"PORT": strconv.Itoa(int(params.ContainerPort))}
+		for name, value := range params.Env {
+			env[name] = value
+		}
+		params.Env = env
+	}
+
+	return params
+}
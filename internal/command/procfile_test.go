@@ -0,0 +1,73 @@
+package command_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/command"
+)
+
+func TestProcfileCommand_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		procfile string
+		service  bool
+		wantErr  bool
+	}{
+		{
+			name:     "valid procfile",
+			procfile: "web: bundle exec puma\nworker: bundle exec sidekiq\nrelease: rake db:migrate\n",
+			service:  true,
+			wantErr:  false,
+		},
+		{
+			name:     "service without web",
+			procfile: "worker: bundle exec sidekiq\n",
+			service:  true,
+			wantErr:  true,
+		},
+		{
+			name:     "only release",
+			procfile: "release: rake db:migrate\n",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid process name",
+			procfile: "web: puma\nWorker.1: sidekiq\n",
+			wantErr:  true,
+		},
+		{
+			name:     "clashing process names",
+			procfile: "web: puma\nmy_worker: sidekiq\nmy-worker: sidekiq\n",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "Procfile"), []byte(tt.procfile), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			c := command.NewProcfileCommand(command.NewProcfileCommandParams{
+				Deployment: &command.DeploymentCommand{
+					Name:          "test",
+					Image:         "ruby:3",
+					Copy:          dir,
+					Replicas:      1,
+					Timeout:       time.Minute,
+					Service:       tt.service,
+					Port:          80,
+					ContainerPort: 3000,
+				},
+			})
+			err := c.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package command
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/plan"
+)
+
+// Result is the outcome of a deployment or a destroy, written as the final document of '--output json'.
+type Result struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// Release is the release identifier of a deployment.
+	Release string `json:"release,omitempty"`
+	// Commit is the git commit released, copied from a git repository or cloned.
+	Commit string `json:"commit,omitempty"`
+	// Resources are the resources created, updated or deleted.
+	Resources []ResultResource `json:"resources"`
+	// Pods are the names of the pods of the release once it's ready.
+	Pods             []string `json:"pods,omitempty"`
+	ServiceClusterIP string   `json:"serviceClusterIP,omitempty"`
+	IngressURL       string   `json:"ingressURL,omitempty"`
+	// Copy is the copy of the code of a deployment.
+	Copy *CopyResult `json:"copy,omitempty"`
+	// Build is the image built in the cluster and deployed.
+	Build *BuildResult `json:"build,omitempty"`
+	// Steps are the steps run, in order, with how long each took.
+	Steps           []Step       `json:"steps"`
+	DurationSeconds float64      `json:"durationSeconds"`
+	Error           *ResultError `json:"error,omitempty"`
+}
+
+// ResultResource is a resource changed by a command.
+type ResultResource struct {
+	Kind      string      `json:"kind"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace,omitempty"`
+	Action    plan.Action `json:"action"`
+}
+
+// CopyResult is the size of the code copied into the pods.
+type CopyResult struct {
+	// SizeBytes is the size of the files, before leaving any out.
+	SizeBytes     int64 `json:"sizeBytes"`
+	FilteredBytes int64 `json:"filteredBytes"`
+	// SentBytes is the size of the compressed archive sent to the pod.
+	SentBytes   int64  `json:"sentBytes"`
+	Compression string `json:"compression,omitempty"`
+}
+
+// BuildResult is an image built in the cluster.
+type BuildResult struct {
+	// Image is the image deployed, by its digest. eg: 'ghcr.io/org/app@sha256:...'
+	Image           string  `json:"image"`
+	Digest          string  `json:"digest"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// Step is a step of a command, eg: copying the code.
+type Step struct {
+	Name            string  `json:"name"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// ResultError is why a command failed, with the code k8run exits with.
+type ResultError struct {
+	Message string `json:"message"`
+	// Kind is the kind of the error, eg: 'timeout'. It's empty for errors of no known kind.
+	Kind     string `json:"kind,omitempty"`
+	ExitCode int    `json:"exitCode"`
+}
+
+// step records how long the named step took since start.
+func (r *Result) step(name string, start time.Time) {
+	r.Steps = append(r.Steps, Step{Name: name, DurationSeconds: time.Since(start).Seconds()})
+}
+
+// addResources records the resources the plan creates, updates or deletes. Unchanged deployments whose pods
+// restart count as updated, as they roll out a new release.
+func (r *Result) addResources(p *plan.Plan) {
+	for _, change := range p.Changes {
+		action := change.Action
+		if action == plan.Unchanged && change.Restart {
+			action = plan.Update
+		}
+		if action == plan.Unchanged {
+			continue
+		}
+		r.Resources = append(r.Resources, ResultResource{
+			Kind:      change.Kind,
+			Name:      change.Name,
+			Namespace: change.Namespace,
+			Action:    action,
+		})
+	}
+}
+
+// WriteJSON prints the result as JSON, with the given error of the command, if any.
+func (r *Result) WriteJSON(w io.Writer, err error) error {
+	result := *r
+	if result.Resources == nil {
+		result.Resources = []ResultResource{}
+	}
+	if result.Steps == nil {
+		result.Steps = []Step{}
+	}
+	if err != nil {
+		result.Error = &ResultError{Message: err.Error(), Kind: errorKind(err), ExitCode: ExitCode(err)}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
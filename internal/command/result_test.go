@@ -0,0 +1,119 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/plan"
+
+	appsv1 "k8s.io/api/apps/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestExitCode(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "no error", err: nil, want: 0},
+		{name: "unknown", err: errors.New("boom"), want: ExitFailure},
+		{name: "validation", err: Invalid(errors.New("Name is required")), want: ExitValidation},
+		{name: "auth", err: withKind(ErrAuth, errors.New("Missing permissions")), want: ExitAuth},
+		{name: "conflict", err: withKind(ErrConflict, errors.New("App is locked")), want: ExitConflict},
+		{name: "app crash", err: withKind(ErrAppCrash, errors.New("App crashed")), want: ExitAppCrash},
+		{name: "wrapped", err: fmt.Errorf("deploying: %w", withKind(ErrConflict, errors.New("App is locked"))), want: ExitConflict},
+		{name: "expired context", err: timedOut(expired, errors.New("Failed to list pods")), want: ExitTimeout},
+		{name: "expired context keeps the kind", err: timedOut(expired, withKind(ErrAppCrash, errors.New("App crashed"))), want: ExitAppCrash},
+		{name: "live context", err: timedOut(context.Background(), errors.New("boom")), want: ExitFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("expected exit code %d, got %d", tt.want, got)
+			}
+		})
+	}
+
+	if err := Invalid(errors.New("Name is required")); err.Error() != "Name is required" {
+		t.Errorf("expected the message to be unchanged, got %q", err)
+	}
+}
+
+func TestResult_WriteJSON(t *testing.T) {
+	r := &Result{Name: "shop", Namespace: "default", Release: "abc"}
+	r.addResources(&plan.Plan{Changes: []plan.Change{
+		{Kind: "PersistentVolumeClaim", Name: "shop-app-pvc", Namespace: "default", Action: plan.Unchanged},
+		{Kind: "Deployment", Name: "shop", Namespace: "default", Action: plan.Unchanged, Restart: true},
+		{Kind: "Service", Name: "shop", Namespace: "default", Action: plan.Create},
+	}})
+	r.step("copy", time.Now())
+
+	b := &bytes.Buffer{}
+	if err := r.WriteJSON(b, withKind(ErrTimeout, errors.New("Timeout while waiting"))); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var got Result
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("expected valid JSON, got %v", err)
+	}
+	want := []ResultResource{
+		{Kind: "Deployment", Name: "shop", Namespace: "default", Action: plan.Update},
+		{Kind: "Service", Name: "shop", Namespace: "default", Action: plan.Create},
+	}
+	if fmt.Sprint(got.Resources) != fmt.Sprint(want) {
+		t.Errorf("expected resources %v, got %v", want, got.Resources)
+	}
+	if got.Release != "abc" || len(got.Steps) != 1 || got.Steps[0].Name != "copy" {
+		t.Errorf("unexpected result:\n%s", b)
+	}
+	if got.Error == nil || got.Error.Kind != "timeout" || got.Error.ExitCode != ExitTimeout {
+		t.Errorf("expected a timeout error, got %+v", got.Error)
+	}
+}
+
+func TestExitCode_Cluster(t *testing.T) {
+	forbidden := fake.NewSimpleClientset()
+	forbidden.PrependReactor("get", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "test", errors.New("denied"))
+	})
+	_, err := testDeploymentCommand().plan(context.Background(), forbidden)
+	if got := ExitCode(err); got != ExitAuth {
+		t.Errorf("expected a forbidden plan to exit with %d, got %d: %v", ExitAuth, got, err)
+	}
+
+	conflicting := fake.NewSimpleClientset()
+	conflicting.PrependReactor("create", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewConflict(schema.GroupResource{Resource: "namespaces"}, "test", errors.New("conflict"))
+	})
+	c := testDeploymentCommand()
+	c.CreateNamespace = true
+	err = c.ensureNamespace(context.Background(), conflicting)
+	if got := ExitCode(err); got != ExitConflict {
+		t.Errorf("expected a conflicting namespace to exit with %d, got %d: %v", ExitConflict, got, err)
+	}
+
+	foreign := fake.NewSimpleClientset(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}})
+	err = k8s.CreateOrUpdateDeployment(context.Background(), foreign, testDeploymentCommand().deploymentParams("abc"))
+	if got := ExitCode(fmt.Errorf("Failed to create or update deployment: %w", err)); got != ExitConflict {
+		t.Errorf("expected a deployment not created by k8run to exit with %d, got %d: %v", ExitConflict, got, err)
+	}
+	if kind := errorKind(err); kind != "conflict" {
+		t.Errorf("expected the kind of the error to be conflict, got %q", kind)
+	}
+}
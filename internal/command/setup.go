@@ -0,0 +1,190 @@
+package command
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+)
+
+const (
+	// setupCacheFolder is the folder of the PVC the setup caches are kept in across releases. Emptying the PVC for a
+	// new release leaves it alone.
+	setupCacheFolder = ".k8run-cache"
+	// setupCachePath is where the setup container finds the setup cache folder, with the hash of the last setup.
+	setupCachePath = "/var/cache/k8run"
+)
+
+// setupLockfiles are the files pinning the dependencies a setup installs. The setup caches are reused while they
+// don't change.
+var setupLockfiles = []string{
+	"package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml", "bun.lockb",
+	"requirements.txt", "poetry.lock", "Pipfile.lock", "uv.lock",
+	"Gemfile.lock", "composer.lock", "go.sum", "Cargo.lock",
+}
+
+// setupScript runs the setup $2 unless the caches in the folder $0 were set up for the hash $1. Pods of the app
+// share the caches, so only one of them runs the setup at a time.
+const setupScript = `set -e
+while ! mkdir "$0/lock" 2>/dev/null; do
+  # a pod killed during its setup leaves the lock behind
+  find "$0" -maxdepth 1 -name lock -mmin +30 -exec rmdir {} \; 2>/dev/null || true
+  echo "Waiting for the setup of another pod..."
+  sleep 2
+done
+trap 'rmdir "$0/lock"' EXIT
+if [ "$(cat "$0/hash" 2>/dev/null)" = "$1" ]; then
+  echo "Dependencies unchanged, reusing the setup cache"
+  exit 0
+fi
+rm -f "$0/hash"
+sh -c "$2"
+echo "$1" > "$0/hash"`
+
+func (c *DeploymentCommand) validateSetup() error {
+	if c.Setup == "" {
+		if len(c.SetupCache) > 0 {
+			return fmt.Errorf("SetupCache requires Setup")
+		}
+		return nil
+	}
+
+	if c.NoCopy {
+		return fmt.Errorf("Setup can't be used with NoCopy")
+	}
+	seen := map[string]bool{}
+	for _, cache := range c.SetupCache {
+		if cache == "" {
+			return fmt.Errorf("SetupCache must not be empty")
+		}
+		cachePath := c.setupCachePath(cache)
+		if cachePath == c.workDir() || strings.HasPrefix(c.workDir(), cachePath+"/") || cachePath == "/" {
+			return fmt.Errorf("SetupCache %s must not contain the working dir", cache)
+		}
+		if seen[cachePath] {
+			return fmt.Errorf("SetupCache %s is used more than once", cache)
+		}
+		seen[cachePath] = true
+	}
+
+	return nil
+}
+
+// workDir returns the working dir of the app container.
+func (c *DeploymentCommand) workDir() string {
+	return cmp.Or(c.WorkDir, copyTo)
+}
+
+// setupCachePath returns where a setup cache is mounted: the path itself or, when relative, in the working dir.
+func (c *DeploymentCommand) setupCachePath(cache string) string {
+	if path.IsAbs(cache) {
+		return path.Clean(cache)
+	}
+	return path.Join(c.workDir(), cache)
+}
+
+// setupCommand returns the command of the setup container: the setup itself or, with caches, the setup skipped
+// while the hash of the lockfiles doesn't change.
+func (c *DeploymentCommand) setupCommand(releaseIdentifier string) []string {
+	if len(c.SetupCache) == 0 {
+		return []string{"sh", "-c", c.Setup}
+	}
+	return []string{"sh", "-c", setupScript, setupCachePath, c.setupHash(releaseIdentifier), c.Setup}
+}
+
+// setupMounts returns the mounts of the setup cache folder in the setup container and of the caches in the setup
+// and app containers.
+func (c *DeploymentCommand) setupMounts() (setup []k8s.CopyMount, caches []k8s.CopyMount) {
+	if len(c.SetupCache) == 0 {
+		return nil, nil
+	}
+
+	for _, cache := range c.SetupCache {
+		cachePath := c.setupCachePath(cache)
+		caches = append(caches, k8s.CopyMount{Path: cachePath, SubPath: path.Join(setupCacheFolder, cachePath)})
+	}
+	return []k8s.CopyMount{{Path: setupCachePath, SubPath: setupCacheFolder}}, caches
+}
+
+// setupHash returns the hash the setup caches are set up for: the hash of the image, the setup and the lockfiles
+// in the working dir. Without lockfiles to read, the caches are set up once per release.
+func (c *DeploymentCommand) setupHash(releaseIdentifier string) string {
+	dir := c.localWorkDir()
+	if dir == "" {
+		return "release-" + releaseIdentifier
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n", c.Image, c.Setup)
+	found := false
+	for _, name := range setupLockfiles {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		found = true
+		fmt.Fprintf(hash, "%s %d\n", name, len(content))
+		hash.Write(content)
+	}
+	if !found {
+		return "release-" + releaseIdentifier
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// localWorkDir returns the local folder copied to the working dir, or an empty string when it isn't a local folder,
+// eg: an archive.
+func (c *DeploymentCommand) localWorkDir() string {
+	if c.GitRepo != "" || c.CopyGitRef != "" {
+		return ""
+	}
+
+	sources, mounts := c.copyLayout()
+	for i, source := range sources {
+		if source.Archive != "" {
+			continue
+		}
+		rel, ok := within(mounts[i].Path, c.workDir())
+		if !ok {
+			continue
+		}
+		dir := filepath.Join(source.Path, filepath.FromSlash(rel))
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
+
+// skipCaches returns the Skip of a source landing at dest, leaving out the setup caches inside it, as they're
+// mounted over the copy. It's nil without any.
+func (c *DeploymentCommand) skipCaches(dest string) func(name string) bool {
+	skipped := map[string]bool{}
+	for _, cache := range c.SetupCache {
+		if rel, ok := within(dest, c.setupCachePath(cache)); ok && rel != "." {
+			skipped[rel] = true
+		}
+	}
+	if len(skipped) == 0 {
+		return nil
+	}
+	return func(name string) bool { return skipped[name] }
+}
+
+// within returns the path of target relative to dir, both slash separated and absolute, if it's inside it.
+func within(dir, target string) (string, bool) {
+	if target == dir {
+		return ".", true
+	}
+	if dir == "/" {
+		return strings.TrimPrefix(target, "/"), true
+	}
+	rel, ok := strings.CutPrefix(target, dir+"/")
+	return rel, ok
+}
@@ -0,0 +1,161 @@
+package command
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// testSetupCommand returns a deployment command copying a folder with a lockfile and node_modules, working in it,
+// set up with 'npm ci' and a node_modules cache.
+func testSetupCommand(t *testing.T) *DeploymentCommand {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "foobar")
+	for name, content := range map[string]string{"index.js": "1", "package-lock.json": "{}", "node_modules/a.js": "2"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := testDeploymentCommand()
+	c.Copy = dir
+	c.WorkDir = "/app/foobar"
+	c.Setup = "npm ci"
+	c.SetupCache = []string{"node_modules"}
+	return c
+}
+
+func TestDeploymentCommand_ValidateSetup(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(c *DeploymentCommand)
+		wantErr bool
+	}{
+		{name: "valid setup", edit: func(c *DeploymentCommand) {}, wantErr: false},
+		{name: "absolute cache", edit: func(c *DeploymentCommand) { c.SetupCache = []string{"/root/.cache/pip"} }, wantErr: false},
+		{name: "cache without setup", edit: func(c *DeploymentCommand) { c.Setup = "" }, wantErr: true},
+		{name: "cache of the working dir", edit: func(c *DeploymentCommand) { c.SetupCache = []string{"."} }, wantErr: true},
+		{name: "cache containing the working dir", edit: func(c *DeploymentCommand) { c.SetupCache = []string{"/"} }, wantErr: true},
+		{name: "duplicated cache", edit: func(c *DeploymentCommand) { c.SetupCache = []string{"node_modules", "/app/foobar/node_modules"} }, wantErr: true},
+		{name: "no copy", edit: func(c *DeploymentCommand) { c.Copy = ""; c.NoCopy = true }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testSetupCommand(t)
+			tt.edit(c)
+			err := c.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeploymentCommand_SetupHash(t *testing.T) {
+	c := testSetupCommand(t)
+	hash := c.setupHash("a")
+	if hash != c.setupHash("b") || strings.HasPrefix(hash, "release-") {
+		t.Errorf("expected the hash of the lockfile to be kept across releases, got %s", hash)
+	}
+
+	if err := os.WriteFile(filepath.Join(c.source(), "package-lock.json"), []byte(`{"a": 1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if c.setupHash("a") == hash {
+		t.Errorf("expected a new hash once the lockfile changed")
+	}
+	c.Image = "node:22"
+	if c.setupHash("a") == hash {
+		t.Errorf("expected a new hash once the image changed")
+	}
+
+	if err := os.Remove(filepath.Join(c.source(), "package-lock.json")); err != nil {
+		t.Fatal(err)
+	}
+	if c.setupHash("a") != "release-a" {
+		t.Errorf("expected a setup per release without lockfiles, got %s", c.setupHash("a"))
+	}
+}
+
+func TestDeploymentCommand_SetupScript(t *testing.T) {
+	cache, work := t.TempDir(), t.TempDir()
+	run := func(hash string) string {
+		t.Helper()
+		cmd := exec.Command("sh", "-c", setupScript, cache, hash, "echo installed >> log")
+		cmd.Dir = work
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("expected no error, got %v: %s", err, out)
+		}
+		return string(out)
+	}
+
+	run("a")
+	if out := run("a"); !strings.Contains(out, "reusing the setup cache") {
+		t.Errorf("expected the setup to be skipped with the same hash, got %q", out)
+	}
+	run("b")
+	if log, _ := os.ReadFile(filepath.Join(work, "log")); string(log) != "installed\ninstalled\n" {
+		t.Errorf("expected the setup to run for each new hash, got %q", log)
+	}
+	if _, err := os.Stat(filepath.Join(cache, "lock")); !os.IsNotExist(err) {
+		t.Errorf("expected the lock to be released")
+	}
+
+	cmd := exec.Command("sh", "-c", setupScript, cache, "c", "exit 3")
+	if err := cmd.Run(); err == nil {
+		t.Errorf("expected the failure of the setup")
+	}
+	if out := run("c"); strings.Contains(out, "reusing") {
+		t.Errorf("expected a failed setup to run again, got %q", out)
+	}
+}
+
+func TestDeploymentCommand_SetupDeployment(t *testing.T) {
+	c := testSetupCommand(t)
+	deployment := k8s.BuildDeployment(c.deploymentParams("release"))
+	spec := deployment.Spec.Template.Spec
+
+	if len(spec.InitContainers) != 2 || spec.InitContainers[1].Name != k8s.SetupContainerName {
+		t.Fatalf("expected the setup to run after the copy, got %v", spec.InitContainers)
+	}
+	setup := spec.InitContainers[1]
+	if setup.Image != c.Image || setup.Command[2] != setupScript || setup.Command[5] != "npm ci" || setup.WorkingDir != "/app/foobar" {
+		t.Errorf("expected the setup to run with the image of the app, got %+v", setup)
+	}
+
+	_, caches := c.setupMounts()
+	if len(caches) != 1 || caches[0].Path != "/app/foobar/node_modules" || caches[0].SubPath != ".k8run-cache/app/foobar/node_modules" {
+		t.Errorf("expected node_modules to be kept in the PVC, got %v", caches)
+	}
+	for _, container := range []string{"setup", "app"} {
+		mounts := setup.VolumeMounts
+		if container == "app" {
+			mounts = spec.Containers[0].VolumeMounts
+		}
+		if !slices.ContainsFunc(mounts, func(m corev1.VolumeMount) bool { return m.MountPath == "/app/foobar/node_modules" }) {
+			t.Errorf("expected the cache to be mounted in the %s container, got %v", container, mounts)
+		}
+	}
+
+	copier := &k8s.MemoryCopier{}
+	c.Copier = copier
+	if err := c.copyCode(context.Background(), "test-pod"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if files := copier.Files(); !slices.Contains(files, "/app/foobar/index.js") || slices.Contains(files, "/app/foobar/node_modules/a.js") {
+		t.Errorf("expected node_modules to be left out of the copy, got %v", files)
+	}
+}
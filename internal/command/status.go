@@ -0,0 +1,416 @@
+package command
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/kube"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/kubernetes"
+)
+
+// App is an app deployed by k8run: a deployment or, for a Procfile, the deployments of its processes.
+type App struct {
+	Name      string
+	Namespace string
+	// Owner is who deployed the app. It's empty when the app was deployed before owners were recorded.
+	Owner string
+	// Deployments are the names of the deployments of the app.
+	Deployments []string
+	// Ready and Replicas add up the replicas of every deployment of the app.
+	Ready    int32
+	Replicas int32
+	// CreatedAt is when the first deployment of the app was created.
+	CreatedAt time.Time
+	// ExpiresAt is when 'k8run gc' destroys the app. It's zero when the app lives forever.
+	ExpiresAt time.Time
+}
+
+// groupApps groups the deployments sharing a name or an app label into apps, sorted by namespace and name.
+func groupApps(deployments []appsv1.Deployment) []App {
+	apps := map[string]*App{}
+	for _, deployment := range deployments {
+		name := cmp.Or(deployment.Labels[k8s.LabelNameApp], deployment.Name)
+		key := deployment.Namespace + "/" + name
+		app, ok := apps[key]
+		if !ok {
+			app = &App{Name: name, Namespace: deployment.Namespace, CreatedAt: deployment.CreationTimestamp.Time}
+			apps[key] = app
+		}
+
+		app.Deployments = append(app.Deployments, deployment.Name)
+		app.Owner = cmp.Or(app.Owner, deployment.Annotations[k8s.AnnotationNameOwner])
+		app.Ready += deployment.Status.ReadyReplicas
+		if deployment.Spec.Replicas != nil {
+			app.Replicas += *deployment.Spec.Replicas
+		}
+		if deployment.CreationTimestamp.Time.Before(app.CreatedAt) {
+			app.CreatedAt = deployment.CreationTimestamp.Time
+		}
+		if expiresAt, ok := k8s.ExpiresAt(deployment.Annotations); ok && (app.ExpiresAt.IsZero() || expiresAt.Before(app.ExpiresAt)) {
+			app.ExpiresAt = expiresAt
+		}
+	}
+
+	grouped := make([]App, 0, len(apps))
+	for _, app := range apps {
+		slices.Sort(app.Deployments)
+		grouped = append(grouped, *app)
+	}
+	slices.SortFunc(grouped, func(a, b App) int {
+		return cmp.Or(cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Name, b.Name))
+	})
+	return grouped
+}
+
+// NewListCommandParams represents the parameters to create a new list command.
+type NewListCommandParams struct {
+	Namespace     string
+	AllNamespaces bool
+	Timeout       time.Duration
+	Kube          kube.Config
+}
+
+// ListCommand represents a command to list the apps deployed by k8run.
+type ListCommand struct {
+	Namespace string
+	// AllNamespaces lists the apps of every namespace instead of only Namespace.
+	AllNamespaces bool
+	Timeout       time.Duration
+	// Kube is how to reach the cluster.
+	Kube kube.Config
+}
+
+// NewListCommand creates a new list command.
+func NewListCommand(params NewListCommandParams) *ListCommand {
+	return &ListCommand{
+		Namespace:     params.Namespace,
+		AllNamespaces: params.AllNamespaces,
+		Timeout:       params.Timeout,
+		Kube:          params.Kube,
+	}
+}
+
+// Validate validates the parameters of the list command.
+func (c *ListCommand) Validate() error {
+	if c.AllNamespaces && c.Namespace != "" {
+		return fmt.Errorf("AllNamespaces can't be used with Namespace")
+	}
+	if c.Timeout < time.Second {
+		return fmt.Errorf("Timeout must be greater than 1s")
+	}
+	return nil
+}
+
+// List returns the apps deployed by k8run.
+func (c *ListCommand) List(ctx context.Context) ([]App, error) {
+	if !c.AllNamespaces {
+		namespace, err := resolveNamespace(c.Kube, c.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		c.Namespace = namespace
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	clientset, err := newClientset(c.Kube)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.list(ctx, clientset)
+}
+
+func (c *ListCommand) list(ctx context.Context, clientset kubernetes.Interface) ([]App, error) {
+	list := k8s.ListParams{Namespace: c.Namespace}
+	if c.AllNamespaces {
+		list.Namespace = ""
+	}
+
+	deployments, err := k8s.ListDeployments(ctx, clientset, list)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list deployments: %w", err)
+	}
+
+	return groupApps(deployments), nil
+}
+
+// WriteApps writes the given apps as a table.
+func WriteApps(w io.Writer, apps []App) {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tNAME\tOWNER\tREADY\tAGE\tEXPIRES")
+	for _, app := range apps {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d/%d\t%s\t%s\n",
+			app.Namespace, app.Name, cmp.Or(app.Owner, "-"), app.Ready, app.Replicas, age(app.CreatedAt), expires(app.ExpiresAt))
+	}
+	tw.Flush()
+}
+
+// appJSON is an app as written by '--output json'.
+type appJSON struct {
+	Name        string     `json:"name"`
+	Namespace   string     `json:"namespace"`
+	Owner       string     `json:"owner,omitempty"`
+	Deployments []string   `json:"deployments"`
+	Ready       int32      `json:"ready"`
+	Replicas    int32      `json:"replicas"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+}
+
+func (a App) json() appJSON {
+	app := appJSON{
+		Name:        a.Name,
+		Namespace:   a.Namespace,
+		Owner:       a.Owner,
+		Deployments: a.Deployments,
+		Ready:       a.Ready,
+		Replicas:    a.Replicas,
+		CreatedAt:   a.CreatedAt,
+	}
+	if !a.ExpiresAt.IsZero() {
+		app.ExpiresAt = &a.ExpiresAt
+	}
+	return app
+}
+
+// WriteAppsJSON writes the given apps as JSON.
+func WriteAppsJSON(w io.Writer, apps []App) error {
+	list := make([]appJSON, 0, len(apps))
+	for _, app := range apps {
+		list = append(list, app.json())
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Apps []appJSON `json:"apps"`
+	}{list})
+}
+
+// NewStatusCommandParams represents the parameters to create a new status command.
+type NewStatusCommandParams struct {
+	Name      string
+	Namespace string
+	Isolated  bool
+	Timeout   time.Duration
+	Kube      kube.Config
+}
+
+// StatusCommand represents a command to show the status of an app deployed by k8run.
+type StatusCommand struct {
+	Name      string
+	Namespace string
+	// Isolated shows an app deployed into a namespace of its own.
+	Isolated bool
+	Timeout  time.Duration
+	// Kube is how to reach the cluster.
+	Kube kube.Config
+}
+
+// AppStatus is an app with the resources exposing it and the state of its pods.
+type AppStatus struct {
+	App
+	// Release is the release identifier of the last deployment of the app.
+	Release string
+	// Service is the address of the service of the app, if any. eg: 'foo:8080'
+	Service string
+	// Ingress is the host of the ingress of the app, if any.
+	Ingress string
+	Pods    []PodStatus
+}
+
+// PodStatus is the state of a pod of an app.
+type PodStatus struct {
+	Name      string    `json:"name"`
+	Phase     string    `json:"phase"`
+	Ready     bool      `json:"ready"`
+	Restarts  int32     `json:"restarts"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// NewStatusCommand creates a new status command.
+func NewStatusCommand(params NewStatusCommandParams) *StatusCommand {
+	return &StatusCommand{
+		Name:      params.Name,
+		Namespace: params.Namespace,
+		Isolated:  params.Isolated,
+		Timeout:   params.Timeout,
+		Kube:      params.Kube,
+	}
+}
+
+// Validate validates the parameters of the status command.
+func (c *StatusCommand) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("Name is required")
+	}
+	if c.Isolated && c.Namespace != "" {
+		return fmt.Errorf("Isolated can't be used with Namespace")
+	}
+	if c.Timeout < time.Second {
+		return fmt.Errorf("Timeout must be greater than 1s")
+	}
+	return nil
+}
+
+// Status returns the status of the app.
+func (c *StatusCommand) Status(ctx context.Context) (*AppStatus, error) {
+	if c.Isolated {
+		c.Namespace = isolatedNamespace(c.Name)
+	} else {
+		namespace, err := resolveNamespace(c.Kube, c.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		c.Namespace = namespace
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	clientset, err := newClientset(c.Kube)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.status(ctx, clientset)
+}
+
+func (c *StatusCommand) status(ctx context.Context, clientset kubernetes.Interface) (*AppStatus, error) {
+	deployments, err := k8s.ListDeployments(ctx, clientset, k8s.ListParams{Namespace: c.Namespace})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list deployments: %w", err)
+	}
+	deployments = slices.DeleteFunc(deployments, func(d appsv1.Deployment) bool {
+		return cmp.Or(d.Labels[k8s.LabelNameApp], d.Name) != c.Name
+	})
+	if len(deployments) == 0 {
+		return nil, fmt.Errorf("App %s not found in namespace %s", c.Name, c.Namespace)
+	}
+
+	status := &AppStatus{App: groupApps(deployments)[0]}
+	for _, deployment := range deployments {
+		if deployment.Name == c.Name {
+			status.Release = deployment.Labels[k8s.LabelNameReleaseIdentifier]
+		}
+
+		pods, err := k8s.ListPods(ctx, clientset, k8s.ListParams{Namespace: c.Namespace, LabelSelector: "app=" + deployment.Name})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list pods: %w", err)
+		}
+		for _, pod := range pods {
+			status.Pods = append(status.Pods, podStatus(pod))
+		}
+	}
+
+	get := k8s.GetParams{Name: c.Name, Namespace: c.Namespace}
+	service, err := k8s.GetService(ctx, clientset, get)
+	if err != nil && !errors.Is(err, k8s.ErrResourceNotFound) {
+		return nil, fmt.Errorf("Failed to get service: %w", err)
+	}
+	if service != nil && len(service.Spec.Ports) > 0 {
+		status.Service = fmt.Sprintf("%s:%d", service.Name, service.Spec.Ports[0].Port)
+	}
+
+	ingress, err := k8s.GetIngress(ctx, clientset, get)
+	if err != nil && !errors.Is(err, k8s.ErrResourceNotFound) {
+		return nil, fmt.Errorf("Failed to get ingress: %w", err)
+	}
+	if ingress != nil && len(ingress.Spec.Rules) > 0 {
+		status.Ingress = ingress.Spec.Rules[0].Host
+	}
+
+	return status, nil
+}
+
+func podStatus(pod corev1.Pod) PodStatus {
+	status := PodStatus{Name: pod.Name, Phase: string(pod.Status.Phase), CreatedAt: pod.CreationTimestamp.Time}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			status.Ready = condition.Status == corev1.ConditionTrue
+		}
+	}
+	for _, container := range pod.Status.ContainerStatuses {
+		status.Restarts += container.RestartCount
+	}
+	return status
+}
+
+// Write writes the status of the app in a human readable form.
+func (s *AppStatus) Write(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%s\n", s.Name)
+	fmt.Fprintf(tw, "Namespace:\t%s\n", s.Namespace)
+	fmt.Fprintf(tw, "Owner:\t%s\n", cmp.Or(s.Owner, "-"))
+	fmt.Fprintf(tw, "Deployments:\t%s\n", strings.Join(s.Deployments, ", "))
+	fmt.Fprintf(tw, "Ready:\t%d/%d\n", s.Ready, s.Replicas)
+	fmt.Fprintf(tw, "Age:\t%s\n", age(s.CreatedAt))
+	fmt.Fprintf(tw, "Expires:\t%s\n", expires(s.ExpiresAt))
+	fmt.Fprintf(tw, "Release:\t%s\n", cmp.Or(s.Release, "-"))
+	fmt.Fprintf(tw, "Service:\t%s\n", cmp.Or(s.Service, "-"))
+	fmt.Fprintf(tw, "Ingress:\t%s\n", cmp.Or(s.Ingress, "-"))
+	tw.Flush()
+
+	if len(s.Pods) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "POD\tREADY\tSTATUS\tRESTARTS\tAGE")
+	for _, pod := range s.Pods {
+		fmt.Fprintf(tw, "%s\t%t\t%s\t%d\t%s\n", pod.Name, pod.Ready, pod.Phase, pod.Restarts, age(pod.CreatedAt))
+	}
+	tw.Flush()
+}
+
+// WriteJSON writes the status of the app as JSON.
+func (s *AppStatus) WriteJSON(w io.Writer) error {
+	pods := s.Pods
+	if pods == nil {
+		pods = []PodStatus{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		appJSON
+		Release string      `json:"release,omitempty"`
+		Service string      `json:"service,omitempty"`
+		Ingress string      `json:"ingress,omitempty"`
+		Pods    []PodStatus `json:"pods"`
+	}{s.App.json(), s.Release, s.Service, s.Ingress, pods})
+}
+
+// age returns how long ago t was, as kubectl shows it. eg: '3d4h'
+func age(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return duration.HumanDuration(time.Since(t))
+}
+
+// expires returns when an app expires, relative to now.
+func expires(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	if remaining := time.Until(t); remaining > 0 {
+		return "in " + duration.HumanDuration(remaining)
+	}
+	return "expired"
+}
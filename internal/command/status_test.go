@@ -0,0 +1,132 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func statusObjects() []runtime.Object {
+	replicas := int32(2)
+	deployment := func(name, namespace, app, owner string) *appsv1.Deployment {
+		d := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels: map[string]string{
+					k8s.LabelNameCreatedBy:         k8s.LabelValueCreatedBy,
+					k8s.LabelNameReleaseIdentifier: "abc",
+				},
+				Annotations: map[string]string{k8s.AnnotationNameOwner: owner},
+			},
+			Spec:   appsv1.DeploymentSpec{Replicas: &replicas},
+			Status: appsv1.DeploymentStatus{ReadyReplicas: 1},
+		}
+		if app != "" {
+			d.Labels[k8s.LabelNameApp] = app
+		}
+		return d
+	}
+
+	return []runtime.Object{
+		deployment("shop", "default", "shop", "jane"),
+		deployment("shop-worker", "default", "shop", "jane"),
+		deployment("api", "team-a", "", "john"),
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 8080}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "shop-1",
+				Namespace: "default",
+				Labels:    map[string]string{"app": "shop", k8s.LabelNameCreatedBy: k8s.LabelValueCreatedBy},
+			},
+			Status: corev1.PodStatus{
+				Phase:             corev1.PodRunning,
+				Conditions:        []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+				ContainerStatuses: []corev1.ContainerStatus{{RestartCount: 2}},
+			},
+		},
+	}
+}
+
+func TestListCommand_List(t *testing.T) {
+	c := &ListCommand{AllNamespaces: true, Timeout: time.Minute}
+	apps, err := c.list(context.Background(), fake.NewSimpleClientset(statusObjects()...))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(apps) != 2 {
+		t.Fatalf("expected 2 apps, got %+v", apps)
+	}
+	shop := apps[0]
+	if shop.Name != "shop" || shop.Owner != "jane" || shop.Ready != 2 || shop.Replicas != 4 || len(shop.Deployments) != 2 {
+		t.Errorf("expected the processes of shop to be grouped, got %+v", shop)
+	}
+	if apps[1].Name != "api" || apps[1].Owner != "john" {
+		t.Errorf("expected api to be owned by john, got %+v", apps[1])
+	}
+
+	out := &bytes.Buffer{}
+	WriteApps(out, apps)
+	if !strings.Contains(out.String(), "OWNER") || !strings.Contains(out.String(), "john") {
+		t.Errorf("expected the owners to be listed, got:\n%s", out)
+	}
+}
+
+func TestStatusCommand_Status(t *testing.T) {
+	c := &StatusCommand{Name: "shop", Namespace: "default", Timeout: time.Minute}
+	status, err := c.status(context.Background(), fake.NewSimpleClientset(statusObjects()...))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if status.Owner != "jane" || status.Release != "abc" || status.Service != "shop:8080" || status.Ingress != "" {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if len(status.Pods) != 1 || !status.Pods[0].Ready || status.Pods[0].Restarts != 2 {
+		t.Errorf("expected the pod of shop, got %+v", status.Pods)
+	}
+
+	out := &bytes.Buffer{}
+	status.Write(out)
+	if !strings.Contains(out.String(), "Owner:") || !strings.Contains(out.String(), "shop-1") {
+		t.Errorf("expected the owner and pods to be shown, got:\n%s", out)
+	}
+
+	out.Reset()
+	if err := status.WriteJSON(out); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var got struct {
+		Name      string      `json:"name"`
+		Owner     string      `json:"owner"`
+		Release   string      `json:"release"`
+		ExpiresAt *time.Time  `json:"expiresAt"`
+		Pods      []PodStatus `json:"pods"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("expected valid JSON, got %v", err)
+	}
+	if got.Name != "shop" || got.Owner != "jane" || got.Release != "abc" || got.ExpiresAt != nil || len(got.Pods) != 1 {
+		t.Errorf("unexpected JSON status:\n%s", out)
+	}
+
+	c = &StatusCommand{Name: "missing", Namespace: "default", Timeout: time.Minute}
+	if _, err := c.status(context.Background(), fake.NewSimpleClientset(statusObjects()...)); err == nil {
+		t.Errorf("expected an error for a missing app")
+	}
+}
@@ -0,0 +1,291 @@
+package command
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/config"
+	"github.com/lucasvmiguel/k8run/internal/kube"
+	"github.com/lucasvmiguel/k8run/internal/logging"
+	"github.com/lucasvmiguel/k8run/internal/plan"
+)
+
+// NewUpCommandParams represents the parameters to create a new up command.
+type NewUpCommandParams struct {
+	File        string
+	Timeout     time.Duration
+	Owner       string
+	Takeover    bool
+	WaitForLock bool
+	Kube        kube.Config
+}
+
+// UpCommand represents a command to deploy every app described by a config file.
+type UpCommand struct {
+	File string
+	// Timeout is the timeout of each app that doesn't declare its own.
+	Timeout time.Duration
+	// Owner is who deploys the apps. Defaults to the git email or the kubeconfig user.
+	Owner string
+	// Takeover allows replacing apps deployed by someone else.
+	Takeover bool
+	// WaitForLock waits for other deployments or destroys of the apps to finish instead of failing.
+	WaitForLock bool
+	// Kube is how to reach the cluster.
+	Kube kube.Config
+
+	config      *config.Config
+	deployments map[string]*DeploymentCommand
+}
+
+// NewUpCommand creates a new up command.
+func NewUpCommand(params NewUpCommandParams) *UpCommand {
+	return &UpCommand{
+		File:        params.File,
+		Timeout:     params.Timeout,
+		Owner:       params.Owner,
+		Takeover:    params.Takeover,
+		WaitForLock: params.WaitForLock,
+		Kube:        params.Kube,
+	}
+}
+
+// Validate validates the config file and the deployment of every app.
+func (c *UpCommand) Validate() error {
+	cfg, err := loadConfig(c.File)
+	if err != nil {
+		return err
+	}
+
+	return c.validateConfig(cfg)
+}
+
+func (c *UpCommand) validateConfig(cfg *config.Config) error {
+	c.config = cfg
+	c.deployments = map[string]*DeploymentCommand{}
+	for _, app := range cfg.Apps {
+		deployment := deploymentFromApp(cfg, app, c.Timeout)
+		deployment.Owner = c.Owner
+		deployment.Takeover = c.Takeover
+		deployment.WaitForLock = c.WaitForLock
+		deployment.Kube = c.Kube
+		if err := deployment.Validate(); err != nil {
+			return fmt.Errorf("App %q is invalid: %w", app.Name, err)
+		}
+		c.deployments[app.Name] = deployment
+	}
+
+	return nil
+}
+
+// Run deploys the apps concurrently, respecting their dependencies.
+func (c *UpCommand) Run(ctx context.Context) (err error) {
+	logging.FromContext(ctx).With("file", c.File, "apps", len(c.config.Apps)).Info("Starting up...")
+	defer func() { err = timedOut(ctx, err) }()
+
+	err = runGraph(ctx, c.config.Dependencies(), false, func(ctx context.Context, name string) error {
+		return c.deployments[name].Run(ctx)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to deploy apps:\n%w", err)
+	}
+
+	logging.FromContext(ctx).Info("Up finished!")
+
+	return nil
+}
+
+// Plan returns the changes of every app, in the order of the config file.
+func (c *UpCommand) Plan(ctx context.Context) (*plan.Plan, error) {
+	clientset, err := newClientset(c.Kube)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &plan.Plan{}
+	for _, app := range c.config.Apps {
+		deployment := c.deployments[app.Name]
+		if err := deployment.resolve(); err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, deployment.Timeout)
+		appPlan, err := deployment.plan(ctx, clientset)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("App %q: %w", app.Name, err)
+		}
+		p.Merge(appPlan)
+	}
+
+	return p, nil
+}
+
+// NewDownCommandParams represents the parameters to create a new down command.
+type NewDownCommandParams struct {
+	File        string
+	Timeout     time.Duration
+	Owner       string
+	Takeover    bool
+	WaitForLock bool
+	Kube        kube.Config
+}
+
+// DownCommand represents a command to destroy every app described by a config file.
+type DownCommand struct {
+	File    string
+	Timeout time.Duration
+	// Owner is who destroys the apps. Defaults to the git email or the kubeconfig user.
+	Owner string
+	// Takeover allows destroying apps deployed by someone else.
+	Takeover bool
+	// WaitForLock waits for other deployments or destroys of the apps to finish instead of failing.
+	WaitForLock bool
+	// Kube is how to reach the cluster.
+	Kube kube.Config
+
+	config   *config.Config
+	destroys map[string]*DestroyCommand
+}
+
+// NewDownCommand creates a new down command.
+func NewDownCommand(params NewDownCommandParams) *DownCommand {
+	return &DownCommand{
+		File:        params.File,
+		Timeout:     params.Timeout,
+		Owner:       params.Owner,
+		Takeover:    params.Takeover,
+		WaitForLock: params.WaitForLock,
+		Kube:        params.Kube,
+	}
+}
+
+// Validate validates the config file and the destroy of every app.
+func (c *DownCommand) Validate() error {
+	cfg, err := loadConfig(c.File)
+	if err != nil {
+		return err
+	}
+
+	return c.validateConfig(cfg)
+}
+
+func (c *DownCommand) validateConfig(cfg *config.Config) error {
+	c.config = cfg
+	c.destroys = map[string]*DestroyCommand{}
+	for _, app := range cfg.Apps {
+		destroy := NewDestroyCommand(NewDestroyCommandParams{
+			Name:        app.Name,
+			Namespace:   cfg.NamespaceOf(app),
+			Timeout:     cmp.Or(time.Duration(app.Timeout), c.Timeout),
+			Owner:       c.Owner,
+			Takeover:    c.Takeover,
+			WaitForLock: c.WaitForLock,
+			Kube:        c.Kube,
+		})
+		if err := destroy.Validate(); err != nil {
+			return fmt.Errorf("App %q is invalid: %w", app.Name, err)
+		}
+		c.destroys[app.Name] = destroy
+	}
+
+	return nil
+}
+
+// Run destroys the apps concurrently, destroying dependents before their dependencies.
+func (c *DownCommand) Run(ctx context.Context) (err error) {
+	logging.FromContext(ctx).With("file", c.File, "apps", len(c.config.Apps)).Info("Starting down...")
+	defer func() { err = timedOut(ctx, err) }()
+
+	err = runGraph(ctx, c.config.Dependencies(), true, func(ctx context.Context, name string) error {
+		return c.destroys[name].Run(ctx)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to destroy apps:\n%w", err)
+	}
+
+	logging.FromContext(ctx).Info("Down finished!")
+
+	return nil
+}
+
+// Plan returns the resources of every app that will be deleted, in the order of the config file.
+func (c *DownCommand) Plan(ctx context.Context) (*plan.Plan, error) {
+	clientset, err := newClientset(c.Kube)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &plan.Plan{}
+	for _, app := range c.config.Apps {
+		destroy := c.destroys[app.Name]
+		if err := destroy.resolve(); err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, destroy.Timeout)
+		appPlan, err := destroy.plan(ctx, clientset)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("App %q: %w", app.Name, err)
+		}
+		p.Merge(appPlan)
+	}
+
+	return p, nil
+}
+
+func loadConfig(file string) (*config.Config, error) {
+	cfg, err := config.Load(cmp.Or(file, config.DefaultFile))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load config: %w", err)
+	}
+	return cfg, nil
+}
+
+// deploymentFromApp creates the deployment command of an app of the config file.
+func deploymentFromApp(cfg *config.Config, app config.App, timeout time.Duration) *DeploymentCommand {
+	params := NewDeploymentCommandParams{
+		Name:          app.Name,
+		Namespace:     cfg.NamespaceOf(app),
+		Entrypoint:    app.Entrypoint,
+		WorkDir:       app.WorkDir,
+		Setup:         app.Setup,
+		SetupCache:    app.SetupCache,
+		Auto:          app.Auto,
+		NodeArch:      app.NodeArch,
+		Copy:          app.Copy,
+		NoCopy:        app.Copy == "",
+		Image:         app.Image,
+		Replicas:      cmp.Or(app.Replicas, 1),
+		Timeout:       cmp.Or(time.Duration(app.Timeout), timeout),
+		Service:       app.Service,
+		ContainerPort: app.ContainerPort,
+		Port:          app.Port,
+		Env:           map[string]string{},
+	}
+
+	if app.Ingress != nil {
+		params.Ingress = true
+		params.IngressHost = app.Ingress.Host
+		params.IngressClass = app.Ingress.Class
+	}
+
+	for name, value := range app.Env {
+		params.Env[name] = string(value)
+	}
+
+	if app.Resources != nil {
+		params.Resources = Resources{Requests: map[string]string{}, Limits: map[string]string{}}
+		for name, value := range app.Resources.Requests {
+			params.Resources.Requests[name] = string(value)
+		}
+		for name, value := range app.Resources.Limits {
+			params.Resources.Limits[name] = string(value)
+		}
+	}
+
+	return NewDeploymentCommand(params)
+}
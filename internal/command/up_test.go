@@ -0,0 +1,131 @@
+package command_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/command"
+	"github.com/lucasvmiguel/k8run/internal/kube"
+
+	"k8s.io/client-go/rest"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "k8run.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUpCommand_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		timeout time.Duration
+		wantErr bool
+	}{
+		{
+			name:    "valid config",
+			config:  "version: v1\napps: [{name: api, image: node, copy: ., entrypoint: node index.js}]",
+			timeout: time.Minute,
+			wantErr: false,
+		},
+		{
+			name:    "invalid config",
+			config:  "version: v1\napps: []",
+			timeout: time.Minute,
+			wantErr: true,
+		},
+		{
+			name:    "invalid app",
+			config:  "version: v1\napps: [{name: api, image: node, copy: ., timeout: 1s}]",
+			timeout: time.Minute,
+			wantErr: true,
+		},
+		{
+			name:    "invalid resources",
+			config:  "version: v1\napps: [{name: api, image: node, copy: ., resources: {limits: {cpu: lots}}}]",
+			timeout: time.Minute,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := command.NewUpCommand(command.NewUpCommandParams{
+				File:    writeConfig(t, tt.config),
+				Timeout: tt.timeout,
+			})
+			err := c.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDownCommand_Validate(t *testing.T) {
+	c := command.NewDownCommand(command.NewDownCommandParams{
+		File:    writeConfig(t, "version: v1\napps: [{name: api, image: node, copy: .}]"),
+		Timeout: time.Minute,
+	})
+	if err := c.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	c = command.NewDownCommand(command.NewDownCommandParams{
+		File:    filepath.Join(t.TempDir(), "missing.yaml"),
+		Timeout: time.Minute,
+	})
+	if err := c.Validate(); err == nil {
+		t.Errorf("expected error for missing file, got nil")
+	}
+}
+
+// forbiddenCluster returns the config of a cluster denying every request.
+func forbiddenCluster(t *testing.T) kube.Config {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "Forbidden", "code": 403}`))
+	}))
+	t.Cleanup(server.Close)
+	return kube.Config{REST: &rest.Config{Host: server.URL}}
+}
+
+func TestUpCommand_RunExitCode(t *testing.T) {
+	config := "version: v1\napps: [{name: api, image: node, copy: ., entrypoint: node index.js}]"
+
+	c := command.NewUpCommand(command.NewUpCommandParams{File: writeConfig(t, config), Timeout: time.Minute, Owner: "test", Kube: forbiddenCluster(t)})
+	if err := c.Validate(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := c.Run(context.Background()); command.ExitCode(err) != command.ExitAuth {
+		t.Errorf("expected the auth error of the app to be kept, got %d: %v", command.ExitCode(err), err)
+	}
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if err := c.Run(expired); command.ExitCode(err) != command.ExitTimeout {
+		t.Errorf("expected a timeout, got %d: %v", command.ExitCode(err), err)
+	}
+
+	down := command.NewDownCommand(command.NewDownCommandParams{File: writeConfig(t, config), Timeout: time.Minute, Owner: "test", Kube: forbiddenCluster(t)})
+	if err := down.Validate(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := down.Run(context.Background()); command.ExitCode(err) != command.ExitAuth {
+		t.Errorf("expected the forbidden lease to be an auth error, got %d: %v", command.ExitCode(err), err)
+	}
+	if err := down.Run(expired); command.ExitCode(err) != command.ExitTimeout {
+		t.Errorf("expected a timeout, got %d: %v", command.ExitCode(err), err)
+	}
+}
@@ -0,0 +1,542 @@
+package compose
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lucasvmiguel/k8run/internal/config"
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Warning reports a part of the compose file that k8run can't translate.
+type Warning struct {
+	Service string
+	Key     string
+	Message string
+}
+
+// String implements fmt.Stringer.
+func (w Warning) String() string {
+	if w.Service == "" {
+		return fmt.Sprintf("%s: %s", w.Key, w.Message)
+	}
+	return fmt.Sprintf("service %q, %s: %s", w.Service, w.Key, w.Message)
+}
+
+// supportedKeys are the service keys translated by k8run.
+var supportedKeys = []string{"image", "command", "build", "ports", "expose", "environment", "env_file", "depends_on", "deploy"}
+
+// ignoredTopLevelKeys are top level keys that don't affect the translation.
+var ignoredTopLevelKeys = []string{"version", "name", "services"}
+
+// Translate reads a docker-compose file and translates each of its services into an app of a k8run config.
+// Everything that can't be translated is reported as a warning instead of being silently dropped.
+func Translate(path string, namespace string) (*config.Config, []Warning, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	lookup, err := envLookup(filepath.Join(dir, ".env"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	interpolated, err := interpolate(string(b), lookup)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(interpolated), &file); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+
+	t := &translator{dir: dir, lookup: lookup}
+
+	for _, key := range sortedKeys(file) {
+		if !slices.Contains(ignoredTopLevelKeys, key) {
+			t.warn("", key, "top level key is not supported and was ignored")
+		}
+	}
+
+	services, ok := file["services"].(map[string]interface{})
+	if !ok || len(services) == 0 {
+		return nil, nil, fmt.Errorf("compose file has no services")
+	}
+
+	cfg := &config.Config{Version: config.Version, Namespace: namespace}
+	for _, name := range sortedKeys(services) {
+		service, ok := services[name].(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("service %q must be a map", name)
+		}
+
+		app, err := t.translateService(name, service)
+		if err != nil {
+			return nil, nil, fmt.Errorf("service %q: %w", name, err)
+		}
+		cfg.Apps = append(cfg.Apps, app)
+	}
+
+	t.warnUnreachableDependencies(cfg)
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, t.warnings, nil
+}
+
+type translator struct {
+	dir      string
+	lookup   func(string) (string, bool)
+	warnings []Warning
+}
+
+func (t *translator) warn(service, key, format string, args ...interface{}) {
+	t.warnings = append(t.warnings, Warning{Service: service, Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+func (t *translator) translateService(name string, service map[string]interface{}) (config.App, error) {
+	app := config.App{Name: appName(name)}
+	if app.Name != name {
+		t.warn(name, "name", "renamed to %q to be a valid Kubernetes name, so other services must use that DNS name", app.Name)
+	}
+
+	for _, key := range sortedKeys(service) {
+		if !slices.Contains(supportedKeys, key) {
+			t.warn(name, key, "is not supported and was ignored")
+		}
+	}
+
+	if image, ok := service["image"].(string); ok {
+		app.Image = image
+	}
+
+	if build, ok := service["build"]; ok {
+		if err := t.translateBuild(name, build, &app); err != nil {
+			return app, err
+		}
+	}
+
+	if app.Image == "" {
+		return app, fmt.Errorf("either image or build is required")
+	}
+
+	if command, ok := service["command"]; ok {
+		entrypoint, err := toCommand(command)
+		if err != nil {
+			return app, fmt.Errorf("command: %w", err)
+		}
+		app.Entrypoint = entrypoint
+	}
+
+	if err := t.translatePorts(name, service, &app); err != nil {
+		return app, err
+	}
+
+	env, err := t.translateEnv(name, service)
+	if err != nil {
+		return app, err
+	}
+	if len(env) > 0 {
+		app.Env = env
+	}
+
+	if dependsOn, ok := service["depends_on"]; ok {
+		deps, err := toDependencies(dependsOn)
+		if err != nil {
+			return app, fmt.Errorf("depends_on: %w", err)
+		}
+		app.DependsOn = deps
+	}
+
+	if deploy, ok := service["deploy"].(map[string]interface{}); ok {
+		if err := t.translateDeploy(name, deploy, &app); err != nil {
+			return app, err
+		}
+	}
+
+	return app, nil
+}
+
+// translateBuild turns the build context into the copy source. As nothing is built, the image falls back
+// to the runtime stage of the Dockerfile when the service doesn't declare one.
+func (t *translator) translateBuild(name string, build interface{}, app *config.App) error {
+	context, dockerfile := "", "Dockerfile"
+	switch b := build.(type) {
+	case string:
+		context = b
+	case map[string]interface{}:
+		context, _ = b["context"].(string)
+		if d, ok := b["dockerfile"].(string); ok {
+			dockerfile = d
+		}
+		for _, key := range sortedKeys(b) {
+			if key != "context" && key != "dockerfile" {
+				t.warn(name, "build."+key, "is not supported and was ignored")
+			}
+		}
+	default:
+		return fmt.Errorf("build must be a string or a map")
+	}
+
+	context = cmp.Or(context, ".")
+	if !filepath.IsAbs(context) {
+		context = filepath.Join(t.dir, context)
+	}
+	app.Copy = context
+	// the context folder lands in the copy destination under its own name
+	app.WorkDir = "/app/" + filepath.Base(context)
+
+	if app.Image != "" {
+		return nil
+	}
+
+	if !filepath.IsAbs(dockerfile) {
+		dockerfile = filepath.Join(context, dockerfile)
+	}
+	image, err := runtimeImage(dockerfile)
+	if err != nil {
+		return fmt.Errorf("build: service has no image and %w", err)
+	}
+	app.Image = image
+	t.warn(name, "build", "the Dockerfile is not built; the context is copied into its runtime image %q instead", image)
+
+	return nil
+}
+
+func (t *translator) translatePorts(name string, service map[string]interface{}, app *config.App) error {
+	ports := []int64{}
+
+	if list, ok := service["ports"].([]interface{}); ok {
+		for _, entry := range list {
+			port, err := containerPort(entry)
+			if err != nil {
+				return fmt.Errorf("ports: %w", err)
+			}
+			ports = append(ports, port)
+		}
+	}
+
+	if list, ok := service["expose"].([]interface{}); ok {
+		for _, entry := range list {
+			port, err := containerPort(fmt.Sprint(entry))
+			if err != nil {
+				return fmt.Errorf("expose: %w", err)
+			}
+			ports = append(ports, port)
+		}
+	}
+
+	if len(ports) == 0 {
+		return nil
+	}
+
+	// other services reach this one on the container port, so the service listens on the same port
+	app.Service = true
+	app.ContainerPort = ports[0]
+	app.Port = ports[0]
+
+	for _, port := range ports[1:] {
+		if port != ports[0] {
+			t.warn(name, "ports", "only one port is supported, port %d was ignored", port)
+		}
+	}
+
+	return nil
+}
+
+func (t *translator) translateEnv(name string, service map[string]interface{}) (map[string]config.Scalar, error) {
+	env := map[string]config.Scalar{}
+
+	if envFile, ok := service["env_file"]; ok {
+		files := []string{}
+		switch f := envFile.(type) {
+		case string:
+			files = append(files, f)
+		case []interface{}:
+			for _, entry := range f {
+				switch e := entry.(type) {
+				case string:
+					files = append(files, e)
+				case map[string]interface{}:
+					path, _ := e["path"].(string)
+					required, hasRequired := e["required"].(bool)
+					if hasRequired && !required {
+						if _, err := os.Stat(filepath.Join(t.dir, path)); err != nil {
+							continue
+						}
+					}
+					files = append(files, path)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("env_file must be a string or a list")
+		}
+
+		for _, file := range files {
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(t.dir, file)
+			}
+			vars, err := readEnvFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("env_file: %w", err)
+			}
+			for k, v := range vars {
+				env[k] = config.Scalar(v)
+			}
+		}
+	}
+
+	switch e := service["environment"].(type) {
+	case nil:
+	case map[string]interface{}:
+		for k, v := range e {
+			if v == nil {
+				value, ok := t.lookup(k)
+				if !ok {
+					t.warn(name, "environment."+k, "has no value and is not set in the environment, so it was ignored")
+					continue
+				}
+				env[k] = config.Scalar(value)
+				continue
+			}
+			env[k] = config.Scalar(scalarString(v))
+		}
+	case []interface{}:
+		for _, entry := range e {
+			s, _ := entry.(string)
+			k, v, ok := strings.Cut(s, "=")
+			if !ok {
+				value, found := t.lookup(k)
+				if !found {
+					t.warn(name, "environment."+k, "has no value and is not set in the environment, so it was ignored")
+					continue
+				}
+				v = value
+			}
+			env[k] = config.Scalar(v)
+		}
+	default:
+		return nil, fmt.Errorf("environment must be a map or a list")
+	}
+
+	return env, nil
+}
+
+func (t *translator) translateDeploy(name string, deploy map[string]interface{}, app *config.App) error {
+	for _, key := range sortedKeys(deploy) {
+		if key != "replicas" && key != "resources" {
+			t.warn(name, "deploy."+key, "is not supported and was ignored")
+		}
+	}
+
+	if replicas, ok := deploy["replicas"].(float64); ok {
+		app.Replicas = int32(replicas)
+	}
+
+	resources, ok := deploy["resources"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	app.Resources = &config.Resources{}
+	for key, target := range map[string]*map[string]config.Scalar{
+		"limits":       &app.Resources.Limits,
+		"reservations": &app.Resources.Requests,
+	} {
+		values, ok := resources[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		*target = map[string]config.Scalar{}
+		for _, k := range sortedKeys(values) {
+			switch k {
+			case "cpus":
+				(*target)["cpu"] = config.Scalar(scalarString(values[k]))
+			case "memory":
+				memory, err := memoryQuantity(scalarString(values[k]))
+				if err != nil {
+					return fmt.Errorf("deploy.resources.%s.memory: %w", key, err)
+				}
+				(*target)["memory"] = config.Scalar(memory)
+			default:
+				t.warn(name, fmt.Sprintf("deploy.resources.%s.%s", key, k), "is not supported and was ignored")
+			}
+		}
+	}
+
+	return nil
+}
+
+// warnUnreachableDependencies warns about services other services depend on but that expose no port,
+// since in Kubernetes they're only reachable through a Service.
+func (t *translator) warnUnreachableDependencies(cfg *config.Config) {
+	ports := map[string]bool{}
+	for _, app := range cfg.Apps {
+		ports[app.Name] = app.Service
+	}
+
+	warned := map[string]bool{}
+	for _, app := range cfg.Apps {
+		for _, dep := range app.DependsOn {
+			if !ports[dep] && !warned[dep] {
+				warned[dep] = true
+				t.warn(dep, "ports", "has no ports or expose, so other services can't reach it by its name")
+			}
+		}
+	}
+}
+
+// appName turns a compose service name into a valid DNS-1123 label.
+func appName(name string) string {
+	return k8s.SanitizeName(name, "service")
+}
+
+// containerPort extracts the container port from the short ("8080:3000/tcp") or long ({target: 3000}) port syntax.
+func containerPort(entry interface{}) (int64, error) {
+	var target string
+	switch e := entry.(type) {
+	case float64:
+		return int64(e), nil
+	case string:
+		target = e
+		if i := strings.LastIndex(target, ":"); i >= 0 {
+			target = target[i+1:]
+		}
+		target, _, _ = strings.Cut(target, "/")
+	case map[string]interface{}:
+		target = scalarString(e["target"])
+	default:
+		return 0, fmt.Errorf("unsupported port %v", entry)
+	}
+
+	if strings.Contains(target, "-") {
+		return 0, fmt.Errorf("port ranges are not supported: %v", entry)
+	}
+
+	port, err := strconv.ParseInt(target, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %v", entry)
+	}
+	return port, nil
+}
+
+func toCommand(command interface{}) ([]string, error) {
+	switch c := command.(type) {
+	case string:
+		return splitCommand(c)
+	case []interface{}:
+		args := []string{}
+		for _, arg := range c {
+			args = append(args, scalarString(arg))
+		}
+		return args, nil
+	default:
+		return nil, fmt.Errorf("must be a string or a list")
+	}
+}
+
+func toDependencies(dependsOn interface{}) ([]string, error) {
+	deps := []string{}
+	switch d := dependsOn.(type) {
+	case []interface{}:
+		for _, dep := range d {
+			deps = append(deps, appName(scalarString(dep)))
+		}
+	case map[string]interface{}:
+		for _, dep := range sortedKeys(d) {
+			deps = append(deps, appName(dep))
+		}
+	default:
+		return nil, fmt.Errorf("must be a list or a map")
+	}
+	return deps, nil
+}
+
+// runtimeImage returns the image of the last stage of a Dockerfile.
+func runtimeImage(dockerfile string) (string, error) {
+	f, err := os.Open(dockerfile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Dockerfile: %w", err)
+	}
+	defer f.Close()
+
+	stages := []string{}
+	image := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+		fields = slices.DeleteFunc(fields[1:], func(f string) bool { return strings.HasPrefix(f, "--") })
+		if len(fields) == 0 {
+			continue
+		}
+		image = fields[0]
+		if len(fields) >= 3 && strings.EqualFold(fields[1], "AS") {
+			stages = append(stages, fields[2])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read Dockerfile: %w", err)
+	}
+
+	if image == "" {
+		return "", fmt.Errorf("the Dockerfile has no FROM instruction")
+	}
+	if slices.Contains(stages, image) {
+		return "", fmt.Errorf("the runtime stage of the Dockerfile is built from stage %q", image)
+	}
+	return image, nil
+}
+
+// memoryQuantity converts a compose byte value (eg: '512m', '1gb') into a Kubernetes quantity (eg: '512Mi').
+func memoryQuantity(s string) (string, error) {
+	value := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "b")
+	units := map[string]string{"k": "Ki", "m": "Mi", "g": "Gi", "t": "Ti"}
+	if len(value) > 0 {
+		if unit, ok := units[value[len(value)-1:]]; ok {
+			value = value[:len(value)-1] + unit
+		}
+	}
+
+	number := strings.TrimRight(value, "KMGTi")
+	if _, err := strconv.ParseFloat(number, 64); err != nil {
+		return "", fmt.Errorf("invalid memory %q", s)
+	}
+	return value, nil
+}
+
+func scalarString(v interface{}) string {
+	switch t := v.(type) {
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
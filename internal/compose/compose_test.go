@@ -0,0 +1,142 @@
+package compose_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/compose"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const composeFile = `
+services:
+  web:
+    build: ./web
+    command: node server.js --name "my web"
+    ports: ["8080:3000"]
+    environment:
+      DB_HOST: db
+      LEVEL: ${LEVEL:-info}
+    env_file: web.env
+    depends_on: [db]
+    restart: always
+    deploy:
+      replicas: 2
+      resources:
+        limits: {cpus: "0.5", memory: 512M}
+  db:
+    image: postgres:16
+    expose: ["5432"]
+    environment:
+      - POSTGRES_PASSWORD=${DB_PASSWORD}
+volumes:
+  data: {}
+`
+
+func TestTranslate(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "docker-compose.yml"), composeFile)
+	writeFile(t, filepath.Join(dir, ".env"), "DB_PASSWORD=secret\n")
+	writeFile(t, filepath.Join(dir, "web.env"), "SECRET='abc'\n# comment\n")
+	writeFile(t, filepath.Join(dir, "web", "Dockerfile"), "FROM node:22 AS build\nRUN npm ci\nFROM node:22-slim\n")
+
+	cfg, warnings, err := compose.Translate(filepath.Join(dir, "docker-compose.yml"), "prototypes")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if cfg.Namespace != "prototypes" || len(cfg.Apps) != 2 {
+		t.Fatalf("expected 2 apps in namespace prototypes, got %+v", cfg)
+	}
+
+	var web, db = cfg.Apps[0], cfg.Apps[1]
+	if web.Name != "web" {
+		web, db = db, web
+	}
+
+	if web.Image != "node:22-slim" {
+		t.Errorf("expected the runtime stage image, got %q", web.Image)
+	}
+	if web.Copy != filepath.Join(dir, "web") || web.WorkDir != "/app/web" {
+		t.Errorf("expected the build context to be copied, got copy %q workdir %q", web.Copy, web.WorkDir)
+	}
+	if !slices.Equal(web.Entrypoint, []string{"node", "server.js", "--name", "my web"}) {
+		t.Errorf("expected the command to be shell split, got %q", web.Entrypoint)
+	}
+	if !web.Service || web.Port != 3000 || web.ContainerPort != 3000 {
+		t.Errorf("expected a service on port 3000, got %+v", web)
+	}
+	if web.Env["DB_HOST"] != "db" || web.Env["LEVEL"] != "info" || web.Env["SECRET"] != "abc" {
+		t.Errorf("expected environment and env_file to be merged, got %v", web.Env)
+	}
+	if !slices.Equal(web.DependsOn, []string{"db"}) {
+		t.Errorf("expected depends_on to be kept, got %v", web.DependsOn)
+	}
+	if web.Replicas != 2 || web.Resources == nil || web.Resources.Limits["cpu"] != "0.5" || web.Resources.Limits["memory"] != "512Mi" {
+		t.Errorf("expected deploy to be translated, got replicas %d resources %+v", web.Replicas, web.Resources)
+	}
+
+	if db.Image != "postgres:16" || db.Copy != "" {
+		t.Errorf("expected the image to run as it is, got %+v", db)
+	}
+	if db.Env["POSTGRES_PASSWORD"] != "secret" {
+		t.Errorf("expected .env interpolation, got %v", db.Env)
+	}
+
+	got := []string{}
+	for _, w := range warnings {
+		got = append(got, w.String())
+	}
+	for _, want := range []string{`volumes`, `service "web", restart`, `service "web", build`} {
+		if !slices.ContainsFunc(got, func(s string) bool { return strings.HasPrefix(s, want+":") }) {
+			t.Errorf("expected a warning for %s, got %v", want, got)
+		}
+	}
+}
+
+func TestTranslate_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		compose string
+	}{
+		{
+			name:    "no services",
+			compose: "services: {}",
+		},
+		{
+			name:    "missing image",
+			compose: "services: {web: {command: node}}",
+		},
+		{
+			name:    "required variable",
+			compose: "services: {web: {image: '${IMAGE:?image is required}'}}",
+		},
+		{
+			name:    "unknown dependency",
+			compose: "services: {web: {image: node, depends_on: [db]}}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "docker-compose.yml")
+			writeFile(t, path, tt.compose)
+
+			if _, _, err := compose.Translate(path, ""); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
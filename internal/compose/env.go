@@ -0,0 +1,175 @@
+package compose
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envLookup looks variables up in the environment first and then in the given .env file, like docker compose does.
+func envLookup(dotEnv string) (func(string) (string, bool), error) {
+	vars := map[string]string{}
+	if _, err := os.Stat(dotEnv); err == nil {
+		vars, err = readEnvFile(dotEnv)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func(name string) (string, bool) {
+		if value, ok := os.LookupEnv(name); ok {
+			return value, true
+		}
+		value, ok := vars[name]
+		return value, ok
+	}, nil
+}
+
+// readEnvFile parses a file of 'KEY=VALUE' lines, ignoring blank lines and comments.
+func readEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	defer f.Close()
+
+	vars := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected 'KEY=VALUE'", path, line)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		vars[strings.TrimSpace(key)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+
+	return vars, nil
+}
+
+// interpolate replaces $VAR, ${VAR}, ${VAR:-default}, ${VAR-default}, ${VAR:?error} and ${VAR?error},
+// and unescapes $$, following the compose file interpolation rules.
+func interpolate(s string, lookup func(string) (string, bool)) (string, error) {
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		next := s[i+1]
+		switch {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unclosed variable at %q", s[i:])
+			}
+			value, err := expand(s[i+2:i+end], lookup)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i += end
+		case isNameChar(next, true):
+			end := i + 1
+			for end < len(s) && isNameChar(s[end], end == i+1) {
+				end++
+			}
+			value, _ := lookup(s[i+1 : end])
+			b.WriteString(value)
+			i = end - 1
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+func expand(expr string, lookup func(string) (string, bool)) (string, error) {
+	for _, op := range []string{":-", ":?", "-", "?"} {
+		name, arg, ok := strings.Cut(expr, op)
+		if !ok {
+			continue
+		}
+
+		value, set := lookup(name)
+		empty := !set || (strings.HasPrefix(op, ":") && value == "")
+		if !empty {
+			return value, nil
+		}
+		if strings.HasSuffix(op, "?") {
+			return "", fmt.Errorf("required variable %s is missing: %s", name, arg)
+		}
+		return arg, nil
+	}
+
+	value, _ := lookup(expr)
+	return value, nil
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
+}
+
+// splitCommand splits a command string into arguments, honoring single and double quotes and backslash escapes.
+func splitCommand(s string) ([]string, error) {
+	args := []string{}
+	current := strings.Builder{}
+	inArg := false
+	var quote byte
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' && i+1 < len(s) {
+				i++
+				current.WriteByte(s[i])
+			} else {
+				current.WriteByte(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case c == '\\' && i+1 < len(s):
+			i++
+			current.WriteByte(s[i])
+			inArg = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteByte(c)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unclosed quote in %q", s)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
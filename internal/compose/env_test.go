@@ -0,0 +1,71 @@
+package compose
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"NAME": "api", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "$NAME-${NAME}", want: "api-api"},
+		{in: "$$NAME", want: "$NAME"},
+		{in: "${MISSING:-default}", want: "default"},
+		{in: "${EMPTY:-default}", want: "default"},
+		{in: "${EMPTY-default}", want: ""},
+		{in: "${MISSING?is required}", wantErr: true},
+		{in: "${EMPTY:?is required}", wantErr: true},
+		{in: "${NAME", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := interpolate(tt.in, lookup)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	got, err := splitCommand(`sh -c "echo 'hi there'" a\ b`)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := []string{"sh", "-c", "echo 'hi there'", "a b"}; !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	if _, err := splitCommand(`echo "unterminated`); err == nil {
+		t.Errorf("expected an error for an unterminated quote")
+	}
+}
+
+func TestMemoryQuantity(t *testing.T) {
+	for in, want := range map[string]string{"512m": "512Mi", "1GB": "1Gi", "100k": "100Ki", "1024": "1024"} {
+		got, err := memoryQuantity(in)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", in, err)
+		}
+		if got != want {
+			t.Errorf("%s: expected %q, got %q", in, want, got)
+		}
+	}
+
+	if _, err := memoryQuantity("lots"); err == nil {
+		t.Errorf("expected an error for an invalid memory")
+	}
+}
@@ -0,0 +1,319 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/schema"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// Version is the current version of the config file format.
+	Version = "v1"
+	// DefaultFile is the config file used when none is given.
+	DefaultFile = "k8run.yaml"
+	// schemaURL identifies the schema when compiling it.
+	schemaURL = "https://raw.githubusercontent.com/lucasvmiguel/k8run/main/schema/k8run.schema.json"
+)
+
+// Config represents a k8run.yaml file describing one or more apps.
+type Config struct {
+	Version   string `json:"version"`
+	Namespace string `json:"namespace,omitempty"`
+	Apps      []App  `json:"apps"`
+}
+
+// App represents a single app of the config file. Its fields mirror the flags of the deployment command.
+type App struct {
+	Name          string            `json:"name"`
+	Namespace     string            `json:"namespace,omitempty"`
+	Image         string            `json:"image,omitempty"`
+	Copy          string            `json:"copy,omitempty"`
+	Entrypoint    Entrypoint        `json:"entrypoint,omitempty"`
+	WorkDir       string            `json:"workdir,omitempty"`
+	Setup         string            `json:"setup,omitempty"`
+	SetupCache    []string          `json:"setupCache,omitempty"`
+	Auto          bool              `json:"auto,omitempty"`
+	NodeArch      string            `json:"nodeArch,omitempty"`
+	Replicas      int32             `json:"replicas,omitempty"`
+	ContainerPort int64             `json:"containerPort,omitempty"`
+	Port          int64             `json:"port,omitempty"`
+	Service       bool              `json:"service,omitempty"`
+	Ingress       *Ingress          `json:"ingress,omitempty"`
+	Env           map[string]Scalar `json:"env,omitempty"`
+	Resources     *Resources        `json:"resources,omitempty"`
+	DependsOn     []string          `json:"dependsOn,omitempty"`
+	Timeout       Duration          `json:"timeout,omitempty"`
+}
+
+// Ingress represents the ingress of an app.
+type Ingress struct {
+	Host  string `json:"host"`
+	Class string `json:"class"`
+}
+
+// Resources represents the compute resources of an app.
+type Resources struct {
+	Requests map[string]Scalar `json:"requests,omitempty"`
+	Limits   map[string]Scalar `json:"limits,omitempty"`
+}
+
+// Entrypoint is the entrypoint of an app, written either as a string split on spaces or as a list.
+type Entrypoint []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *Entrypoint) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*e = strings.Fields(s)
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return fmt.Errorf("entrypoint must be a string or a list of strings")
+	}
+	*e = list
+	return nil
+}
+
+// Scalar is a string that can also be written as a number or boolean, eg: an env var value.
+type Scalar string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *Scalar) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		*s = Scalar(str)
+		return nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch v.(type) {
+	case float64, bool:
+		*s = Scalar(strings.TrimSpace(string(b)))
+		return nil
+	default:
+		return fmt.Errorf("expected a string, number or boolean, got %s", b)
+	}
+}
+
+// Duration is a time.Duration written as a string. eg: '2m'
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string. eg: '2m'")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Load reads, validates and parses a config file. Relative copy paths are resolved against the file's folder.
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	cfg, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for i := range cfg.Apps {
+		if cfg.Apps[i].Copy != "" && !filepath.IsAbs(cfg.Apps[i].Copy) {
+			cfg.Apps[i].Copy = filepath.Join(dir, cfg.Apps[i].Copy)
+		}
+	}
+
+	return cfg, nil
+}
+
+// Parse validates the given YAML against the published JSON schema and parses it.
+func Parse(b []byte) (*Config, error) {
+	j, err := yaml.YAMLToJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+
+	if err := validateSchema(j); err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(j))
+	decoder.DisallowUnknownFields()
+	cfg := &Config{}
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Validate checks the rules that can't be expressed by the JSON schema: unique names and dependencies.
+func (c *Config) Validate() error {
+	apps := map[string]bool{}
+	for _, app := range c.Apps {
+		if apps[app.Name] {
+			return fmt.Errorf("app %q is declared more than once", app.Name)
+		}
+		apps[app.Name] = true
+	}
+
+	names := c.names()
+	for _, app := range c.Apps {
+		for _, dep := range app.DependsOn {
+			if !slices.Contains(names, dep) {
+				return fmt.Errorf("app %q depends on unknown app %q", app.Name, dep)
+			}
+			if dep == app.Name {
+				return fmt.Errorf("app %q depends on itself", app.Name)
+			}
+		}
+	}
+
+	if cycle := c.findCycle(); cycle != nil {
+		return fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	return nil
+}
+
+// NamespaceOf returns the namespace of the given app, falling back to the config namespace.
+func (c *Config) NamespaceOf(app App) string {
+	if app.Namespace != "" {
+		return app.Namespace
+	}
+	return c.Namespace
+}
+
+// Dependencies returns the dependencies of every app, keyed by app name.
+func (c *Config) Dependencies() map[string][]string {
+	deps := map[string][]string{}
+	for _, app := range c.Apps {
+		deps[app.Name] = app.DependsOn
+	}
+	return deps
+}
+
+func (c *Config) names() []string {
+	names := []string{}
+	for _, app := range c.Apps {
+		names = append(names, app.Name)
+	}
+	return names
+}
+
+func (c *Config) findCycle() []string {
+	deps := c.Dependencies()
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	path := []string{}
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			start := slices.Index(path, name)
+			return append(slices.Clone(path[start:]), name)
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range deps[name] {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+
+	for _, name := range c.names() {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+func validateSchema(j []byte) error {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema.Config))
+	if err != nil {
+		return fmt.Errorf("failed to parse JSON schema: %w", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(schemaURL, doc); err != nil {
+		return fmt.Errorf("failed to load JSON schema: %w", err)
+	}
+	sch, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return fmt.Errorf("failed to compile JSON schema: %w", err)
+	}
+
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(j))
+	if err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	if err := sch.Validate(inst); err != nil {
+		var validationErr *jsonschema.ValidationError
+		if errors.As(err, &validationErr) {
+			return fmt.Errorf("config does not match the schema:\n%s", schemaErrors(validationErr))
+		}
+		return err
+	}
+
+	return nil
+}
+
+// schemaErrors flattens a validation error into one line per failing location.
+func schemaErrors(err *jsonschema.ValidationError) string {
+	if len(err.Causes) == 0 {
+		return "  " + err.Error()
+	}
+
+	lines := []string{}
+	for _, cause := range err.Causes {
+		lines = append(lines, schemaErrors(cause))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,177 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/config"
+)
+
+const validConfig = `
+version: v1
+namespace: prototypes
+apps:
+  - name: api
+    image: node:22
+    copy: ./api
+    entrypoint: node index.js
+    setup: npm ci
+    setupCache: [node_modules]
+    auto: true
+    nodeArch: arm64
+    containerPort: 3000
+    port: 8080
+    service: true
+    ingress:
+      host: api.example.com
+      class: nginx
+    env:
+      PORT: 3000
+      DEBUG: true
+      NAME: api
+    resources:
+      requests:
+        cpu: 100m
+        memory: 128Mi
+    dependsOn: [db]
+    timeout: 2m
+  - name: db
+    namespace: data
+    image: postgres:16
+    copy: ./db
+    entrypoint: [docker-entrypoint.sh, postgres]
+`
+
+func TestParse_Valid(t *testing.T) {
+	cfg, err := config.Parse([]byte(validConfig))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(cfg.Apps) != 2 {
+		t.Fatalf("expected 2 apps, got %d", len(cfg.Apps))
+	}
+
+	api := cfg.Apps[0]
+	if !slices.Equal(api.Entrypoint, []string{"node", "index.js"}) {
+		t.Errorf("expected entrypoint to be split, got %v", api.Entrypoint)
+	}
+	if api.Setup != "npm ci" || !slices.Equal(api.SetupCache, []string{"node_modules"}) {
+		t.Errorf("expected the setup and its cache, got %q and %v", api.Setup, api.SetupCache)
+	}
+	if !api.Auto || api.NodeArch != "arm64" {
+		t.Errorf("expected auto and the node arch to be parsed, got %v and %q", api.Auto, api.NodeArch)
+	}
+	if api.Env["PORT"] != "3000" || api.Env["DEBUG"] != "true" {
+		t.Errorf("expected scalar env values to become strings, got %v", api.Env)
+	}
+	if time.Duration(api.Timeout) != 2*time.Minute {
+		t.Errorf("expected timeout to be 2m, got %v", time.Duration(api.Timeout))
+	}
+	if cfg.NamespaceOf(api) != "prototypes" {
+		t.Errorf("expected default namespace, got %q", cfg.NamespaceOf(api))
+	}
+
+	db := cfg.Apps[1]
+	if !slices.Equal(db.Entrypoint, []string{"docker-entrypoint.sh", "postgres"}) {
+		t.Errorf("expected entrypoint list to be kept, got %v", db.Entrypoint)
+	}
+	if cfg.NamespaceOf(db) != "data" {
+		t.Errorf("expected app namespace, got %q", cfg.NamespaceOf(db))
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			name:    "unknown version",
+			config:  "version: v2\napps: [{name: api, image: node, copy: .}]",
+			wantErr: "/version",
+		},
+		{
+			name:    "unknown field",
+			config:  "version: v1\napps: [{name: api, image: node, copy: ., replica: 2}]",
+			wantErr: "replica",
+		},
+		{
+			name:    "missing image",
+			config:  "version: v1\napps: [{name: api, copy: .}]",
+			wantErr: "image",
+		},
+		{
+			name:    "missing image without auto",
+			config:  "version: v1\napps: [{name: api, copy: ., auto: false}]",
+			wantErr: "image",
+		},
+		{
+			name:    "invalid name",
+			config:  "version: v1\napps: [{name: My_App, image: node, copy: .}]",
+			wantErr: "/apps/0/name",
+		},
+		{
+			name:    "duplicated app",
+			config:  "version: v1\napps: [{name: api, image: node, copy: .}, {name: api, image: node, copy: .}]",
+			wantErr: "more than once",
+		},
+		{
+			name:    "unknown dependency",
+			config:  "version: v1\napps: [{name: api, image: node, copy: ., dependsOn: [db]}]",
+			wantErr: "unknown app",
+		},
+		{
+			name:    "dependency cycle",
+			config:  "version: v1\napps: [{name: a, image: node, copy: ., dependsOn: [b]}, {name: b, image: node, copy: ., dependsOn: [a]}]",
+			wantErr: "cycle",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.Parse([]byte(tt.config))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_ResolvesCopyPaths(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "k8run.yaml")
+	if err := os.WriteFile(path, []byte(validConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if cfg.Apps[0].Copy != filepath.Join(dir, "api") {
+		t.Errorf("expected copy to be resolved against the config file, got %q", cfg.Apps[0].Copy)
+	}
+}
+
+func TestLoad_AutoWithoutImage(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "k8run.yaml")
+	if err := os.WriteFile(path, []byte("version: v1\napps: [{name: api, copy: ./api, auto: true}]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("expected the image to be left to auto, got %v", err)
+	}
+	if app := cfg.Apps[0]; app.Image != "" || !app.Auto {
+		t.Errorf("expected an app without image to infer it, got %+v", app)
+	}
+}
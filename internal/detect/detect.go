@@ -0,0 +1,338 @@
+package detect
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Node is a Node.js app, with a package.json.
+	Node = "node"
+	// Go is a Go module, cross-compiled locally and run on a distroless image.
+	Go = "go"
+	// Python is a Python app, with a requirements.txt or pyproject.toml.
+	Python = "python"
+	// Ruby is a Ruby app, with a Gemfile.
+	Ruby = "ruby"
+	// Static is a static site, with an index.html served by nginx.
+	Static = "static"
+)
+
+// GoImage is the image Go binaries run on. Binaries built with CGO_ENABLED=0 don't need anything else.
+const GoImage = "gcr.io/distroless/static-debian12"
+
+// ErrNotDetected is returned when the folder doesn't look like any known app.
+var ErrNotDetected = errors.New("no package.json, go.mod, requirements.txt, pyproject.toml, Gemfile or index.html found")
+
+var (
+	majorRegexp      = regexp.MustCompile(`\d+`)
+	minorRegexp      = regexp.MustCompile(`\d+\.\d+`)
+	portRegexp       = regexp.MustCompile(`(?:--port[= ]|-p |PORT=)(\d+)`)
+	moduleRegexp     = regexp.MustCompile(`(?m)^module\s+"?([^\s"]+)"?`)
+	gemfileRbRegexp  = regexp.MustCompile(`(?m)^ruby\s+["'](\d+\.\d+)`)
+	majorPathRegexp  = regexp.MustCompile(`^v\d+$`)
+	requiresPyRegexp = regexp.MustCompile(`(?m)^requires-python\s*=\s*["']([^"']*)["']`)
+)
+
+// App represents how to run the app of a folder, as inferred from its files.
+type App struct {
+	// Runtime is what the app was recognized as. eg: Node
+	Runtime string
+	Image   string
+	// Entrypoint is the command of the container, relative to the folder of the app.
+	Entrypoint    []string
+	ContainerPort int64
+	// Setup installs the dependencies once the folder is copied, with SetupCache kept across releases.
+	Setup      string
+	SetupCache []string
+	Env        map[string]string
+	// GoPackage is the package of a Go app cross-compiled into GoBinary, relative to the folder. eg: './cmd/server'
+	GoPackage string
+	GoBinary  string
+	// Inferences explain where each value comes from, in the order they were inferred.
+	Inferences []Inference
+}
+
+// Inference represents a value inferred for an app and where it comes from.
+type Inference struct {
+	// Field is what the value is for. eg: 'image'
+	Field string
+	Value string
+	// Source is where the value comes from. eg: 'engines.node in package.json'
+	Source string
+}
+
+// String returns the inference as 'field value (source)'.
+func (i Inference) String() string {
+	return fmt.Sprintf("%s %s (%s)", i.Field, i.Value, i.Source)
+}
+
+// Detect inspects the files of dir and infers how to run its app. The first runtime found wins, in the order Go,
+// Node.js, Python, Ruby and static sites, so a Node.js app serving an index.html isn't mistaken for a static site.
+func Detect(dir string) (*App, error) {
+	detectors := []struct {
+		files  []string
+		detect func(dir string) (*App, error)
+	}{
+		{files: []string{"go.mod"}, detect: detectGo},
+		{files: []string{"package.json"}, detect: detectNode},
+		{files: []string{"requirements.txt", "pyproject.toml"}, detect: detectPython},
+		{files: []string{"Gemfile"}, detect: detectRuby},
+		{files: []string{"index.html"}, detect: detectStatic},
+	}
+
+	for _, detector := range detectors {
+		for _, file := range detector.files {
+			if exists(dir, file) {
+				return detector.detect(dir)
+			}
+		}
+	}
+
+	return nil, ErrNotDetected
+}
+
+// infer sets the value of a field of the app and records where it comes from.
+func (a *App) infer(field, value, source string) {
+	a.Inferences = append(a.Inferences, Inference{Field: field, Value: value, Source: source})
+}
+
+func (a *App) inferImage(image, source string) {
+	a.Image = image
+	a.infer("image", image, source)
+}
+
+func (a *App) inferEntrypoint(entrypoint []string, source string) {
+	a.Entrypoint = entrypoint
+	a.infer("entrypoint", strings.Join(entrypoint, " "), source)
+}
+
+func (a *App) inferPort(port int64, source string) {
+	a.ContainerPort = port
+	a.infer("container port", strconv.FormatInt(port, 10), source)
+}
+
+func (a *App) inferSetup(setup string, cache []string, source string) {
+	a.Setup = setup
+	a.infer("setup", setup, source)
+	if len(cache) > 0 {
+		a.SetupCache = cache
+		a.infer("setup cache", strings.Join(cache, ","), source)
+	}
+}
+
+// packageJSON represents the fields of a package.json describing how to run the app.
+type packageJSON struct {
+	Main    string            `json:"main"`
+	Scripts map[string]string `json:"scripts"`
+	Engines map[string]string `json:"engines"`
+}
+
+func detectNode(dir string) (*App, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+	pkg := packageJSON{}
+	if err := json.Unmarshal(b, &pkg); err != nil {
+		return nil, fmt.Errorf("invalid package.json: %w", err)
+	}
+
+	app := &App{Runtime: Node}
+	if major := majorRegexp.FindString(pkg.Engines["node"]); major != "" {
+		app.inferImage("node:"+major, "engines.node in package.json")
+	} else {
+		app.inferImage("node:lts", "package.json without engines.node")
+	}
+
+	install, source := "npm install", "package.json without a lockfile"
+	switch {
+	case exists(dir, "package-lock.json") || exists(dir, "npm-shrinkwrap.json"):
+		install, source = "npm ci", "package-lock.json"
+	case exists(dir, "yarn.lock"):
+		install, source = "yarn install --frozen-lockfile", "yarn.lock"
+	case exists(dir, "pnpm-lock.yaml"):
+		install, source = "corepack enable pnpm && pnpm install --frozen-lockfile", "pnpm-lock.yaml"
+	}
+	if _, ok := pkg.Scripts["build"]; ok {
+		// the build output is copied over on every release, so the setup can't be skipped
+		app.inferSetup(install+" && npm run build", nil, source+" and scripts.build in package.json")
+	} else {
+		app.inferSetup(install, []string{"node_modules"}, source)
+	}
+
+	start := pkg.Scripts["start"]
+	switch {
+	case start != "":
+		app.inferEntrypoint([]string{"npm", "start"}, "scripts.start in package.json")
+	case pkg.Main != "":
+		app.inferEntrypoint([]string{"node", pkg.Main}, "main in package.json")
+	default:
+		file := firstExisting(dir, "index.js", "server.js", "app.js", "main.js")
+		if file == "" {
+			return nil, fmt.Errorf("package.json has neither scripts.start nor main, and no index.js, server.js, app.js or main.js was found")
+		}
+		app.inferEntrypoint([]string{"node", file}, file)
+	}
+
+	if match := portRegexp.FindStringSubmatch(start); match != nil {
+		port, _ := strconv.ParseInt(match[1], 10, 64)
+		app.inferPort(port, "scripts.start in package.json")
+	} else {
+		app.inferPort(3000, "the usual port of Node.js apps")
+	}
+
+	return app, nil
+}
+
+func detectGo(dir string) (*App, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	match := moduleRegexp.FindSubmatch(b)
+	if match == nil {
+		return nil, fmt.Errorf("go.mod has no module directive")
+	}
+
+	// eg: 'example.com/server/v2' builds 'server'
+	module := string(match[1])
+	binary := path.Base(module)
+	if majorPathRegexp.MatchString(binary) && path.Dir(module) != "." {
+		binary = path.Base(path.Dir(module))
+	}
+
+	app := &App{Runtime: Go, GoPackage: "."}
+	if !exists(dir, "main.go") {
+		commands, _ := filepath.Glob(filepath.Join(dir, "cmd", "*", "main.go"))
+		switch {
+		case exists(dir, "cmd", binary, "main.go"):
+			app.GoPackage = "./cmd/" + binary
+		case len(commands) == 1:
+			binary = filepath.Base(filepath.Dir(commands[0]))
+			app.GoPackage = "./cmd/" + binary
+		}
+	}
+	app.GoBinary = binary
+	app.infer("binary", binary, fmt.Sprintf("%s of module %s, cross-compiled for linux", app.GoPackage, module))
+
+	app.inferImage(GoImage, "go.mod, a static binary needs no runtime")
+	app.inferEntrypoint([]string{"./" + binary}, "go.mod")
+	app.inferPort(8080, "the usual port of Go apps")
+
+	return app, nil
+}
+
+func detectPython(dir string) (*App, error) {
+	app := &App{Runtime: Python}
+	requirements, _ := os.ReadFile(filepath.Join(dir, "requirements.txt"))
+	pyproject, _ := os.ReadFile(filepath.Join(dir, "pyproject.toml"))
+	pythonVersion, _ := os.ReadFile(filepath.Join(dir, ".python-version"))
+
+	if version := minorRegexp.FindString(string(pythonVersion)); version != "" {
+		app.inferImage("python:"+version+"-slim", ".python-version")
+	} else if match := requiresPyRegexp.FindSubmatch(pyproject); match != nil && minorRegexp.Match(match[1]) {
+		app.inferImage("python:"+minorRegexp.FindString(string(match[1]))+"-slim", "requires-python in pyproject.toml")
+	} else {
+		app.inferImage("python:3.12-slim", "the latest Python supported by most libraries")
+	}
+
+	// the virtualenv is kept in the working dir, so the app container finds what the setup installed
+	if requirements != nil {
+		app.inferSetup("python -m venv .venv && .venv/bin/pip install -r requirements.txt", []string{".venv"}, "requirements.txt")
+	} else {
+		app.inferSetup("python -m venv .venv && .venv/bin/pip install .", []string{".venv"}, "pyproject.toml")
+	}
+
+	deps := strings.ToLower(string(requirements) + "\n" + string(pyproject))
+	module := strings.TrimSuffix(firstExisting(dir, "main.py", "app.py", "wsgi.py"), ".py")
+	switch {
+	case exists(dir, "manage.py"):
+		app.inferEntrypoint([]string{".venv/bin/python", "manage.py", "runserver", "0.0.0.0:8000"}, "manage.py of a Django app")
+		app.inferPort(8000, "manage.py runserver")
+	case module != "" && strings.Contains(deps, "uvicorn"):
+		app.inferEntrypoint([]string{".venv/bin/uvicorn", module + ":app", "--host", "0.0.0.0", "--port", "8000"}, "uvicorn in the dependencies")
+		app.inferPort(8000, "uvicorn")
+	case module != "" && strings.Contains(deps, "gunicorn"):
+		app.inferEntrypoint([]string{".venv/bin/gunicorn", "--bind", "0.0.0.0:8000", module + ":app"}, "gunicorn in the dependencies")
+		app.inferPort(8000, "gunicorn")
+	case module != "" && strings.Contains(deps, "flask"):
+		app.inferEntrypoint([]string{".venv/bin/flask", "--app", module, "run", "--host", "0.0.0.0", "--port", "5000"}, "flask in the dependencies")
+		app.inferPort(5000, "flask run")
+	case module != "":
+		app.inferEntrypoint([]string{".venv/bin/python", module + ".py"}, module+".py")
+		app.inferPort(8000, "the usual port of Python apps")
+	default:
+		return nil, fmt.Errorf("no manage.py, main.py, app.py or wsgi.py found")
+	}
+
+	return app, nil
+}
+
+func detectRuby(dir string) (*App, error) {
+	app := &App{Runtime: Ruby}
+	gemfile, _ := os.ReadFile(filepath.Join(dir, "Gemfile"))
+	rubyVersion, _ := os.ReadFile(filepath.Join(dir, ".ruby-version"))
+
+	if version := minorRegexp.FindString(string(rubyVersion)); version != "" {
+		app.inferImage("ruby:"+version, ".ruby-version")
+	} else if match := gemfileRbRegexp.FindSubmatch(gemfile); match != nil {
+		app.inferImage("ruby:"+string(match[1]), "ruby in Gemfile")
+	} else {
+		app.inferImage("ruby:3.3", "Gemfile without a ruby version")
+	}
+
+	// the bundle path is set by env, as a '.bundle/config' would be gone once the setup is skipped
+	app.Env = map[string]string{"BUNDLE_PATH": "vendor/bundle"}
+	app.infer("env", "BUNDLE_PATH=vendor/bundle", "Gemfile")
+	app.inferSetup("bundle install", []string{"vendor/bundle"}, "Gemfile")
+
+	switch {
+	case exists(dir, "bin", "rails"):
+		app.inferEntrypoint([]string{"bin/rails", "server", "-b", "0.0.0.0"}, "bin/rails of a Rails app")
+		app.inferPort(3000, "rails server")
+	case exists(dir, "config.ru"):
+		app.inferEntrypoint([]string{"bundle", "exec", "rackup", "--host", "0.0.0.0", "--port", "9292"}, "config.ru of a Rack app")
+		app.inferPort(9292, "rackup")
+	default:
+		file := firstExisting(dir, "app.rb", "main.rb", "server.rb")
+		if file == "" {
+			return nil, fmt.Errorf("no bin/rails, config.ru, app.rb, main.rb or server.rb found")
+		}
+		app.inferEntrypoint([]string{"bundle", "exec", "ruby", file, "-o", "0.0.0.0"}, file)
+		app.inferPort(4567, "the port of Sinatra apps")
+	}
+
+	return app, nil
+}
+
+func detectStatic(dir string) (*App, error) {
+	app := &App{Runtime: Static}
+	app.inferImage("nginx:alpine", "index.html of a static site")
+	// nginx serves its html folder, replaced by the working dir the site lands in
+	app.inferEntrypoint([]string{"sh", "-c", `rm -rf /usr/share/nginx/html && ln -s "$PWD" /usr/share/nginx/html && exec nginx -g "daemon off;"`}, "index.html served by nginx")
+	app.inferPort(80, "nginx")
+	return app, nil
+}
+
+func exists(dir string, elem ...string) bool {
+	_, err := os.Stat(filepath.Join(append([]string{dir}, elem...)...))
+	return err == nil
+}
+
+// firstExisting returns the first of the files found in dir, or an empty string if none is.
+func firstExisting(dir string, files ...string) string {
+	for _, file := range files {
+		if exists(dir, file) {
+			return file
+		}
+	}
+	return ""
+}
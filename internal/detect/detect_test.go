@@ -0,0 +1,161 @@
+package detect_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/detect"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      map[string]string
+		runtime    string
+		image      string
+		entrypoint []string
+		port       int64
+		setup      string
+		setupCache []string
+	}{
+		{
+			name: "node with start script",
+			files: map[string]string{
+				"package.json":      `{"engines": {"node": ">=20.1"}, "scripts": {"start": "next start -p 4000"}}`,
+				"package-lock.json": "{}",
+				"index.html":        "",
+			},
+			runtime:    detect.Node,
+			image:      "node:20",
+			entrypoint: []string{"npm", "start"},
+			port:       4000,
+			setup:      "npm ci",
+			setupCache: []string{"node_modules"},
+		},
+		{
+			name:       "node with build script",
+			files:      map[string]string{"package.json": `{"main": "dist/server.js", "scripts": {"build": "tsc"}}`, "yarn.lock": ""},
+			runtime:    detect.Node,
+			image:      "node:lts",
+			entrypoint: []string{"node", "dist/server.js"},
+			port:       3000,
+			setup:      "yarn install --frozen-lockfile && npm run build",
+		},
+		{
+			name:       "go command",
+			files:      map[string]string{"go.mod": "module github.com/org/api/v2\n\ngo 1.23\n", "cmd/api/main.go": "package main"},
+			runtime:    detect.Go,
+			image:      detect.GoImage,
+			entrypoint: []string{"./api"},
+			port:       8080,
+		},
+		{
+			name:       "django",
+			files:      map[string]string{"requirements.txt": "django\n", "manage.py": "", ".python-version": "3.11.4\n"},
+			runtime:    detect.Python,
+			image:      "python:3.11-slim",
+			entrypoint: []string{".venv/bin/python", "manage.py", "runserver", "0.0.0.0:8000"},
+			port:       8000,
+			setup:      "python -m venv .venv && .venv/bin/pip install -r requirements.txt",
+			setupCache: []string{".venv"},
+		},
+		{
+			name:       "fastapi",
+			files:      map[string]string{"pyproject.toml": "[project]\nrequires-python = \">=3.10\"\ndependencies = [\"fastapi\", \"uvicorn\"]\n", "main.py": ""},
+			runtime:    detect.Python,
+			image:      "python:3.10-slim",
+			entrypoint: []string{".venv/bin/uvicorn", "main:app", "--host", "0.0.0.0", "--port", "8000"},
+			port:       8000,
+			setup:      "python -m venv .venv && .venv/bin/pip install .",
+			setupCache: []string{".venv"},
+		},
+		{
+			name:       "flask",
+			files:      map[string]string{"requirements.txt": "Flask==3.0\n", "app.py": ""},
+			runtime:    detect.Python,
+			image:      "python:3.12-slim",
+			entrypoint: []string{".venv/bin/flask", "--app", "app", "run", "--host", "0.0.0.0", "--port", "5000"},
+			port:       5000,
+			setup:      "python -m venv .venv && .venv/bin/pip install -r requirements.txt",
+			setupCache: []string{".venv"},
+		},
+		{
+			name:       "rails",
+			files:      map[string]string{"Gemfile": "source 'https://rubygems.org'\nruby '3.2.2'\n", "bin/rails": ""},
+			runtime:    detect.Ruby,
+			image:      "ruby:3.2",
+			entrypoint: []string{"bin/rails", "server", "-b", "0.0.0.0"},
+			port:       3000,
+			setup:      "bundle install",
+			setupCache: []string{"vendor/bundle"},
+		},
+		{
+			name:       "static site",
+			files:      map[string]string{"index.html": "<h1>hi</h1>"},
+			runtime:    detect.Static,
+			image:      "nginx:alpine",
+			entrypoint: []string{"sh", "-c", `rm -rf /usr/share/nginx/html && ln -s "$PWD" /usr/share/nginx/html && exec nginx -g "daemon off;"`},
+			port:       80,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, err := detect.Detect(writeFiles(t, tt.files))
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if app.Runtime != tt.runtime || app.Image != tt.image || app.ContainerPort != tt.port {
+				t.Errorf("expected %s on %s:%d, got %s on %s:%d", tt.runtime, tt.image, tt.port, app.Runtime, app.Image, app.ContainerPort)
+			}
+			if !slices.Equal(app.Entrypoint, tt.entrypoint) {
+				t.Errorf("expected entrypoint %q, got %q", tt.entrypoint, app.Entrypoint)
+			}
+			if app.Setup != tt.setup || !slices.Equal(app.SetupCache, tt.setupCache) {
+				t.Errorf("expected setup %q with cache %v, got %q with %v", tt.setup, tt.setupCache, app.Setup, app.SetupCache)
+			}
+			if len(app.Inferences) == 0 {
+				t.Errorf("expected the inferences to be explained")
+			}
+		})
+	}
+}
+
+func TestDetect_Go(t *testing.T) {
+	app, err := detect.Detect(writeFiles(t, map[string]string{"go.mod": "module example.com/server\n", "main.go": "package main"}))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if app.GoPackage != "." || app.GoBinary != "server" {
+		t.Errorf("expected the module to be compiled into server, got %s into %s", app.GoPackage, app.GoBinary)
+	}
+}
+
+func TestDetect_Invalid(t *testing.T) {
+	if _, err := detect.Detect(writeFiles(t, map[string]string{"README.md": ""})); !errors.Is(err, detect.ErrNotDetected) {
+		t.Errorf("expected ErrNotDetected, got %v", err)
+	}
+	if _, err := detect.Detect(writeFiles(t, map[string]string{"package.json": "{}"})); err == nil {
+		t.Errorf("expected an error without anything to start")
+	}
+	if _, err := detect.Detect(writeFiles(t, map[string]string{"package.json": "{"})); err == nil {
+		t.Errorf("expected an error with an invalid package.json")
+	}
+}
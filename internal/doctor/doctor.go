@@ -0,0 +1,75 @@
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	Pass Status = "pass"
+	// Warn means k8run may still work, eg: the check couldn't be run or only some features are affected.
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+// Check is a single readiness check of the cluster.
+type Check struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Report is the list of checks run by the doctor.
+type Report struct {
+	Checks []Check `json:"checks"`
+}
+
+// Add adds checks to the report.
+func (r *Report) Add(checks ...Check) {
+	r.Checks = append(r.Checks, checks...)
+}
+
+// Count returns the number of checks with the given status.
+func (r *Report) Count(status Status) int {
+	n := 0
+	for _, check := range r.Checks {
+		if check.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed returns true if any check failed.
+func (r *Report) Failed() bool {
+	return r.Count(Fail) > 0
+}
+
+// Write prints the report in a human readable form.
+func (r *Report) Write(w io.Writer) {
+	for _, check := range r.Checks {
+		line := fmt.Sprintf("  [%s] %s", check.Status, check.Name)
+		if check.Message != "" {
+			line += ": " + check.Message
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	fmt.Fprintf(w, "%d passed, %d warnings, %d failed.\n", r.Count(Pass), r.Count(Warn), r.Count(Fail))
+}
+
+// WriteJSON prints the report as JSON, with the number of checks of each status.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Checks []Check `json:"checks"`
+		Passed int     `json:"passed"`
+		Warned int     `json:"warned"`
+		Failed int     `json:"failed"`
+	}{r.Checks, r.Count(Pass), r.Count(Warn), r.Count(Fail)})
+}
@@ -0,0 +1,59 @@
+package doctor_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/doctor"
+)
+
+func testReport() *doctor.Report {
+	report := &doctor.Report{}
+	report.Add(
+		doctor.Check{Name: "kubectl is on PATH", Status: doctor.Pass},
+		doctor.Check{Name: "default storage class", Status: doctor.Warn, Message: "no default storage class"},
+		doctor.Check{Name: "can create pods/exec", Status: doctor.Fail},
+	)
+	return report
+}
+
+func TestReport_Write(t *testing.T) {
+	report := testReport()
+	if !report.Failed() {
+		t.Errorf("expected the report to have failed")
+	}
+
+	buf := &bytes.Buffer{}
+	report.Write(buf)
+
+	for _, want := range []string{
+		"[pass] kubectl is on PATH",
+		"[warn] default storage class: no default storage class",
+		"[fail] can create pods/exec",
+		"1 passed, 1 warnings, 1 failed.",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestReport_WriteJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := testReport().WriteJSON(buf); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	got := struct {
+		Checks []doctor.Check `json:"checks"`
+		Failed int            `json:"failed"`
+	}{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("expected valid JSON, got %v", err)
+	}
+	if len(got.Checks) != 3 || got.Failed != 1 || got.Checks[1].Status != doctor.Warn {
+		t.Errorf("unexpected report: %+v", got)
+	}
+}
@@ -0,0 +1,145 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// writeDockerfile writes a Dockerfile baking the copied content into an image, together with its build context,
+// and rewrites the deployment to run that image instead of relying on the init container and PVC.
+func writeDockerfile(dir string, deployment *appsv1.Deployment, d *Dockerfile) error {
+	if d.Image == "" {
+		return fmt.Errorf("dockerfile image is required")
+	}
+	if d.Source == "" {
+		return fmt.Errorf("dockerfile source is required")
+	}
+
+	podSpec := &deployment.Spec.Template.Spec
+	if len(podSpec.Containers) == 0 {
+		return fmt.Errorf("deployment has no containers")
+	}
+	container := &podSpec.Containers[0]
+	baseImage := container.Image
+	workDir := container.WorkingDir
+
+	pvcVolumes := map[string]bool{}
+	volumes := []corev1.Volume{}
+	for _, v := range podSpec.Volumes {
+		if v.PersistentVolumeClaim != nil {
+			pvcVolumes[v.Name] = true
+			continue
+		}
+		volumes = append(volumes, v)
+	}
+	podSpec.Volumes = volumes
+	podSpec.InitContainers = nil
+	for i := range podSpec.Containers {
+		mounts := []corev1.VolumeMount{}
+		for _, m := range podSpec.Containers[i].VolumeMounts {
+			if !pvcVolumes[m.Name] {
+				mounts = append(mounts, m)
+			}
+		}
+		podSpec.Containers[i].VolumeMounts = mounts
+	}
+	container.Image = d.Image
+
+	contextDir := filepath.Join(dir, imageDir)
+	source := filepath.Clean(d.Source)
+	base := filepath.Base(source)
+	if err := copyTree(source, filepath.Join(contextDir, base), dir); err != nil {
+		return fmt.Errorf("failed to copy build context: %w", err)
+	}
+
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "FROM %s\n", baseImage)
+	if workDir != "" {
+		fmt.Fprintf(&b, "WORKDIR %s\n", workDir)
+		fmt.Fprintf(&b, "COPY %s %s\n", base, path.Join(workDir, base))
+	} else {
+		fmt.Fprintf(&b, "COPY %s /%s\n", base, base)
+	}
+	if len(container.Args) > 0 {
+		args, err := json.Marshal(container.Args)
+		if err != nil {
+			return fmt.Errorf("failed to encode entrypoint: %w", err)
+		}
+		fmt.Fprintf(&b, "CMD %s\n", args)
+	}
+
+	return writeFile(filepath.Join(contextDir, "Dockerfile"), []byte(b.String()))
+}
+
+// copyTree copies a file or folder from src to dst, skipping the skip path so the output dir is never copied into itself.
+func copyTree(src, dst, skip string) error {
+	skip, err := filepath.Abs(skip)
+	if err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(src, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		if abs == skip {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case info.Mode().IsRegular():
+			return copyFile(p, target, info.Mode().Perm())
+		default:
+			// symlinks and special files are not part of a build context
+			return nil
+		}
+	})
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
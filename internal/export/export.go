@@ -0,0 +1,203 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/lucasvmiguel/k8run/internal/manifest"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// Format represents the layout of the exported project.
+type Format string
+
+const (
+	// FormatYAML writes one plain manifest per resource.
+	FormatYAML Format = "yaml"
+	// FormatKustomize writes the manifests plus a kustomization.yaml.
+	FormatKustomize Format = "kustomize"
+	// FormatHelm writes a Helm chart with the main settings exposed as values.
+	FormatHelm Format = "helm"
+)
+
+// Formats lists every supported format.
+var Formats = []Format{FormatYAML, FormatKustomize, FormatHelm}
+
+// imageDir is the folder, relative to the output dir, where the Dockerfile and its build context are written.
+const imageDir = "image"
+
+// Dockerfile describes an image that bakes the copied content, replacing the init container and PVC copy model.
+type Dockerfile struct {
+	// Image is the name of the image that will be built from the generated Dockerfile.
+	Image string
+	// Source is the local file or folder that used to be copied with --copy.
+	Source string
+}
+
+// WriteParams represents the parameters to export resources.
+type WriteParams struct {
+	Name       string
+	Namespace  string
+	Format     Format
+	Dir        string
+	Deployment *appsv1.Deployment
+	Service    *corev1.Service
+	Ingress    *networkingv1.Ingress
+	PVC        *corev1.PersistentVolumeClaim
+	Dockerfile *Dockerfile
+}
+
+// Write cleans the given resources and writes them into params.Dir using the requested format.
+func Write(params WriteParams) error {
+	if !slices.Contains(Formats, params.Format) {
+		return fmt.Errorf("unsupported format %q", params.Format)
+	}
+	if params.Deployment == nil {
+		return fmt.Errorf("deployment is required")
+	}
+
+	if err := os.MkdirAll(params.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create output dir: %w", err)
+	}
+
+	if params.Dockerfile != nil {
+		if err := writeDockerfile(params.Dir, params.Deployment, params.Dockerfile); err != nil {
+			return err
+		}
+		params.PVC = nil
+	}
+
+	resources := []resource{}
+	for _, r := range []struct {
+		file string
+		obj  runtime.Object
+		skip bool
+	}{
+		{file: "pvc.yaml", obj: params.PVC, skip: params.PVC == nil},
+		{file: "deployment.yaml", obj: params.Deployment},
+		{file: "service.yaml", obj: params.Service, skip: params.Service == nil},
+		{file: "ingress.yaml", obj: params.Ingress, skip: params.Ingress == nil},
+	} {
+		if r.skip {
+			continue
+		}
+		if err := manifest.Clean(r.obj); err != nil {
+			return err
+		}
+		m, err := manifest.ToMap(r.obj)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, resource{file: r.file, object: m})
+	}
+
+	switch params.Format {
+	case FormatKustomize:
+		return writeKustomize(params, resources)
+	case FormatHelm:
+		return writeHelm(params, resources)
+	default:
+		return writeResources(params.Dir, resources)
+	}
+}
+
+type resource struct {
+	file   string
+	object map[string]interface{}
+}
+
+func writeResources(dir string, resources []resource) error {
+	for _, r := range resources {
+		if err := writeYAML(filepath.Join(dir, r.file), r.object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeKustomize(params WriteParams, resources []resource) error {
+	files := []string{}
+	for _, r := range resources {
+		setNested(r.object, nil, "metadata", "namespace")
+		files = append(files, r.file)
+	}
+
+	if err := writeResources(params.Dir, resources); err != nil {
+		return err
+	}
+
+	return writeYAML(filepath.Join(params.Dir, "kustomization.yaml"), map[string]interface{}{
+		"apiVersion": "kustomize.config.k8s.io/v1beta1",
+		"kind":       "Kustomization",
+		"namespace":  params.Namespace,
+		"resources":  files,
+	})
+}
+
+func writeYAML(path string, v interface{}) error {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", filepath.Base(path), err)
+	}
+	return writeFile(path, b)
+}
+
+func writeFile(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create dir for %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// setNested sets value at the given path, where every key is either a map key (string) or a slice index (int).
+// It returns the previous value and whether the path existed.
+func setNested(obj interface{}, value interface{}, path ...interface{}) (interface{}, bool) {
+	current := obj
+	for i, key := range path {
+		last := i == len(path)-1
+		switch k := key.(type) {
+		case string:
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			next, ok := m[k]
+			if !ok {
+				return nil, false
+			}
+			if last {
+				if value == nil {
+					delete(m, k)
+				} else {
+					m[k] = value
+				}
+				return next, true
+			}
+			current = next
+		case int:
+			s, ok := current.([]interface{})
+			if !ok || k >= len(s) {
+				return nil, false
+			}
+			if last {
+				previous := s[k]
+				s[k] = value
+				return previous, true
+			}
+			current = s[k]
+		default:
+			return nil, false
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,150 @@
+package export_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/export"
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+)
+
+func newWriteParams(t *testing.T, format export.Format) export.WriteParams {
+	ingressClass := "nginx"
+	return export.WriteParams{
+		Name:      "foobar",
+		Namespace: "default",
+		Format:    format,
+		Dir:       t.TempDir(),
+		PVC:       k8s.BuildPVC(k8s.CreatePVCIfNotExistsParams{Name: "foobar-app-pvc", Namespace: "default"}),
+		Deployment: k8s.BuildDeployment(k8s.CreateOrUpdateDeploymentParams{
+			Name:              "foobar",
+			Namespace:         "default",
+			Entrypoint:        []string{"node", "foobar/index.js"},
+			Image:             "node:22",
+			CopyTo:            "/app",
+			Replicas:          2,
+			PVCName:           "foobar-app-pvc",
+			InitContainerName: "wait-to-copy-app",
+			ReleaseIdentifier: "release",
+		}),
+		Service: k8s.BuildService(k8s.CreateOrUpdateServiceParams{Name: "foobar", Namespace: "default", Port: 8080, ContainerPort: 3000}),
+		Ingress: k8s.BuildIngress(k8s.CreateOrUpdateIngressParams{Name: "foobar", Namespace: "default", IngressClass: &ingressClass, IngressHost: "foobar.example.com", Port: 8080}),
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(b)
+}
+
+func TestWrite_YAML(t *testing.T) {
+	params := newWriteParams(t, export.FormatYAML)
+
+	if err := export.Write(params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, file := range []string{"pvc.yaml", "deployment.yaml", "service.yaml", "ingress.yaml"} {
+		content := readFile(t, filepath.Join(params.Dir, file))
+		if strings.Contains(content, "k8run-") || strings.Contains(content, "K8RUN_") {
+			t.Errorf("expected %s to have no k8run specific fields, got:\n%s", file, content)
+		}
+	}
+}
+
+func TestWrite_Kustomize(t *testing.T) {
+	params := newWriteParams(t, export.FormatKustomize)
+
+	if err := export.Write(params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	kustomization := readFile(t, filepath.Join(params.Dir, "kustomization.yaml"))
+	if !strings.Contains(kustomization, "namespace: default") || !strings.Contains(kustomization, "- deployment.yaml") {
+		t.Errorf("unexpected kustomization.yaml:\n%s", kustomization)
+	}
+
+	deployment := readFile(t, filepath.Join(params.Dir, "deployment.yaml"))
+	if strings.Contains(deployment, "namespace:") {
+		t.Errorf("expected namespace to be set by kustomize only, got:\n%s", deployment)
+	}
+}
+
+func TestWrite_Helm(t *testing.T) {
+	params := newWriteParams(t, export.FormatHelm)
+
+	if err := export.Write(params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	values := readFile(t, filepath.Join(params.Dir, "values.yaml"))
+	for _, want := range []string{"image: node:22", "replicas: 2", "port: 8080", "host: foobar.example.com", "className: nginx"} {
+		if !strings.Contains(values, want) {
+			t.Errorf("expected values.yaml to contain %q, got:\n%s", want, values)
+		}
+	}
+
+	deployment := readFile(t, filepath.Join(params.Dir, "templates", "deployment.yaml"))
+	for _, want := range []string{"image: {{ .Values.image | quote }}", "replicas: {{ .Values.replicas }}", "namespace: {{ .Release.Namespace }}"} {
+		if !strings.Contains(deployment, want) {
+			t.Errorf("expected deployment template to contain %q, got:\n%s", want, deployment)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(params.Dir, "Chart.yaml")); err != nil {
+		t.Errorf("expected Chart.yaml to exist: %v", err)
+	}
+}
+
+func TestWrite_Dockerfile(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "foobar")
+	if err := os.MkdirAll(source, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "index.js"), []byte("console.log('hi')"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	params := newWriteParams(t, export.FormatYAML)
+	params.Dockerfile = &export.Dockerfile{Image: "registry.example.com/foobar:1.0.0", Source: source}
+
+	if err := export.Write(params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	dockerfile := readFile(t, filepath.Join(params.Dir, "image", "Dockerfile"))
+	for _, want := range []string{"FROM node:22", "WORKDIR /app", "COPY foobar /app/foobar", `CMD ["node","foobar/index.js"]`} {
+		if !strings.Contains(dockerfile, want) {
+			t.Errorf("expected Dockerfile to contain %q, got:\n%s", want, dockerfile)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(params.Dir, "image", "foobar", "index.js")); err != nil {
+		t.Errorf("expected build context to be copied: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(params.Dir, "pvc.yaml")); !os.IsNotExist(err) {
+		t.Errorf("expected no PVC when baking an image, got %v", err)
+	}
+
+	deployment := readFile(t, filepath.Join(params.Dir, "deployment.yaml"))
+	if strings.Contains(deployment, "initContainers") || strings.Contains(deployment, "persistentVolumeClaim") {
+		t.Errorf("expected init container and PVC to be removed, got:\n%s", deployment)
+	}
+	if !strings.Contains(deployment, "image: registry.example.com/foobar:1.0.0") {
+		t.Errorf("expected baked image to be used, got:\n%s", deployment)
+	}
+}
+
+func TestWrite_UnsupportedFormat(t *testing.T) {
+	params := newWriteParams(t, export.Format("jsonnet"))
+
+	if err := export.Write(params); err == nil {
+		t.Fatalf("expected error for unsupported format, got nil")
+	}
+}
@@ -0,0 +1,102 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// helmValue maps a field of an exported resource to a key in the chart's values.yaml.
+type helmValue struct {
+	kind string
+	key  []string
+	path []interface{}
+}
+
+var helmValues = []helmValue{
+	{kind: "Deployment", key: []string{"image"}, path: []interface{}{"spec", "template", "spec", "containers", 0, "image"}},
+	{kind: "Deployment", key: []string{"replicas"}, path: []interface{}{"spec", "replicas"}},
+	{kind: "Service", key: []string{"service", "port"}, path: []interface{}{"spec", "ports", 0, "port"}},
+	{kind: "Ingress", key: []string{"ingress", "className"}, path: []interface{}{"spec", "ingressClassName"}},
+	{kind: "Ingress", key: []string{"ingress", "host"}, path: []interface{}{"spec", "rules", 0, "host"}},
+	{kind: "Ingress", key: []string{"service", "port"}, path: []interface{}{"spec", "rules", 0, "http", "paths", 0, "backend", "service", "port", "number"}},
+}
+
+const helmNamespacePlaceholder = "__k8run_release_namespace__"
+
+func writeHelm(params WriteParams, resources []resource) error {
+	values := map[string]interface{}{}
+	replacements := map[string]string{
+		helmNamespacePlaceholder: "{{ .Release.Namespace }}",
+	}
+
+	for _, r := range resources {
+		setNested(r.object, helmNamespacePlaceholder, "metadata", "namespace")
+
+		for _, v := range helmValues {
+			if r.object["kind"] != v.kind {
+				continue
+			}
+
+			placeholder := fmt.Sprintf("__k8run_values_%s__", strings.Join(v.key, "_"))
+			previous, ok := setNested(r.object, placeholder, v.path...)
+			if !ok {
+				continue
+			}
+
+			expression := fmt.Sprintf("{{ .Values.%s }}", strings.Join(v.key, "."))
+			if _, isString := previous.(string); isString {
+				expression = fmt.Sprintf("{{ .Values.%s | quote }}", strings.Join(v.key, "."))
+			}
+			replacements[placeholder] = expression
+			setValue(values, previous, v.key...)
+		}
+
+		b, err := yaml.Marshal(r.object)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", r.file, err)
+		}
+		for placeholder, expression := range replacements {
+			b = bytes.ReplaceAll(b, []byte(placeholder), []byte(expression))
+		}
+
+		if err := writeFile(filepath.Join(params.Dir, "templates", r.file), b); err != nil {
+			return err
+		}
+	}
+
+	if err := writeYAML(filepath.Join(params.Dir, "values.yaml"), values); err != nil {
+		return err
+	}
+
+	if params.Dockerfile != nil {
+		if err := writeFile(filepath.Join(params.Dir, ".helmignore"), []byte(imageDir+"/\n")); err != nil {
+			return err
+		}
+	}
+
+	return writeYAML(filepath.Join(params.Dir, "Chart.yaml"), map[string]interface{}{
+		"apiVersion":  "v2",
+		"name":        params.Name,
+		"description": fmt.Sprintf("Helm chart for %s, exported by k8run", params.Name),
+		"type":        "application",
+		"version":     "0.1.0",
+		"appVersion":  "0.1.0",
+	})
+}
+
+func setValue(values map[string]interface{}, value interface{}, key ...string) {
+	current := values
+	for _, k := range key[:len(key)-1] {
+		next, ok := current[k].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			current[k] = next
+		}
+		current = next
+	}
+	current[key[len(key)-1]] = value
+}
@@ -0,0 +1,149 @@
+package guard
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// EnvFile is the env var pointing to a team guard file, eg: one shared in a repository.
+	EnvFile = "K8RUN_GUARD_FILE"
+	// InCluster is the context name used when k8run runs inside a pod, without a kubeconfig context.
+	InCluster = "in-cluster"
+)
+
+// Verdict is the result of checking a target against the guard rules.
+type Verdict int
+
+const (
+	// Allowed targets are listed as allowed, so the usual confirmation is enough.
+	Allowed Verdict = iota
+	// Unlisted targets aren't listed at all, so the context name must be typed to confirm.
+	Unlisted
+	// Denied targets are refused.
+	Denied
+)
+
+// Rules are glob patterns (eg: '*prod*') of allowed and denied names.
+type Rules struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// Config represents the guard rules of the contexts and namespaces k8run can change.
+type Config struct {
+	Contexts   Rules `json:"contexts"`
+	Namespaces Rules `json:"namespaces"`
+
+	// files are the files the config has been loaded from.
+	files []string
+}
+
+// Files returns the default guard files: the user file and, when set, the team file of the K8RUN_GUARD_FILE env var.
+func Files() []string {
+	files := []string{}
+	if dir, err := os.UserConfigDir(); err == nil {
+		files = append(files, filepath.Join(dir, "k8run", "guard.yaml"))
+	}
+	if file := os.Getenv(EnvFile); file != "" {
+		files = append(files, file)
+	}
+	return files
+}
+
+// Load reads and merges the given guard files, ignoring the ones that don't exist. It returns nil when none exists,
+// in which case there are no guard rails.
+func Load(files ...string) (*Config, error) {
+	var merged *Config
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read guard file: %w", err)
+		}
+
+		config, err := parse(b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid guard file %s: %w", file, err)
+		}
+
+		if merged == nil {
+			merged = &Config{}
+		}
+		merged.Contexts.Allow = append(merged.Contexts.Allow, config.Contexts.Allow...)
+		merged.Contexts.Deny = append(merged.Contexts.Deny, config.Contexts.Deny...)
+		merged.Namespaces.Allow = append(merged.Namespaces.Allow, config.Namespaces.Allow...)
+		merged.Namespaces.Deny = append(merged.Namespaces.Deny, config.Namespaces.Deny...)
+		merged.files = append(merged.files, file)
+	}
+
+	return merged, nil
+}
+
+// Check checks the given context and namespace. Deny patterns win over allow patterns, and a target is only allowed
+// when both its context and namespace are allowed. Without namespace patterns, every namespace is allowed.
+// The reason explains the verdict.
+func (c *Config) Check(context, namespace string) (Verdict, string) {
+	if c == nil {
+		return Allowed, "no guard file"
+	}
+
+	if pattern, ok := match(c.Contexts.Deny, context); ok {
+		return Denied, fmt.Sprintf("context %q is denied by %q in %v", context, pattern, c.files)
+	}
+	if pattern, ok := match(c.Namespaces.Deny, namespace); ok {
+		return Denied, fmt.Sprintf("namespace %q is denied by %q in %v", namespace, pattern, c.files)
+	}
+
+	if _, ok := match(c.Contexts.Allow, context); !ok {
+		return Unlisted, fmt.Sprintf("context %q isn't allowed in %v", context, c.files)
+	}
+	if len(c.Namespaces.Allow) > 0 {
+		if _, ok := match(c.Namespaces.Allow, namespace); !ok {
+			return Unlisted, fmt.Sprintf("namespace %q isn't allowed in %v", namespace, c.files)
+		}
+	}
+
+	return Allowed, fmt.Sprintf("allowed in %v", c.files)
+}
+
+func parse(b []byte) (*Config, error) {
+	j, err := yaml.YAMLToJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(j))
+	decoder.DisallowUnknownFields()
+	config := &Config{}
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("failed to decode guard file: %w", err)
+	}
+
+	return config, nil
+}
+
+// match returns the first pattern matching the name. In patterns, '*' matches any text, including slashes
+// (eg: EKS contexts are ARNs), and '?' matches a single character.
+func match(patterns []string, name string) (string, bool) {
+	for _, pattern := range patterns {
+		expr := regexp.QuoteMeta(pattern)
+		expr = strings.ReplaceAll(expr, `\*`, ".*")
+		expr = strings.ReplaceAll(expr, `\?`, ".")
+		if regexp.MustCompile("^" + expr + "$").MatchString(name) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
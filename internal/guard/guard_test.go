@@ -0,0 +1,90 @@
+package guard_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/guard"
+)
+
+const userGuard = `
+contexts:
+  allow: ["kind-*", "dev"]
+  deny: ["*prod*"]
+`
+
+const teamGuard = `
+contexts:
+  allow: ["arn:aws:eks:*:cluster/staging"]
+namespaces:
+  allow: ["team-*"]
+  deny: ["kube-system"]
+`
+
+func writeGuard(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	user := writeGuard(t, dir, "user.yaml", userGuard)
+	team := writeGuard(t, dir, "team.yaml", teamGuard)
+	invalid := writeGuard(t, dir, "invalid.yaml", "contexts:\n  allowed: [dev]\n")
+
+	config, err := guard.Load(filepath.Join(dir, "missing.yaml"))
+	if err != nil || config != nil {
+		t.Fatalf("expected no config and no error when no file exists, got %v and %v", config, err)
+	}
+
+	if _, err := guard.Load(invalid); err == nil {
+		t.Fatalf("expected an error for an unknown key")
+	}
+
+	config, err = guard.Load(user, team, filepath.Join(dir, "missing.yaml"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(config.Contexts.Allow) != 3 || len(config.Contexts.Deny) != 1 || len(config.Namespaces.Allow) != 1 || len(config.Namespaces.Deny) != 1 {
+		t.Errorf("expected the rules of both files to be merged, got %+v", config)
+	}
+}
+
+func TestConfig_Check(t *testing.T) {
+	dir := t.TempDir()
+	config, err := guard.Load(writeGuard(t, dir, "user.yaml", userGuard), writeGuard(t, dir, "team.yaml", teamGuard))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		config    *guard.Config
+		context   string
+		namespace string
+		want      guard.Verdict
+	}{
+		{name: "no guard file", config: nil, context: "prod", namespace: "default", want: guard.Allowed},
+		{name: "allowed", config: config, context: "kind-dev", namespace: "team-a", want: guard.Allowed},
+		{name: "allowed arn", config: config, context: "arn:aws:eks:eu-west-1:123:cluster/staging", namespace: "team-a", want: guard.Allowed},
+		{name: "denied context", config: config, context: "eks-prod-1", namespace: "team-a", want: guard.Denied},
+		{name: "deny wins over allow", config: config, context: "kind-prod", namespace: "team-a", want: guard.Denied},
+		{name: "denied namespace", config: config, context: "dev", namespace: "kube-system", want: guard.Denied},
+		{name: "unlisted context", config: config, context: "staging", namespace: "team-a", want: guard.Unlisted},
+		{name: "unlisted namespace", config: config, context: "dev", namespace: "default", want: guard.Unlisted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := tt.config.Check(tt.context, tt.namespace)
+			if got != tt.want {
+				t.Errorf("expected verdict %v, got %v (%s)", tt.want, got, reason)
+			}
+		})
+	}
+}
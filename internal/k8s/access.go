@@ -0,0 +1,74 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Permission represents a verb on a resource, eg: create on the exec subresource of pods.
+type Permission struct {
+	Verb        string
+	Group       string
+	Resource    string
+	Subresource string
+	// ClusterScoped is set for resources that don't live in a namespace, eg: namespaces.
+	ClusterScoped bool
+}
+
+// String returns the permission as kubectl auth can-i takes it. eg: 'create pods/exec' or 'create ingresses.networking.k8s.io'
+func (p Permission) String() string {
+	resource := p.Resource
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	if p.Subresource != "" {
+		resource += "/" + p.Subresource
+	}
+	return fmt.Sprintf("%s %s", p.Verb, resource)
+}
+
+// AccessReview represents whether the current user has a permission.
+type AccessReview struct {
+	Permission Permission
+	Allowed    bool
+	// Reason is the reason given by the authorizer, if any.
+	Reason string
+}
+
+// ReviewAccess checks, with a SelfSubjectAccessReview each, whether the current user has the given permissions in the namespace.
+func ReviewAccess(ctx context.Context, clientset kubernetes.Interface, namespace string, permissions []Permission) ([]AccessReview, error) {
+	reviews := []AccessReview{}
+	for _, permission := range permissions {
+		attributes := &authorizationv1.ResourceAttributes{
+			Namespace:   namespace,
+			Verb:        permission.Verb,
+			Group:       permission.Group,
+			Resource:    permission.Resource,
+			Subresource: permission.Subresource,
+		}
+		if permission.ClusterScoped {
+			attributes.Namespace = ""
+		}
+
+		review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: attributes,
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to review access to %s: %w", permission, err)
+		}
+
+		reviews = append(reviews, AccessReview{
+			Permission: permission,
+			Allowed:    review.Status.Allowed,
+			Reason:     review.Status.Reason,
+		})
+	}
+
+	return reviews, nil
+}
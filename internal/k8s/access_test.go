@@ -0,0 +1,50 @@
+package k8s_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestReviewAccess(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		want := "team-a"
+		if attributes.Resource == "namespaces" {
+			want = ""
+		}
+		if attributes.Namespace != want {
+			t.Errorf("expected namespace %q for %s, got %q", want, attributes.Resource, attributes.Namespace)
+		}
+		review.Status.Allowed = attributes.Subresource != "exec"
+		return true, review, nil
+	})
+
+	permissions := []k8s.Permission{
+		{Verb: "create", Group: "apps", Resource: "deployments"},
+		{Verb: "create", Resource: "pods", Subresource: "exec"},
+		{Verb: "create", Resource: "namespaces", ClusterScoped: true},
+	}
+	reviews, err := k8s.ReviewAccess(context.Background(), clientset, "team-a", permissions)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(reviews) != 3 || !reviews[0].Allowed || reviews[1].Allowed {
+		t.Fatalf("expected only the deployment permission to be allowed, got %+v", reviews)
+	}
+	if got := reviews[0].Permission.String(); got != "create deployments.apps" {
+		t.Errorf("expected 'create deployments.apps', got %q", got)
+	}
+	if got := reviews[1].Permission.String(); got != "create pods/exec" {
+		t.Errorf("expected 'create pods/exec', got %q", got)
+	}
+}
@@ -0,0 +1,207 @@
+package k8s
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+// ArchiveFormat is the format of an archive source, whose entries are copied instead of the archive itself.
+type ArchiveFormat string
+
+const (
+	ArchiveTar     ArchiveFormat = "tar"
+	ArchiveTarGzip ArchiveFormat = "tar.gz"
+	ArchiveZip     ArchiveFormat = "zip"
+)
+
+// DetectArchive returns the format of the archive at path from its extension, or an empty format when it isn't
+// an archive.
+func DetectArchive(path string) ArchiveFormat {
+	name := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(name, ".tar"):
+		return ArchiveTar
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return ArchiveTarGzip
+	case strings.HasSuffix(name, ".zip"):
+		return ArchiveZip
+	}
+	return ""
+}
+
+// archiveSize returns the size of the files of the archive, before and after leaving out the skipped ones.
+func (s Source) archiveSize() (total int64, filtered int64, err error) {
+	err = s.walkArchive(func(name string, header *tar.Header, _ io.Reader) error {
+		if header.Typeflag != tar.TypeReg {
+			return nil
+		}
+		total += header.Size
+		if !s.skippedName(name) {
+			filtered += header.Size
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to size %s: %w", s.Path, err)
+	}
+	return total, filtered, nil
+}
+
+// writeArchiveEntries writes the entries of the archive to tw, in Name.
+func (s Source) writeArchiveEntries(tw *tar.Writer, copied *atomic.Int64) error {
+	prefix := s.Name
+	if prefix == "." {
+		prefix = ""
+	}
+
+	err := s.walkArchive(func(name string, header *tar.Header, r io.Reader) error {
+		if s.skippedName(name) {
+			return nil
+		}
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeDir, tar.TypeSymlink:
+		case tar.TypeLink:
+			link, err := archiveName(header.Linkname)
+			if err != nil {
+				return err
+			}
+			header.Linkname = path.Join(prefix, link)
+		default:
+			// devices, fifos and the like aren't code
+			return nil
+		}
+
+		header.Name = path.Join(prefix, name)
+		if header.Typeflag == tar.TypeDir {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil
+		}
+
+		if copied != nil {
+			r = &countingReader{r: r, n: copied}
+		}
+		_, err := io.Copy(tw, r)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", s.Path, err)
+	}
+
+	return nil
+}
+
+// walkArchive calls fn with the validated name, the header and the content of each entry of the archive, in order.
+// Entries of zip archives get the headers of a tar archive.
+func (s Source) walkArchive(fn func(name string, header *tar.Header, r io.Reader) error) error {
+	if s.Archive == ArchiveZip {
+		return s.walkZip(fn)
+	}
+
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if s.Archive == ArchiveTarGzip {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name, err := archiveName(header.Name)
+		if err != nil {
+			return err
+		}
+		if name == "." || header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		if err := fn(name, header, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func (s Source) walkZip(fn func(name string, header *tar.Header, r io.Reader) error) error {
+	zr, err := zip.OpenReader(s.Path)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, file := range zr.File {
+		name, err := archiveName(file.Name)
+		if err != nil {
+			return err
+		}
+		if name == "." {
+			continue
+		}
+
+		err = func() error {
+			info := file.FileInfo()
+			rc, err := file.Open()
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+
+			// the target of a symlink is its content
+			link := ""
+			if info.Mode()&fs.ModeSymlink != 0 {
+				target, err := io.ReadAll(rc)
+				if err != nil {
+					return err
+				}
+				link = string(target)
+			}
+
+			header, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			return fn(name, header, rc)
+		}()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveName returns the cleaned, slash separated name of an archive entry. Names escaping the archive, eg:
+// '../etc/passwd' or '/etc/passwd', are refused.
+func archiveName(name string) (string, error) {
+	cleaned := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("entry %s is outside of the archive", name)
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,141 @@
+package k8s_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+)
+
+// writeArchive writes the files to an archive of the given format in dir and returns its path.
+func writeArchive(t *testing.T, dir string, format k8s.ArchiveFormat, files map[string]string) string {
+	t.Helper()
+	file := filepath.Join(dir, "code."+string(format))
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	if format == k8s.ArchiveZip {
+		zw := zip.NewWriter(f)
+		for _, name := range names {
+			w, err := zw.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.WriteString(w, files[name]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	var w io.Writer = f
+	if format == k8s.ArchiveTarGzip {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	for _, name := range names {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, files[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestDetectArchive(t *testing.T) {
+	for path, want := range map[string]k8s.ArchiveFormat{
+		"code.tar":     k8s.ArchiveTar,
+		"code.tar.gz":  k8s.ArchiveTarGzip,
+		"CODE.TGZ":     k8s.ArchiveTarGzip,
+		"code.zip":     k8s.ArchiveZip,
+		"./foobar":     "",
+		"index.js":     "",
+		"code.tar.bz2": "",
+	} {
+		if got := k8s.DetectArchive(path); got != want {
+			t.Errorf("expected %q for %s, got %q", want, path, got)
+		}
+	}
+}
+
+func TestMemoryCopier_Archive(t *testing.T) {
+	files := map[string]string{
+		"./index.js":        "console.log('hi')",
+		"lib/util.js":       "module.exports = {}",
+		"node_modules/a.js": "12345",
+	}
+
+	for _, format := range []k8s.ArchiveFormat{k8s.ArchiveTar, k8s.ArchiveTarGzip, k8s.ArchiveZip} {
+		t.Run(string(format), func(t *testing.T) {
+			source := k8s.Source{
+				Path:    writeArchive(t, t.TempDir(), format, files),
+				Archive: format,
+				Skip:    func(name string) bool { return name == "node_modules" },
+			}
+
+			total, filtered, err := source.Size()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != 41 || filtered != 36 {
+				t.Errorf("expected 41 and 36 bytes, got %d and %d", total, filtered)
+			}
+
+			copier := &k8s.MemoryCopier{}
+			if err := copier.Copy(context.Background(), k8s.CopyParams{Sources: []k8s.Source{source}, ContainerPath: "/app"}); err != nil {
+				t.Fatal(err)
+			}
+			want := []string{"/app/index.js", "/app/lib/util.js"}
+			if files := copier.Files(); !slices.Equal(files, want) {
+				t.Errorf("expected %v, got %v", want, files)
+			}
+
+			source.Name = "k8run-copy-1"
+			copier = &k8s.MemoryCopier{}
+			if err := copier.Copy(context.Background(), k8s.CopyParams{Sources: []k8s.Source{source}, ContainerPath: "/app"}); err != nil {
+				t.Fatal(err)
+			}
+			if content, ok := copier.File("/app/k8run-copy-1/index.js"); !ok || string(content) != "console.log('hi')" {
+				t.Errorf("expected the archive to be extracted in its name, got %v", copier.Files())
+			}
+		})
+	}
+}
+
+func TestMemoryCopier_ArchiveOutside(t *testing.T) {
+	for _, name := range []string{"../etc/passwd", "/etc/passwd", "lib/../../passwd"} {
+		t.Run(name, func(t *testing.T) {
+			source := k8s.Source{Path: writeArchive(t, t.TempDir(), k8s.ArchiveTar, map[string]string{name: "root"}), Archive: k8s.ArchiveTar}
+			copier := &k8s.MemoryCopier{}
+			if err := copier.Copy(context.Background(), k8s.CopyParams{Sources: []k8s.Source{source}, ContainerPath: "/app"}); err == nil {
+				t.Errorf("expected an error, got %v", copier.Files())
+			}
+		})
+	}
+}
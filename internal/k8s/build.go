@@ -0,0 +1,147 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/lucasvmiguel/k8run/internal/logging"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// BuildImageParams represents the parameters to build an image in the cluster.
+type BuildImageParams struct {
+	Name              string
+	Namespace         string
+	App               string
+	ReleaseIdentifier string
+	InitContainerName string
+	// InitContainerCommand waits for the context to be copied into ContextPath.
+	InitContainerCommand []string
+	// ContextPath is where the context of the build is copied. eg: '/app'
+	ContextPath string
+	// Dockerfile is the path of the Dockerfile, relative to the context. eg: 'Dockerfile'
+	Dockerfile string
+	// Destination is the image pushed. eg: 'ghcr.io/org/app:abc123'
+	Destination string
+	// RegistrySecret is the docker config secret, of type kubernetes.io/dockerconfigjson, the image is pushed with.
+	RegistrySecret string
+	// NodeArch builds on the nodes of the architecture, the one the image is built for. eg: 'arm64'
+	NodeArch string
+}
+
+// BuildImageJob builds the job object building and pushing an image without sending it to the cluster.
+// Its pod waits in the init container for the context to be copied, then builds the Dockerfile without a docker
+// daemon nor privileges, and writes the digest of the image pushed as the termination message of the builder.
+func BuildImageJob(params BuildImageParams) *batchv1.Job {
+	backoffLimit := int32(0)
+	ttl := jobTTL
+	labels := map[string]string{
+		LabelNameCreatedBy:         LabelValueCreatedBy,
+		LabelNameReleaseIdentifier: params.ReleaseIdentifier,
+		LabelNameApp:               params.App,
+	}
+
+	job := &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      params.Name,
+			Namespace: params.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					InitContainers: []corev1.Container{
+						{
+							Name:    params.InitContainerName,
+							Image:   HelperImage,
+							Command: params.InitContainerCommand,
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "context",
+									MountPath: params.ContextPath,
+								},
+							},
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:  BuilderContainerName,
+							Image: BuilderImage,
+							Args: []string{
+								"--context=dir://" + params.ContextPath,
+								"--dockerfile=" + path.Join(params.ContextPath, params.Dockerfile),
+								"--destination=" + params.Destination,
+								"--digest-file=/dev/termination-log",
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "context",
+									MountPath: params.ContextPath,
+								},
+							},
+						},
+					},
+					// the context only lives as long as the build
+					Volumes: []corev1.Volume{
+						{
+							Name: "context",
+							VolumeSource: corev1.VolumeSource{
+								EmptyDir: &corev1.EmptyDirVolumeSource{},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if params.RegistrySecret != "" {
+		podSpec := &job.Spec.Template.Spec
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: "registry-secret",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: params.RegistrySecret,
+					Items:      []corev1.KeyToPath{{Key: corev1.DockerConfigJsonKey, Path: "config.json"}},
+				},
+			},
+		})
+		podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      "registry-secret",
+			MountPath: builderDockerConfigPath,
+			ReadOnly:  true,
+		})
+	}
+
+	if params.NodeArch != "" {
+		job.Spec.Template.Spec.NodeSelector = map[string]string{corev1.LabelArchStable: params.NodeArch}
+	}
+
+	return job
+}
+
+// CreateBuildImageJob creates the job building and pushing an image in the given namespace.
+func CreateBuildImageJob(ctx context.Context, clientset kubernetes.Interface, params BuildImageParams) error {
+	_, err := clientset.BatchV1().Jobs(params.Namespace).Create(ctx, BuildImageJob(params), metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create build job: %w", err)
+	}
+
+	logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace, "destination", params.Destination).Info("Build job created")
+	return nil
+}
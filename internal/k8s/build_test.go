@@ -0,0 +1,51 @@
+package k8s_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCreateBuildImageJob(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	params := k8s.BuildImageParams{
+		Name:                 "test-build-abc",
+		Namespace:            "default",
+		App:                  "test",
+		ReleaseIdentifier:    "abc",
+		InitContainerName:    "init-container",
+		InitContainerCommand: []string{"sh", "-c", "echo 'Init'"},
+		ContextPath:          "/app",
+		Dockerfile:           "Dockerfile",
+		Destination:          "ghcr.io/org/test:abc",
+		RegistrySecret:       "registry",
+	}
+
+	if err := k8s.CreateBuildImageJob(context.Background(), clientset, params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	job, err := clientset.BatchV1().Jobs(params.Namespace).Get(context.Background(), params.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get created job: %v", err)
+	}
+
+	spec := job.Spec.Template.Spec
+	if spec.Volumes[0].EmptyDir == nil {
+		t.Errorf("expected the context to live in an empty dir, got %v", spec.Volumes)
+	}
+	builder := spec.Containers[0]
+	if builder.Name != k8s.BuilderContainerName || builder.Image != k8s.BuilderImage || builder.SecurityContext != nil {
+		t.Errorf("expected the unprivileged builder, got %+v", builder)
+	}
+	if secret := spec.Volumes[1].Secret; secret == nil || secret.SecretName != "registry" || secret.Items[0].Key != corev1.DockerConfigJsonKey {
+		t.Errorf("expected the registry secret to be mounted as the docker config, got %v", spec.Volumes)
+	}
+	if mount := builder.VolumeMounts[1]; mount.MountPath != "/kaniko/.docker" || !mount.ReadOnly {
+		t.Errorf("expected the docker config to be mounted read-only, got %v", builder.VolumeMounts)
+	}
+}
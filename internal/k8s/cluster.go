@@ -0,0 +1,207 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/logging"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	annotationDefaultStorageClass = "storageclass.kubernetes.io/is-default-class"
+	annotationDefaultIngressClass = "ingressclass.kubernetes.io/is-default-class"
+)
+
+// GetDefaultStorageClass gets the storage class used by PVCs that don't name one, like the ones k8run creates.
+func GetDefaultStorageClass(ctx context.Context, clientset kubernetes.Interface) (*storagev1.StorageClass, error) {
+	list, err := clientset.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage classes: %w", err)
+	}
+
+	for _, storageClass := range list.Items {
+		if storageClass.Annotations[annotationDefaultStorageClass] == "true" {
+			return &storageClass, nil
+		}
+	}
+
+	return nil, ErrResourceNotFound
+}
+
+// GetIngressClass gets an ingress class by name or, when the name is empty, the default one.
+func GetIngressClass(ctx context.Context, clientset kubernetes.Interface, name string) (*networkingv1.IngressClass, error) {
+	list, err := clientset.NetworkingV1().IngressClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ingress classes: %w", err)
+	}
+
+	for _, ingressClass := range list.Items {
+		if ingressClass.Name == name || (name == "" && ingressClass.Annotations[annotationDefaultIngressClass] == "true") {
+			return &ingressClass, nil
+		}
+	}
+
+	return nil, ErrResourceNotFound
+}
+
+// SupportsResource returns true if the cluster serves the resource in the given group version. eg: 'networking.k8s.io/v1' and 'ingresses'
+func SupportsResource(clientset kubernetes.Interface, groupVersion, resource string) (bool, error) {
+	list, err := clientset.Discovery().ServerResourcesForGroupVersion(groupVersion)
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to discover %s: %w", groupVersion, err)
+	}
+
+	return slices.ContainsFunc(list.APIResources, func(r metav1.APIResource) bool { return r.Name == resource }), nil
+}
+
+// probeCleanupTimeout is how long deleting the test PVC and pod may take once the probe is done.
+const probeCleanupTimeout = 10 * time.Second
+
+// ProbeParams represents the parameters to probe the cluster with a test PVC and pod.
+type ProbeParams struct {
+	Name      string
+	Namespace string
+	// Interval is how often the pod and PVC are checked. Defaults to 2s.
+	Interval time.Duration
+}
+
+// ProbeResult represents what the probe found out.
+type ProbeResult struct {
+	// ImagePulled is set when the helper image has been pulled.
+	ImagePulled bool
+	// PVCBound is set when the test PVC has been bound.
+	PVCBound bool
+	// Message explains what went wrong, if anything.
+	Message string
+}
+
+// Probe creates a test PVC and a pod of the helper image mounting it, waits for the pod to complete (or the context
+// to be done) and deletes both. The pod is needed because storage classes may wait for a consumer to bind.
+func Probe(ctx context.Context, clientset kubernetes.Interface, params ProbeParams) (ProbeResult, error) {
+	result := ProbeResult{}
+	interval := params.Interval
+	if interval == 0 {
+		interval = 2 * time.Second
+	}
+
+	pvcClient := clientset.CoreV1().PersistentVolumeClaims(params.Namespace)
+	podsClient := clientset.CoreV1().Pods(params.Namespace)
+
+	pvc := BuildPVC(CreatePVCIfNotExistsParams{Name: params.Name, Namespace: params.Namespace})
+	if _, err := pvcClient.Create(ctx, pvc, metav1.CreateOptions{}); err != nil {
+		return result, fmt.Errorf("failed to create test PVC: %w", err)
+	}
+	defer func() {
+		// the context may be done already, the probe must be cleaned up anyway, without hanging on the API server
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), probeCleanupTimeout)
+		defer cancel()
+		if err := pvcClient.Delete(ctx, params.Name, metav1.DeleteOptions{}); err != nil {
+			logging.FromContext(ctx).With("name", params.Name, "error", err).Warn("Failed to delete test PVC")
+		}
+	}()
+
+	if _, err := podsClient.Create(ctx, buildProbePod(params), metav1.CreateOptions{}); err != nil {
+		return result, fmt.Errorf("failed to create test pod: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), probeCleanupTimeout)
+		defer cancel()
+		if err := podsClient.Delete(ctx, params.Name, metav1.DeleteOptions{}); err != nil {
+			logging.FromContext(ctx).With("name", params.Name, "error", err).Warn("Failed to delete test pod")
+		}
+	}()
+
+	for {
+		pod, err := podsClient.Get(ctx, params.Name, metav1.GetOptions{})
+		if err != nil {
+			if ctx.Err() == nil {
+				return result, fmt.Errorf("failed to get test pod: %w", err)
+			}
+			pod = nil
+		}
+		if pod != nil {
+			for _, status := range pod.Status.ContainerStatuses {
+				if status.ImageID != "" {
+					result.ImagePulled = true
+				}
+				if waiting := status.State.Waiting; waiting != nil && slices.Contains([]string{"ErrImagePull", "ImagePullBackOff", "InvalidImageName"}, waiting.Reason) {
+					result.Message = fmt.Sprintf("%s: %s", waiting.Reason, waiting.Message)
+					return result, nil
+				}
+			}
+		}
+
+		claim, err := pvcClient.Get(ctx, params.Name, metav1.GetOptions{})
+		if err == nil {
+			result.PVCBound = claim.Status.Phase == corev1.ClaimBound
+		}
+
+		if pod != nil && pod.Status.Phase == corev1.PodSucceeded {
+			result.ImagePulled = true
+			return result, nil
+		}
+		if pod != nil && pod.Status.Phase == corev1.PodFailed {
+			result.Message = fmt.Sprintf("test pod failed: %s", pod.Status.Message)
+			return result, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			result.Message = "timed out waiting for the test pod to complete"
+			if pod != nil {
+				for _, condition := range pod.Status.Conditions {
+					if condition.Type == corev1.PodScheduled && condition.Status != corev1.ConditionTrue {
+						result.Message = fmt.Sprintf("test pod not scheduled: %s", condition.Message)
+					}
+				}
+			}
+			return result, nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+func buildProbePod(params ProbeParams) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      params.Name,
+			Namespace: params.Namespace,
+			Labels: map[string]string{
+				LabelNameCreatedBy: LabelValueCreatedBy,
+			},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{
+					Name:    "probe",
+					Image:   HelperImage,
+					Command: []string{"true"},
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: "probe", MountPath: "/probe"},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: "probe",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: params.Name},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,157 @@
+package k8s_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestGetDefaultStorageClass(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "slow"}})
+	if _, err := k8s.GetDefaultStorageClass(context.Background(), clientset); !errors.Is(err, k8s.ErrResourceNotFound) {
+		t.Fatalf("expected ErrResourceNotFound, got %v", err)
+	}
+
+	clientset = fake.NewSimpleClientset(
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "slow"}},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{
+			Name:        "standard",
+			Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"},
+		}},
+	)
+	storageClass, err := k8s.GetDefaultStorageClass(context.Background(), clientset)
+	if err != nil || storageClass.Name != "standard" {
+		t.Fatalf("expected the standard storage class, got %v and %v", storageClass, err)
+	}
+}
+
+func TestGetIngressClass(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&networkingv1.IngressClass{ObjectMeta: metav1.ObjectMeta{Name: "traefik"}},
+		&networkingv1.IngressClass{ObjectMeta: metav1.ObjectMeta{
+			Name:        "nginx",
+			Annotations: map[string]string{"ingressclass.kubernetes.io/is-default-class": "true"},
+		}},
+	)
+
+	tests := []struct {
+		name    string
+		class   string
+		want    string
+		wantErr error
+	}{
+		{name: "by name", class: "traefik", want: "traefik"},
+		{name: "default", class: "", want: "nginx"},
+		{name: "missing", class: "haproxy", wantErr: k8s.ErrResourceNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingressClass, err := k8s.GetIngressClass(context.Background(), clientset, tt.class)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && ingressClass.Name != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, ingressClass.Name)
+			}
+		})
+	}
+}
+
+func TestSupportsResource(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{GroupVersion: "networking.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "ingresses"}}},
+	}
+
+	ok, err := k8s.SupportsResource(clientset, "networking.k8s.io/v1", "ingresses")
+	if err != nil || !ok {
+		t.Errorf("expected ingresses to be supported, got %v and %v", ok, err)
+	}
+
+	ok, err = k8s.SupportsResource(clientset, "networking.k8s.io/v1", "ingressclasses")
+	if err != nil || ok {
+		t.Errorf("expected ingressclasses not to be supported, got %v and %v", ok, err)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	tests := []struct {
+		name  string
+		state func(pod *corev1.Pod)
+		want  k8s.ProbeResult
+	}{
+		{
+			name:  "succeeded",
+			state: func(pod *corev1.Pod) { pod.Status.Phase = corev1.PodSucceeded },
+			want:  k8s.ProbeResult{ImagePulled: true, PVCBound: true},
+		},
+		{
+			name: "image pull error",
+			state: func(pod *corev1.Pod) {
+				pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "rate limited"}},
+				}}
+			},
+			want: k8s.ProbeResult{Message: "ImagePullBackOff: rate limited"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			clientset.PrependReactor("create", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				pvc := action.(k8stesting.CreateAction).GetObject().(*corev1.PersistentVolumeClaim)
+				pvc.Status.Phase = corev1.ClaimBound
+				return false, nil, nil
+			})
+			clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				tt.state(action.(k8stesting.CreateAction).GetObject().(*corev1.Pod))
+				return false, nil, nil
+			})
+
+			result, err := k8s.Probe(context.Background(), clientset, k8s.ProbeParams{Name: "probe", Namespace: "default", Interval: time.Millisecond})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if result.ImagePulled != tt.want.ImagePulled || result.Message != tt.want.Message {
+				t.Errorf("expected %+v, got %+v", tt.want, result)
+			}
+			if tt.want.PVCBound && !result.PVCBound {
+				t.Errorf("expected the PVC to be bound")
+			}
+
+			pods, _ := clientset.CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{})
+			pvcs, _ := clientset.CoreV1().PersistentVolumeClaims("default").List(context.Background(), metav1.ListOptions{})
+			if len(pods.Items) != 0 || len(pvcs.Items) != 0 {
+				t.Errorf("expected the probe to be cleaned up, got %d pods and %d PVCs", len(pods.Items), len(pvcs.Items))
+			}
+		})
+	}
+}
+
+func TestProbe_Timeout(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	result, err := k8s.Probe(ctx, clientset, k8s.ProbeParams{Name: "probe", Namespace: "default", Interval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.PVCBound || result.ImagePulled || result.Message == "" {
+		t.Errorf("expected a timed out probe, got %+v", result)
+	}
+}
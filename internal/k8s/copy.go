@@ -0,0 +1,711 @@
+package k8s
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/logging"
+
+	"github.com/klauspost/compress/zstd"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes/scheme"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// CopyTransport is how the code is sent to the pod.
+type CopyTransport string
+
+const (
+	// CopyTransportAuto streams over WebSocket, falling back to SPDY when the cluster rejects it.
+	CopyTransportAuto CopyTransport = "auto"
+	// CopyTransportWebSocket streams with the WebSocket exec protocol, served since Kubernetes 1.29.
+	CopyTransportWebSocket CopyTransport = "websocket"
+	// CopyTransportSPDY streams with the SPDY exec protocol, deprecated but served by every cluster.
+	CopyTransportSPDY CopyTransport = "spdy"
+	// CopyTransportKubectl runs 'kubectl cp', which must be on PATH.
+	CopyTransportKubectl CopyTransport = "kubectl"
+)
+
+// CopyTransports are the supported copy transports.
+var CopyTransports = []CopyTransport{CopyTransportAuto, CopyTransportWebSocket, CopyTransportSPDY, CopyTransportKubectl}
+
+// CopyCompression is how the archive sent to the pod is compressed.
+type CopyCompression string
+
+const (
+	// CopyCompressionAuto picks the best compression the container can decompress: zstd, gzip or none.
+	CopyCompressionAuto CopyCompression = "auto"
+	CopyCompressionNone CopyCompression = "none"
+	CopyCompressionGzip CopyCompression = "gzip"
+	CopyCompressionZstd CopyCompression = "zstd"
+)
+
+// CopyCompressions are the supported copy compressions.
+var CopyCompressions = []CopyCompression{CopyCompressionAuto, CopyCompressionNone, CopyCompressionGzip, CopyCompressionZstd}
+
+// probeCompression prints the best compression the container can decompress.
+const probeCompression = `if command -v zstd >/dev/null; then echo zstd; elif command -v gzip >/dev/null; then echo gzip; else echo none; fi`
+
+// extractCommand returns the command extracting an archive compressed with the given compression into dir.
+func extractCommand(compression CopyCompression, dir string) []string {
+	switch compression {
+	case CopyCompressionGzip:
+		return []string{"sh", "-c", `gzip -dc | tar -xf - -C "$0"`, dir}
+	case CopyCompressionZstd:
+		return []string{"sh", "-c", `zstd -dc | tar -xf - -C "$0"`, dir}
+	}
+	return []string{"tar", "-xf", "-", "-C", dir}
+}
+
+// parseCompression parses the output of probeCompression.
+func parseCompression(output string) (CopyCompression, error) {
+	compression := CopyCompression(strings.TrimSpace(output))
+	if !slices.Contains([]CopyCompression{CopyCompressionNone, CopyCompressionGzip, CopyCompressionZstd}, compression) {
+		return "", fmt.Errorf("failed to find the compressions the container supports: %q", output)
+	}
+	return compression, nil
+}
+
+// Source is a local file, folder or archive to copy, with the files to leave out.
+type Source struct {
+	Path string
+	// Name is where Path lands, relative to the destination, '.' being the destination itself. Defaults to the
+	// base name of Path, like 'kubectl cp'.
+	Name string
+	// Skip leaves out the files and folders it returns true for, given their slash separated path relative to
+	// Path. eg: '.git'
+	Skip func(name string) bool
+	// Archive, when set, copies the entries of the archive at Path instead of the archive itself, landing in the
+	// destination unless Name is set. eg: ArchiveTarGzip
+	Archive ArchiveFormat
+}
+
+// Size returns the size of the files of the source, before and after leaving out the skipped ones.
+func (s Source) Size() (total int64, filtered int64, err error) {
+	if s.Archive != "" {
+		return s.archiveSize()
+	}
+
+	root := filepath.Clean(s.Path)
+	err = filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		if !s.skipped(root, file) {
+			filtered += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to size %s: %w", s.Path, err)
+	}
+	return total, filtered, nil
+}
+
+// skipped returns whether the file, or a folder it's in, is left out.
+func (s Source) skipped(root, file string) bool {
+	rel, err := filepath.Rel(root, file)
+	if err != nil || rel == "." {
+		return false
+	}
+	return s.skippedName(filepath.ToSlash(rel))
+}
+
+// skippedName returns whether the file at the slash separated path relative to Path, or a folder it's in, is
+// left out.
+func (s Source) skippedName(rel string) bool {
+	if s.Skip == nil {
+		return false
+	}
+
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		if s.Skip(strings.Join(parts[:i+1], "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteTar writes the source to w as a tar archive, with its entries in Name.
+func (s Source) WriteTar(w io.Writer) error {
+	return writeTar(w, []Source{s}, nil)
+}
+
+// writeTar writes the sources to a single archive, adding the size of the files written so far to copied.
+func writeTar(w io.Writer, sources []Source, copied *atomic.Int64) error {
+	tw := tar.NewWriter(w)
+	for _, source := range sources {
+		if err := source.writeEntries(tw, copied); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// writeEntries writes the files of the source to the archive.
+func (s Source) writeEntries(tw *tar.Writer, copied *atomic.Int64) error {
+	if s.Archive != "" {
+		return s.writeArchiveEntries(tw, copied)
+	}
+
+	root := filepath.Clean(s.Path)
+	prefix := s.Name
+	if prefix == "" {
+		prefix = filepath.Base(root)
+	}
+	if prefix == "." || prefix == string(filepath.Separator) {
+		prefix = ""
+	}
+
+	err := filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." && s.Skip != nil && s.Skip(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		name := path.Join(prefix, rel)
+		if name == "." {
+			return nil
+		}
+
+		return writeTarEntry(tw, file, name, d, copied)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", s.Path, err)
+	}
+
+	return nil
+}
+
+// writeTarEntry writes a file, folder or symlink to the archive. Other files, eg: sockets, are left out.
+func writeTarEntry(tw *tar.Writer, file, name string, d fs.DirEntry, copied *atomic.Int64) error {
+	info, err := d.Info()
+	if err != nil {
+		return err
+	}
+
+	link := ""
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		if link, err = os.Readlink(file); err != nil {
+			return err
+		}
+	case !info.Mode().IsRegular() && !info.IsDir():
+		return nil
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if copied != nil {
+		r = &countingReader{r: f, n: copied}
+	}
+	_, err = io.Copy(tw, r)
+	return err
+}
+
+// CopyProgress is how far a copy is.
+type CopyProgress struct {
+	Compression CopyCompression
+	// Total is the size of the files to copy.
+	Total int64
+	// Copied is the size of the files archived so far.
+	Copied int64
+	// Sent is the size of the compressed archive sent so far.
+	Sent    int64
+	Elapsed time.Duration
+	// Done is set on the last progress of a successful copy.
+	Done bool
+}
+
+// Rate returns how many bytes of the files are copied per second.
+func (p CopyProgress) Rate() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Copied) / p.Elapsed.Seconds()
+}
+
+// ETA returns how long the rest of the copy should take at the current rate, or zero when it's unknown.
+func (p CopyProgress) ETA() time.Duration {
+	rate := p.Rate()
+	if rate == 0 || p.Copied >= p.Total {
+		return 0
+	}
+	return time.Duration(float64(p.Total-p.Copied) / rate * float64(time.Second))
+}
+
+// CopyParams represents the parameters to copy local files into a container of a running pod.
+type CopyParams struct {
+	// Sources are sent together, in a single archive.
+	Sources       []Source
+	Namespace     string
+	PodName       string
+	ContainerName string
+	// ContainerPath is the folder the copy lands in.
+	ContainerPath string
+	// Compression is how the archive is compressed. Defaults to auto.
+	Compression CopyCompression
+	// Progress, when set, is called with the progress of the copy a few times per second.
+	Progress func(CopyProgress)
+}
+
+// compression returns the compression of the copy, probing the container with probe when it's auto.
+func (p CopyParams) compression(probe func() (string, error)) (CopyCompression, error) {
+	if p.Compression != "" && p.Compression != CopyCompressionAuto {
+		return p.Compression, nil
+	}
+
+	output, err := probe()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the compressions the container supports: %w", err)
+	}
+	return parseCompression(output)
+}
+
+// Copier copies local files into a container of a running pod, eg: the init container waiting for the code.
+type Copier interface {
+	Copy(ctx context.Context, params CopyParams) error
+}
+
+// NewCopierParams represents the parameters to create a copier.
+type NewCopierParams struct {
+	Transport CopyTransport
+	Config    *rest.Config
+	// KubectlFlags are passed to kubectl, as it can't be given the config.
+	KubectlFlags []string
+}
+
+// NewCopier returns the copier of the given transport.
+func NewCopier(params NewCopierParams) (Copier, error) {
+	switch params.Transport {
+	case "", CopyTransportAuto, CopyTransportWebSocket, CopyTransportSPDY:
+		return ExecCopier{Config: params.Config, Transport: params.Transport}, nil
+	case CopyTransportKubectl:
+		return KubectlCopier{Flags: params.KubectlFlags}, nil
+	}
+	return nil, fmt.Errorf("unknown copy transport %q", params.Transport)
+}
+
+// ExecCopier copies by streaming a tar archive into 'tar -x' run in the container with the exec API, so it only
+// needs tar in the container.
+type ExecCopier struct {
+	Config *rest.Config
+	// Transport is the exec protocol. Defaults to auto.
+	Transport CopyTransport
+}
+
+// Copy copies the sources into the container.
+func (c ExecCopier) Copy(ctx context.Context, params CopyParams) error {
+	logger := logging.FromContext(ctx).With("podName", params.PodName, "namespace", params.Namespace)
+
+	client, err := corev1client.NewForConfig(c.Config)
+	if err != nil {
+		return fmt.Errorf("failed to create k8s client: %w", err)
+	}
+
+	transports := []CopyTransport{c.Transport}
+	if c.Transport == "" || c.Transport == CopyTransportAuto {
+		transports = []CopyTransport{CopyTransportWebSocket, CopyTransportSPDY}
+	}
+
+	// run runs the command in the container, falling back to the next transport while the cluster rejects them,
+	// and keeps the first accepted one for the next commands
+	run := func(command []string, stdin io.Reader) (string, error) {
+		req := client.RESTClient().Post().
+			Namespace(params.Namespace).
+			Resource("pods").
+			Name(params.PodName).
+			SubResource("exec").
+			VersionedParams(&corev1.PodExecOptions{
+				Container: params.ContainerName,
+				Command:   command,
+				Stdin:     stdin != nil,
+				Stdout:    true,
+				Stderr:    true,
+			}, scheme.ParameterCodec)
+
+		for {
+			transport := transports[0]
+			var executor remotecommand.Executor
+			var err error
+			if transport == CopyTransportWebSocket {
+				executor, err = remotecommand.NewWebSocketExecutor(c.Config, "GET", req.URL().String())
+			} else {
+				executor, err = remotecommand.NewSPDYExecutor(c.Config, "POST", req.URL())
+			}
+			if err != nil {
+				return "", fmt.Errorf("failed to create %s executor: %w", transport, err)
+			}
+
+			var output bytes.Buffer
+			err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdin: stdin, Stdout: &output, Stderr: &output})
+			if len(transports) > 1 && rejected(err) {
+				logger.With("transport", transport, "error", err).Warn("The cluster rejected the exec protocol, falling back to " + string(transports[1]))
+				transports = transports[1:]
+				continue
+			}
+			transports = transports[:1]
+			if err != nil && output.Len() > 0 {
+				return "", fmt.Errorf("failed to exec over %s: %w, output: %s", transport, err, output.String())
+			}
+			if err != nil {
+				return "", fmt.Errorf("failed to exec over %s: %w", transport, err)
+			}
+			return output.String(), nil
+		}
+	}
+
+	compression, err := params.compression(func() (string, error) {
+		return run([]string{"sh", "-c", probeCompression}, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.With("compression", compression).Info("Copying to pod...")
+	return streamArchive(params, compression, func(archive io.Reader) error {
+		_, err := run(extractCommand(compression, params.ContainerPath), archive)
+		return err
+	})
+}
+
+// streamArchive streams the compressed archive of the sources to send, reporting the progress of the copy.
+func streamArchive(params CopyParams, compression CopyCompression, send func(archive io.Reader) error) error {
+	var total int64
+	if params.Progress != nil {
+		for _, source := range params.Sources {
+			_, filtered, err := source.Size()
+			if err != nil {
+				return err
+			}
+			total += filtered
+		}
+	}
+
+	var copied, sent atomic.Int64
+	start := time.Now()
+	progress := func(done bool) CopyProgress {
+		return CopyProgress{
+			Compression: compression,
+			Total:       total,
+			Copied:      copied.Load(),
+			Sent:        sent.Load(),
+			Elapsed:     time.Since(start),
+			Done:        done,
+		}
+	}
+
+	stop := make(chan struct{})
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		if params.Progress == nil {
+			return
+		}
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				params.Progress(progress(false))
+			}
+		}
+	}()
+
+	r, w := io.Pipe()
+	archived := make(chan error, 1)
+	go func() {
+		err := writeArchive(&countingWriter{w: w, n: &sent}, params.Sources, compression, &copied)
+		w.CloseWithError(err)
+		archived <- err
+	}()
+
+	err := send(r)
+	// unblocks the archive when the container stopped reading it
+	r.CloseWithError(io.ErrClosedPipe)
+	archiveErr := <-archived
+	close(stop)
+	<-reported
+
+	if err != nil {
+		return err
+	}
+	if archiveErr != nil && !errors.Is(archiveErr, io.ErrClosedPipe) {
+		return archiveErr
+	}
+	if params.Progress != nil {
+		params.Progress(progress(true))
+	}
+	return nil
+}
+
+// writeArchive writes the archive of the sources compressed with the given compression.
+func writeArchive(w io.Writer, sources []Source, compression CopyCompression, copied *atomic.Int64) error {
+	var compressor io.WriteCloser
+	switch compression {
+	case CopyCompressionGzip:
+		compressor = gzip.NewWriter(w)
+	case CopyCompressionZstd:
+		encoder, err := zstd.NewWriter(w)
+		if err != nil {
+			return fmt.Errorf("failed to create zstd encoder: %w", err)
+		}
+		compressor = encoder
+	default:
+		return writeTar(w, sources, copied)
+	}
+
+	if err := writeTar(compressor, sources, copied); err != nil {
+		compressor.Close()
+		return err
+	}
+	return compressor.Close()
+}
+
+// rejected returns whether the error comes from the cluster or a proxy not accepting the exec protocol.
+func rejected(err error) bool {
+	return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+}
+
+// KubectlCopier copies with 'kubectl cp', which must be on PATH. A copy of several sources, of an archive, leaving
+// files out, compressed or reporting its progress is streamed to 'tar -x' with 'kubectl exec' instead.
+type KubectlCopier struct {
+	// Flags are extra flags passed to kubectl. eg: '--context'
+	Flags []string
+}
+
+// Copy copies the sources into the container.
+func (c KubectlCopier) Copy(ctx context.Context, params CopyParams) error {
+	logger := logging.FromContext(ctx).With("podName", params.PodName, "namespace", params.Namespace)
+
+	if len(params.Sources) == 1 && params.Sources[0].Name == "" && params.Sources[0].Skip == nil &&
+		params.Sources[0].Archive == "" && params.Compression == CopyCompressionNone && params.Progress == nil {
+		logger.Info("Copying to pod...")
+		args := []string{"cp", params.Sources[0].Path, fmt.Sprintf("%s:%s", params.PodName, params.ContainerPath), "-c", params.ContainerName, "-n", params.Namespace}
+		_, err := c.output(ctx, nil, append(args, c.Flags...)...)
+		return err
+	}
+
+	exec := func(stdin io.Reader, command ...string) (string, error) {
+		args := []string{"exec", params.PodName, "-c", params.ContainerName, "-n", params.Namespace}
+		if stdin != nil {
+			args = append(args, "-i")
+		}
+		args = append(append(args, c.Flags...), "--")
+		return c.output(ctx, stdin, append(args, command...)...)
+	}
+
+	compression, err := params.compression(func() (string, error) {
+		return exec(nil, "sh", "-c", probeCompression)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.With("compression", compression).Info("Copying to pod...")
+	return streamArchive(params, compression, func(archive io.Reader) error {
+		_, err := exec(archive, extractCommand(compression, params.ContainerPath)...)
+		return err
+	})
+}
+
+// output runs kubectl with the given args and returns its output.
+func (c KubectlCopier) output(ctx context.Context, stdin io.Reader, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	cmd.Stdin = stdin
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("error copying folder: %s, output: %s", err, string(output))
+	}
+
+	return string(output), nil
+}
+
+// MemoryCopier keeps the copied files in memory instead of sending them to a pod, eg: in tests. The archive is
+// compressed and decompressed like in a container supporting every compression.
+type MemoryCopier struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+// Copy reads the archive of the sources into memory.
+func (c *MemoryCopier) Copy(_ context.Context, params CopyParams) error {
+	compression, err := params.compression(func() (string, error) {
+		return string(CopyCompressionZstd), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var archive bytes.Buffer
+	err = streamArchive(params, compression, func(r io.Reader) error {
+		_, err := io.Copy(&archive, r)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = &archive
+	switch compression {
+	case CopyCompressionGzip:
+		if r, err = gzip.NewReader(r); err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+	case CopyCompressionZstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		defer decoder.Close()
+		r = decoder
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.files == nil {
+		c.files = map[string][]byte{}
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		c.files[path.Join(params.ContainerPath, header.Name)] = content
+	}
+}
+
+// Files returns the paths of the copied files in the container, sorted. eg: '/app/foobar/index.js'
+func (c *MemoryCopier) Files() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files := make([]string, 0, len(c.files))
+	for file := range c.files {
+		files = append(files, file)
+	}
+	slices.Sort(files)
+	return files
+}
+
+// File returns the content of a copied file, or false when it hasn't been copied.
+func (c *MemoryCopier) File(file string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	content, ok := c.files[file]
+	return content, ok
+}
+
+// countingReader adds the bytes read to n.
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
+
+// countingWriter adds the bytes written to n.
+type countingWriter struct {
+	w io.Writer
+	n *atomic.Int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n.Add(int64(n))
+	return n, err
+}
+
+// FormatBytes formats a size in bytes with a binary unit. eg: '1.5 MiB'
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
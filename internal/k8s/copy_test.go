@@ -0,0 +1,224 @@
+package k8s_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"k8s.io/client-go/rest"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMemoryCopier(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "foobar")
+	writeFiles(t, dir, map[string]string{
+		"index.js":          "console.log('hi')",
+		"lib/util.js":       "module.exports = {}",
+		".git/HEAD":         "ref: refs/heads/main",
+		"node_modules/a.js": "",
+	})
+
+	tests := []struct {
+		name   string
+		source k8s.Source
+		want   []string
+	}{
+		{
+			name:   "folder",
+			source: k8s.Source{Path: dir},
+			want:   []string{"/app/foobar/.git/HEAD", "/app/foobar/index.js", "/app/foobar/lib/util.js", "/app/foobar/node_modules/a.js"},
+		},
+		{
+			name: "skipped files",
+			source: k8s.Source{Path: dir, Skip: func(name string) bool {
+				return name == ".git" || name == "node_modules"
+			}},
+			want: []string{"/app/foobar/index.js", "/app/foobar/lib/util.js"},
+		},
+		{
+			name:   "file",
+			source: k8s.Source{Path: filepath.Join(dir, "index.js")},
+			want:   []string{"/app/index.js"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			copier := &k8s.MemoryCopier{}
+			err := copier.Copy(context.Background(), k8s.CopyParams{Sources: []k8s.Source{tt.source}, ContainerPath: "/app"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if files := copier.Files(); !slices.Equal(files, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, files)
+			}
+		})
+	}
+
+	copier := &k8s.MemoryCopier{}
+	if err := copier.Copy(context.Background(), k8s.CopyParams{Sources: []k8s.Source{{Path: dir}}, ContainerPath: "/app"}); err != nil {
+		t.Fatal(err)
+	}
+	if content, ok := copier.File("/app/foobar/index.js"); !ok || string(content) != "console.log('hi')" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
+
+func TestMemoryCopier_Compression(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "foobar")
+	writeFiles(t, dir, map[string]string{
+		"index.js":   strings.Repeat("console.log('hi')\n", 1000),
+		"data/a.txt": strings.Repeat("a", 10000),
+	})
+
+	tests := []struct {
+		compression k8s.CopyCompression
+		want        k8s.CopyCompression
+	}{
+		{compression: "", want: k8s.CopyCompressionZstd},
+		{compression: k8s.CopyCompressionNone, want: k8s.CopyCompressionNone},
+		{compression: k8s.CopyCompressionGzip, want: k8s.CopyCompressionGzip},
+		{compression: k8s.CopyCompressionZstd, want: k8s.CopyCompressionZstd},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.want), func(t *testing.T) {
+			var last k8s.CopyProgress
+			copier := &k8s.MemoryCopier{}
+			err := copier.Copy(context.Background(), k8s.CopyParams{
+				Sources:       []k8s.Source{{Path: dir}},
+				ContainerPath: "/app",
+				Compression:   tt.compression,
+				Progress:      func(p k8s.CopyProgress) { last = p },
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if content, ok := copier.File("/app/foobar/data/a.txt"); !ok || len(content) != 10000 {
+				t.Errorf("expected the file to be copied, got %d bytes", len(content))
+			}
+			if !last.Done || last.Compression != tt.want {
+				t.Errorf("expected a last progress with %s, got %+v", tt.want, last)
+			}
+			if last.Total != 28000 || last.Copied != last.Total {
+				t.Errorf("expected 28000 bytes to be copied, got %+v", last)
+			}
+			if tt.want != k8s.CopyCompressionNone && last.Sent >= last.Total {
+				t.Errorf("expected the archive to be compressed, got %+v", last)
+			}
+		})
+	}
+}
+
+func TestSource_Size(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"index.js":            "12345",
+		".git/HEAD":           "123",
+		"node_modules/a/b.js": "12",
+	})
+
+	source := k8s.Source{Path: dir, Skip: func(name string) bool { return name == ".git" || name == "node_modules" }}
+	total, filtered, err := source.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 10 || filtered != 5 {
+		t.Errorf("expected 10 and 5 bytes, got %d and %d", total, filtered)
+	}
+}
+
+func TestCopyProgress(t *testing.T) {
+	p := k8s.CopyProgress{Total: 300, Copied: 100, Elapsed: time.Second}
+	if p.Rate() != 100 {
+		t.Errorf("expected a rate of 100, got %v", p.Rate())
+	}
+	if p.ETA() != 2*time.Second {
+		t.Errorf("expected an ETA of 2s, got %v", p.ETA())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 5 << 20: "5.0 MiB"} {
+		if got := k8s.FormatBytes(n); got != want {
+			t.Errorf("expected %q for %d, got %q", want, n, got)
+		}
+	}
+}
+
+func TestNewCopier(t *testing.T) {
+	for _, transport := range k8s.CopyTransports {
+		if _, err := k8s.NewCopier(k8s.NewCopierParams{Transport: transport, Config: &rest.Config{}}); err != nil {
+			t.Errorf("expected no error for %s, got %v", transport, err)
+		}
+	}
+	if _, err := k8s.NewCopier(k8s.NewCopierParams{Transport: "ftp", Config: &rest.Config{}}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestExecCopier_FallsBack(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"index.js": ""})
+
+	// the cluster rejects every upgrade, recording the protocols asked for
+	var mu sync.Mutex
+	upgrades := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		upgrades = append(upgrades, r.Header.Get("Upgrade"))
+		mu.Unlock()
+		http.Error(w, "upgrade not supported", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		transport k8s.CopyTransport
+		want      []string
+	}{
+		{transport: k8s.CopyTransportAuto, want: []string{"websocket", "SPDY/3.1"}},
+		{transport: k8s.CopyTransportWebSocket, want: []string{"websocket"}},
+		{transport: k8s.CopyTransportSPDY, want: []string{"SPDY/3.1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.transport), func(t *testing.T) {
+			upgrades = []string{}
+			copier := k8s.ExecCopier{Config: &rest.Config{Host: server.URL}, Transport: tt.transport}
+			err := copier.Copy(context.Background(), k8s.CopyParams{
+				Sources:       []k8s.Source{{Path: dir}},
+				Namespace:     "default",
+				PodName:       "foobar",
+				ContainerName: "wait-to-copy-app",
+				ContainerPath: "/app",
+			})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !slices.Equal(upgrades, tt.want) {
+				t.Errorf("expected upgrades to %v, got %v", tt.want, upgrades)
+			}
+		})
+	}
+}
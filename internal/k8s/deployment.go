@@ -1,13 +1,13 @@
 package k8s
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
-	"log/slog"
-
-	"cmp"
+	"github.com/lucasvmiguel/k8run/internal/logging"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -18,29 +18,89 @@ import (
 
 // CreateOrUpdateDeploymentParams represents the parameters to create or update a deployment.
 type CreateOrUpdateDeploymentParams struct {
-	Name                 string
-	Namespace            string
-	Entrypoint           []string
-	ContainerPort        int32
-	Image                string
-	CopyTo               string
-	Replicas             int32
-	PVCName              string
-	InitContainerName    string
+	Name          string
+	Namespace     string
+	Entrypoint    []string
+	ContainerPort int32
+	Image         string
+	// CopyTo is where the PVC is mounted, in the init container and, without CopyMounts, in the app container.
+	CopyTo string
+	// CopyMounts mount parts of the PVC in the app container instead of the whole PVC at CopyTo.
+	CopyMounts        []CopyMount
+	WorkingDir        string
+	Replicas          int32
+	PVCName           string
+	InitContainerName string
+	// InitContainerImage is the image of the init container. Defaults to HelperImage.
+	InitContainerImage string
+	// InitContainerSecret is the secret mounted read-only at InitContainerSecretPath in the init container.
+	InitContainerSecret  string
 	InitContainerCommand []string
 	ReleaseIdentifier    string
+	Env                  map[string]string
+	Resources            corev1.ResourceRequirements
+	// SetupCommand is run by an init container with the image of the app once the content is copied, eg: to install
+	// dependencies.
+	SetupCommand []string
+	// SetupMounts mount parts of the PVC in the setup container only, besides the mounts of the app container.
+	SetupMounts []CopyMount
+	// CacheMounts mount parts of the PVC kept across releases in the setup and app containers. eg: node_modules
+	CacheMounts []CopyMount
+	// Annotations are set on the resource, eg: its expiry.
+	Annotations map[string]string
+	// ImagePullSecret is the docker config secret the image is pulled with, eg: an image built in the cluster.
+	ImagePullSecret string
+	// NodeArch schedules the pods on the nodes of the architecture, by their kubernetes.io/arch label. eg: 'arm64'
+	NodeArch string
+	// App groups deployments sharing the same PVC. Their pods are scheduled on the same node, so they can all mount it.
+	App string
 }
 
-// CreateOrUpdateDeployment creates or updates a deployment in the given namespace.
-func CreateOrUpdateDeployment(ctx context.Context, clientset kubernetes.Interface, params CreateOrUpdateDeploymentParams) error {
-	deploymentsClient := clientset.AppsV1().Deployments(params.Namespace)
+// CopyMount mounts a part of the PVC in the app container.
+type CopyMount struct {
+	// Path is where the part is mounted. eg: '/etc/myapp/config.yaml'
+	Path string
+	// SubPath is the file or folder of the PVC mounted. Empty mounts the whole PVC.
+	SubPath  string
+	ReadOnly bool
+}
+
+// appVolumeMounts returns the mounts of the PVC in the app container.
+func appVolumeMounts(copyTo string, mounts []CopyMount) []corev1.VolumeMount {
+	if len(mounts) == 0 {
+		return []corev1.VolumeMount{{Name: "app", MountPath: copyTo}}
+	}
+
+	return volumeMounts(mounts)
+}
+
+// volumeMounts returns the mounts of the given parts of the PVC.
+func volumeMounts(mounts []CopyMount) []corev1.VolumeMount {
+	volumeMounts := make([]corev1.VolumeMount, 0, len(mounts))
+	for _, mount := range mounts {
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      "app",
+			MountPath: mount.Path,
+			SubPath:   mount.SubPath,
+			ReadOnly:  mount.ReadOnly,
+		})
+	}
+	return volumeMounts
+}
+
+// BuildDeployment builds the deployment object described by the given parameters without sending it to the cluster.
+func BuildDeployment(params CreateOrUpdateDeploymentParams) *appsv1.Deployment {
 	replicas := cmp.Or(params.Replicas, int32(1))
-	envVarDeployTimestamp := "K8RUN_DEPLOY_TIMESTAMP"
 
 	deployment := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      params.Name,
-			Namespace: params.Namespace,
+			Name:        params.Name,
+			Namespace:   params.Namespace,
+			Annotations: params.Annotations,
 			Labels: map[string]string{
 				LabelNameCreatedBy:         LabelValueCreatedBy,
 				LabelNameReleaseIdentifier: params.ReleaseIdentifier,
@@ -65,7 +125,7 @@ func CreateOrUpdateDeployment(ctx context.Context, clientset kubernetes.Interfac
 					InitContainers: []corev1.Container{
 						{
 							Name:    params.InitContainerName,
-							Image:   "busybox",
+							Image:   cmp.Or(params.InitContainerImage, HelperImage),
 							Command: params.InitContainerCommand,
 							VolumeMounts: []corev1.VolumeMount{
 								{
@@ -75,7 +135,7 @@ func CreateOrUpdateDeployment(ctx context.Context, clientset kubernetes.Interfac
 							},
 							Env: []corev1.EnvVar{
 								{
-									Name:  envVarDeployTimestamp,
+									Name:  EnvVarDeployTimestamp,
 									Value: time.Now().Format(time.RFC3339),
 								},
 							},
@@ -86,25 +146,21 @@ func CreateOrUpdateDeployment(ctx context.Context, clientset kubernetes.Interfac
 							Name:       params.Name,
 							Image:      params.Image,
 							Args:       params.Entrypoint,
-							WorkingDir: params.CopyTo,
+							WorkingDir: cmp.Or(params.WorkingDir, params.CopyTo),
 							Ports: []corev1.ContainerPort{
 								{
 									ContainerPort: params.ContainerPort,
 									Protocol:      corev1.ProtocolTCP,
 								},
 							},
-							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name:      "app",
-									MountPath: params.CopyTo,
-								},
-							},
-							Env: []corev1.EnvVar{
+							VolumeMounts: append(appVolumeMounts(params.CopyTo, params.CopyMounts), volumeMounts(params.CacheMounts)...),
+							Env: append([]corev1.EnvVar{
 								{
-									Name:  envVarDeployTimestamp,
+									Name:  EnvVarDeployTimestamp,
 									Value: time.Now().Format(time.RFC3339),
 								},
-							},
+							}, envVars(params.Env)...),
+							Resources: params.Resources,
 						},
 					},
 					Volumes: []corev1.Volume{
@@ -122,6 +178,79 @@ func CreateOrUpdateDeployment(ctx context.Context, clientset kubernetes.Interfac
 		},
 	}
 
+	podSpec := &deployment.Spec.Template.Spec
+	if params.ContainerPort == 0 {
+		podSpec.Containers[0].Ports = nil
+	}
+
+	// without an init container, the content is expected to be copied into the PVC by someone else
+	if params.InitContainerName == "" {
+		podSpec.InitContainers = nil
+	}
+
+	if params.InitContainerSecret != "" && params.InitContainerName != "" {
+		// readable by the owner only, as ssh requires of private keys
+		mode := int32(0o400)
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: "init-secret",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  params.InitContainerSecret,
+					DefaultMode: &mode,
+				},
+			},
+		})
+		podSpec.InitContainers[0].VolumeMounts = append(podSpec.InitContainers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      "init-secret",
+			MountPath: InitContainerSecretPath,
+			ReadOnly:  true,
+		})
+	}
+
+	// the setup runs in the environment of the app, once the content is copied
+	if len(params.SetupCommand) > 0 && params.InitContainerName != "" {
+		app := podSpec.Containers[0]
+		podSpec.InitContainers = append(podSpec.InitContainers, corev1.Container{
+			Name:         SetupContainerName,
+			Image:        params.Image,
+			Command:      params.SetupCommand,
+			WorkingDir:   app.WorkingDir,
+			VolumeMounts: append(slices.Clone(app.VolumeMounts), volumeMounts(params.SetupMounts)...),
+			Env:          app.Env,
+			Resources:    params.Resources,
+		})
+	}
+
+	// without a PVC there is nothing to copy, so the image runs as it is
+	if params.PVCName == "" {
+		podSpec.InitContainers = nil
+		podSpec.Volumes = nil
+		podSpec.Containers[0].VolumeMounts = nil
+		podSpec.Containers[0].WorkingDir = params.WorkingDir
+	}
+
+	if params.ImagePullSecret != "" {
+		podSpec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: params.ImagePullSecret}}
+	}
+
+	if params.NodeArch != "" {
+		podSpec.NodeSelector = map[string]string{corev1.LabelArchStable: params.NodeArch}
+	}
+
+	if params.App != "" {
+		deployment.Labels[LabelNameApp] = params.App
+		deployment.Spec.Template.Labels[LabelNameApp] = params.App
+		podSpec.Affinity = colocate(params.App)
+	}
+
+	return deployment
+}
+
+// CreateOrUpdateDeployment creates or updates a deployment in the given namespace.
+func CreateOrUpdateDeployment(ctx context.Context, clientset kubernetes.Interface, params CreateOrUpdateDeploymentParams) error {
+	deploymentsClient := clientset.AppsV1().Deployments(params.Namespace)
+	deployment := BuildDeployment(params)
+
 	// Try to create or update the deployment
 	existentDeployment, err := deploymentsClient.Get(ctx, params.Name, metav1.GetOptions{})
 	if err != nil {
@@ -129,17 +258,80 @@ func CreateOrUpdateDeployment(ctx context.Context, clientset kubernetes.Interfac
 		if err != nil {
 			return fmt.Errorf("failed to create deployment: %w", err)
 		}
-		slog.With("name", params.Name, "namespace", params.Namespace).Info("Deployment created")
+		logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Deployment created")
 	} else {
 		if existentDeployment.Labels[LabelNameCreatedBy] != LabelValueCreatedBy {
-			return fmt.Errorf("deployment already exists but it has not been created by k8run")
+			return fmt.Errorf("deployment %w", ErrNotCreatedByK8run)
 		}
 
 		_, err = deploymentsClient.Update(ctx, deployment, metav1.UpdateOptions{})
 		if err != nil {
 			return fmt.Errorf("failed to update deployment: %w", err)
 		}
-		slog.With("name", params.Name, "namespace", params.Namespace).Info("Deployment updated")
+		logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Deployment updated")
+	}
+
+	return nil
+}
+
+// AnnotateDeploymentParams represents the parameters to annotate a deployment.
+type AnnotateDeploymentParams struct {
+	Name        string
+	Namespace   string
+	Annotations map[string]string
+}
+
+// AnnotateDeployment adds annotations to a deployment, without changing its pods.
+func AnnotateDeployment(ctx context.Context, clientset kubernetes.Interface, params AnnotateDeploymentParams) error {
+	deployment, err := GetDeployment(ctx, clientset, GetParams{Name: params.Name, Namespace: params.Namespace})
+	if err != nil {
+		return err
+	}
+
+	if deployment.Annotations == nil {
+		deployment.Annotations = map[string]string{}
+	}
+	for name, value := range params.Annotations {
+		deployment.Annotations[name] = value
+	}
+	_, err = clientset.AppsV1().Deployments(params.Namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to annotate deployment: %w", err)
+	}
+
+	return nil
+}
+
+// SetInitContainerCommandParams represents the parameters to set the command of an init container of a deployment.
+type SetInitContainerCommandParams struct {
+	Name          string
+	Namespace     string
+	ContainerName string
+	Command       []string
+}
+
+// SetInitContainerCommand sets the command of an init container of a deployment. Its pods are replaced when the
+// command changes.
+func SetInitContainerCommand(ctx context.Context, clientset kubernetes.Interface, params SetInitContainerCommandParams) error {
+	deployment, err := GetDeployment(ctx, clientset, GetParams{Name: params.Name, Namespace: params.Namespace})
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i, container := range deployment.Spec.Template.Spec.InitContainers {
+		if container.Name == params.ContainerName {
+			deployment.Spec.Template.Spec.InitContainers[i].Command = params.Command
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("init container %s not found in deployment %s", params.ContainerName, params.Name)
+	}
+
+	_, err = clientset.AppsV1().Deployments(params.Namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update deployment: %w", err)
 	}
 
 	return nil
@@ -164,7 +356,7 @@ func DeleteDeployment(ctx context.Context, clientset kubernetes.Interface, param
 	}
 
 	if existentDeployment.Labels[LabelNameCreatedBy] != LabelValueCreatedBy {
-		return fmt.Errorf("deployment already exists but it has not been created by k8run")
+		return fmt.Errorf("deployment %w", ErrNotCreatedByK8run)
 	}
 
 	err = deploymentsClient.Delete(ctx, params.Name, metav1.DeleteOptions{})
@@ -172,7 +364,7 @@ func DeleteDeployment(ctx context.Context, clientset kubernetes.Interface, param
 		return fmt.Errorf("failed to delete deployment: %w", err)
 	}
 
-	slog.With("name", params.Name, "namespace", params.Namespace).Info("Deployment marked for deletion")
+	logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Deployment marked for deletion")
 	return nil
 }
 
@@ -191,6 +383,16 @@ func GetDeployment(ctx context.Context, clientset kubernetes.Interface, params G
 	return existentDeployment, nil
 }
 
+// ListDeployments lists the deployments created by k8run matching the given label selector in the given namespace.
+func ListDeployments(ctx context.Context, clientset kubernetes.Interface, params ListParams) ([]appsv1.Deployment, error) {
+	list, err := clientset.AppsV1().Deployments(params.Namespace).List(ctx, metav1.ListOptions{LabelSelector: params.selector()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	return list.Items, nil
+}
+
 // WaitForDeploymentToBeReadyParams represents the parameters to wait for a deployment to be ready.
 type WaitForDeploymentToBeReadyParams struct {
 	Name              string
@@ -210,16 +412,81 @@ func WaitForDeploymentToBeReady(ctx context.Context, clientset kubernetes.Interf
 		}
 
 		if deployment.Labels[LabelNameCreatedBy] != LabelValueCreatedBy {
-			return fmt.Errorf("deployment already exists but it has not been created by k8run")
+			return fmt.Errorf("deployment %w", ErrNotCreatedByK8run)
 		}
 
 		if deployment.Labels[LabelNameReleaseIdentifier] == params.ReleaseIdentifier && deployment.Status.ReadyReplicas == *deployment.Spec.Replicas {
 			break
 		}
 
-		slog.With("name", params.Name, "namespace", params.Namespace).Info("Waiting for deployment to be ready...")
-		time.Sleep(2 * time.Second)
+		pods, err := ListPods(ctx, clientset, ListParams{
+			Namespace:     params.Namespace,
+			LabelSelector: fmt.Sprintf("%s=%s", LabelNameReleaseIdentifier, params.ReleaseIdentifier),
+		})
+		if err != nil {
+			return err
+		}
+		for _, pod := range pods {
+			if reason := crashed(pod); reason != "" {
+				return fmt.Errorf("%w: pod %s %s", ErrAppCrashed, pod.Name, reason)
+			}
+		}
+
+		logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Waiting for deployment to be ready...")
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context cancelled while waiting for deployment to be ready")
+		case <-time.After(2 * time.Second):
+		}
 	}
 
 	return nil
 }
+
+// crashed returns why a container of the pod is crashing, or an empty string when none is.
+func crashed(pod corev1.Pod) string {
+	statuses := append(slices.Clone(pod.Status.InitContainerStatuses), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff" {
+			if terminated := status.LastTerminationState.Terminated; terminated != nil {
+				return fmt.Sprintf("container %s keeps exiting with code %d (%s)", status.Name, terminated.ExitCode, terminated.Reason)
+			}
+			return fmt.Sprintf("container %s keeps exiting", status.Name)
+		}
+		if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+			return fmt.Sprintf("container %s exited with code %d (%s)", status.Name, terminated.ExitCode, terminated.Reason)
+		}
+	}
+	return ""
+}
+
+// colocate returns an affinity that schedules every pod of the given app on the same node.
+func colocate(app string) *corev1.Affinity {
+	return &corev1.Affinity{
+		PodAffinity: &corev1.PodAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+				{
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{LabelNameApp: app},
+					},
+					TopologyKey: "kubernetes.io/hostname",
+				},
+			},
+		},
+	}
+}
+
+// envVars converts a map of env vars into a list sorted by name, so the pod template is stable across releases.
+func envVars(env map[string]string) []corev1.EnvVar {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	vars := make([]corev1.EnvVar, 0, len(env))
+	for _, name := range names {
+		vars = append(vars, corev1.EnvVar{Name: name, Value: env[name]})
+	}
+	return vars
+}
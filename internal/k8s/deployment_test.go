@@ -2,10 +2,15 @@ package k8s_test
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/lucasvmiguel/k8run/internal/k8s"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 )
@@ -43,6 +48,136 @@ func TestCreateOrUpdateDeployment(t *testing.T) {
 	}
 }
 
+func TestBuildDeployment_EnvAndResources(t *testing.T) {
+	deployment := k8s.BuildDeployment(k8s.CreateOrUpdateDeploymentParams{
+		Name:  "test-deployment",
+		Image: "test-image",
+		Env:   map[string]string{"B": "2", "A": "1"},
+		Resources: corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+		},
+	})
+
+	container := deployment.Spec.Template.Spec.Containers[0]
+	names := []string{}
+	for _, env := range container.Env {
+		names = append(names, env.Name)
+	}
+	if !slices.Equal(names, []string{k8s.EnvVarDeployTimestamp, "A", "B"}) {
+		t.Errorf("expected env vars sorted after the deploy timestamp, got %v", names)
+	}
+
+	if container.Resources.Limits.Cpu().String() != "500m" {
+		t.Errorf("expected cpu limit to be 500m, got %s", container.Resources.Limits.Cpu())
+	}
+}
+
+func TestBuildDeployment_SharedPVC(t *testing.T) {
+	deployment := k8s.BuildDeployment(k8s.CreateOrUpdateDeploymentParams{
+		Name:    "test-worker",
+		Image:   "test-image",
+		CopyTo:  "/app",
+		PVCName: "test-pvc",
+		App:     "test",
+	})
+
+	podSpec := deployment.Spec.Template.Spec
+	if podSpec.InitContainers != nil {
+		t.Errorf("expected no init container, got %v", podSpec.InitContainers)
+	}
+	if podSpec.Volumes[0].PersistentVolumeClaim.ClaimName != "test-pvc" {
+		t.Errorf("expected the PVC to be mounted, got %v", podSpec.Volumes)
+	}
+	if deployment.Spec.Template.Labels[k8s.LabelNameApp] != "test" {
+		t.Errorf("expected the app label, got %v", deployment.Spec.Template.Labels)
+	}
+	if podSpec.Affinity == nil || podSpec.Affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution[0].LabelSelector.MatchLabels[k8s.LabelNameApp] != "test" {
+		t.Errorf("expected the pods to be colocated with the app, got %v", podSpec.Affinity)
+	}
+}
+
+func TestBuildDeployment_CopyMounts(t *testing.T) {
+	params := k8s.CreateOrUpdateDeploymentParams{
+		Name:    "test-deployment",
+		Image:   "test-image",
+		CopyTo:  "/app",
+		PVCName: "test-pvc",
+	}
+
+	mounts := k8s.BuildDeployment(params).Spec.Template.Spec.Containers[0].VolumeMounts
+	if len(mounts) != 1 || mounts[0].MountPath != "/app" || mounts[0].SubPath != "" {
+		t.Errorf("expected the whole PVC at /app, got %v", mounts)
+	}
+
+	params.CopyMounts = []k8s.CopyMount{
+		{Path: "/app", SubPath: "k8run-copy-0"},
+		{Path: "/etc/myapp/config.yaml", SubPath: "k8run-copy-1", ReadOnly: true},
+	}
+	container := k8s.BuildDeployment(params).Spec.Template.Spec.Containers[0]
+	if len(container.VolumeMounts) != 2 {
+		t.Fatalf("expected 2 mounts, got %v", container.VolumeMounts)
+	}
+	if mount := container.VolumeMounts[1]; mount.MountPath != "/etc/myapp/config.yaml" || mount.SubPath != "k8run-copy-1" || !mount.ReadOnly {
+		t.Errorf("expected the config to be mounted read-only, got %v", mount)
+	}
+	if container.WorkingDir != "/app" {
+		t.Errorf("expected the working dir to be /app, got %s", container.WorkingDir)
+	}
+}
+
+func TestBuildDeployment_InitContainerSecret(t *testing.T) {
+	podSpec := k8s.BuildDeployment(k8s.CreateOrUpdateDeploymentParams{
+		Name:                "test-deployment",
+		Image:               "test-image",
+		CopyTo:              "/app",
+		PVCName:             "test-pvc",
+		InitContainerName:   "clone",
+		InitContainerImage:  k8s.GitImage,
+		InitContainerSecret: "test-deploy-key",
+	}).Spec.Template.Spec
+
+	initContainer := podSpec.InitContainers[0]
+	if initContainer.Image != k8s.GitImage {
+		t.Errorf("expected the init container to run %s, got %s", k8s.GitImage, initContainer.Image)
+	}
+	if len(initContainer.VolumeMounts) != 2 || initContainer.VolumeMounts[1].MountPath != k8s.InitContainerSecretPath || !initContainer.VolumeMounts[1].ReadOnly {
+		t.Errorf("expected the secret to be mounted read-only, got %v", initContainer.VolumeMounts)
+	}
+	if len(podSpec.Volumes) != 2 || podSpec.Volumes[1].Secret.SecretName != "test-deploy-key" {
+		t.Errorf("expected the secret volume, got %v", podSpec.Volumes)
+	}
+	if len(podSpec.Containers[0].VolumeMounts) != 1 {
+		t.Errorf("expected the secret not to be mounted in the app container, got %v", podSpec.Containers[0].VolumeMounts)
+	}
+}
+
+func TestBuildDeployment_Setup(t *testing.T) {
+	podSpec := k8s.BuildDeployment(k8s.CreateOrUpdateDeploymentParams{
+		Name:              "test-deployment",
+		Image:             "node:22",
+		CopyTo:            "/app",
+		PVCName:           "test-pvc",
+		InitContainerName: "init-container",
+		SetupCommand:      []string{"sh", "-c", "npm ci"},
+		SetupMounts:       []k8s.CopyMount{{Path: "/var/cache/k8run", SubPath: ".k8run-cache"}},
+		CacheMounts:       []k8s.CopyMount{{Path: "/app/node_modules", SubPath: ".k8run-cache/app/node_modules"}},
+	}).Spec.Template.Spec
+
+	if len(podSpec.InitContainers) != 2 {
+		t.Fatalf("expected the setup to run after the init container, got %v", podSpec.InitContainers)
+	}
+	setup := podSpec.InitContainers[1]
+	if setup.Name != k8s.SetupContainerName || setup.Image != "node:22" || setup.WorkingDir != "/app" {
+		t.Errorf("expected the setup to run with the image of the app, got %+v", setup)
+	}
+	if len(setup.VolumeMounts) != 3 || setup.VolumeMounts[2].MountPath != "/var/cache/k8run" {
+		t.Errorf("expected the code, the cache and the setup folder to be mounted, got %v", setup.VolumeMounts)
+	}
+	if mounts := podSpec.Containers[0].VolumeMounts; len(mounts) != 2 || mounts[1].SubPath != ".k8run-cache/app/node_modules" {
+		t.Errorf("expected the cache to be mounted in the app container, got %v", mounts)
+	}
+}
+
 func TestDeleteDeployment(t *testing.T) {
 	clientset := fake.NewSimpleClientset(&appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -127,6 +262,53 @@ func TestWaitForDeploymentToBeReady(t *testing.T) {
 	}
 }
 
+func TestWaitForDeploymentToBeReady_Crashed(t *testing.T) {
+	labels := map[string]string{
+		k8s.LabelNameCreatedBy:         k8s.LabelValueCreatedBy,
+		k8s.LabelNameReleaseIdentifier: "test-release",
+	}
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "default", Labels: labels},
+			Spec:       appsv1.DeploymentSpec{Replicas: int32Ptr(1)},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default", Labels: labels},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:                 "test-deployment",
+					State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+					LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"}},
+				}},
+			},
+		},
+	)
+
+	err := k8s.WaitForDeploymentToBeReady(context.TODO(), clientset, k8s.WaitForDeploymentToBeReadyParams{
+		Name:              "test-deployment",
+		Namespace:         "default",
+		ReleaseIdentifier: "test-release",
+	})
+	if !errors.Is(err, k8s.ErrAppCrashed) {
+		t.Fatalf("expected ErrAppCrashed, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "test-pod container test-deployment keeps exiting with code 1") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
 func int32Ptr(i int32) *int32 {
 	return &i
 }
+
+func TestBuildDeployment_NodeArch(t *testing.T) {
+	params := k8s.CreateOrUpdateDeploymentParams{Name: "test-deployment", Image: "test-image"}
+	if selector := k8s.BuildDeployment(params).Spec.Template.Spec.NodeSelector; selector != nil {
+		t.Errorf("expected no node selector, got %v", selector)
+	}
+
+	params.NodeArch = "arm64"
+	if selector := k8s.BuildDeployment(params).Spec.Template.Spec.NodeSelector; selector["kubernetes.io/arch"] != "arm64" {
+		t.Errorf("expected the pods to be pinned to arm64 nodes, got %v", selector)
+	}
+}
@@ -0,0 +1,232 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/lucasvmiguel/k8run/internal/logging"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GCParams represents the parameters of the CronJob running 'k8run gc' in the cluster.
+type GCParams struct {
+	Name      string
+	Namespace string
+	// Schedule is the cron schedule of the job. eg: '0 * * * *'
+	Schedule string
+	Image    string
+	Command  []string
+	// AllNamespaces lets the job collect apps in every namespace, with a cluster role instead of a role.
+	AllNamespaces bool
+}
+
+// gcRules are the only permissions 'k8run gc' needs: finding, locking and destroying apps.
+var gcRules = []rbacv1.PolicyRule{
+	{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "list", "delete"}},
+	{APIGroups: []string{""}, Resources: []string{"services", "persistentvolumeclaims"}, Verbs: []string{"get", "list", "delete"}},
+	{APIGroups: []string{"networking.k8s.io"}, Resources: []string{"ingresses"}, Verbs: []string{"get", "list", "delete"}},
+	{APIGroups: []string{"batch"}, Resources: []string{"jobs"}, Verbs: []string{"get", "list", "delete", "deletecollection"}},
+	{APIGroups: []string{"coordination.k8s.io"}, Resources: []string{"leases"}, Verbs: []string{"get", "list", "create", "update", "delete"}},
+}
+
+// BuildGC builds the service account, role, role binding and CronJob running 'k8run gc' without sending them to
+// the cluster. With AllNamespaces, the role and binding are cluster wide and may also delete the namespaces k8run created.
+func BuildGC(params GCParams) []runtime.Object {
+	meta := func(namespace string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      params.Name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				LabelNameCreatedBy: LabelValueCreatedBy,
+			},
+		}
+	}
+
+	serviceAccount := &corev1.ServiceAccount{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+		ObjectMeta: meta(params.Namespace),
+	}
+	subjects := []rbacv1.Subject{{Kind: "ServiceAccount", Name: params.Name, Namespace: params.Namespace}}
+
+	var role, binding runtime.Object
+	if params.AllNamespaces {
+		rules := append(slices.Clone(gcRules), rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"get", "list", "delete"}})
+		role = &rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+			ObjectMeta: meta(""),
+			Rules:      rules,
+		}
+		binding = &rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+			ObjectMeta: meta(""),
+			Subjects:   subjects,
+			RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: params.Name},
+		}
+	} else {
+		role = &rbacv1.Role{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+			ObjectMeta: meta(params.Namespace),
+			Rules:      gcRules,
+		}
+		binding = &rbacv1.RoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+			ObjectMeta: meta(params.Namespace),
+			Subjects:   subjects,
+			RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: params.Name},
+		}
+	}
+
+	backoffLimit := int32(0)
+	cronJob := &batchv1.CronJob{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "CronJob"},
+		ObjectMeta: meta(params.Namespace),
+		Spec: batchv1.CronJobSpec{
+			Schedule:          params.Schedule,
+			ConcurrencyPolicy: batchv1.ForbidConcurrent,
+			JobTemplate: batchv1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{
+					BackoffLimit: &backoffLimit,
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: map[string]string{
+								LabelNameCreatedBy: LabelValueCreatedBy,
+							},
+						},
+						Spec: corev1.PodSpec{
+							ServiceAccountName: params.Name,
+							RestartPolicy:      corev1.RestartPolicyNever,
+							Containers: []corev1.Container{
+								{
+									Name:    "gc",
+									Image:   params.Image,
+									Command: params.Command,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return []runtime.Object{serviceAccount, role, binding, cronJob}
+}
+
+// ApplyGC creates or updates the resources running 'k8run gc' in the cluster.
+func ApplyGC(ctx context.Context, clientset kubernetes.Interface, params GCParams) error {
+	for _, obj := range BuildGC(params) {
+		var err error
+		switch o := obj.(type) {
+		case *corev1.ServiceAccount:
+			err = apply(ctx, o, clientset.CoreV1().ServiceAccounts(o.Namespace))
+		case *rbacv1.Role:
+			err = apply(ctx, o, clientset.RbacV1().Roles(o.Namespace))
+		case *rbacv1.RoleBinding:
+			err = apply(ctx, o, clientset.RbacV1().RoleBindings(o.Namespace))
+		case *rbacv1.ClusterRole:
+			err = apply(ctx, o, clientset.RbacV1().ClusterRoles())
+		case *rbacv1.ClusterRoleBinding:
+			err = apply(ctx, o, clientset.RbacV1().ClusterRoleBindings())
+		case *batchv1.CronJob:
+			err = apply(ctx, o, clientset.BatchV1().CronJobs(o.Namespace))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to apply %s: %w", obj.GetObjectKind().GroupVersionKind().Kind, err)
+		}
+	}
+
+	logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("GC CronJob installed")
+	return nil
+}
+
+// DeleteGC deletes the resources running 'k8run gc' in the cluster, ignoring the ones that don't exist.
+func DeleteGC(ctx context.Context, clientset kubernetes.Interface, params GCParams) error {
+	for _, obj := range BuildGC(params) {
+		var err error
+		switch o := obj.(type) {
+		case *corev1.ServiceAccount:
+			err = clientset.CoreV1().ServiceAccounts(o.Namespace).Delete(ctx, o.Name, metav1.DeleteOptions{})
+		case *rbacv1.Role:
+			err = clientset.RbacV1().Roles(o.Namespace).Delete(ctx, o.Name, metav1.DeleteOptions{})
+		case *rbacv1.RoleBinding:
+			err = clientset.RbacV1().RoleBindings(o.Namespace).Delete(ctx, o.Name, metav1.DeleteOptions{})
+		case *rbacv1.ClusterRole:
+			err = clientset.RbacV1().ClusterRoles().Delete(ctx, o.Name, metav1.DeleteOptions{})
+		case *rbacv1.ClusterRoleBinding:
+			err = clientset.RbacV1().ClusterRoleBindings().Delete(ctx, o.Name, metav1.DeleteOptions{})
+		case *batchv1.CronJob:
+			err = clientset.BatchV1().CronJobs(o.Namespace).Delete(ctx, o.Name, metav1.DeleteOptions{})
+		}
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete %s: %w", obj.GetObjectKind().GroupVersionKind().Kind, err)
+		}
+	}
+
+	logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("GC CronJob uninstalled")
+	return nil
+}
+
+// GetGC retrieves the resources running 'k8run gc' in the cluster, in the order of BuildGC. Missing ones are nil.
+func GetGC(ctx context.Context, clientset kubernetes.Interface, params GCParams) ([]runtime.Object, error) {
+	live := []runtime.Object{}
+	for _, obj := range BuildGC(params) {
+		var existing runtime.Object
+		var err error
+		switch o := obj.(type) {
+		case *corev1.ServiceAccount:
+			existing, err = clientset.CoreV1().ServiceAccounts(o.Namespace).Get(ctx, o.Name, metav1.GetOptions{})
+		case *rbacv1.Role:
+			existing, err = clientset.RbacV1().Roles(o.Namespace).Get(ctx, o.Name, metav1.GetOptions{})
+		case *rbacv1.RoleBinding:
+			existing, err = clientset.RbacV1().RoleBindings(o.Namespace).Get(ctx, o.Name, metav1.GetOptions{})
+		case *rbacv1.ClusterRole:
+			existing, err = clientset.RbacV1().ClusterRoles().Get(ctx, o.Name, metav1.GetOptions{})
+		case *rbacv1.ClusterRoleBinding:
+			existing, err = clientset.RbacV1().ClusterRoleBindings().Get(ctx, o.Name, metav1.GetOptions{})
+		case *batchv1.CronJob:
+			existing, err = clientset.BatchV1().CronJobs(o.Namespace).Get(ctx, o.Name, metav1.GetOptions{})
+		}
+		if k8serrors.IsNotFound(err) {
+			existing = nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", obj.GetObjectKind().GroupVersionKind().Kind, err)
+		}
+		live = append(live, existing)
+	}
+
+	return live, nil
+}
+
+// applier is implemented by the typed clients of client-go.
+type applier[T metav1.Object] interface {
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (T, error)
+	Create(ctx context.Context, obj T, opts metav1.CreateOptions) (T, error)
+	Update(ctx context.Context, obj T, opts metav1.UpdateOptions) (T, error)
+}
+
+// apply creates the object or, when it exists, updates it.
+func apply[T metav1.Object](ctx context.Context, obj T, client applier[T]) error {
+	existing, err := client.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		_, err = client.Create(ctx, obj, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if existing.GetLabels()[LabelNameCreatedBy] != LabelValueCreatedBy {
+		return fmt.Errorf("%s %w", obj.GetName(), ErrNotCreatedByK8run)
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	_, err = client.Update(ctx, obj, metav1.UpdateOptions{})
+	return err
+}
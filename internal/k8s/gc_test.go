@@ -0,0 +1,81 @@
+package k8s_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestBuildGC(t *testing.T) {
+	params := k8s.GCParams{Name: "k8run-gc", Namespace: "k8run-system", Schedule: "@hourly", Image: "k8run", Command: []string{"k8run", "gc"}}
+
+	objects := k8s.BuildGC(params)
+	if _, ok := objects[1].(*rbacv1.Role); !ok {
+		t.Errorf("expected a role, got %T", objects[1])
+	}
+	if _, ok := objects[2].(*rbacv1.RoleBinding); !ok {
+		t.Errorf("expected a role binding, got %T", objects[2])
+	}
+
+	params.AllNamespaces = true
+	objects = k8s.BuildGC(params)
+	role, ok := objects[1].(*rbacv1.ClusterRole)
+	if !ok {
+		t.Fatalf("expected a cluster role, got %T", objects[1])
+	}
+	if rule := role.Rules[len(role.Rules)-1]; rule.Resources[0] != "namespaces" {
+		t.Errorf("expected the cluster role to delete namespaces, got %v", rule)
+	}
+	if binding := objects[2].(*rbacv1.ClusterRoleBinding); binding.Subjects[0].Namespace != "k8run-system" {
+		t.Errorf("expected the service account to be bound, got %v", binding.Subjects)
+	}
+}
+
+func TestApplyGC(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	params := k8s.GCParams{Name: "k8run-gc", Namespace: "k8run-system", Schedule: "@hourly", Image: "k8run", Command: []string{"k8run", "gc"}}
+
+	if err := k8s.ApplyGC(context.Background(), clientset, params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	params.Schedule = "@daily"
+	if err := k8s.ApplyGC(context.Background(), clientset, params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	live, err := k8s.GetGC(context.Background(), clientset, params)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for _, obj := range live {
+		if obj == nil {
+			t.Fatalf("expected every resource to exist, got %v", live)
+		}
+	}
+	cronJob, _ := clientset.BatchV1().CronJobs("k8run-system").Get(context.Background(), "k8run-gc", metav1.GetOptions{})
+	if cronJob.Spec.Schedule != "@daily" {
+		t.Errorf("expected the schedule to be updated, got %s", cronJob.Spec.Schedule)
+	}
+
+	if err := k8s.DeleteGC(context.Background(), clientset, params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := k8s.DeleteGC(context.Background(), clientset, params); err != nil {
+		t.Errorf("expected deleting twice to succeed, got %v", err)
+	}
+}
+
+func TestApplyGC_NotCreatedByK8run(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "k8run-gc", Namespace: "k8run-system"}})
+	params := k8s.GCParams{Name: "k8run-gc", Namespace: "k8run-system", Schedule: "@hourly", Image: "k8run"}
+
+	if err := k8s.ApplyGC(context.Background(), clientset, params); err == nil {
+		t.Errorf("expected an error for a service account not created by k8run")
+	}
+}
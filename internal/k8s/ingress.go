@@ -3,7 +3,8 @@ package k8s
 import (
 	"context"
 	"fmt"
-	"log/slog"
+
+	"github.com/lucasvmiguel/k8run/internal/logging"
 
 	networkingv1 "k8s.io/api/networking/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
@@ -18,14 +19,21 @@ type CreateOrUpdateIngressParams struct {
 	IngressClass *string
 	IngressHost  string
 	Port         int32
+	// Annotations are set on the resource, eg: its expiry.
+	Annotations map[string]string
 }
 
-// CreateOrUpdateIngress creates or updates an ingress in the given namespace.
-func CreateOrUpdateIngress(ctx context.Context, clientset kubernetes.Interface, params CreateOrUpdateIngressParams) error {
-	ingress := &networkingv1.Ingress{
+// BuildIngress builds the ingress object described by the given parameters without sending it to the cluster.
+func BuildIngress(params CreateOrUpdateIngressParams) *networkingv1.Ingress {
+	return &networkingv1.Ingress{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "networking.k8s.io/v1",
+			Kind:       "Ingress",
+		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      params.Name,
-			Namespace: params.Namespace,
+			Name:        params.Name,
+			Namespace:   params.Namespace,
+			Annotations: params.Annotations,
 			Labels: map[string]string{
 				LabelNameCreatedBy: LabelValueCreatedBy,
 			},
@@ -57,6 +65,11 @@ func CreateOrUpdateIngress(ctx context.Context, clientset kubernetes.Interface,
 			},
 		},
 	}
+}
+
+// CreateOrUpdateIngress creates or updates an ingress in the given namespace.
+func CreateOrUpdateIngress(ctx context.Context, clientset kubernetes.Interface, params CreateOrUpdateIngressParams) error {
+	ingress := BuildIngress(params)
 
 	existingIngress, err := clientset.NetworkingV1().Ingresses(params.Namespace).Get(ctx, params.Name, metav1.GetOptions{})
 	if err != nil {
@@ -64,10 +77,10 @@ func CreateOrUpdateIngress(ctx context.Context, clientset kubernetes.Interface,
 		if err != nil {
 			return fmt.Errorf("failed to create ingress: %v", err)
 		}
-		slog.With("name", params.Name, "namespace", params.Namespace).Info("Ingress created")
+		logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Ingress created")
 	} else {
 		if existingIngress.Labels[LabelNameCreatedBy] != LabelValueCreatedBy {
-			return fmt.Errorf("ingress already exists but it has not been created by k8run")
+			return fmt.Errorf("ingress %w", ErrNotCreatedByK8run)
 		}
 
 		ingress.ResourceVersion = existingIngress.ResourceVersion
@@ -75,7 +88,7 @@ func CreateOrUpdateIngress(ctx context.Context, clientset kubernetes.Interface,
 		if err != nil {
 			return fmt.Errorf("failed to update ingress: %v", err)
 		}
-		slog.With("name", params.Name, "namespace", params.Namespace).Info("Ingress updated")
+		logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Ingress updated")
 	}
 
 	return nil
@@ -100,7 +113,7 @@ func DeleteIngress(ctx context.Context, clientset kubernetes.Interface, params D
 	}
 
 	if existentIngress.Labels[LabelNameCreatedBy] != LabelValueCreatedBy {
-		return fmt.Errorf("ingress already exists but it has not been created by k8run")
+		return fmt.Errorf("ingress %w", ErrNotCreatedByK8run)
 	}
 
 	err = ingressesClient.Delete(ctx, params.Name, metav1.DeleteOptions{})
@@ -108,11 +121,11 @@ func DeleteIngress(ctx context.Context, clientset kubernetes.Interface, params D
 		return fmt.Errorf("failed to delete ingress: %w", err)
 	}
 
-	slog.With("name", params.Name, "namespace", params.Namespace).Info("Ingress marked for deletion")
+	logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Ingress marked for deletion")
 	return nil
 }
 
-// GetIngressParams represents the parameters to get an ingress.
+// GetIngress retrieves an ingress in the given namespace.
 func GetIngress(ctx context.Context, clientset kubernetes.Interface, params GetParams) (*networkingv1.Ingress, error) {
 	ingressesClient := clientset.NetworkingV1().Ingresses(params.Namespace)
 	existentIngress, err := ingressesClient.Get(ctx, params.Name, metav1.GetOptions{})
@@ -126,3 +139,13 @@ func GetIngress(ctx context.Context, clientset kubernetes.Interface, params GetP
 
 	return existentIngress, nil
 }
+
+// ListIngresses lists the ingresses created by k8run. An empty namespace lists them in every namespace.
+func ListIngresses(ctx context.Context, clientset kubernetes.Interface, params ListParams) ([]networkingv1.Ingress, error) {
+	list, err := clientset.NetworkingV1().Ingresses(params.Namespace).List(ctx, metav1.ListOptions{LabelSelector: params.selector()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ingresses: %w", err)
+	}
+
+	return list.Items, nil
+}
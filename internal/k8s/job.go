@@ -0,0 +1,204 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/logging"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// jobTTL is how long a finished job is kept around, so its logs can still be read.
+const jobTTL = int32(10 * 60)
+
+// CreateJobParams represents the parameters to create a job.
+type CreateJobParams struct {
+	Name                 string
+	Namespace            string
+	App                  string
+	Image                string
+	Entrypoint           []string
+	WorkingDir           string
+	CopyTo               string
+	CopyMounts           []CopyMount
+	PVCName              string
+	InitContainerName    string
+	InitContainerCommand []string
+	ReleaseIdentifier    string
+	Env                  map[string]string
+}
+
+// BuildJob builds the job object described by the given parameters without sending it to the cluster.
+// The job runs once: its pod waits in the init container for the content to be copied into the PVC, then runs the entrypoint.
+func BuildJob(params CreateJobParams) *batchv1.Job {
+	backoffLimit := int32(0)
+	ttl := jobTTL
+	labels := map[string]string{
+		LabelNameCreatedBy:         LabelValueCreatedBy,
+		LabelNameReleaseIdentifier: params.ReleaseIdentifier,
+		LabelNameApp:               params.App,
+	}
+
+	return &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      params.Name,
+			Namespace: params.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Affinity:      colocate(params.App),
+					InitContainers: []corev1.Container{
+						{
+							Name:    params.InitContainerName,
+							Image:   HelperImage,
+							Command: params.InitContainerCommand,
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "app",
+									MountPath: params.CopyTo,
+								},
+							},
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:         params.App,
+							Image:        params.Image,
+							Args:         params.Entrypoint,
+							WorkingDir:   params.WorkingDir,
+							VolumeMounts: appVolumeMounts(params.CopyTo, params.CopyMounts),
+							Env:          envVars(params.Env),
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "app",
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+									ClaimName: params.PVCName,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// CreateJob creates a job in the given namespace.
+func CreateJob(ctx context.Context, clientset kubernetes.Interface, params CreateJobParams) error {
+	_, err := clientset.BatchV1().Jobs(params.Namespace).Create(ctx, BuildJob(params), metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+
+	logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Job created")
+	return nil
+}
+
+// WaitForJobToComplete waits for a job to succeed in the given namespace. It fails as soon as the job fails.
+func WaitForJobToComplete(ctx context.Context, clientset kubernetes.Interface, params GetParams) error {
+	jobsClient := clientset.BatchV1().Jobs(params.Namespace)
+
+	for {
+		job, err := jobsClient.Get(ctx, params.Name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get job: %w", err)
+		}
+
+		for _, condition := range job.Status.Conditions {
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch condition.Type {
+			case batchv1.JobComplete:
+				logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Job completed")
+				return nil
+			case batchv1.JobFailed:
+				return fmt.Errorf("job %q failed: %s", params.Name, condition.Message)
+			}
+		}
+
+		logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Waiting for job to complete...")
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context cancelled while waiting for job to complete")
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// ListJobs lists the jobs created by k8run matching the given label selector in the given namespace.
+func ListJobs(ctx context.Context, clientset kubernetes.Interface, params ListParams) ([]batchv1.Job, error) {
+	list, err := clientset.BatchV1().Jobs(params.Namespace).List(ctx, metav1.ListOptions{LabelSelector: params.selector()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	return list.Items, nil
+}
+
+// DeleteJobs deletes the jobs created by k8run matching the given label selector in the given namespace, along with their pods.
+func DeleteJobs(ctx context.Context, clientset kubernetes.Interface, params ListParams) error {
+	propagation := metav1.DeletePropagationBackground
+	err := clientset.BatchV1().Jobs(params.Namespace).DeleteCollection(ctx,
+		metav1.DeleteOptions{PropagationPolicy: &propagation},
+		metav1.ListOptions{LabelSelector: params.selector()},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete jobs: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteJobParams represents the parameters to delete a job.
+type DeleteJobParams struct {
+	Name      string
+	Namespace string
+}
+
+// DeleteJob deletes a job created by k8run, along with its pods.
+func DeleteJob(ctx context.Context, clientset kubernetes.Interface, params DeleteJobParams) error {
+	jobsClient := clientset.BatchV1().Jobs(params.Namespace)
+
+	job, err := jobsClient.Get(ctx, params.Name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return fmt.Errorf("job %q not found: %w", params.Name, ErrResourceNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get job: %w", err)
+	}
+
+	if job.Labels[LabelNameCreatedBy] != LabelValueCreatedBy {
+		return fmt.Errorf("job %w", ErrNotCreatedByK8run)
+	}
+
+	propagation := metav1.DeletePropagationBackground
+	err = jobsClient.Delete(ctx, params.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil {
+		return fmt.Errorf("failed to delete job: %w", err)
+	}
+
+	logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Job marked for deletion")
+	return nil
+}
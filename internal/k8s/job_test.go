@@ -0,0 +1,77 @@
+package k8s_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCreateJob(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	params := k8s.CreateJobParams{
+		Name:                 "test-release",
+		Namespace:            "default",
+		App:                  "test",
+		Image:                "test-image",
+		Entrypoint:           []string{"sh", "-c", "rake db:migrate"},
+		CopyTo:               "/app",
+		PVCName:              "test-pvc",
+		InitContainerName:    "init-container",
+		InitContainerCommand: []string{"sh", "-c", "echo 'Init'"},
+		ReleaseIdentifier:    "test-release",
+	}
+
+	if err := k8s.CreateJob(context.Background(), clientset, params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	job, err := clientset.BatchV1().Jobs(params.Namespace).Get(context.Background(), params.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get created job: %v", err)
+	}
+
+	if job.Labels[k8s.LabelNameApp] != "test" || job.Spec.Template.Labels[k8s.LabelNameReleaseIdentifier] != "test-release" {
+		t.Errorf("expected app and release labels, got %v", job.Spec.Template.Labels)
+	}
+	if *job.Spec.BackoffLimit != 0 {
+		t.Errorf("expected the job not to be retried, got backoff limit %d", *job.Spec.BackoffLimit)
+	}
+	if job.Spec.Template.Spec.InitContainers[0].Name != "init-container" {
+		t.Errorf("expected the init container, got %v", job.Spec.Template.Spec.InitContainers)
+	}
+	if job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName != "test-pvc" {
+		t.Errorf("expected the PVC to be mounted, got %v", job.Spec.Template.Spec.Volumes)
+	}
+}
+
+func TestWaitForJobToComplete(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition batchv1.JobConditionType
+		wantErr   bool
+	}{
+		{name: "complete", condition: batchv1.JobComplete, wantErr: false},
+		{name: "failed", condition: batchv1.JobFailed, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(&batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-release", Namespace: "default"},
+				Status: batchv1.JobStatus{
+					Conditions: []batchv1.JobCondition{{Type: tt.condition, Status: corev1.ConditionTrue}},
+				},
+			})
+
+			err := k8s.WaitForJobToComplete(context.Background(), clientset, k8s.GetParams{Name: "test-release", Namespace: "default"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WaitForJobToComplete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,10 +1,19 @@
 package k8s
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // ErrResourceNotFound is the error returned when a resource is not found.
 var ErrResourceNotFound = fmt.Errorf("resource not found")
 
+// ErrAppCrashed is the error returned when the containers of the app keep exiting instead of becoming ready.
+var ErrAppCrashed = fmt.Errorf("app crashed")
+
+// ErrNotCreatedByK8run is the error returned when a resource of the app exists, but wasn't created by k8run.
+var ErrNotCreatedByK8run = fmt.Errorf("already exists but it has not been created by k8run")
+
 const (
 	// LabelNameCreatedBy is the label name to identify resources created by k8run.
 	LabelNameCreatedBy = "k8run-created-by"
@@ -12,6 +21,46 @@ const (
 	LabelValueCreatedBy = "k8run"
 	// LabelNameReleaseIdentifier is the label name to identify resources by release identifier.
 	LabelNameReleaseIdentifier = "k8run-release-identifier"
+	// LabelNameApp is the label name to group the resources of an app made of several deployments. eg: the processes of a Procfile
+	LabelNameApp = "k8run-app"
+	// AnnotationNameExpiresAt is the annotation name of the time (RFC 3339) after which 'k8run gc' destroys the app.
+	AnnotationNameExpiresAt = "k8run-expires-at"
+	// AnnotationNameOwner is the annotation name of who deployed the app, eg: the kubeconfig user or the git email.
+	AnnotationNameOwner = "k8run-owner"
+	// AnnotationNameGitCommit, AnnotationNameGitBranch, AnnotationNameGitDirty and AnnotationNameGitRemote are the
+	// annotation names of the git metadata of the copied folder.
+	AnnotationNameGitCommit = "k8run-git-commit"
+	AnnotationNameGitBranch = "k8run-git-branch"
+	AnnotationNameGitDirty  = "k8run-git-dirty"
+	AnnotationNameGitRemote = "k8run-git-remote"
+	// AnnotationNameImageDigest and AnnotationNameBuildDuration are the annotation names of the digest of the image
+	// built in the cluster and how long building it took. eg: 'sha256:...' and '1m32s'
+	AnnotationNameImageDigest   = "k8run-image-digest"
+	AnnotationNameBuildDuration = "k8run-build-duration"
+	// AnnotationNameChangeCause is the annotation 'kubectl rollout history' shows for each revision.
+	AnnotationNameChangeCause = "kubernetes.io/change-cause"
+	// HelperImage is the image of the helper containers, eg: the init container waiting for the copy.
+	HelperImage = "busybox"
+	// GitImage is the image of the init container cloning a git repository.
+	GitImage = "alpine/git"
+	// BuilderImage is the image building images in the cluster, without a docker daemon nor privileges.
+	BuilderImage = "gcr.io/kaniko-project/executor:latest"
+	// BuilderContainerName is the name of the container building the image in a build job.
+	BuilderContainerName = "build"
+	// builderDockerConfigPath is where the builder reads the registry credentials from.
+	builderDockerConfigPath = "/kaniko/.docker"
+	// SetupContainerName is the name of the init container running the setup of the app, eg: 'npm ci'.
+	SetupContainerName = "setup"
+	// InitContainerSecretPath is where the secret of the init container is mounted. eg: the git credentials
+	InitContainerSecretPath = "/etc/k8run/secret"
+	// EnvVarDeployTimestamp is the env var set on every release to force pods to be recreated.
+	EnvVarDeployTimestamp = "K8RUN_DEPLOY_TIMESTAMP"
+	// EnvVarGitCommit, EnvVarGitBranch, EnvVarGitDirty and EnvVarGitRemote are the env vars exposing the git
+	// metadata of the copied folder to the app.
+	EnvVarGitCommit = "K8RUN_GIT_COMMIT"
+	EnvVarGitBranch = "K8RUN_GIT_BRANCH"
+	EnvVarGitDirty  = "K8RUN_GIT_DIRTY"
+	EnvVarGitRemote = "K8RUN_GIT_REMOTE"
 )
 
 // GetParams represents the parameters to get a resource.
@@ -19,3 +68,27 @@ type GetParams struct {
 	Name      string
 	Namespace string
 }
+
+// ListParams represents the parameters to list resources.
+type ListParams struct {
+	Namespace     string
+	LabelSelector string
+}
+
+// selector returns the label selector matching the resources created by k8run, narrowed by the given one.
+func (p ListParams) selector() string {
+	selector := fmt.Sprintf("%s=%s", LabelNameCreatedBy, LabelValueCreatedBy)
+	if p.LabelSelector != "" {
+		selector += "," + p.LabelSelector
+	}
+	return selector
+}
+
+// ExpiresAt returns the expiry time stored in the given annotations, if any.
+func ExpiresAt(annotations map[string]string) (time.Time, bool) {
+	expiresAt, err := time.Parse(time.RFC3339, annotations[AnnotationNameExpiresAt])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return expiresAt, true
+}
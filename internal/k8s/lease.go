@@ -0,0 +1,197 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/logging"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// LeaseParams represents the parameters of a lease locking an app.
+type LeaseParams struct {
+	Name      string
+	Namespace string
+	App       string
+	// Holder identifies who holds the lease, it must be unique to each run. eg: 'jane@example.com on laptop (x1y2z)'
+	Holder string
+	// Duration is how long the lease is held without being renewed, eg: after the holder crashed.
+	Duration time.Duration
+}
+
+// LeaseHeldError is the error returned when the lease is held by someone else.
+type LeaseHeldError struct {
+	Holder     string
+	AcquiredAt time.Time
+}
+
+func (e *LeaseHeldError) Error() string {
+	return fmt.Sprintf("lease held by %s since %s", e.Holder, e.AcquiredAt.Format(time.RFC3339))
+}
+
+// ErrLeaseLost is the error returned when renewing a lease that someone else holds or deleted.
+var ErrLeaseLost = fmt.Errorf("lease lost")
+
+// BuildLease builds the lease object described by the given parameters, acquired at the given time.
+func BuildLease(params LeaseParams, now time.Time) *coordinationv1.Lease {
+	acquiredAt := metav1.NewMicroTime(now)
+	seconds := int32(params.Duration.Seconds())
+
+	return &coordinationv1.Lease{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      params.Name,
+			Namespace: params.Namespace,
+			Labels: map[string]string{
+				LabelNameCreatedBy: LabelValueCreatedBy,
+				LabelNameApp:       params.App,
+			},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &params.Holder,
+			LeaseDurationSeconds: &seconds,
+			AcquireTime:          &acquiredAt,
+			RenewTime:            &acquiredAt,
+		},
+	}
+}
+
+// AcquireLease acquires the lease, creating it or taking it over when its holder stopped renewing it.
+// It returns a LeaseHeldError when someone else holds it.
+func AcquireLease(ctx context.Context, clientset kubernetes.Interface, params LeaseParams) error {
+	leasesClient := clientset.CoordinationV1().Leases(params.Namespace)
+	now := time.Now()
+
+	lease, err := leasesClient.Get(ctx, params.Name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		_, err = leasesClient.Create(ctx, BuildLease(params, now), metav1.CreateOptions{})
+		if k8serrors.IsAlreadyExists(err) {
+			// someone else acquired it in the meantime
+			return AcquireLease(ctx, clientset, params)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create lease: %w", err)
+		}
+
+		logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Lease acquired")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get lease: %w", err)
+	}
+
+	if holder := leaseHolder(lease); holder != params.Holder && !leaseExpired(lease, now) {
+		acquiredAt := now
+		if lease.Spec.AcquireTime != nil {
+			acquiredAt = lease.Spec.AcquireTime.Time
+		}
+		return &LeaseHeldError{Holder: holder, AcquiredAt: acquiredAt}
+	}
+
+	if lease.Labels[LabelNameCreatedBy] != LabelValueCreatedBy {
+		return fmt.Errorf("lease %w", ErrNotCreatedByK8run)
+	}
+
+	acquired := BuildLease(params, now)
+	acquired.ResourceVersion = lease.ResourceVersion
+	_, err = leasesClient.Update(ctx, acquired, metav1.UpdateOptions{})
+	if k8serrors.IsConflict(err) {
+		return AcquireLease(ctx, clientset, params)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update lease: %w", err)
+	}
+
+	logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Lease acquired")
+	return nil
+}
+
+// RenewLease renews the lease, failing when it's no longer held by the holder of the given parameters.
+func RenewLease(ctx context.Context, clientset kubernetes.Interface, params LeaseParams) error {
+	leasesClient := clientset.CoordinationV1().Leases(params.Namespace)
+
+	lease, err := leasesClient.Get(ctx, params.Name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return fmt.Errorf("%w, it was deleted", ErrLeaseLost)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get lease: %w", err)
+	}
+	if holder := leaseHolder(lease); holder != params.Holder {
+		return fmt.Errorf("%w to %s", ErrLeaseLost, holder)
+	}
+
+	renewedAt := metav1.NewMicroTime(time.Now())
+	lease.Spec.RenewTime = &renewedAt
+	_, err = leasesClient.Update(ctx, lease, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to renew lease: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseLease deletes the lease if it's still held by the holder of the given parameters.
+func ReleaseLease(ctx context.Context, clientset kubernetes.Interface, params LeaseParams) error {
+	leasesClient := clientset.CoordinationV1().Leases(params.Namespace)
+
+	lease, err := leasesClient.Get(ctx, params.Name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get lease: %w", err)
+	}
+	if leaseHolder(lease) != params.Holder {
+		return nil
+	}
+
+	err = leasesClient.Delete(ctx, params.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &lease.ResourceVersion},
+	})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete lease: %w", err)
+	}
+
+	logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Lease released")
+	return nil
+}
+
+// ListLeases lists the leases created by k8run. An empty namespace lists them in every namespace.
+func ListLeases(ctx context.Context, clientset kubernetes.Interface, params ListParams) ([]coordinationv1.Lease, error) {
+	list, err := clientset.CoordinationV1().Leases(params.Namespace).List(ctx, metav1.ListOptions{LabelSelector: params.selector()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list leases: %w", err)
+	}
+
+	return list.Items, nil
+}
+
+// LeaseHeld returns true if the lease is held at the given time, by a holder still renewing it.
+func LeaseHeld(lease *coordinationv1.Lease, now time.Time) bool {
+	return !leaseExpired(lease, now)
+}
+
+func leaseHolder(lease *coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// leaseExpired returns true if the holder of the lease stopped renewing it for longer than its duration.
+func leaseExpired(lease *coordinationv1.Lease, now time.Time) bool {
+	if leaseHolder(lease) == "" || lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	expiresAt := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return !now.Before(expiresAt)
+}
@@ -0,0 +1,69 @@
+package k8s_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAcquireLease(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	jane := k8s.LeaseParams{Name: "k8run-foo", Namespace: "default", App: "foo", Holder: "jane", Duration: time.Minute}
+	john := jane
+	john.Holder = "john"
+
+	if err := k8s.AcquireLease(context.Background(), clientset, jane); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := k8s.AcquireLease(context.Background(), clientset, jane); err != nil {
+		t.Errorf("expected the holder to acquire the lease again, got %v", err)
+	}
+
+	var held *k8s.LeaseHeldError
+	if err := k8s.AcquireLease(context.Background(), clientset, john); !errors.As(err, &held) || held.Holder != "jane" {
+		t.Fatalf("expected the lease to be held by jane, got %v", err)
+	}
+	if err := k8s.RenewLease(context.Background(), clientset, john); err == nil {
+		t.Errorf("expected an error when renewing a lease held by someone else")
+	}
+	if err := k8s.ReleaseLease(context.Background(), clientset, john); err != nil {
+		t.Errorf("expected releasing a lease held by someone else to be a no-op, got %v", err)
+	}
+
+	if err := k8s.RenewLease(context.Background(), clientset, jane); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := k8s.ReleaseLease(context.Background(), clientset, jane); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	_, err := clientset.CoordinationV1().Leases("default").Get(context.Background(), "k8run-foo", metav1.GetOptions{})
+	if !k8serrors.IsNotFound(err) {
+		t.Errorf("expected the lease to be deleted, got %v", err)
+	}
+}
+
+func TestAcquireLease_Expired(t *testing.T) {
+	jane := k8s.LeaseParams{Name: "k8run-foo", Namespace: "default", App: "foo", Holder: "jane", Duration: time.Minute}
+	clientset := fake.NewSimpleClientset(k8s.BuildLease(jane, time.Now().Add(-2*time.Minute)))
+
+	john := jane
+	john.Holder = "john"
+	if err := k8s.AcquireLease(context.Background(), clientset, john); err != nil {
+		t.Fatalf("expected an expired lease to be taken over, got %v", err)
+	}
+
+	lease, err := clientset.CoordinationV1().Leases("default").Get(context.Background(), "k8run-foo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *lease.Spec.HolderIdentity != "john" {
+		t.Errorf("expected the lease to be held by john, got %s", *lease.Spec.HolderIdentity)
+	}
+}
@@ -0,0 +1,28 @@
+package k8s
+
+import (
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// invalidNameChars matches the runs of characters DNS labels don't allow.
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// SanitizeName turns a name, eg: a folder or a branch, into a DNS-1123 label starting with a letter, as services
+// require. eg: 'My_App' becomes 'my-app'. Names left empty become the fallback, names starting with a digit are
+// prefixed by it and names that are too long are truncated.
+func SanitizeName(name, fallback string) string {
+	name = strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if name == "" {
+		return fallback
+	}
+	if name[0] < 'a' || name[0] > 'z' {
+		name = fallback + "-" + name
+	}
+	if len(name) > validation.DNS1123LabelMaxLength {
+		name = strings.TrimRight(name[:validation.DNS1123LabelMaxLength], "-")
+	}
+	return name
+}
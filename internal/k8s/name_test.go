@@ -0,0 +1,27 @@
+package k8s_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := map[string]string{
+		"api":                     "api",
+		"My_App":                  "my-app",
+		"web--api":                "web-api",
+		"___":                     "app",
+		"":                        "app",
+		"2048":                    "app-2048",
+		strings.Repeat("a", 70):   strings.Repeat("a", 63),
+		strings.Repeat("ab-", 30): strings.Repeat("ab-", 20) + "ab",
+	}
+
+	for name, want := range tests {
+		if got := k8s.SanitizeName(name, "app"); got != want {
+			t.Errorf("expected %q to become %q, got %q", name, want, got)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lucasvmiguel/k8run/internal/logging"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NameNamespaceLimits is the name of the resource quota and limit range k8run creates in a namespace.
+const NameNamespaceLimits = "k8run"
+
+// CreateNamespaceParams represents the parameters to create a namespace.
+type CreateNamespaceParams struct {
+	Name string
+	// App is the app the namespace is created for, so destroying the app also deletes the namespace.
+	App string
+}
+
+// BuildNamespace builds the namespace object described by the given parameters without sending it to the cluster.
+func BuildNamespace(params CreateNamespaceParams) *corev1.Namespace {
+	return &corev1.Namespace{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Namespace",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: params.Name,
+			Labels: map[string]string{
+				LabelNameCreatedBy: LabelValueCreatedBy,
+				LabelNameApp:       params.App,
+			},
+		},
+	}
+}
+
+// CreateNamespaceIfNotExists creates a namespace if it does not exist. An existing namespace is used as it is,
+// whoever created it.
+func CreateNamespaceIfNotExists(ctx context.Context, clientset kubernetes.Interface, params CreateNamespaceParams) error {
+	_, err := GetNamespace(ctx, clientset, params.Name)
+	if err == nil {
+		logging.FromContext(ctx).With("name", params.Name).Info("Namespace already exists")
+		return nil
+	}
+
+	_, err = clientset.CoreV1().Namespaces().Create(ctx, BuildNamespace(params), metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create namespace: %w", err)
+	}
+
+	logging.FromContext(ctx).With("name", params.Name).Info("Namespace created")
+	return nil
+}
+
+// DeleteNamespaceParams represents the parameters to delete a namespace.
+type DeleteNamespaceParams struct {
+	Name string
+}
+
+// DeleteNamespace deletes a namespace, with everything in it.
+func DeleteNamespace(ctx context.Context, clientset kubernetes.Interface, params DeleteNamespaceParams) error {
+	namespace, err := GetNamespace(ctx, clientset, params.Name)
+	if err != nil {
+		return err
+	}
+
+	if namespace.Labels[LabelNameCreatedBy] != LabelValueCreatedBy {
+		return fmt.Errorf("namespace %w", ErrNotCreatedByK8run)
+	}
+
+	err = clientset.CoreV1().Namespaces().Delete(ctx, params.Name, metav1.DeleteOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to delete namespace: %w", err)
+	}
+
+	logging.FromContext(ctx).With("name", params.Name).Info("Namespace marked for deletion")
+	return nil
+}
+
+// ListNamespaces lists the namespaces created by k8run. The namespace of the given parameters is ignored.
+func ListNamespaces(ctx context.Context, clientset kubernetes.Interface, params ListParams) ([]corev1.Namespace, error) {
+	list, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: params.selector()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	return list.Items, nil
+}
+
+// GetNamespace gets a namespace by name.
+func GetNamespace(ctx context.Context, clientset kubernetes.Interface, name string) (*corev1.Namespace, error) {
+	namespace, err := clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, fmt.Errorf("namespace %q not found: %w", name, ErrResourceNotFound)
+		}
+
+		return nil, fmt.Errorf("failed to get namespace: %w", err)
+	}
+
+	return namespace, nil
+}
+
+// NamespaceLimitsParams represents the resource quota and default container resources of a namespace.
+type NamespaceLimitsParams struct {
+	Namespace string
+	// Quota is the total of resources the namespace can use. eg: {"requests.cpu": "2", "pods": "10"}
+	Quota corev1.ResourceList
+	// DefaultRequests are the resources requested by containers that don't request any.
+	DefaultRequests corev1.ResourceList
+	// DefaultLimits are the resource limits of containers that don't set any.
+	DefaultLimits corev1.ResourceList
+}
+
+// BuildResourceQuota builds the resource quota described by the given parameters without sending it to the cluster.
+// It returns nil when there is no quota.
+func BuildResourceQuota(params NamespaceLimitsParams) *corev1.ResourceQuota {
+	if len(params.Quota) == 0 {
+		return nil
+	}
+
+	return &corev1.ResourceQuota{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ResourceQuota",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      NameNamespaceLimits,
+			Namespace: params.Namespace,
+			Labels: map[string]string{
+				LabelNameCreatedBy: LabelValueCreatedBy,
+			},
+		},
+		Spec: corev1.ResourceQuotaSpec{
+			Hard: params.Quota,
+		},
+	}
+}
+
+// BuildLimitRange builds the limit range described by the given parameters without sending it to the cluster.
+// It returns nil when there are no defaults.
+func BuildLimitRange(params NamespaceLimitsParams) *corev1.LimitRange {
+	if len(params.DefaultRequests) == 0 && len(params.DefaultLimits) == 0 {
+		return nil
+	}
+
+	return &corev1.LimitRange{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "LimitRange",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      NameNamespaceLimits,
+			Namespace: params.Namespace,
+			Labels: map[string]string{
+				LabelNameCreatedBy: LabelValueCreatedBy,
+			},
+		},
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{
+				{
+					Type:           corev1.LimitTypeContainer,
+					Default:        params.DefaultLimits,
+					DefaultRequest: params.DefaultRequests,
+				},
+			},
+		},
+	}
+}
+
+// CreateOrUpdateNamespaceLimits creates or updates the resource quota and limit range of a namespace.
+func CreateOrUpdateNamespaceLimits(ctx context.Context, clientset kubernetes.Interface, params NamespaceLimitsParams) error {
+	if quota := BuildResourceQuota(params); quota != nil {
+		quotasClient := clientset.CoreV1().ResourceQuotas(params.Namespace)
+		existing, err := quotasClient.Get(ctx, quota.Name, metav1.GetOptions{})
+		if err != nil {
+			_, err = quotasClient.Create(ctx, quota, metav1.CreateOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to create resource quota: %w", err)
+			}
+		} else {
+			existing.Spec = quota.Spec
+			_, err = quotasClient.Update(ctx, existing, metav1.UpdateOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to update resource quota: %w", err)
+			}
+		}
+		logging.FromContext(ctx).With("namespace", params.Namespace).Info("Resource quota created or updated")
+	}
+
+	if limitRange := BuildLimitRange(params); limitRange != nil {
+		limitRangesClient := clientset.CoreV1().LimitRanges(params.Namespace)
+		existing, err := limitRangesClient.Get(ctx, limitRange.Name, metav1.GetOptions{})
+		if err != nil {
+			_, err = limitRangesClient.Create(ctx, limitRange, metav1.CreateOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to create limit range: %w", err)
+			}
+		} else {
+			existing.Spec = limitRange.Spec
+			_, err = limitRangesClient.Update(ctx, existing, metav1.UpdateOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to update limit range: %w", err)
+			}
+		}
+		logging.FromContext(ctx).With("namespace", params.Namespace).Info("Limit range created or updated")
+	}
+
+	return nil
+}
+
+// GetResourceQuota retrieves a resource quota in the given namespace.
+func GetResourceQuota(ctx context.Context, clientset kubernetes.Interface, params GetParams) (*corev1.ResourceQuota, error) {
+	quota, err := clientset.CoreV1().ResourceQuotas(params.Namespace).Get(ctx, params.Name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, fmt.Errorf("resource quota %q not found in namespace %q: %w", params.Name, params.Namespace, ErrResourceNotFound)
+		}
+
+		return nil, fmt.Errorf("failed to get resource quota: %w", err)
+	}
+
+	return quota, nil
+}
+
+// GetLimitRange retrieves a limit range in the given namespace.
+func GetLimitRange(ctx context.Context, clientset kubernetes.Interface, params GetParams) (*corev1.LimitRange, error) {
+	limitRange, err := clientset.CoreV1().LimitRanges(params.Namespace).Get(ctx, params.Name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, fmt.Errorf("limit range %q not found in namespace %q: %w", params.Name, params.Namespace, ErrResourceNotFound)
+		}
+
+		return nil, fmt.Errorf("failed to get limit range: %w", err)
+	}
+
+	return limitRange, nil
+}
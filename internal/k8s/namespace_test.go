@@ -0,0 +1,67 @@
+package k8s_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDeleteNamespace(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}})
+
+	if err := k8s.DeleteNamespace(context.Background(), clientset, k8s.DeleteNamespaceParams{Name: "team-a"}); err == nil {
+		t.Errorf("expected an error for a namespace not created by k8run")
+	}
+	if err := k8s.DeleteNamespace(context.Background(), clientset, k8s.DeleteNamespaceParams{Name: "missing"}); !errors.Is(err, k8s.ErrResourceNotFound) {
+		t.Errorf("expected ErrResourceNotFound, got %v", err)
+	}
+
+	err := k8s.CreateNamespaceIfNotExists(context.Background(), clientset, k8s.CreateNamespaceParams{Name: "k8run-foo", App: "foo"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := k8s.DeleteNamespace(context.Background(), clientset, k8s.DeleteNamespaceParams{Name: "k8run-foo"}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestCreateOrUpdateNamespaceLimits(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	params := k8s.NamespaceLimitsParams{
+		Namespace:       "k8run-foo",
+		Quota:           corev1.ResourceList{corev1.ResourcePods: resource.MustParse("10")},
+		DefaultRequests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("50m")},
+	}
+	if err := k8s.CreateOrUpdateNamespaceLimits(context.Background(), clientset, params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	params.Quota = corev1.ResourceList{corev1.ResourcePods: resource.MustParse("5")}
+	if err := k8s.CreateOrUpdateNamespaceLimits(context.Background(), clientset, params); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	get := k8s.GetParams{Name: k8s.NameNamespaceLimits, Namespace: "k8run-foo"}
+	quota, err := k8s.GetResourceQuota(context.Background(), clientset, get)
+	if err != nil {
+		t.Fatalf("expected the resource quota, got %v", err)
+	}
+	if pods := quota.Spec.Hard[corev1.ResourcePods]; pods.Value() != 5 {
+		t.Errorf("expected the quota to be updated to 5 pods, got %s", pods.String())
+	}
+
+	limitRange, err := k8s.GetLimitRange(context.Background(), clientset, get)
+	if err != nil {
+		t.Fatalf("expected the limit range, got %v", err)
+	}
+	if cpu := limitRange.Spec.Limits[0].DefaultRequest[corev1.ResourceCPU]; cpu.String() != "50m" {
+		t.Errorf("expected a 50m default cpu request, got %s", cpu.String())
+	}
+}
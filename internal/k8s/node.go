@@ -0,0 +1,39 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ListNodeArchs lists the architectures of the linux nodes new pods can be scheduled on, from their
+// kubernetes.io/arch label, sorted. Cordoned nodes and nodes tainted with NoSchedule or NoExecute, eg: control
+// planes, are left out.
+func ListNodeArchs(ctx context.Context, clientset kubernetes.Interface) ([]string, error) {
+	list, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	archs := []string{}
+	for _, node := range list.Items {
+		if node.Spec.Unschedulable || node.Labels[corev1.LabelOSStable] != "linux" {
+			continue
+		}
+		tainted := slices.ContainsFunc(node.Spec.Taints, func(taint corev1.Taint) bool {
+			return taint.Effect == corev1.TaintEffectNoSchedule || taint.Effect == corev1.TaintEffectNoExecute
+		})
+		arch := node.Labels[corev1.LabelArchStable]
+		if tainted || arch == "" || slices.Contains(archs, arch) {
+			continue
+		}
+		archs = append(archs, arch)
+	}
+
+	slices.Sort(archs)
+	return archs, nil
+}
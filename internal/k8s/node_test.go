@@ -0,0 +1,46 @@
+package k8s_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testNode(name, goos, arch string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   name,
+		Labels: map[string]string{corev1.LabelOSStable: goos, corev1.LabelArchStable: arch},
+	}}
+}
+
+func TestListNodeArchs(t *testing.T) {
+	cordoned := testNode("cordoned", "linux", "s390x")
+	cordoned.Spec.Unschedulable = true
+	controlPlane := testNode("control-plane", "linux", "ppc64le")
+	controlPlane.Spec.Taints = []corev1.Taint{{Key: "node-role.kubernetes.io/control-plane", Effect: corev1.TaintEffectNoSchedule}}
+	preferred := testNode("preferred", "linux", "arm64")
+	preferred.Spec.Taints = []corev1.Taint{{Key: "spot", Effect: corev1.TaintEffectPreferNoSchedule}}
+
+	clientset := fake.NewSimpleClientset(
+		testNode("node-1", "linux", "amd64"),
+		testNode("node-2", "linux", "amd64"),
+		testNode("windows", "windows", "386"),
+		cordoned,
+		controlPlane,
+		preferred,
+	)
+
+	archs, err := k8s.ListNodeArchs(context.Background(), clientset)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !slices.Equal(archs, []string{"amd64", "arm64"}) {
+		t.Errorf("expected the architectures of the schedulable linux nodes, got %v", archs)
+	}
+}
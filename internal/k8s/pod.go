@@ -3,10 +3,10 @@ package k8s
 import (
 	"context"
 	"fmt"
-	"os/exec"
+	"io"
 	"time"
 
-	"log/slog"
+	"github.com/lucasvmiguel/k8run/internal/logging"
 
 	"k8s.io/client-go/kubernetes"
 
@@ -32,7 +32,7 @@ func WaitForRunningInitContainer(ctx context.Context, clientset kubernetes.Inter
 		case <-ctx.Done():
 			return nil, fmt.Errorf("context cancelled while waiting for init container to be running")
 		default:
-			slog.With("name", params.Name, "namespace", params.Namespace).Info("Waiting for init container to be running...")
+			logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Waiting for init container to be running...")
 
 			pods, err := podsClient.List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", LabelNameReleaseIdentifier, params.ReleaseIdentifier)})
 			if err != nil {
@@ -48,7 +48,7 @@ func WaitForRunningInitContainer(ctx context.Context, clientset kubernetes.Inter
 				if params.ReleaseIdentifier == pod.Labels[LabelNameReleaseIdentifier] {
 					for _, containerStatus := range pod.Status.InitContainerStatuses {
 						if containerStatus.Name == params.InitContainerName && containerStatus.State.Running != nil {
-							slog.With("pod", pod.Name).Info("Init container is running.")
+							logging.FromContext(ctx).With("pod", pod.Name).Info("Init container is running.")
 							return &pod, nil
 						}
 					}
@@ -60,24 +60,85 @@ func WaitForRunningInitContainer(ctx context.Context, clientset kubernetes.Inter
 	}
 }
 
-// CopyToPodParams represents the parameters to copy a folder to a pod.
-type CopyToPodParams struct {
-	LocalPath         string
-	PodName           string
-	ContainerPath     string
-	InitContainerName string
-	Namespace         string
+// WaitForContainerToStartParams represents the parameters to wait for a container of a pod to start.
+type WaitForContainerToStartParams struct {
+	Namespace string
+	PodName   string
+	Container string
 }
 
-// CopyToPod copies a file or folder to a pod.
-func CopyToPod(params CopyToPodParams) error {
-	slog.With("podName", params.PodName, "namespace", params.Namespace).Info("Copying to pod...")
+// WaitForContainerToStart waits for a container of a pod to be running or, if it was quick, to have terminated, so
+// its logs can be read. It fails as soon as a container of the pod fails, eg: the init container.
+func WaitForContainerToStart(ctx context.Context, clientset kubernetes.Interface, params WaitForContainerToStartParams) (*corev1.Pod, error) {
+	podsClient := clientset.CoreV1().Pods(params.Namespace)
 
-	cmd := exec.Command("kubectl", "cp", params.LocalPath, fmt.Sprintf("%s:%s", params.PodName, params.ContainerPath), "-c", params.InitContainerName, "-n", params.Namespace)
+	for {
+		pod, err := podsClient.Get(ctx, params.PodName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pod: %w", err)
+		}
+
+		if reason := crashed(*pod); reason != "" {
+			return nil, fmt.Errorf("pod %q failed: %s", params.PodName, reason)
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name == params.Container && (status.State.Running != nil || status.State.Terminated != nil) {
+				return pod, nil
+			}
+		}
 
-	output, err := cmd.CombinedOutput()
+		logging.FromContext(ctx).With("pod", params.PodName, "container", params.Container).Info("Waiting for container to start...")
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("context cancelled while waiting for container to start")
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// ListPods lists the pods created by k8run matching the given label selector in the given namespace.
+func ListPods(ctx context.Context, clientset kubernetes.Interface, params ListParams) ([]corev1.Pod, error) {
+	list, err := clientset.CoreV1().Pods(params.Namespace).List(ctx, metav1.ListOptions{LabelSelector: params.selector()})
 	if err != nil {
-		return fmt.Errorf("error copying folder: %s, output: %s", err, string(output))
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	return list.Items, nil
+}
+
+// LogsParams represents the parameters to stream the logs of a container.
+type LogsParams struct {
+	Namespace string
+	PodName   string
+	Container string
+	// Follow keeps streaming new logs until the context is done.
+	Follow bool
+	// Since only streams the logs newer than this duration. Zero streams all of them.
+	Since time.Duration
+	// Tail only streams this number of lines from the end of the logs. Zero streams all of them.
+	Tail int64
+}
+
+// StreamLogs copies the logs of a container to w.
+func StreamLogs(ctx context.Context, clientset kubernetes.Interface, params LogsParams, w io.Writer) error {
+	options := &corev1.PodLogOptions{Container: params.Container, Follow: params.Follow}
+	if params.Since > 0 {
+		seconds := int64(params.Since.Seconds())
+		options.SinceSeconds = &seconds
+	}
+	if params.Tail > 0 {
+		options.TailLines = &params.Tail
+	}
+
+	stream, err := clientset.CoreV1().Pods(params.Namespace).GetLogs(params.PodName, options).Stream(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to stream logs: %w", err)
+	}
+	defer stream.Close()
+
+	_, err = io.Copy(w, stream)
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to read logs: %w", err)
 	}
 
 	return nil
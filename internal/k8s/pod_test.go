@@ -1,6 +1,7 @@
 package k8s_test
 
 import (
+	"bytes"
 	"context"
 	"testing"
 	"time"
@@ -130,3 +131,63 @@ func TestWaitForRunningInitContainer_InitContainerNotRunning(t *testing.T) {
 		t.Fatalf("expected timeout or init container not running error, got nil")
 	}
 }
+
+func TestStreamLogs(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"}})
+
+	out := &bytes.Buffer{}
+	err := k8s.StreamLogs(context.TODO(), clientset, k8s.LogsParams{Namespace: "default", PodName: "test-pod", Tail: 10}, out)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if out.String() != "fake logs" {
+		t.Errorf("expected the logs of the pod, got %q", out)
+	}
+}
+
+func TestWaitForContainerToStart(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  corev1.PodStatus
+		wantErr bool
+	}{
+		{
+			name: "running",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "build", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "already terminated",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "build", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "init container failed",
+			status: corev1.PodStatus{InitContainerStatuses: []corev1.ContainerStatus{
+				{Name: "init", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"}}},
+			}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+				Status:     tt.status,
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_, err := k8s.WaitForContainerToStart(ctx, clientset, k8s.WaitForContainerToStartParams{Namespace: "default", PodName: "test-pod", Container: "build"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WaitForContainerToStart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,7 +3,8 @@ package k8s
 import (
 	"context"
 	"fmt"
-	"log/slog"
+
+	"github.com/lucasvmiguel/k8run/internal/logging"
 
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
@@ -27,13 +28,29 @@ func CreatePVCIfNotExists(ctx context.Context, clientset kubernetes.Interface, p
 			return fmt.Errorf("PVC with the same name already exists but it was not created by k8run")
 		}
 
-		slog.With("name", params.Name, "namespace", params.Namespace).Info("PVC already exists")
+		logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("PVC already exists")
 		return nil
 	}
 
-	pvcParams := &corev1.PersistentVolumeClaim{
+	_, err = pvcClient.Create(ctx, BuildPVC(params), metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create PVC: %w", err)
+	}
+
+	logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("PVC created")
+	return nil
+}
+
+// BuildPVC builds the PVC object described by the given parameters without sending it to the cluster.
+func BuildPVC(params CreatePVCIfNotExistsParams) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "PersistentVolumeClaim",
+		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: params.Name,
+			Name:      params.Name,
+			Namespace: params.Namespace,
 			Labels: map[string]string{
 				LabelNameCreatedBy: LabelValueCreatedBy,
 			},
@@ -49,14 +66,6 @@ func CreatePVCIfNotExists(ctx context.Context, clientset kubernetes.Interface, p
 			},
 		},
 	}
-
-	_, err = pvcClient.Create(ctx, pvcParams, metav1.CreateOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to create PVC: %w", err)
-	}
-
-	slog.With("name", params.Name, "namespace", params.Namespace).Info("PVC created")
-	return nil
 }
 
 // DeletePVCParams represents the parameters to delete a PVC.
@@ -78,7 +87,7 @@ func DeletePVC(ctx context.Context, clientset kubernetes.Interface, params Delet
 	}
 
 	if pvc.Labels[LabelNameCreatedBy] != LabelValueCreatedBy {
-		return fmt.Errorf("PVC already exists but it has not been created by k8run")
+		return fmt.Errorf("PVC %w", ErrNotCreatedByK8run)
 	}
 
 	err = pvcClient.Delete(ctx, params.Name, metav1.DeleteOptions{})
@@ -86,7 +95,7 @@ func DeletePVC(ctx context.Context, clientset kubernetes.Interface, params Delet
 		return fmt.Errorf("failed to delete PVC: %w", err)
 	}
 
-	slog.With("name", params.Name, "namespace", params.Namespace).Info("PVC marked for deletion")
+	logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("PVC marked for deletion")
 	return nil
 }
 
@@ -104,3 +113,13 @@ func GetPVC(ctx context.Context, clientset kubernetes.Interface, params GetParam
 
 	return pvc, nil
 }
+
+// ListPVCs lists the PVCs created by k8run. An empty namespace lists them in every namespace.
+func ListPVCs(ctx context.Context, clientset kubernetes.Interface, params ListParams) ([]corev1.PersistentVolumeClaim, error) {
+	list, err := clientset.CoreV1().PersistentVolumeClaims(params.Namespace).List(ctx, metav1.ListOptions{LabelSelector: params.selector()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PVCs: %w", err)
+	}
+
+	return list.Items, nil
+}
@@ -3,7 +3,8 @@ package k8s
 import (
 	"context"
 	"fmt"
-	"log/slog"
+
+	"github.com/lucasvmiguel/k8run/internal/logging"
 
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
@@ -19,14 +20,21 @@ type CreateOrUpdateServiceParams struct {
 	Port              int32
 	ContainerPort     int32
 	ReleaseIdentifier string
+	// Annotations are set on the resource, eg: its expiry.
+	Annotations map[string]string
 }
 
-// CreateOrUpdateService creates or updates a service in the given namespace.
-func CreateOrUpdateService(ctx context.Context, clientset kubernetes.Interface, params CreateOrUpdateServiceParams) error {
-	service := &corev1.Service{
+// BuildService builds the service object described by the given parameters without sending it to the cluster.
+func BuildService(params CreateOrUpdateServiceParams) *corev1.Service {
+	return &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      params.Name,
-			Namespace: params.Namespace,
+			Name:        params.Name,
+			Namespace:   params.Namespace,
+			Annotations: params.Annotations,
 			Labels: map[string]string{
 				LabelNameCreatedBy:         LabelValueCreatedBy,
 				LabelNameReleaseIdentifier: params.ReleaseIdentifier,
@@ -45,6 +53,11 @@ func CreateOrUpdateService(ctx context.Context, clientset kubernetes.Interface,
 			},
 		},
 	}
+}
+
+// CreateOrUpdateService creates or updates a service in the given namespace.
+func CreateOrUpdateService(ctx context.Context, clientset kubernetes.Interface, params CreateOrUpdateServiceParams) error {
+	service := BuildService(params)
 
 	existingService, err := clientset.CoreV1().Services(params.Namespace).Get(ctx, params.Name, metav1.GetOptions{})
 	if err != nil {
@@ -52,10 +65,10 @@ func CreateOrUpdateService(ctx context.Context, clientset kubernetes.Interface,
 		if err != nil {
 			return fmt.Errorf("failed to create service: %v", err)
 		}
-		slog.With("name", params.Name, "namespace", params.Namespace).Info("Service created")
+		logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Service created")
 	} else {
 		if existingService.Labels[LabelNameCreatedBy] != LabelValueCreatedBy {
-			return fmt.Errorf("service already exists but it has not been created by k8run")
+			return fmt.Errorf("service %w", ErrNotCreatedByK8run)
 		}
 
 		service.ResourceVersion = existingService.ResourceVersion
@@ -63,7 +76,7 @@ func CreateOrUpdateService(ctx context.Context, clientset kubernetes.Interface,
 		if err != nil {
 			return fmt.Errorf("failed to update service: %v", err)
 		}
-		slog.With("name", params.Name, "namespace", params.Namespace).Info("Service updated")
+		logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Service updated")
 	}
 
 	return nil
@@ -88,7 +101,7 @@ func DeleteService(ctx context.Context, clientset kubernetes.Interface, params D
 	}
 
 	if existentService.Labels[LabelNameCreatedBy] != LabelValueCreatedBy {
-		return fmt.Errorf("service already exists but it has not been created by k8run")
+		return fmt.Errorf("service %w", ErrNotCreatedByK8run)
 	}
 
 	err = servicesClient.Delete(ctx, params.Name, metav1.DeleteOptions{})
@@ -96,7 +109,7 @@ func DeleteService(ctx context.Context, clientset kubernetes.Interface, params D
 		return fmt.Errorf("failed to delete service: %w", err)
 	}
 
-	slog.With("name", params.Name, "namespace", params.Namespace).Info("Service marked for deletion")
+	logging.FromContext(ctx).With("name", params.Name, "namespace", params.Namespace).Info("Service marked for deletion")
 	return nil
 }
 
@@ -114,3 +127,13 @@ func GetService(ctx context.Context, clientset kubernetes.Interface, params GetP
 
 	return service, nil
 }
+
+// ListServices lists the services created by k8run. An empty namespace lists them in every namespace.
+func ListServices(ctx context.Context, clientset kubernetes.Interface, params ListParams) ([]corev1.Service, error) {
+	list, err := clientset.CoreV1().Services(params.Namespace).List(ctx, metav1.ListOptions{LabelSelector: params.selector()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	return list.Items, nil
+}
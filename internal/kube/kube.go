@@ -0,0 +1,161 @@
+package kube
+
+import (
+	"cmp"
+	"fmt"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Config represents how to reach the cluster. Its zero value follows kubectl: the files listed in the
+// KUBECONFIG env var are merged (falling back to ~/.kube/config) and, when there is none, the
+// in-cluster config of the pod k8run runs in is used.
+type Config struct {
+	// Kubeconfig is the kubeconfig file to use instead of the KUBECONFIG env var.
+	Kubeconfig string
+	// Context is the kubeconfig context to use instead of the current one.
+	Context string
+	// As is the user to impersonate.
+	As string
+	// AsGroups are the groups to impersonate.
+	AsGroups []string
+	// REST is the config to use instead of a kubeconfig, eg: when k8run is embedded. kubectl can't be given it, so
+	// it uses its own config.
+	REST *rest.Config
+	// CopyTransport is how the code is sent to the pods: 'auto', 'websocket', 'spdy' or 'kubectl'. Defaults to auto.
+	CopyTransport string
+	// CopyCompression is how the code sent to the pods is compressed: 'auto', 'none', 'gzip' or 'zstd'. Defaults to
+	// auto.
+	CopyCompression string
+}
+
+// RESTConfig builds the config used to create clients.
+func (c Config) RESTConfig() (*rest.Config, error) {
+	if c.REST != nil {
+		config := rest.CopyConfig(c.REST)
+		if c.As != "" || len(c.AsGroups) > 0 {
+			config.Impersonate = rest.ImpersonationConfig{UserName: c.As, Groups: c.AsGroups}
+		}
+		return config, nil
+	}
+
+	config, err := c.clientConfig().ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build k8s config: %w", err)
+	}
+
+	// impersonation is set here, as the in-cluster config ignores the kubeconfig overrides
+	if c.As != "" || len(c.AsGroups) > 0 {
+		config.Impersonate = rest.ImpersonationConfig{UserName: c.As, Groups: c.AsGroups}
+	}
+
+	return config, nil
+}
+
+// Clientset builds a k8s clientset.
+func (c Config) Clientset() (kubernetes.Interface, error) {
+	config, err := c.RESTConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create k8s clientset: %w", err)
+	}
+
+	return clientset, nil
+}
+
+// Namespace returns the namespace of the kubeconfig context or, in a pod, the namespace of the pod.
+// It defaults to 'default'.
+func (c Config) Namespace() (string, error) {
+	if c.REST != nil {
+		return "default", nil
+	}
+
+	namespace, _, err := c.clientConfig().Namespace()
+	if clientcmd.IsEmptyConfig(err) {
+		return "default", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the namespace of the k8s config: %w", err)
+	}
+
+	return namespace, nil
+}
+
+// ContextName returns the name of the kubeconfig context in use. It's empty when there is no kubeconfig,
+// eg: when the in-cluster config is used.
+func (c Config) ContextName() (string, error) {
+	if c.Context != "" {
+		return c.Context, nil
+	}
+	if c.REST != nil {
+		return "", nil
+	}
+
+	raw, err := c.clientConfig().RawConfig()
+	if err != nil {
+		return "", fmt.Errorf("failed to read k8s config: %w", err)
+	}
+	return raw.CurrentContext, nil
+}
+
+// User returns the user k8run acts as: the impersonated user or the user of the kubeconfig context. It's empty
+// when there is no kubeconfig, eg: when the in-cluster config is used.
+func (c Config) User() (string, error) {
+	if c.As != "" {
+		return c.As, nil
+	}
+	if c.REST != nil {
+		return cmp.Or(c.REST.Impersonate.UserName, c.REST.Username), nil
+	}
+
+	raw, err := c.clientConfig().RawConfig()
+	if err != nil {
+		return "", fmt.Errorf("failed to read k8s config: %w", err)
+	}
+
+	context, ok := raw.Contexts[cmp.Or(c.Context, raw.CurrentContext)]
+	if !ok {
+		return "", nil
+	}
+	return context.AuthInfo, nil
+}
+
+// KubectlFlags returns the flags passing the same config to kubectl.
+func (c Config) KubectlFlags() []string {
+	flags := []string{}
+	if c.Kubeconfig != "" {
+		flags = append(flags, "--kubeconfig", c.Kubeconfig)
+	}
+	if c.Context != "" {
+		flags = append(flags, "--context", c.Context)
+	}
+	if c.As != "" {
+		flags = append(flags, "--as", c.As)
+	}
+	for _, group := range c.AsGroups {
+		flags = append(flags, "--as-group", group)
+	}
+	return flags
+}
+
+func (c Config) clientConfig() clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = c.Kubeconfig
+
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: c.Context,
+		AuthInfo: clientcmdapi.AuthInfo{
+			Impersonate:       c.As,
+			ImpersonateGroups: c.AsGroups,
+		},
+	}
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+}
@@ -0,0 +1,185 @@
+package kube_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/kube"
+
+	"k8s.io/client-go/rest"
+)
+
+const kubeconfigA = `
+apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev
+  cluster: {server: https://dev.example.com}
+contexts:
+- name: dev
+  context: {cluster: dev, user: dev, namespace: team-a}
+users:
+- name: dev
+  user: {token: dev-token}
+`
+
+const kubeconfigB = `
+apiVersion: v1
+kind: Config
+clusters:
+- name: staging
+  cluster: {server: https://staging.example.com}
+contexts:
+- name: staging
+  context: {cluster: staging, user: staging}
+users:
+- name: staging
+  user: {token: staging-token}
+`
+
+func writeKubeconfigs(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	if err := os.WriteFile(a, []byte(kubeconfigA), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte(kubeconfigB), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return a, b
+}
+
+func TestConfig_MergesKubeconfigEnv(t *testing.T) {
+	a, b := writeKubeconfigs(t)
+	t.Setenv("KUBECONFIG", a+string(os.PathListSeparator)+b)
+
+	config, err := kube.Config{}.RESTConfig()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if config.Host != "https://dev.example.com" {
+		t.Errorf("expected the current context of the first file, got %q", config.Host)
+	}
+
+	context, err := kube.Config{}.ContextName()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if context != "dev" {
+		t.Errorf("expected the current context, got %q", context)
+	}
+
+	namespace, err := kube.Config{}.Namespace()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if namespace != "team-a" {
+		t.Errorf("expected the namespace of the current context, got %q", namespace)
+	}
+
+	// the context of the second file can be selected as both files are merged
+	staging := kube.Config{Context: "staging"}
+	config, err = staging.RESTConfig()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if config.Host != "https://staging.example.com" {
+		t.Errorf("expected the selected context, got %q", config.Host)
+	}
+
+	namespace, err = staging.Namespace()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if namespace != "default" {
+		t.Errorf("expected the default namespace, got %q", namespace)
+	}
+}
+
+func TestConfig_ExplicitKubeconfigAndImpersonation(t *testing.T) {
+	a, b := writeKubeconfigs(t)
+	t.Setenv("KUBECONFIG", a)
+
+	c := kube.Config{Kubeconfig: b, Context: "staging", As: "jane", AsGroups: []string{"developers"}}
+	config, err := c.RESTConfig()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if config.Host != "https://staging.example.com" {
+		t.Errorf("expected the explicit kubeconfig to be used, got %q", config.Host)
+	}
+	if config.Impersonate.UserName != "jane" || !slices.Equal(config.Impersonate.Groups, []string{"developers"}) {
+		t.Errorf("expected impersonation, got %+v", config.Impersonate)
+	}
+
+	want := []string{"--kubeconfig", b, "--context", "staging", "--as", "jane", "--as-group", "developers"}
+	if !slices.Equal(c.KubectlFlags(), want) {
+		t.Errorf("expected kubectl flags %v, got %v", want, c.KubectlFlags())
+	}
+}
+
+func TestConfig_REST(t *testing.T) {
+	a, _ := writeKubeconfigs(t)
+	t.Setenv("KUBECONFIG", a)
+
+	c := kube.Config{REST: &rest.Config{Host: "https://embedded.example.com"}, As: "jane"}
+	config, err := c.RESTConfig()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if config.Host != "https://embedded.example.com" || config.Impersonate.UserName != "jane" {
+		t.Errorf("expected the REST config with impersonation, got %+v", config)
+	}
+	if c.REST.Impersonate.UserName != "" {
+		t.Errorf("expected the given REST config to be left untouched")
+	}
+
+	namespace, err := c.Namespace()
+	if err != nil || namespace != "default" {
+		t.Errorf("expected the default namespace instead of the kubeconfig one, got %q (%v)", namespace, err)
+	}
+	contextName, err := c.ContextName()
+	if err != nil || contextName != "" {
+		t.Errorf("expected no context, got %q (%v)", contextName, err)
+	}
+}
+
+func TestConfig_UnknownContext(t *testing.T) {
+	a, _ := writeKubeconfigs(t)
+
+	if _, err := (kube.Config{Kubeconfig: a, Context: "prod"}).RESTConfig(); err == nil {
+		t.Errorf("expected an error for an unknown context")
+	}
+}
+
+func TestConfig_User(t *testing.T) {
+	a, b := writeKubeconfigs(t)
+	t.Setenv("KUBECONFIG", a+string(os.PathListSeparator)+b)
+
+	tests := []struct {
+		name   string
+		config kube.Config
+		want   string
+	}{
+		{name: "current context", config: kube.Config{}, want: "dev"},
+		{name: "selected context", config: kube.Config{Context: "staging"}, want: "staging"},
+		{name: "impersonated user", config: kube.Config{As: "jane"}, want: "jane"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := tt.config.User()
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if user != tt.want {
+				t.Errorf("expected user %q, got %q", tt.want, user)
+			}
+		})
+	}
+}
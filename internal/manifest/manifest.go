@@ -0,0 +1,158 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// k8runPrefix is the prefix of every label, annotation and env var managed by k8run.
+const (
+	k8runPrefix    = "k8run-"
+	k8runEnvPrefix = "K8RUN_"
+)
+
+// runtimeAnnotationPrefixes are annotations set by the cluster that should never be exported.
+var runtimeAnnotationPrefixes = []string{
+	"deployment.kubernetes.io/",
+	"kubectl.kubernetes.io/",
+	"pv.kubernetes.io/",
+	"volume.beta.kubernetes.io/",
+	"volume.kubernetes.io/",
+}
+
+// Clean strips runtime fields set by the cluster and every k8run-specific label, annotation and env var
+// from the given object, so it can be applied as a plain manifest.
+func Clean(obj runtime.Object) error {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		o.TypeMeta = metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"}
+		cleanObjectMeta(&o.ObjectMeta)
+		o.Spec.Template.Labels = cleanMap(o.Spec.Template.Labels, k8runPrefix)
+		o.Spec.Template.Annotations = cleanMap(o.Spec.Template.Annotations, k8runPrefix)
+		o.Spec.Template.CreationTimestamp = metav1.Time{}
+		for i := range o.Spec.Template.Spec.InitContainers {
+			cleanContainer(&o.Spec.Template.Spec.InitContainers[i])
+		}
+		for i := range o.Spec.Template.Spec.Containers {
+			cleanContainer(&o.Spec.Template.Spec.Containers[i])
+		}
+		o.Status = appsv1.DeploymentStatus{}
+	case *corev1.Service:
+		o.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Service"}
+		cleanObjectMeta(&o.ObjectMeta)
+		o.Spec.ClusterIP = ""
+		o.Spec.ClusterIPs = nil
+		o.Spec.IPFamilies = nil
+		o.Spec.IPFamilyPolicy = nil
+		o.Status = corev1.ServiceStatus{}
+	case *networkingv1.Ingress:
+		o.TypeMeta = metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "Ingress"}
+		cleanObjectMeta(&o.ObjectMeta)
+		o.Status = networkingv1.IngressStatus{}
+	case *corev1.PersistentVolumeClaim:
+		o.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolumeClaim"}
+		cleanObjectMeta(&o.ObjectMeta)
+		o.Finalizers = nil
+		o.Spec.VolumeName = ""
+		o.Spec.StorageClassName = nil
+		o.Status = corev1.PersistentVolumeClaimStatus{}
+	default:
+		return fmt.Errorf("unsupported object type %T", obj)
+	}
+
+	return nil
+}
+
+// ToMap converts an object into its generic map representation, dropping empty status and timestamps.
+func ToMap(obj runtime.Object) (map[string]interface{}, error) {
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert object: %w", err)
+	}
+
+	delete(m, "status")
+	dropNullTimestamps(m)
+
+	return m, nil
+}
+
+// ToYAML marshals an object into YAML, dropping empty status and timestamps.
+func ToYAML(obj runtime.Object) ([]byte, error) {
+	m, err := ToMap(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(m)
+}
+
+func cleanObjectMeta(meta *metav1.ObjectMeta) {
+	meta.UID = ""
+	meta.ResourceVersion = ""
+	meta.Generation = 0
+	meta.CreationTimestamp = metav1.Time{}
+	meta.DeletionTimestamp = nil
+	meta.DeletionGracePeriodSeconds = nil
+	meta.ManagedFields = nil
+	meta.OwnerReferences = nil
+	meta.SelfLink = ""
+	meta.Labels = cleanMap(meta.Labels, k8runPrefix)
+	meta.Annotations = cleanMap(meta.Annotations, append([]string{k8runPrefix}, runtimeAnnotationPrefixes...)...)
+}
+
+func cleanContainer(container *corev1.Container) {
+	env := []corev1.EnvVar{}
+	for _, e := range container.Env {
+		if !strings.HasPrefix(e.Name, k8runEnvPrefix) {
+			env = append(env, e)
+		}
+	}
+	if len(env) == 0 {
+		env = nil
+	}
+	container.Env = env
+}
+
+func cleanMap(m map[string]string, prefixes ...string) map[string]string {
+	cleaned := map[string]string{}
+	for k, v := range m {
+		drop := false
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(k, prefix) {
+				drop = true
+				break
+			}
+		}
+		if !drop {
+			cleaned[k] = v
+		}
+	}
+	if len(cleaned) == 0 {
+		return nil
+	}
+	return cleaned
+}
+
+func dropNullTimestamps(v interface{}) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if k == "creationTimestamp" && child == nil {
+				delete(t, k)
+				continue
+			}
+			dropNullTimestamps(child)
+		}
+	case []interface{}:
+		for _, child := range t {
+			dropNullTimestamps(child)
+		}
+	}
+}
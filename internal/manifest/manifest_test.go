@@ -0,0 +1,90 @@
+package manifest_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/manifest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClean_Deployment(t *testing.T) {
+	deployment := k8s.BuildDeployment(k8s.CreateOrUpdateDeploymentParams{
+		Name:              "test-deployment",
+		Namespace:         "default",
+		Image:             "test-image",
+		CopyTo:            "/app",
+		PVCName:           "test-pvc",
+		InitContainerName: "init-container",
+		ReleaseIdentifier: "test-release",
+	})
+	deployment.ResourceVersion = "123"
+	deployment.UID = "abc"
+	deployment.Annotations = map[string]string{"deployment.kubernetes.io/revision": "3", "team": "core"}
+
+	if err := manifest.Clean(deployment); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if deployment.ResourceVersion != "" || deployment.UID != "" {
+		t.Errorf("expected runtime fields to be stripped, got %q and %q", deployment.ResourceVersion, deployment.UID)
+	}
+	if _, ok := deployment.Labels[k8s.LabelNameCreatedBy]; ok {
+		t.Errorf("expected k8run labels to be stripped, got %v", deployment.Labels)
+	}
+	if deployment.Spec.Template.Labels["app"] != "test-deployment" {
+		t.Errorf("expected app label to be kept, got %v", deployment.Spec.Template.Labels)
+	}
+	if len(deployment.Annotations) != 1 || deployment.Annotations["team"] != "core" {
+		t.Errorf("expected only user annotations to be kept, got %v", deployment.Annotations)
+	}
+	if len(deployment.Spec.Template.Spec.Containers[0].Env) != 0 {
+		t.Errorf("expected k8run env vars to be stripped, got %v", deployment.Spec.Template.Spec.Containers[0].Env)
+	}
+}
+
+func TestClean_Service(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-service"},
+		Spec:       corev1.ServiceSpec{ClusterIP: "10.0.0.1", ClusterIPs: []string{"10.0.0.1"}},
+	}
+
+	if err := manifest.Clean(service); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if service.Spec.ClusterIP != "" || service.Spec.ClusterIPs != nil {
+		t.Errorf("expected cluster IPs to be stripped, got %v", service.Spec)
+	}
+	if service.Kind != "Service" || service.APIVersion != "v1" {
+		t.Errorf("expected type meta to be set, got %v", service.TypeMeta)
+	}
+}
+
+func TestClean_UnsupportedType(t *testing.T) {
+	if err := manifest.Clean(&corev1.ConfigMap{}); err == nil {
+		t.Fatalf("expected error for unsupported type, got nil")
+	}
+}
+
+func TestToYAML(t *testing.T) {
+	service := k8s.BuildService(k8s.CreateOrUpdateServiceParams{Name: "test-service", Namespace: "default", Port: 80})
+	if err := manifest.Clean(service); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	b, err := manifest.ToYAML(service)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	out := string(b)
+	if strings.Contains(out, "creationTimestamp") || strings.Contains(out, "status") {
+		t.Errorf("expected timestamps and status to be dropped, got:\n%s", out)
+	}
+	if !strings.Contains(out, "kind: Service") {
+		t.Errorf("expected kind to be present, got:\n%s", out)
+	}
+}
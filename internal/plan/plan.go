@@ -0,0 +1,327 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/lucasvmiguel/k8run/internal/manifest"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Action is what will happen to a resource.
+type Action string
+
+const (
+	Create    Action = "create"
+	Update    Action = "update"
+	Delete    Action = "delete"
+	Unchanged Action = "unchanged"
+)
+
+// immutableFields are the fields that can't be updated, so changing them means recreating the resource.
+var immutableFields = map[string][]string{
+	"Deployment":            {"spec.selector"},
+	"Service":               {"spec.clusterIP", "spec.clusterIPs"},
+	"PersistentVolumeClaim": {"spec"},
+	"Job":                   {"spec.selector", "spec.template"},
+}
+
+// Field is a field that differs between the live and the desired resource. An empty Old means the field is added.
+type Field struct {
+	Path string `json:"path"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// Change is what will happen to a single resource.
+type Change struct {
+	Kind      string  `json:"kind"`
+	Name      string  `json:"name"`
+	Namespace string  `json:"namespace,omitempty"`
+	Action    Action  `json:"action"`
+	Fields    []Field `json:"fields,omitempty"`
+	// Recreate is set when an immutable field changes, so the resource must be deleted and created again.
+	Recreate bool `json:"recreate,omitempty"`
+	// Restart is set when the pods of the resource will be restarted.
+	Restart bool `json:"restart,omitempty"`
+	// Note explains anything else worth knowing before proceeding.
+	Note string `json:"note,omitempty"`
+}
+
+// Plan is the list of changes a command will make.
+type Plan struct {
+	Changes []Change
+}
+
+// Add adds changes to the plan.
+func (p *Plan) Add(changes ...Change) {
+	p.Changes = append(p.Changes, changes...)
+}
+
+// Merge adds the changes of another plan.
+func (p *Plan) Merge(other *Plan) {
+	if other != nil {
+		p.Add(other.Changes...)
+	}
+}
+
+// Count returns the number of changes with the given action.
+func (p *Plan) Count(action Action) int {
+	n := 0
+	for _, change := range p.Changes {
+		if change.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// Namespaces returns the namespaces of the planned resources, sorted. Unchanged resources are included, as
+// commands may still act on them, eg: replacing the content of a volume. A planned namespace counts as its own namespace.
+func (p *Plan) Namespaces() []string {
+	namespaces := []string{}
+	for _, change := range p.Changes {
+		namespace := change.Namespace
+		if change.Kind == "Namespace" {
+			namespace = change.Name
+		}
+		if namespace != "" && !slices.Contains(namespaces, namespace) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	slices.Sort(namespaces)
+	return namespaces
+}
+
+// HasChanges returns true if any resource will be created, updated or deleted.
+func (p *Plan) HasChanges() bool {
+	return len(p.Changes) > p.Count(Unchanged)
+}
+
+// Write prints the plan in a human readable form.
+func (p *Plan) Write(w io.Writer) {
+	symbols := map[Action]string{Create: "+", Update: "~", Delete: "-", Unchanged: "="}
+
+	fmt.Fprintln(w, "Plan:")
+	for _, change := range p.Changes {
+		symbol := symbols[change.Action]
+		if change.Recreate {
+			symbol = "-/+"
+		}
+
+		flags := []string{}
+		if change.Recreate {
+			flags = append(flags, "recreated")
+		}
+		if change.Restart {
+			flags = append(flags, "restarts pods")
+		}
+		if change.Note != "" {
+			flags = append(flags, change.Note)
+		}
+
+		name := change.Name
+		if change.Namespace != "" {
+			name = change.Namespace + "/" + change.Name
+		}
+		line := fmt.Sprintf("  %-3s %-9s %s %s", symbol, change.Action, change.Kind, name)
+		if len(flags) > 0 {
+			line += fmt.Sprintf(" (%s)", strings.Join(flags, ", "))
+		}
+		fmt.Fprintln(w, line)
+
+		for _, field := range change.Fields {
+			switch {
+			case field.Old == "":
+				fmt.Fprintf(w, "        + %s: %s\n", field.Path, field.New)
+			case field.New == "":
+				fmt.Fprintf(w, "        - %s: %s\n", field.Path, field.Old)
+			default:
+				fmt.Fprintf(w, "        ~ %s: %s -> %s\n", field.Path, field.Old, field.New)
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "%d to create, %d to update, %d to delete, %d unchanged.\n",
+		p.Count(Create), p.Count(Update), p.Count(Delete), p.Count(Unchanged))
+}
+
+// WriteJSON prints the plan as JSON, with the number of changes of each action.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	changes := p.Changes
+	if changes == nil {
+		changes = []Change{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Changes   []Change `json:"changes"`
+		Create    int      `json:"create"`
+		Update    int      `json:"update"`
+		Delete    int      `json:"delete"`
+		Unchanged int      `json:"unchanged"`
+	}{changes, p.Count(Create), p.Count(Update), p.Count(Delete), p.Count(Unchanged)})
+}
+
+// Compare compares the desired resource with the live one, which is nil when it doesn't exist yet.
+// Both are cleaned of runtime and k8run fields first. Fields only set on the live resource are
+// considered defaults set by the cluster and ignored, except for lists, which are compared entry by entry.
+func Compare(desired, live runtime.Object) (Change, error) {
+	change, err := newChange(desired)
+	if err != nil {
+		return change, err
+	}
+
+	if live == nil {
+		change.Action = Create
+		return change, nil
+	}
+
+	desiredMap, err := cleanMap(desired)
+	if err != nil {
+		return change, err
+	}
+	liveMap, err := cleanMap(live)
+	if err != nil {
+		return change, err
+	}
+
+	change.Fields = diff("", liveMap, desiredMap)
+	change.Action = Unchanged
+	if len(change.Fields) > 0 {
+		change.Action = Update
+	}
+
+	for _, field := range change.Fields {
+		for _, immutable := range immutableFields[change.Kind] {
+			if field.Path == immutable || strings.HasPrefix(field.Path, immutable+".") || strings.HasPrefix(field.Path, immutable+"[") {
+				change.Recreate = true
+			}
+		}
+	}
+
+	return change, nil
+}
+
+// Remove returns the change deleting the given live resource. The kind must be given, as live objects don't carry it.
+func Remove(kind string, live runtime.Object) (Change, error) {
+	accessor, err := meta.Accessor(live)
+	if err != nil {
+		return Change{}, fmt.Errorf("failed to read object metadata: %w", err)
+	}
+
+	return Change{
+		Kind:      kind,
+		Name:      accessor.GetName(),
+		Namespace: accessor.GetNamespace(),
+		Action:    Delete,
+	}, nil
+}
+
+func newChange(desired runtime.Object) (Change, error) {
+	accessor, err := meta.Accessor(desired)
+	if err != nil {
+		return Change{}, fmt.Errorf("failed to read object metadata: %w", err)
+	}
+
+	return Change{
+		Kind:      desired.GetObjectKind().GroupVersionKind().Kind,
+		Name:      accessor.GetName(),
+		Namespace: accessor.GetNamespace(),
+	}, nil
+}
+
+func cleanMap(obj runtime.Object) (map[string]interface{}, error) {
+	obj = obj.DeepCopyObject()
+	if err := manifest.Clean(obj); err != nil {
+		return nil, err
+	}
+	return manifest.ToMap(obj)
+}
+
+// diff walks the desired value and returns the fields that differ from the live value.
+func diff(path string, live, desired interface{}) []Field {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			return changed(path, live, desired)
+		}
+
+		fields := []Field{}
+		keys := make([]string, 0, len(d))
+		for k := range d {
+			keys = append(keys, k)
+		}
+		// lists are always set in full, so a list missing from the desired value has been removed
+		for k, v := range l {
+			if _, isList := v.([]interface{}); isList && d[k] == nil {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		keys = slices.Compact(keys)
+		for _, k := range keys {
+			fields = append(fields, diff(join(path, k), l[k], d[k])...)
+		}
+		return fields
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok {
+			return changed(path, live, desired)
+		}
+
+		fields := []Field{}
+		for i := range max(len(l), len(d)) {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(d):
+				fields = append(fields, Field{Path: itemPath, Old: format(l[i])})
+			case i >= len(l):
+				fields = append(fields, Field{Path: itemPath, New: format(d[i])})
+			default:
+				fields = append(fields, diff(itemPath, l[i], d[i])...)
+			}
+		}
+		return fields
+	default:
+		return changed(path, live, desired)
+	}
+}
+
+func changed(path string, live, desired interface{}) []Field {
+	if format(live) == format(desired) {
+		return nil
+	}
+	if live == nil {
+		return []Field{{Path: path, New: format(desired)}}
+	}
+	if desired == nil {
+		return []Field{{Path: path, Old: format(live)}}
+	}
+	return []Field{{Path: path, Old: format(live), New: format(desired)}}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func format(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,167 @@
+package plan_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/internal/plan"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func deploymentParams() k8s.CreateOrUpdateDeploymentParams {
+	return k8s.CreateOrUpdateDeploymentParams{
+		Name:              "test",
+		Namespace:         "default",
+		Image:             "node:20",
+		CopyTo:            "/app",
+		Replicas:          1,
+		PVCName:           "test-pvc",
+		InitContainerName: "init-container",
+		ReleaseIdentifier: "old-release",
+		Env:               map[string]string{"A": "1"},
+	}
+}
+
+func TestCompare_Create(t *testing.T) {
+	change, err := plan.Compare(k8s.BuildDeployment(deploymentParams()), nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if change.Action != plan.Create || change.Kind != "Deployment" || change.Name != "test" {
+		t.Errorf("expected the deployment to be created, got %+v", change)
+	}
+}
+
+func TestCompare_Update(t *testing.T) {
+	live := k8s.BuildDeployment(deploymentParams())
+	// defaults set by the cluster must not show up in the diff
+	live.ResourceVersion = "42"
+	live.Spec.Template.Spec.Containers[0].ImagePullPolicy = corev1.PullIfNotPresent
+	live.Spec.Template.Spec.DNSPolicy = corev1.DNSClusterFirst
+
+	params := deploymentParams()
+	params.Image = "node:22"
+	params.Replicas = 3
+	params.ReleaseIdentifier = "new-release"
+	params.Env = map[string]string{}
+
+	change, err := plan.Compare(k8s.BuildDeployment(params), live)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if change.Action != plan.Update || change.Recreate {
+		t.Errorf("expected the deployment to be updated in place, got %+v", change)
+	}
+
+	want := []plan.Field{
+		{Path: "spec.replicas", Old: "1", New: "3"},
+		{Path: "spec.template.spec.containers[0].env", Old: `[{"name":"A","value":"1"}]`},
+		{Path: "spec.template.spec.containers[0].image", Old: `"node:20"`, New: `"node:22"`},
+	}
+	if len(change.Fields) != len(want) {
+		t.Fatalf("expected fields %v, got %v", want, change.Fields)
+	}
+	for i := range want {
+		if change.Fields[i] != want[i] {
+			t.Errorf("expected field %v, got %v", want[i], change.Fields[i])
+		}
+	}
+}
+
+func TestCompare_Unchanged(t *testing.T) {
+	live := k8s.BuildDeployment(deploymentParams())
+	params := deploymentParams()
+	params.ReleaseIdentifier = "new-release"
+
+	change, err := plan.Compare(k8s.BuildDeployment(params), live)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if change.Action != plan.Unchanged {
+		t.Errorf("expected the deployment to be unchanged, got %+v", change)
+	}
+}
+
+func TestCompare_Recreate(t *testing.T) {
+	live := k8s.BuildDeployment(deploymentParams())
+	live.Spec.Selector.MatchLabels["tier"] = "web"
+
+	change, err := plan.Compare(k8s.BuildDeployment(deploymentParams()), live)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// fields only set on the live object are ignored, so the selector is compared the other way around
+	if change.Recreate {
+		t.Errorf("expected extra live labels to be ignored, got %+v", change)
+	}
+
+	desired := k8s.BuildDeployment(deploymentParams())
+	desired.Spec.Selector.MatchLabels["app"] = "other"
+	change, err = plan.Compare(desired, k8s.BuildDeployment(deploymentParams()))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !change.Recreate {
+		t.Errorf("expected a selector change to recreate the deployment, got %+v", change)
+	}
+}
+
+func TestPlan_Write(t *testing.T) {
+	p := &plan.Plan{}
+	remove, err := plan.Remove("Service", &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	p.Add(
+		plan.Change{Kind: "Deployment", Name: "test", Namespace: "default", Action: plan.Update, Restart: true,
+			Fields: []plan.Field{{Path: "spec.replicas", Old: "1", New: "3"}}},
+		remove,
+	)
+
+	b := &bytes.Buffer{}
+	p.Write(b)
+
+	for _, want := range []string{
+		"~   update    Deployment default/test (restarts pods)",
+		"~ spec.replicas: 1 -> 3",
+		"-   delete    Service default/test",
+		"0 to create, 1 to update, 1 to delete, 0 unchanged.",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, b.String())
+		}
+	}
+	if !p.HasChanges() {
+		t.Errorf("expected the plan to have changes")
+	}
+}
+
+func TestPlan_WriteJSON(t *testing.T) {
+	p := &plan.Plan{}
+	p.Add(plan.Change{Kind: "Service", Name: "test", Namespace: "default", Action: plan.Create})
+
+	b := &bytes.Buffer{}
+	if err := p.WriteJSON(b); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var got struct {
+		Changes []plan.Change `json:"changes"`
+		Create  int           `json:"create"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("expected valid JSON, got %v", err)
+	}
+	if got.Create != 1 || len(got.Changes) != 1 || got.Changes[0].Action != plan.Create || got.Changes[0].Kind != "Service" {
+		t.Errorf("unexpected JSON plan:\n%s", b)
+	}
+}
@@ -0,0 +1,76 @@
+package procfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Web is the process type that receives HTTP traffic.
+	Web = "web"
+	// Release is the process type run once before every rollout. eg: database migrations
+	Release = "release"
+)
+
+var lineRegexp = regexp.MustCompile(`^([A-Za-z0-9_-]+):\s*(.+)$`)
+
+// Process represents a process type of a Procfile.
+type Process struct {
+	Name    string
+	Command string
+}
+
+// Load reads and parses a Procfile.
+func Load(path string) ([]Process, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Procfile: %w", err)
+	}
+	defer f.Close()
+
+	processes, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Procfile %s: %w", path, err)
+	}
+	return processes, nil
+}
+
+// Parse parses the 'name: command' lines of a Procfile, keeping their order. Blank lines and comments are ignored.
+func Parse(r io.Reader) ([]Process, error) {
+	processes := []Process{}
+	seen := map[string]bool{}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		match := lineRegexp.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("line %d: expected 'name: command', got %q", n, line)
+		}
+
+		name, command := match[1], strings.TrimSpace(match[2])
+		if seen[name] {
+			return nil, fmt.Errorf("line %d: process %q is declared more than once", n, name)
+		}
+		seen[name] = true
+
+		processes = append(processes, Process{Name: name, Command: command})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(processes) == 0 {
+		return nil, fmt.Errorf("no process declared")
+	}
+
+	return processes, nil
+}
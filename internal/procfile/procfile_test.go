@@ -0,0 +1,51 @@
+package procfile_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/internal/procfile"
+)
+
+func TestParse(t *testing.T) {
+	processes, err := procfile.Parse(strings.NewReader(`
+# processes
+web: bundle exec puma -p $PORT
+worker:   bundle exec sidekiq
+
+release: rake db:migrate
+`))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	want := []procfile.Process{
+		{Name: "web", Command: "bundle exec puma -p $PORT"},
+		{Name: "worker", Command: "bundle exec sidekiq"},
+		{Name: "release", Command: "rake db:migrate"},
+	}
+	if !slices.Equal(processes, want) {
+		t.Errorf("expected %v, got %v", want, processes)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		procfile string
+	}{
+		{name: "empty", procfile: "# nothing\n"},
+		{name: "missing command", procfile: "web:\n"},
+		{name: "invalid name", procfile: "web server: puma\n"},
+		{name: "duplicated process", procfile: "web: puma\nweb: rails s\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := procfile.Parse(strings.NewReader(tt.procfile)); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
@@ -3,13 +3,21 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
+	"runtime"
+	"slices"
 	"strings"
 	"time"
 
-	"github.com/lucasvmiguel/k8run/internal/command"
+	"github.com/lucasvmiguel/k8run/internal/config"
+	"github.com/lucasvmiguel/k8run/internal/guard"
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+	"github.com/lucasvmiguel/k8run/pkg/k8run"
 	"github.com/urfave/cli/v3"
 )
 
@@ -18,6 +26,46 @@ func main() {
 		Name:    "k8run",
 		Usage:   "k8run is a CLI tool designed to quickly prototype Kubernetes deployments, services, and ingresses. It simplifies the process of setting up a working Kubernetes environment for development and testing.",
 		Version: "0.1.0",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "kubeconfig",
+				Usage:    "kubeconfig file to be used. eg: '~/.kube/config' (default: the files in $KUBECONFIG, '~/.kube/config' or the in-cluster config)",
+				Required: false,
+			},
+			&cli.StringFlag{
+				Name:     "context",
+				Usage:    "kubeconfig context to be used. eg: 'staging' (default: the current context)",
+				Required: false,
+			},
+			&cli.StringFlag{
+				Name:     "as",
+				Usage:    "user to impersonate. eg: 'jane@example.com'",
+				Required: false,
+			},
+			&cli.StringSliceFlag{
+				Name:     "as-group",
+				Usage:    "group to impersonate, can be repeated. eg: 'developers'",
+				Required: false,
+			},
+			&cli.StringFlag{
+				Name:     "owner",
+				Usage:    "who deploys or destroys apps. eg: 'jane@example.com' (default: the git email, or the kubeconfig user, which teammates sharing a kubeconfig share too)",
+				Sources:  cli.EnvVars("K8RUN_OWNER"),
+				Required: false,
+			},
+			&cli.StringFlag{
+				Name:     "copy-compression",
+				Usage:    "how the code sent to the pods is compressed: 'auto' (the best the init container can decompress), 'none', 'gzip' or 'zstd'",
+				Value:    "auto",
+				Required: false,
+			},
+			&cli.StringFlag{
+				Name:     "copy-transport",
+				Usage:    "how the code is sent to the pods: 'auto' (WebSocket, falling back to SPDY), 'websocket', 'spdy' or 'kubectl'",
+				Value:    "auto",
+				Required: false,
+			},
+		},
 		Commands: []*cli.Command{
 			{
 				Name:      "destroy",
@@ -26,8 +74,7 @@ func main() {
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     "namespace",
-						Usage:    "namespace to be used. eg: 'default'",
-						Value:    "default",
+						Usage:    "namespace to be used. eg: 'default' (default: the namespace of the kubeconfig context)",
 						Required: false,
 					},
 					&cli.DurationFlag{
@@ -36,6 +83,32 @@ func main() {
 						Required: false,
 						Value:    time.Minute,
 					},
+					&cli.BoolFlag{
+						Name:     "isolated",
+						Usage:    "destroys an app deployed with '--isolated', including its namespace",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "preview",
+						Usage:    "destroys the preview app of the git branch of the current folder, prefixed by <name> if given",
+						Required: false,
+					},
+					&cli.StringFlag{
+						Name:     "preview-branch",
+						Usage:    "branch the preview app is named after. eg: \"$GITHUB_HEAD_REF\" (default: the git branch of the current folder)",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "wait-for-lock",
+						Usage:    "waits for other deployments or destroys of the same app to finish, instead of failing",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "takeover",
+						Usage:    "destroys the app even if someone else deployed it",
+						Required: false,
+					},
+					outputFlag("format of the final result. eg: 'text' or 'json'"),
 					&cli.BoolFlag{
 						Name:     "yes",
 						Aliases:  []string{"y"},
@@ -44,93 +117,538 @@ func main() {
 					},
 				},
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					fmt.Println()
-					if !cmd.Bool("yes") && !confirm("Are you sure you want to proceed? (yes/no)") {
-						fmt.Println("Operation aborted.")
-						return nil
+					json, err := jsonOutput(cmd)
+					if err != nil {
+						return finish(cmd, json, nil, err)
 					}
-					fmt.Println()
 
-					c := command.NewDestroyCommand(command.NewDestroyCommandParams{
-						Name:      cmd.Args().First(),
-						Namespace: cmd.String("namespace"),
-						Timeout:   cmd.Duration("timeout"),
+					fmt.Fprintln(humanOutput(cmd))
+					result, err := newClient(cmd).Destroy(ctx, k8run.DestroyOptions{
+						Name:          cmd.Args().First(),
+						Namespace:     cmd.String("namespace"),
+						Timeout:       cmd.Duration("timeout"),
+						Isolated:      cmd.Bool("isolated"),
+						Takeover:      cmd.Bool("takeover"),
+						WaitForLock:   cmd.Bool("wait-for-lock"),
+						Preview:       cmd.Bool("preview"),
+						PreviewBranch: cmd.String("preview-branch"),
 					})
+					return finish(cmd, json, result, err)
+				},
+			},
+			{
+				Name:      "deployment",
+				Usage:     "Creates a deployment and dependending on the flags, a service and ingress",
+				ArgsUsage: "<name>",
+				Flags: append(deploymentFlags(),
+					autoFlag(),
+					&cli.BoolFlag{
+						Name:     "wait-for-lock",
+						Usage:    "waits for other deployments or destroys of the same app to finish, instead of failing",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "takeover",
+						Usage:    "replaces the app even if someone else deployed it",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "yes",
+						Aliases:  []string{"y"},
+						Usage:    "skips the confirmation",
+						Required: false,
+					},
+					outputFlag("format of the final result. eg: 'text' or 'json'"),
+				),
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					json, err := jsonOutput(cmd)
+					if err != nil {
+						return finish(cmd, json, nil, err)
+					}
+					options, err := deployOptions(cmd)
+					if err != nil {
+						return finish(cmd, json, nil, err)
+					}
+
+					fmt.Fprintln(humanOutput(cmd))
+					result, err := newClient(cmd).Deploy(ctx, options)
+					return finish(cmd, json, result, err)
+				},
+			},
+			{
+				Name:      "diff",
+				Usage:     "Shows the changes the 'deployment' command would make with the same flags, without making them",
+				ArgsUsage: "<name>",
+				Flags: append(deploymentFlags(),
+					autoFlag(),
+					&cli.BoolFlag{
+						Name:     "takeover",
+						Usage:    "shows the changes even if someone else deployed the app",
+						Required: false,
+					},
+					outputFlag("format of the changes. eg: 'text' or 'json'"),
+				),
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					options, err := deployOptions(cmd)
+					if err != nil {
+						return err
+					}
+					json, err := jsonOutput(cmd)
+					if err != nil {
+						return err
+					}
 
-					if err := c.Validate(); err != nil {
+					changes, err := newClient(cmd).Diff(ctx, options)
+					if err != nil {
 						return err
 					}
+					if json {
+						return changes.WriteJSON(os.Stdout)
+					}
+					changes.Write(os.Stdout)
 
-					return c.Run(ctx)
+					return nil
 				},
 			},
 			{
-				Name:      "deployment",
-				Usage:     "Creates a deployment and dependending on the flags, a service and ingress",
-				ArgsUsage: "<name>",
+				Name:      "init",
+				Usage:     "Writes a k8run.yaml for the app of a folder, with the image, entrypoint and port inferred from its files",
+				ArgsUsage: "[name]",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:     "entrypoint",
-						Usage:    "entrypoint of the container. eg: 'node index.js'",
-						Required: true,
+						Name:     "copy",
+						Usage:    "folder of the app. eg: './web'",
+						Value:    ".",
+						Required: false,
 					},
 					&cli.StringFlag{
-						Name:     "image",
-						Usage:    "image to be used. eg: 'node:14'",
-						Required: true,
+						Name:     "file",
+						Aliases:  []string{"f"},
+						Usage:    "config file written. eg: './k8run.yaml'",
+						Value:    config.DefaultFile,
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "force",
+						Usage:    "overwrites the config file if it exists",
+						Required: false,
 					},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					return newClient(cmd).Init(ctx, k8run.InitOptions{
+						Name:  cmd.Args().First(),
+						Copy:  cmd.String("copy"),
+						File:  cmd.String("file"),
+						Force: cmd.Bool("force"),
+					})
+				},
+			},
+			{
+				Name:  "up",
+				Usage: "Deploys every app described by a k8run.yaml file, respecting their dependencies",
+				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:     "copy",
-						Usage:    "file or folder to be copied to the container. eg: '/Users/me/my_local_folder_to_copy'",
+						Name:     "file",
+						Aliases:  []string{"f"},
+						Usage:    "config file describing the apps. eg: './k8run.yaml'",
+						Value:    config.DefaultFile,
+						Required: false,
+					},
+					&cli.DurationFlag{
+						Name:     "timeout",
+						Usage:    "timeout for each app that doesn't declare its own. eg: 30s",
+						Required: false,
+						Value:    time.Minute,
+					},
+					&cli.BoolFlag{
+						Name:     "wait-for-lock",
+						Usage:    "waits for other deployments or destroys of the same app to finish, instead of failing",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "takeover",
+						Usage:    "replaces the apps even if someone else deployed them",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "yes",
+						Aliases:  []string{"y"},
+						Usage:    "skips the confirmation",
+						Required: false,
+					},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					fmt.Println()
+					return unlessAborted(newClient(cmd).Up(ctx, k8run.UpOptions{
+						File:        cmd.String("file"),
+						Timeout:     cmd.Duration("timeout"),
+						Takeover:    cmd.Bool("takeover"),
+						WaitForLock: cmd.Bool("wait-for-lock"),
+					}))
+				},
+			},
+			{
+				Name:  "down",
+				Usage: "Destroys every app described by a k8run.yaml file, destroying dependents first",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "file",
+						Aliases:  []string{"f"},
+						Usage:    "config file describing the apps. eg: './k8run.yaml'",
+						Value:    config.DefaultFile,
+						Required: false,
+					},
+					&cli.DurationFlag{
+						Name:     "timeout",
+						Usage:    "timeout for each app that doesn't declare its own. eg: 30s",
+						Required: false,
+						Value:    time.Minute,
+					},
+					&cli.BoolFlag{
+						Name:     "wait-for-lock",
+						Usage:    "waits for other deployments or destroys of the same app to finish, instead of failing",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "takeover",
+						Usage:    "destroys the apps even if someone else deployed them",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "yes",
+						Aliases:  []string{"y"},
+						Usage:    "skips the confirmation",
+						Required: false,
+					},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					fmt.Println()
+					return unlessAborted(newClient(cmd).Down(ctx, k8run.UpOptions{
+						File:        cmd.String("file"),
+						Timeout:     cmd.Duration("timeout"),
+						Takeover:    cmd.Bool("takeover"),
+						WaitForLock: cmd.Bool("wait-for-lock"),
+					}))
+				},
+			},
+			{
+				Name:      "procfile",
+				Usage:     "Deploys every process of a Procfile from a single copy of the code. 'web' gets the service and ingress, and 'release' runs before the rollout",
+				ArgsUsage: "<name>",
+				Flags: append(procfileFlags(),
+					&cli.StringFlag{
+						Name:     "procfile",
+						Usage:    "Procfile describing the processes. eg: './Procfile' (default: the Procfile inside '--copy')",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "wait-for-lock",
+						Usage:    "waits for other deployments or destroys of the same app to finish, instead of failing",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "takeover",
+						Usage:    "replaces the app even if someone else deployed it",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "yes",
+						Aliases:  []string{"y"},
+						Usage:    "skips the confirmation",
+						Required: false,
+					},
+				),
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					options, err := deployOptions(cmd)
+					if err != nil {
+						return err
+					}
+
+					fmt.Println()
+					return unlessAborted(newClient(cmd).DeployProcfile(ctx, k8run.ProcfileOptions{
+						Deploy:   options,
+						Procfile: cmd.String("procfile"),
+					}))
+				},
+			},
+			{
+				Name:      "build",
+				Usage:     "Builds the image of the '--copy' folder from its Dockerfile in the cluster, pushes it to the registry and deploys it",
+				ArgsUsage: "<name>",
+				Flags: append(buildFlags(),
+					&cli.StringFlag{
+						Name:     "dockerfile",
+						Usage:    "path of the Dockerfile, relative to the '--copy' folder (default: 'Dockerfile')",
+						Required: false,
+					},
+					&cli.StringFlag{
+						Name:     "registry",
+						Usage:    "registry the image is pushed to, as '<registry>/<name>'. eg: 'ghcr.io/org'",
+						Sources:  cli.EnvVars("K8RUN_REGISTRY"),
 						Required: true,
 					},
+					&cli.StringFlag{
+						Name:     "registry-secret",
+						Usage:    "docker config secret ('kubernetes.io/dockerconfigjson') the image is pushed and pulled with",
+						Sources:  cli.EnvVars("K8RUN_REGISTRY_SECRET"),
+						Required: false,
+					},
 					&cli.BoolFlag{
-						Name:     "service",
-						Usage:    "if service will be created",
-						Value:    false,
+						Name:     "wait-for-lock",
+						Usage:    "waits for other deployments or destroys of the same app to finish, instead of failing",
 						Required: false,
 					},
 					&cli.BoolFlag{
-						Name:     "ingress",
-						Usage:    "if ingress will be created",
-						Value:    false,
+						Name:     "takeover",
+						Usage:    "replaces the app even if someone else deployed it",
 						Required: false,
 					},
-					&cli.IntFlag{
-						Name:     "container-port",
-						Usage:    "port that the container is listening to",
+					&cli.BoolFlag{
+						Name:     "yes",
+						Aliases:  []string{"y"},
+						Usage:    "skips the confirmation",
 						Required: false,
 					},
-					&cli.IntFlag{
-						Name:     "port",
-						Usage:    "port that the service will be listening to",
+					outputFlag("format of the final result. eg: 'text' or 'json'"),
+				),
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					json, err := jsonOutput(cmd)
+					if err != nil {
+						return finish(cmd, json, nil, err)
+					}
+
+					options, err := deployOptions(cmd)
+					if err != nil {
+						return finish(cmd, json, nil, err)
+					}
+
+					fmt.Fprintln(humanOutput(cmd))
+					result, err := newClient(cmd).Build(ctx, k8run.BuildOptions{
+						Deploy:         options,
+						Registry:       cmd.String("registry"),
+						RegistrySecret: cmd.String("registry-secret"),
+						Dockerfile:     cmd.String("dockerfile"),
+						Output:         humanOutput(cmd),
+					})
+					return finish(cmd, json, result, err)
+				},
+			},
+			{
+				Name:  "compose",
+				Usage: "Translates the services of a docker-compose file into k8run deployments",
+				Commands: []*cli.Command{
+					composeCommand(false),
+					composeCommand(true),
+				},
+			},
+			{
+				Name:      "export",
+				Usage:     "Exports a deployment and its resources as plain YAML, a Kustomize base or a Helm chart",
+				ArgsUsage: "<name>",
+				Flags: append(deploymentFlags(),
+					&cli.StringFlag{
+						Name:     "format",
+						Usage:    "format of the exported project. eg: 'yaml', 'kustomize' or 'helm'",
+						Value:    "yaml",
 						Required: false,
 					},
 					&cli.StringFlag{
-						Name:     "ingress-class",
-						Usage:    "ingress class to be used. eg: 'nginx'",
+						Name:     "out",
+						Usage:    "folder where the exported project will be written. eg: './deploy'",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:     "dockerfile",
+						Usage:    "generates a Dockerfile baking the '--copy' content into an image instead of using an init container and PVC",
+						Required: false,
+					},
+					&cli.StringFlag{
+						Name:     "dockerfile-image",
+						Usage:    "image built from the generated Dockerfile. eg: 'registry.example.com/foobar:1.0.0' (default: '<name>:latest')",
+						Required: false,
+					},
+				),
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					var render *k8run.DeployOptions
+					if cmd.IsSet("image") {
+						options, err := deployOptions(cmd)
+						if err != nil {
+							return err
+						}
+						render = &options
+					}
+
+					return newClient(cmd).Export(ctx, k8run.ExportOptions{
+						Name:            cmd.Args().First(),
+						Namespace:       cmd.String("namespace"),
+						Format:          cmd.String("format"),
+						Out:             cmd.String("out"),
+						Dockerfile:      cmd.Bool("dockerfile"),
+						DockerfileImage: cmd.String("dockerfile-image"),
+						Copy:            firstCopy(cmd),
+						Timeout:         cmd.Duration("timeout"),
+						Render:          render,
+					})
+				},
+			},
+			{
+				Name:  "list",
+				Usage: "Lists the apps deployed by k8run, with their owner",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "namespace",
+						Usage:    "namespace to be listed. eg: 'default' (default: the namespace of the kubeconfig context)",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "all-namespaces",
+						Aliases:  []string{"A"},
+						Usage:    "lists the apps of every namespace",
+						Required: false,
+					},
+					&cli.DurationFlag{
+						Name:     "timeout",
+						Usage:    "timeout for listing the apps. eg: 30s",
 						Required: false,
+						Value:    30 * time.Second,
 					},
+					outputFlag("format of the apps. eg: 'text' or 'json'"),
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					json, err := jsonOutput(cmd)
+					if err != nil {
+						return err
+					}
+
+					apps, err := newClient(cmd).List(ctx, k8run.ListOptions{
+						Namespace:     cmd.String("namespace"),
+						AllNamespaces: cmd.Bool("all-namespaces"),
+						Timeout:       cmd.Duration("timeout"),
+					})
+					if err != nil {
+						return err
+					}
+					if json {
+						return k8run.WriteAppsJSON(os.Stdout, apps)
+					}
+					k8run.WriteApps(os.Stdout, apps)
+
+					return nil
+				},
+			},
+			{
+				Name:      "status",
+				Usage:     "Shows the status of an app deployed by k8run: owner, replicas, expiry, service, ingress and pods",
+				ArgsUsage: "<name>",
+				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:     "ingress-host",
-						Usage:    "ingress host to be used. eg: 'foo.myapp.com'",
+						Name:     "namespace",
+						Usage:    "namespace to be used. eg: 'default' (default: the namespace of the kubeconfig context)",
 						Required: false,
 					},
+					&cli.BoolFlag{
+						Name:     "isolated",
+						Usage:    "shows an app deployed with '--isolated'",
+						Required: false,
+					},
+					&cli.DurationFlag{
+						Name:     "timeout",
+						Usage:    "timeout for reading the status. eg: 30s",
+						Required: false,
+						Value:    30 * time.Second,
+					},
+					outputFlag("format of the status. eg: 'text' or 'json'"),
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					json, err := jsonOutput(cmd)
+					if err != nil {
+						return err
+					}
+
+					status, err := newClient(cmd).Status(ctx, k8run.StatusOptions{
+						Name:      cmd.Args().First(),
+						Namespace: cmd.String("namespace"),
+						Isolated:  cmd.Bool("isolated"),
+						Timeout:   cmd.Duration("timeout"),
+					})
+					if err != nil {
+						return err
+					}
+					if json {
+						return status.WriteJSON(os.Stdout)
+					}
+					status.Write(os.Stdout)
+
+					return nil
+				},
+			},
+			{
+				Name:      "logs",
+				Usage:     "Streams the logs of the pods of an app deployed by k8run",
+				ArgsUsage: "<name>",
+				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     "namespace",
-						Usage:    "namespace to be used. eg: 'default'",
-						Value:    "default",
+						Usage:    "namespace to be used. eg: 'default' (default: the namespace of the kubeconfig context)",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "isolated",
+						Usage:    "streams the logs of an app deployed with '--isolated'",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "follow",
+						Aliases:  []string{"f"},
+						Usage:    "keeps streaming new logs",
+						Required: false,
+					},
+					&cli.DurationFlag{
+						Name:     "since",
+						Usage:    "only streams the logs newer than this. eg: 10m (default: all of them)",
 						Required: false,
 					},
 					&cli.IntFlag{
-						Name:     "replicas",
-						Value:    1,
-						Usage:    "number of replicas. eg: 3",
+						Name:     "tail",
+						Usage:    "only streams this number of lines from the end of the logs of each pod. eg: 100 (default: all of them)",
+						Required: false,
+					},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					return newClient(cmd).Logs(ctx, k8run.LogsOptions{
+						Name:      cmd.Args().First(),
+						Namespace: cmd.String("namespace"),
+						Isolated:  cmd.Bool("isolated"),
+						Follow:    cmd.Bool("follow"),
+						Since:     cmd.Duration("since"),
+						Tail:      cmd.Int("tail"),
+					}, os.Stdout)
+				},
+			},
+			{
+				Name:  "gc",
+				Usage: "Destroys the apps whose '--ttl' expired and the k8run resources left behind by apps that no longer exist",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "namespace",
+						Usage:    "namespace to be collected. eg: 'default' (default: the namespace of the kubeconfig context)",
+						Required: false,
+					},
+					&cli.BoolFlag{
+						Name:     "all-namespaces",
+						Aliases:  []string{"A"},
+						Usage:    "collects in every namespace",
 						Required: false,
 					},
+					&cli.BoolFlag{
+						Name:     "dry-run",
+						Usage:    "shows what would be destroyed, without destroying it",
+						Required: false,
+					},
+					outputFlag("format of the '--dry-run' changes. eg: 'text' or 'json'"),
 					&cli.DurationFlag{
 						Name:     "timeout",
-						Usage:    "timeout for the deployment. eg: 30s",
+						Usage:    "timeout for each app. eg: 30s",
 						Required: false,
 						Value:    time.Minute,
 					},
@@ -141,56 +659,305 @@ func main() {
 						Required: false,
 					},
 				},
+				Commands: []*cli.Command{
+					gcInstallCommand(false),
+					gcInstallCommand(true),
+				},
 				Action: func(ctx context.Context, cmd *cli.Command) error {
-					fmt.Println()
-					if !cmd.Bool("yes") && !confirm("Are you sure you want to proceed? (yes/no)") {
-						fmt.Println("Operation aborted.")
+					json, err := jsonOutput(cmd)
+					if err != nil {
+						return err
+					}
+
+					options := k8run.GCOptions{
+						Namespace:     cmd.String("namespace"),
+						AllNamespaces: cmd.Bool("all-namespaces"),
+						Timeout:       cmd.Duration("timeout"),
+					}
+					if cmd.Bool("dry-run") {
+						changes, err := newClient(cmd).GCPlan(ctx, options)
+						if err != nil {
+							return err
+						}
+						if json {
+							return changes.WriteJSON(os.Stdout)
+						}
+						changes.Write(os.Stdout)
 						return nil
 					}
+
 					fmt.Println()
+					return unlessAborted(newClient(cmd).GC(ctx, options))
+				},
+			},
+			{
+				Name:  "doctor",
+				Usage: "Checks whether the cluster is ready for k8run: permissions, kubectl, namespace, storage, ingress and a test PVC and pod",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "namespace",
+						Usage:    "namespace to be checked. eg: 'default' (default: the namespace of the kubeconfig context)",
+						Required: false,
+					},
+					&cli.StringFlag{
+						Name:     "ingress-class",
+						Usage:    "ingress class deployments will use. eg: 'nginx' (default: the default ingress class of the cluster)",
+						Required: false,
+					},
+					outputFlag("format of the report. eg: 'text' or 'json'"),
+					&cli.DurationFlag{
+						Name:     "timeout",
+						Usage:    "timeout for the checks, including pulling the test image. eg: 30s",
+						Required: false,
+						Value:    2 * time.Minute,
+					},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					json, err := jsonOutput(cmd)
+					if err != nil {
+						return err
+					}
 
-					c := command.NewDeploymentCommand(command.NewDeploymentCommandParams{
-						Name:       cmd.Args().First(),
-						Namespace:  cmd.String("namespace"),
-						Entrypoint: strings.Split(cmd.String("entrypoint"), " "),
-						Timeout:    cmd.Duration("timeout"),
-						// Deployment
-						Replicas: int32(cmd.Int("replicas")),
-						Copy:     cmd.String("copy"),
-						Image:    cmd.String("image"),
-						// Service
-						Service:       cmd.Bool("service"),
-						ContainerPort: cmd.Int("container-port"),
-						Port:          cmd.Int("port"),
-						// Ingress
-						Ingress:      cmd.Bool("ingress"),
-						IngressHost:  cmd.String("ingress-host"),
+					report, err := newClient(cmd).Doctor(ctx, k8run.DoctorOptions{
+						Namespace:    cmd.String("namespace"),
 						IngressClass: cmd.String("ingress-class"),
+						Timeout:      cmd.Duration("timeout"),
 					})
-
-					if err := c.Validate(); err != nil {
+					if err != nil {
 						return err
 					}
 
-					return c.Run(ctx)
+					if json {
+						if err := report.WriteJSON(os.Stdout); err != nil {
+							return err
+						}
+					} else {
+						report.Write(os.Stdout)
+					}
+
+					if report.Failed() {
+						return k8run.Invalid(fmt.Errorf("Some checks failed"))
+					}
+					return nil
 				},
 			},
 		},
 	}
 
+	started := false
+	trackActions(cmd, &started)
 	if err := cmd.Run(context.Background(), os.Args); err != nil {
-		log.Fatal(err)
+		if !started {
+			// unknown or missing flags
+			err = k8run.Invalid(err)
+		}
+		log.Print(err)
+		os.Exit(k8run.ExitCode(err))
+	}
+}
+
+// trackActions wraps the actions of the command and its subcommands to record whether one started, so the errors
+// returned before, while parsing the flags, are told apart.
+func trackActions(cmd *cli.Command, started *bool) {
+	if action := cmd.Action; action != nil {
+		cmd.Action = func(ctx context.Context, cmd *cli.Command) error {
+			*started = true
+			return action(ctx, cmd)
+		}
+	}
+	for _, subcommand := range cmd.Commands {
+		trackActions(subcommand, started)
+	}
+}
+
+// finish ends a deployment or a destroy: an aborted one succeeds, and with json, the result is printed with the
+// error the run failed with, which is returned. Without a result, eg: when the flags are invalid, the document only
+// names the app and has the error.
+func finish(cmd *cli.Command, json bool, result *k8run.Result, err error) error {
+	err = unlessAborted(err)
+	if !json {
+		return err
+	}
+
+	if result == nil {
+		result = &k8run.Result{Name: cmd.Args().First(), Namespace: cmd.String("namespace")}
+	}
+	if writeErr := result.WriteJSON(os.Stdout, err); writeErr != nil {
+		return writeErr
+	}
+	return err
+}
+
+// unlessAborted returns err, or nil when the user declined the changes, which isn't a failure.
+func unlessAborted(err error) error {
+	if errors.Is(err, k8run.ErrAborted) {
+		return nil
+	}
+	return err
+}
+
+// outputFlag returns the flag choosing the format of what the command prints.
+func outputFlag(usage string) cli.Flag {
+	return &cli.StringFlag{
+		Name:     "output",
+		Aliases:  []string{"o"},
+		Usage:    usage,
+		Value:    "text",
+		Required: false,
+	}
+}
+
+// jsonOutput returns true when the command prints JSON, failing on unknown formats. They're most likely a typo of a
+// script reading JSON, so their error is printed as JSON too.
+func jsonOutput(cmd *cli.Command) (bool, error) {
+	switch cmd.String("output") {
+	case "text":
+		return false, nil
+	case "json":
+		return true, nil
+	default:
+		return true, k8run.Invalid(fmt.Errorf("Output must be 'text' or 'json'"))
+	}
+}
+
+// humanOutput returns where the messages meant for humans go: stdout or, when the command prints JSON, stderr,
+// so stdout only has the JSON document.
+func humanOutput(cmd *cli.Command) io.Writer {
+	if cmd.String("output") == "json" {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// newClient returns the client reaching the cluster from the global flags, logging with the default logger and
+// confirming changes in the terminal, within the guard rails.
+func newClient(cmd *cli.Command) *k8run.Client {
+	return k8run.NewFromKubeconfig(
+		k8run.Kubeconfig{
+			Path:     cmd.String("kubeconfig"),
+			Context:  cmd.String("context"),
+			As:       cmd.String("as"),
+			AsGroups: cmd.StringSlice("as-group"),
+		},
+		k8run.WithOwner(cmd.String("owner")),
+		k8run.WithCopyTransport(k8run.CopyTransport(cmd.String("copy-transport"))),
+		k8run.WithCopyCompression(k8run.CopyCompression(cmd.String("copy-compression"))),
+		k8run.WithCopyProgress(copyProgressBar()),
+		k8run.WithLogger(slog.Default()),
+		k8run.WithGuardRails(guard.Files()...),
+		k8run.WithPrompter(terminalPrompter{yes: cmd.Bool("yes"), w: humanOutput(cmd)}),
+	)
+}
+
+// terminalPrompter prints the target and plan of the changes and asks the user to confirm them, unless yes is set.
+// Targets not allowed by the guard rails require typing the context name, even with yes. It fails instead of
+// waiting for an answer that will never come when stdin isn't a terminal.
+type terminalPrompter struct {
+	yes bool
+	w   io.Writer
+}
+
+func (p terminalPrompter) Confirm(ctx context.Context, target k8run.Target, changes *k8run.Plan) (bool, error) {
+	interactive := isTerminal(os.Stdin)
+	if !p.yes && !interactive {
+		return false, k8run.Invalid(fmt.Errorf("stdin is not a terminal, so the confirmation can't be asked: use --yes to proceed"))
+	}
+
+	printTarget(p.w, target.Context, target.Namespaces)
+	changes.Write(p.w)
+	fmt.Fprintln(p.w)
+
+	if len(target.Unlisted) > 0 {
+		for _, reason := range target.Unlisted {
+			fmt.Fprintln(p.w, "Not allowed by the guard rails:", reason)
+		}
+		if !interactive {
+			return false, k8run.Invalid(fmt.Errorf("the target must be confirmed by typing its context name, which requires a terminal"))
+		}
+		if !confirmName(p.w, target.Context) {
+			fmt.Fprintln(p.w, "Operation aborted.")
+			return false, nil
+		}
+		fmt.Fprintln(p.w)
+		return true, nil
+	}
+
+	if !p.yes && !confirm(p.w, "Are you sure you want to proceed? (yes/no)") {
+		fmt.Fprintln(p.w, "Operation aborted.")
+		return false, nil
+	}
+	fmt.Fprintln(p.w)
+	return true, nil
+}
+
+// printTarget prints the context and namespaces that are about to be changed, so they can't be missed.
+func printTarget(w io.Writer, contextName string, namespaces []string) {
+	line := strings.Repeat("=", 60)
+	fmt.Fprintln(w, line)
+	fmt.Fprintf(w, "  Context:   %s\n", contextName)
+	fmt.Fprintf(w, "  Namespace: %s\n", strings.Join(namespaces, ", "))
+	fmt.Fprintln(w, line)
+}
+
+// confirmName asks the user to type the given name to confirm.
+func confirmName(w io.Writer, name string) bool {
+	fmt.Fprintf(w, "Type the context name (%s) to proceed: ", name)
+	input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		fmt.Fprintln(w, "Error reading input:", err)
+		return false
+	}
+	return strings.TrimSpace(input) == name
+}
+
+// isTerminal returns true if the file is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// copyProgressBar returns a progress bar of the copy of the code drawn on stderr, or nil when stderr isn't a
+// terminal.
+func copyProgressBar() func(k8run.CopyProgress) {
+	if !isTerminal(os.Stderr) {
+		return nil
+	}
+
+	return func(p k8run.CopyProgress) {
+		const width = 30
+		ratio := 1.0
+		if p.Total > 0 {
+			ratio = min(float64(p.Copied)/float64(p.Total), 1)
+		}
+		filled := int(ratio * width)
+
+		line := fmt.Sprintf("\r[%s%s] %3.0f%% %s / %s, %s sent (%s), %s/s",
+			strings.Repeat("=", filled), strings.Repeat(" ", width-filled), ratio*100,
+			k8s.FormatBytes(p.Copied), k8s.FormatBytes(p.Total), k8s.FormatBytes(p.Sent), p.Compression,
+			k8s.FormatBytes(int64(p.Rate())))
+		if eta := p.ETA(); eta > 0 && !p.Done {
+			line += ", ETA " + eta.Round(time.Second).String()
+		}
+
+		// clears the rest of the previous line
+		fmt.Fprint(os.Stderr, line+"\033[K")
+		if p.Done {
+			fmt.Fprintln(os.Stderr)
+		}
 	}
 }
 
 // confirm asks the user for confirmation (yes/no)
-func confirm(message string) bool {
+func confirm(w io.Writer, message string) bool {
 	reader := bufio.NewReader(os.Stdin)
 	for {
-		fmt.Print(message + " ")
+		fmt.Fprint(w, message+" ")
 		input, err := reader.ReadString('\n')
 		if err != nil {
-			fmt.Println("Error reading input:", err)
+			fmt.Fprintln(w, "Error reading input:", err)
 			return false
 		}
 
@@ -200,7 +967,494 @@ func confirm(message string) bool {
 		} else if input == "no" || input == "n" {
 			return false
 		} else {
-			fmt.Println("Please type 'yes' or 'no'.")
+			fmt.Fprintln(w, "Please type 'yes' or 'no'.")
+		}
+	}
+}
+
+// composeCommand returns the compose subcommand that deploys, or destroys when down is true, a docker-compose file.
+func composeCommand(down bool) *cli.Command {
+	name, usage := "up", "Deploys every service of a docker-compose file, respecting depends_on"
+	if down {
+		name, usage = "down", "Destroys every service of a docker-compose file, destroying dependents first"
+	}
+
+	return &cli.Command{
+		Name:  name,
+		Usage: usage,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "file",
+				Aliases:  []string{"f"},
+				Usage:    "docker-compose file. eg: './docker-compose.yml'",
+				Value:    "docker-compose.yml",
+				Required: false,
+			},
+			&cli.StringFlag{
+				Name:     "namespace",
+				Usage:    "namespace to be used. eg: 'default' (default: the namespace of the kubeconfig context)",
+				Required: false,
+			},
+			&cli.BoolFlag{
+				Name:     "strict",
+				Usage:    "fails when the compose file uses keys that can't be translated",
+				Required: false,
+			},
+			&cli.DurationFlag{
+				Name:     "timeout",
+				Usage:    "timeout for each service. eg: 30s",
+				Required: false,
+				Value:    time.Minute,
+			},
+			&cli.BoolFlag{
+				Name:     "wait-for-lock",
+				Usage:    "waits for other deployments or destroys of the same app to finish, instead of failing",
+				Required: false,
+			},
+			&cli.BoolFlag{
+				Name:     "takeover",
+				Usage:    "replaces or destroys the services even if someone else deployed them",
+				Required: false,
+			},
+			&cli.BoolFlag{
+				Name:     "yes",
+				Aliases:  []string{"y"},
+				Usage:    "skips the confirmation",
+				Required: false,
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			options := k8run.ComposeOptions{
+				File:        cmd.String("file"),
+				Namespace:   cmd.String("namespace"),
+				Timeout:     cmd.Duration("timeout"),
+				Strict:      cmd.Bool("strict"),
+				Takeover:    cmd.Bool("takeover"),
+				WaitForLock: cmd.Bool("wait-for-lock"),
+			}
+
+			fmt.Println()
+			client := newClient(cmd)
+			if down {
+				return unlessAborted(client.ComposeDown(ctx, options))
+			}
+			return unlessAborted(client.ComposeUp(ctx, options))
+		},
+	}
+}
+
+// gcInstallCommand returns the gc subcommand that installs, or uninstalls when uninstall is true, the CronJob running gc.
+func gcInstallCommand(uninstall bool) *cli.Command {
+	name, usage := "install", "Installs a CronJob running 'k8run gc' on a schedule, with a service account only allowed to find and destroy apps"
+	if uninstall {
+		name, usage = "uninstall", "Uninstalls the CronJob running 'k8run gc' and its service account"
+	}
+
+	return &cli.Command{
+		Name:  name,
+		Usage: usage,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "schedule",
+				Usage:    "cron schedule of the CronJob. eg: '@daily'",
+				Value:    "0 * * * *",
+				Required: false,
+			},
+			&cli.StringFlag{
+				Name:     "image",
+				Usage:    "image with the k8run binary on its PATH. eg: 'registry.example.com/k8run:0.1.0' (default: downloads the k8run release)",
+				Required: false,
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			options := k8run.GCInstallOptions{
+				Namespace:     cmd.String("namespace"),
+				AllNamespaces: cmd.Bool("all-namespaces"),
+				Schedule:      cmd.String("schedule"),
+				Image:         cmd.String("image"),
+				Version:       cmd.Root().Version,
+				Timeout:       cmd.Duration("timeout"),
+			}
+
+			fmt.Println()
+			client := newClient(cmd)
+			if uninstall {
+				return unlessAborted(client.UninstallGC(ctx, options))
+			}
+			return unlessAborted(client.InstallGC(ctx, options))
+		},
+	}
+}
+
+// deploymentFlags returns the flags describing a deployment. The image is only required once validated, as it can
+// be inferred with '--auto' and other commands render a deployment only when asked to.
+func deploymentFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "entrypoint",
+			Usage:    "entrypoint of the container. eg: 'node index.js' (default: the command of the image)",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "image",
+			Usage:    "image to be used. eg: 'node:14'",
+			Required: false,
+		},
+		&cli.StringSliceFlag{
+			Name:  "copy",
+			Usage: "file or folder to be copied to the container, as 'src[:dest[:mode]]' with mode 'ro' or 'rw', landing in '/app' without dest. Archives and '-' (a tar from stdin) are extracted. eg: './dist', './config/dev.yaml:/etc/myapp/config.yaml:ro'",
+			// or '--git-repo'
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "copy-git-ref",
+			Usage:    "ships the first '--copy' folder as committed at the ref instead of the working tree. eg: 'main' or 'v1.2.0'",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "git-repo",
+			Usage:    "git repository cloned into the container by the init container, instead of '--copy'. eg: 'https://github.com/org/repo.git'",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "git-ref",
+			Usage:    "branch, tag or commit of '--git-repo' cloned. eg: 'main' or 'v1.2.0' (default: the default branch)",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "git-secret",
+			Usage:    "secret with the credentials of '--git-repo': a deploy key in 'ssh-privatekey' or a token in 'password'. eg: 'foobar-deploy-key'",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "workdir",
+			Usage:    "working dir of the container. eg: '/app/dist' (default: '/app')",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "setup",
+			Usage:    "command run once the code is copied, by an init container with '--image', eg: 'npm ci'",
+			Required: false,
+		},
+		&cli.StringSliceFlag{
+			Name:     "setup-cache",
+			Usage:    "folder kept across releases for '--setup', relative to the working dir, reused while the lockfiles don't change. eg: 'node_modules'",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "node-arch",
+			Usage:    "schedules the app on the nodes of the architecture, the one the copied binaries are checked against. eg: 'arm64' (default: the architectures of the nodes)",
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     "skip-binary-check",
+			Usage:    "deploys copied binaries that can't run on the nodes or with the C library of the image, eg: a macOS build the app doesn't run",
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     "service",
+			Usage:    "if service will be created",
+			Value:    false,
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     "ingress",
+			Usage:    "if ingress will be created",
+			Value:    false,
+			Required: false,
+		},
+		&cli.IntFlag{
+			Name:     "container-port",
+			Usage:    "port that the container is listening to",
+			Required: false,
+		},
+		&cli.IntFlag{
+			Name:     "port",
+			Usage:    "port that the service will be listening to",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "ingress-class",
+			Usage:    "ingress class to be used. eg: 'nginx'",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "ingress-host",
+			Usage:    "ingress host to be used. eg: 'foo.myapp.com'",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "namespace",
+			Usage:    "namespace to be used. eg: 'default' (default: the namespace of the kubeconfig context)",
+			Required: false,
+		},
+		&cli.IntFlag{
+			Name:     "replicas",
+			Value:    1,
+			Usage:    "number of replicas. eg: 3",
+			Required: false,
+		},
+		&cli.DurationFlag{
+			Name:     "timeout",
+			Usage:    "timeout for the deployment. eg: 30s",
+			Required: false,
+			Value:    time.Minute,
+		},
+		&cli.StringSliceFlag{
+			Name:     "env",
+			Usage:    "env var of the container, can be repeated. eg: 'PORT=3000'",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "requests",
+			Usage:    "resources requested by the container. eg: 'cpu=100m,memory=128Mi'",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "limits",
+			Usage:    "resource limits of the container. eg: 'cpu=500m,memory=512Mi'",
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     "preview",
+			Usage:    "names the app after the git branch of '--copy', prefixed by <name> if given. eg: 'feature-x'",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "preview-branch",
+			Usage:    "branch the preview app is named after. eg: \"$GITHUB_HEAD_REF\" (default: the git branch of '--copy')",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "preview-domain",
+			Usage:    "exposes the preview app on '<name>.<domain>' with an ingress. eg: 'preview.example.com'",
+			Required: false,
+		},
+		&cli.DurationFlag{
+			Name:     "ttl",
+			Usage:    "how long the app lives before 'k8run gc' destroys it. eg: 48h (default: forever)",
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     "create-namespace",
+			Usage:    "creates the namespace if it doesn't exist",
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     "isolated",
+			Usage:    "deploys into a namespace of its own, 'k8run-<name>', deleted when the app is destroyed with '--isolated'",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "quota",
+			Usage:    "resource quota of the isolated namespace. eg: 'requests.cpu=2,limits.memory=4Gi,pods=10'",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "default-requests",
+			Usage:    "resources requested by the containers of the isolated namespace that don't request any. eg: 'cpu=50m,memory=64Mi'",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "default-limits",
+			Usage:    "resource limits of the containers of the isolated namespace that don't set any. eg: 'cpu=500m,memory=512Mi'",
+			Required: false,
+		},
+	}
+}
+
+// autoFlag returns the flag inferring what isn't set from the files of the first '--copy' folder.
+func autoFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:     "auto",
+		Usage:    "infers the image, entrypoint, container port and setup left unset from the first '--copy' folder, eg: its package.json, and prints them. A Go module is cross-compiled and its binary copied",
+		Required: false,
+	}
+}
+
+// procfileFlags returns the deployment flags that apply to every process of a Procfile. The entrypoint comes from the
+// Procfile, and the setup belongs in its release process.
+func procfileFlags() []cli.Flag {
+	return slices.DeleteFunc(deploymentFlags(), func(flag cli.Flag) bool {
+		return slices.ContainsFunc(flag.Names(), func(name string) bool {
+			return name == "entrypoint" || strings.HasPrefix(name, "setup")
+		})
+	})
+}
+
+// buildFlags returns the deployment flags that apply to an image built from a Dockerfile. The image is the one built,
+// the context is copied without being run and the setup belongs in the Dockerfile.
+func buildFlags() []cli.Flag {
+	return slices.DeleteFunc(deploymentFlags(), func(flag cli.Flag) bool {
+		return slices.ContainsFunc(flag.Names(), func(name string) bool {
+			return name == "image" || name == "skip-binary-check" || strings.HasPrefix(name, "git-") || strings.HasPrefix(name, "setup")
+		})
+	})
+}
+
+// deployOptions returns the options of a deploy from the flags returned by deploymentFlags.
+func deployOptions(cmd *cli.Command) (k8run.DeployOptions, error) {
+	env, err := parseKeyValues(cmd.StringSlice("env"))
+	if err != nil {
+		return k8run.DeployOptions{}, k8run.Invalid(fmt.Errorf("Invalid env: %s", err))
+	}
+
+	requests, err := parseKeyValues(splitList(cmd.String("requests")))
+	if err != nil {
+		return k8run.DeployOptions{}, k8run.Invalid(fmt.Errorf("Invalid requests: %s", err))
+	}
+
+	limits, err := parseKeyValues(splitList(cmd.String("limits")))
+	if err != nil {
+		return k8run.DeployOptions{}, k8run.Invalid(fmt.Errorf("Invalid limits: %s", err))
+	}
+
+	quota, err := parseKeyValues(splitList(cmd.String("quota")))
+	if err != nil {
+		return k8run.DeployOptions{}, k8run.Invalid(fmt.Errorf("Invalid quota: %s", err))
+	}
+
+	defaultRequests, err := parseKeyValues(splitList(cmd.String("default-requests")))
+	if err != nil {
+		return k8run.DeployOptions{}, k8run.Invalid(fmt.Errorf("Invalid default requests: %s", err))
+	}
+
+	defaultLimits, err := parseKeyValues(splitList(cmd.String("default-limits")))
+	if err != nil {
+		return k8run.DeployOptions{}, k8run.Invalid(fmt.Errorf("Invalid default limits: %s", err))
+	}
+
+	copies, err := parseCopies(cmd.StringSlice("copy"))
+	if err != nil {
+		return k8run.DeployOptions{}, k8run.Invalid(fmt.Errorf("Invalid copy: %s", err))
+	}
+
+	return k8run.DeployOptions{
+		Name:       cmd.Args().First(),
+		Namespace:  cmd.String("namespace"),
+		Entrypoint: entrypoint(cmd),
+		Timeout:    cmd.Duration("timeout"),
+		// Deployment
+		Replicas:   int32(cmd.Int("replicas")),
+		Copies:     copies,
+		CopyGitRef: cmd.String("copy-git-ref"),
+		GitRepo:    cmd.String("git-repo"),
+		GitRef:     cmd.String("git-ref"),
+		GitSecret:  cmd.String("git-secret"),
+		WorkDir:    cmd.String("workdir"),
+		Setup:      cmd.String("setup"),
+		SetupCache: cmd.StringSlice("setup-cache"),
+		Auto:       cmd.Bool("auto"),
+		Image:      cmd.String("image"),
+		// Nodes
+		NodeArch:        cmd.String("node-arch"),
+		SkipBinaryCheck: cmd.Bool("skip-binary-check"),
+		// Service
+		Service:       cmd.Bool("service"),
+		ContainerPort: cmd.Int("container-port"),
+		Port:          cmd.Int("port"),
+		// Ingress
+		Ingress:      cmd.Bool("ingress"),
+		IngressHost:  cmd.String("ingress-host"),
+		IngressClass: cmd.String("ingress-class"),
+		// Container
+		Env: env,
+		Resources: k8run.Resources{
+			Requests: requests,
+			Limits:   limits,
+		},
+		TTL:         cmd.Duration("ttl"),
+		Takeover:    cmd.Bool("takeover"),
+		WaitForLock: cmd.Bool("wait-for-lock"),
+		// Preview
+		Preview:       cmd.Bool("preview"),
+		PreviewBranch: cmd.String("preview-branch"),
+		PreviewDomain: cmd.String("preview-domain"),
+		// Namespace
+		CreateNamespace: cmd.Bool("create-namespace"),
+		Isolated:        cmd.Bool("isolated"),
+		NamespaceLimits: k8run.NamespaceLimits{
+			Quota:           quota,
+			DefaultRequests: defaultRequests,
+			DefaultLimits:   defaultLimits,
+		},
+	}, nil
+}
+
+// entrypoint returns the entrypoint flag split on spaces, or nil to run the command of the image.
+func entrypoint(cmd *cli.Command) []string {
+	if cmd.String("entrypoint") == "" {
+		return nil
+	}
+	return strings.Split(cmd.String("entrypoint"), " ")
+}
+
+// parseKeyValues parses a list of 'key=value' entries into a map.
+func parseKeyValues(list []string) (map[string]string, error) {
+	m := map[string]string{}
+	for _, entry := range list {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("expected 'key=value', got %q", entry)
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// parseCopies parses a list of 'src[:dest[:mode]]' entries, mode being 'ro' or 'rw'. The drive letter of a windows
+// source, eg: 'C:\src:/app', is part of the source.
+func parseCopies(list []string) ([]k8run.CopyEntry, error) {
+	copies := []k8run.CopyEntry{}
+	for _, spec := range list {
+		drive := driveLetter(spec)
+		parts := strings.SplitN(strings.TrimPrefix(spec, drive), ":", 3)
+		entry := k8run.CopyEntry{Source: drive + parts[0]}
+		if len(parts) > 1 {
+			entry.Dest = parts[1]
+		}
+		if len(parts) > 2 {
+			switch parts[2] {
+			case "ro":
+				entry.ReadOnly = true
+			case "rw":
+			default:
+				return nil, fmt.Errorf("expected mode 'ro' or 'rw', got %q", parts[2])
+			}
+		}
+		copies = append(copies, entry)
+	}
+	return copies, nil
+}
+
+// driveLetter returns the drive letter the source starts with, eg: 'C:'. Backslashes only separate windows paths,
+// while 'a:/app' is the folder a copied to /app anywhere but on windows.
+func driveLetter(spec string) string {
+	if len(spec) < 3 || spec[1] != ':' || !('a' <= spec[0] && spec[0] <= 'z' || 'A' <= spec[0] && spec[0] <= 'Z') {
+		return ""
+	}
+	if spec[2] == '\\' || (spec[2] == '/' && runtime.GOOS == "windows") {
+		return spec[:2]
+	}
+	return ""
+}
+
+// firstCopy returns the source of the first '--copy' entry, the one an app is built from.
+func firstCopy(cmd *cli.Command) string {
+	copies, err := parseCopies(cmd.StringSlice("copy"))
+	if err != nil || len(copies) == 0 {
+		return ""
+	}
+	return copies[0].Source
+}
+
+// splitList splits a comma separated list, ignoring empty entries.
+func splitList(s string) []string {
+	list := []string{}
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
 		}
 	}
+	return list
 }
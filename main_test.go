@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/pkg/k8run"
+)
+
+func TestParseCopies(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    k8run.CopyEntry
+		wantErr bool
+	}{
+		{spec: "./dist", want: k8run.CopyEntry{Source: "./dist"}},
+		{spec: "./config/dev.yaml:/etc/myapp/config.yaml:ro", want: k8run.CopyEntry{Source: "./config/dev.yaml", Dest: "/etc/myapp/config.yaml", ReadOnly: true}},
+		{spec: "a:/app", want: k8run.CopyEntry{Source: "a", Dest: "/app"}},
+		{spec: `C:\src`, want: k8run.CopyEntry{Source: `C:\src`}},
+		{spec: `C:\src:/app:rw`, want: k8run.CopyEntry{Source: `C:\src`, Dest: "/app"}},
+		{spec: "./dist:/app:rx", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			copies, err := parseCopies([]string{tt.spec})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", copies)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if copies[0] != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, copies[0])
+			}
+		})
+	}
+}
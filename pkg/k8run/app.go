@@ -0,0 +1,110 @@
+package k8run
+
+import (
+	"io"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/command"
+)
+
+// App is an app deployed by k8run: a deployment or, for a Procfile, the deployments of its processes.
+type App struct {
+	Name      string
+	Namespace string
+	// Owner is who deployed the app. It's empty when the app was deployed before owners were recorded.
+	Owner string
+	// Deployments are the names of the deployments of the app.
+	Deployments []string
+	// Ready and Replicas add up the replicas of every deployment of the app.
+	Ready    int32
+	Replicas int32
+	// CreatedAt is when the first deployment of the app was created.
+	CreatedAt time.Time
+	// ExpiresAt is when 'k8run gc' destroys the app. It's zero when the app lives forever.
+	ExpiresAt time.Time
+}
+
+// AppStatus is an app with the resources exposing it and the state of its pods.
+type AppStatus struct {
+	App
+	// Release is the release identifier of the last deploy of the app.
+	Release string
+	// Service is the address of the service of the app, if any. eg: 'foo:8080'
+	Service string
+	// Ingress is the host of the ingress of the app, if any.
+	Ingress string
+	Pods    []PodStatus
+}
+
+// PodStatus is the state of a pod of an app.
+type PodStatus struct {
+	Name      string    `json:"name"`
+	Phase     string    `json:"phase"`
+	Ready     bool      `json:"ready"`
+	Restarts  int32     `json:"restarts"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// WriteApps writes the given apps as a table.
+func WriteApps(w io.Writer, apps []App) {
+	command.WriteApps(w, toApps(apps))
+}
+
+// WriteAppsJSON writes the given apps as JSON.
+func WriteAppsJSON(w io.Writer, apps []App) error {
+	return command.WriteAppsJSON(w, toApps(apps))
+}
+
+// Write writes the status of the app in a human readable form.
+func (s *AppStatus) Write(w io.Writer) {
+	toAppStatus(s).Write(w)
+}
+
+// WriteJSON writes the status of the app as JSON.
+func (s *AppStatus) WriteJSON(w io.Writer) error {
+	return toAppStatus(s).WriteJSON(w)
+}
+
+func toApps(apps []App) []command.App {
+	list := make([]command.App, 0, len(apps))
+	for _, app := range apps {
+		list = append(list, command.App(app))
+	}
+	return list
+}
+
+func fromApps(apps []command.App) []App {
+	list := make([]App, 0, len(apps))
+	for _, app := range apps {
+		list = append(list, App(app))
+	}
+	return list
+}
+
+func toAppStatus(s *AppStatus) *command.AppStatus {
+	pods := make([]command.PodStatus, 0, len(s.Pods))
+	for _, pod := range s.Pods {
+		pods = append(pods, command.PodStatus(pod))
+	}
+	return &command.AppStatus{
+		App:     command.App(s.App),
+		Release: s.Release,
+		Service: s.Service,
+		Ingress: s.Ingress,
+		Pods:    pods,
+	}
+}
+
+func fromAppStatus(s *command.AppStatus) *AppStatus {
+	pods := make([]PodStatus, 0, len(s.Pods))
+	for _, pod := range s.Pods {
+		pods = append(pods, PodStatus(pod))
+	}
+	return &AppStatus{
+		App:     App(s.App),
+		Release: s.Release,
+		Service: s.Service,
+		Ingress: s.Ingress,
+		Pods:    pods,
+	}
+}
@@ -0,0 +1,69 @@
+package k8run
+
+import (
+	"context"
+	"io"
+
+	"github.com/lucasvmiguel/k8run/internal/command"
+)
+
+// BuildOptions represents the image to build and how to deploy it.
+type BuildOptions struct {
+	// Deploy is how the image built is deployed. Its image is the one built, and its Copy is the context of the
+	// build, which isn't run.
+	Deploy DeployOptions
+	// Registry is the repository the image is pushed to, as '<Registry>/<name>'. eg: 'ghcr.io/org'
+	Registry string
+	// RegistrySecret is the docker config secret the image is pushed and pulled with.
+	RegistrySecret string
+	// Dockerfile is the path of the Dockerfile, relative to the context. Defaults to 'Dockerfile'.
+	Dockerfile string
+	// Output receives the logs of the build. Defaults to os.Stderr.
+	Output io.Writer
+}
+
+// Build builds the image of an app in the cluster from a Dockerfile, pushes it to the registry and deploys it, after
+// the prompter confirmed the plan of the deploy. Like with Deploy, the result is never nil.
+func (c *Client) Build(ctx context.Context, options BuildOptions) (*Result, error) {
+	ctx = c.context(ctx)
+	cmd := command.NewBuildCommand(command.NewBuildCommandParams{
+		Registry:       options.Registry,
+		RegistrySecret: options.RegistrySecret,
+		Dockerfile:     options.Dockerfile,
+		Output:         options.Output,
+		Deployment:     c.deploymentCommand(options.Deploy),
+	})
+	result := &Result{Name: options.Deploy.Name, Namespace: options.Deploy.Namespace}
+	if err := cmd.Validate(); err != nil {
+		return result, command.Invalid(err)
+	}
+
+	ok, err := c.confirm(ctx, planner{cmd})
+	if err != nil {
+		return result, err
+	}
+	if !ok {
+		return result, ErrAborted
+	}
+
+	err = cmd.Run(ctx)
+	return fromResult(cmd.Result()), err
+}
+
+// ProcfileOptions represents the Procfile to deploy and how.
+type ProcfileOptions struct {
+	// Deploy holds the options shared by every process. Its entrypoint is replaced by the command of each process.
+	Deploy DeployOptions
+	// Procfile is the path of the Procfile. Defaults to the Procfile inside Deploy.Copy.
+	Procfile string
+}
+
+// DeployProcfile deploys every process type of a Procfile from a single copy of the code, after the prompter
+// confirmed its plan. The 'web' process gets the service and ingress, and the 'release' process runs as a job before
+// the rollout.
+func (c *Client) DeployProcfile(ctx context.Context, options ProcfileOptions) error {
+	return c.run(ctx, command.NewProcfileCommand(command.NewProcfileCommandParams{
+		Procfile:   options.Procfile,
+		Deployment: c.deploymentCommand(options.Deploy),
+	}))
+}
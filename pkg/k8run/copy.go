@@ -0,0 +1,248 @@
+package k8run
+
+import (
+	"context"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/k8s"
+
+	"k8s.io/client-go/rest"
+)
+
+// CopyTransport is how the code is sent to the pods.
+type CopyTransport string
+
+// The copy transports.
+const (
+	// CopyTransportAuto streams over WebSocket, falling back to SPDY when the cluster rejects it.
+	CopyTransportAuto CopyTransport = "auto"
+	// CopyTransportWebSocket streams with the WebSocket exec protocol, served since Kubernetes 1.29.
+	CopyTransportWebSocket CopyTransport = "websocket"
+	// CopyTransportSPDY streams with the SPDY exec protocol, deprecated but served by every cluster.
+	CopyTransportSPDY CopyTransport = "spdy"
+	// CopyTransportKubectl runs 'kubectl cp', which must be on PATH.
+	CopyTransportKubectl CopyTransport = "kubectl"
+)
+
+// CopyCompression is how the code sent to the pods is compressed.
+type CopyCompression string
+
+// The copy compressions.
+const (
+	// CopyCompressionAuto picks the best compression the container can decompress: zstd, gzip or none.
+	CopyCompressionAuto CopyCompression = "auto"
+	CopyCompressionNone CopyCompression = "none"
+	CopyCompressionGzip CopyCompression = "gzip"
+	CopyCompressionZstd CopyCompression = "zstd"
+)
+
+// ArchiveFormat is the format of an archive source, whose entries are copied instead of the archive itself.
+type ArchiveFormat string
+
+// The archive formats.
+const (
+	ArchiveTar     ArchiveFormat = "tar"
+	ArchiveTarGzip ArchiveFormat = "tar.gz"
+	ArchiveZip     ArchiveFormat = "zip"
+)
+
+// Source is a local file, folder or archive to copy, with the files to leave out.
+type Source struct {
+	Path string
+	// Name is where Path lands, relative to the destination, '.' being the destination itself. Defaults to the
+	// base name of Path, like 'kubectl cp'.
+	Name string
+	// Skip leaves out the files and folders it returns true for, given their slash separated path relative to
+	// Path. eg: '.git'
+	Skip func(name string) bool
+	// Archive, when set, copies the entries of the archive at Path instead of the archive itself, landing in the
+	// destination unless Name is set. eg: ArchiveTarGzip
+	Archive ArchiveFormat
+}
+
+// CopyProgress is how far a copy is.
+type CopyProgress struct {
+	Compression CopyCompression
+	// Total is the size of the files to copy.
+	Total int64
+	// Copied is the size of the files archived so far.
+	Copied int64
+	// Sent is the size of the compressed archive sent so far.
+	Sent    int64
+	Elapsed time.Duration
+	// Done is set on the last progress of a successful copy.
+	Done bool
+}
+
+// Rate returns how many bytes of the files are copied per second.
+func (p CopyProgress) Rate() float64 {
+	return toCopyProgress(p).Rate()
+}
+
+// ETA returns how long the rest of the copy should take at the current rate, or zero when it's unknown.
+func (p CopyProgress) ETA() time.Duration {
+	return toCopyProgress(p).ETA()
+}
+
+// CopyParams represents the parameters of a copy.
+type CopyParams struct {
+	// Sources are sent together, in a single archive.
+	Sources       []Source
+	Namespace     string
+	PodName       string
+	ContainerName string
+	// ContainerPath is the folder the copy lands in.
+	ContainerPath string
+	// Compression is how the archive is compressed. Defaults to auto.
+	Compression CopyCompression
+	// Progress, when set, is called with the progress of the copy a few times per second.
+	Progress func(CopyProgress)
+}
+
+// Copier copies local files into a container of a running pod, eg: the init container waiting for the code.
+type Copier interface {
+	Copy(ctx context.Context, params CopyParams) error
+}
+
+// ExecCopier streams a tar archive into the container with the exec API. It's the default copier.
+type ExecCopier struct {
+	Config *rest.Config
+	// Transport is the exec protocol. Defaults to auto.
+	Transport CopyTransport
+}
+
+// Copy copies the sources into the container.
+func (c ExecCopier) Copy(ctx context.Context, params CopyParams) error {
+	return k8s.ExecCopier{Config: c.Config, Transport: k8s.CopyTransport(c.Transport)}.Copy(ctx, toCopyParams(params))
+}
+
+// KubectlCopier copies with 'kubectl cp', which must be on PATH.
+type KubectlCopier struct {
+	// Flags are extra flags passed to kubectl. eg: '--context'
+	Flags []string
+}
+
+// Copy copies the sources into the container.
+func (c KubectlCopier) Copy(ctx context.Context, params CopyParams) error {
+	return k8s.KubectlCopier{Flags: c.Flags}.Copy(ctx, toCopyParams(params))
+}
+
+// MemoryCopier keeps the copied files in memory instead of sending them to a pod, eg: in tests.
+type MemoryCopier struct {
+	copier k8s.MemoryCopier
+}
+
+// Copy reads the archive of the sources into memory.
+func (c *MemoryCopier) Copy(ctx context.Context, params CopyParams) error {
+	return c.copier.Copy(ctx, toCopyParams(params))
+}
+
+// Files returns the paths of the copied files in the container, sorted. eg: '/app/foobar/index.js'
+func (c *MemoryCopier) Files() []string {
+	return c.copier.Files()
+}
+
+// File returns the content of a copied file, or false when it hasn't been copied.
+func (c *MemoryCopier) File(file string) ([]byte, bool) {
+	return c.copier.File(file)
+}
+
+// copier adapts a Copier to the copier of the commands.
+type copier struct {
+	copier Copier
+}
+
+func (c copier) Copy(ctx context.Context, params k8s.CopyParams) error {
+	return c.copier.Copy(ctx, fromCopyParams(params))
+}
+
+// toCopier returns the copier of the commands copying with c, or nil to use the one of the copy transport.
+func toCopier(c Copier) k8s.Copier {
+	if c == nil {
+		return nil
+	}
+	return copier{copier: c}
+}
+
+func toCopyParams(params CopyParams) k8s.CopyParams {
+	sources := make([]k8s.Source, 0, len(params.Sources))
+	for _, source := range params.Sources {
+		sources = append(sources, k8s.Source{
+			Path:    source.Path,
+			Name:    source.Name,
+			Skip:    source.Skip,
+			Archive: k8s.ArchiveFormat(source.Archive),
+		})
+	}
+
+	return k8s.CopyParams{
+		Sources:       sources,
+		Namespace:     params.Namespace,
+		PodName:       params.PodName,
+		ContainerName: params.ContainerName,
+		ContainerPath: params.ContainerPath,
+		Compression:   k8s.CopyCompression(params.Compression),
+		Progress:      toCopyProgressFunc(params.Progress),
+	}
+}
+
+func fromCopyParams(params k8s.CopyParams) CopyParams {
+	sources := make([]Source, 0, len(params.Sources))
+	for _, source := range params.Sources {
+		sources = append(sources, Source{
+			Path:    source.Path,
+			Name:    source.Name,
+			Skip:    source.Skip,
+			Archive: ArchiveFormat(source.Archive),
+		})
+	}
+
+	var progress func(CopyProgress)
+	if params.Progress != nil {
+		progress = func(p CopyProgress) {
+			params.Progress(toCopyProgress(p))
+		}
+	}
+
+	return CopyParams{
+		Sources:       sources,
+		Namespace:     params.Namespace,
+		PodName:       params.PodName,
+		ContainerName: params.ContainerName,
+		ContainerPath: params.ContainerPath,
+		Compression:   CopyCompression(params.Compression),
+		Progress:      progress,
+	}
+}
+
+// toCopyProgressFunc returns the progress callback of the commands calling progress, or nil without one.
+func toCopyProgressFunc(progress func(CopyProgress)) func(k8s.CopyProgress) {
+	if progress == nil {
+		return nil
+	}
+	return func(p k8s.CopyProgress) {
+		progress(fromCopyProgress(p))
+	}
+}
+
+func toCopyProgress(p CopyProgress) k8s.CopyProgress {
+	return k8s.CopyProgress{
+		Compression: k8s.CopyCompression(p.Compression),
+		Total:       p.Total,
+		Copied:      p.Copied,
+		Sent:        p.Sent,
+		Elapsed:     p.Elapsed,
+		Done:        p.Done,
+	}
+}
+
+func fromCopyProgress(p k8s.CopyProgress) CopyProgress {
+	return CopyProgress{
+		Compression: CopyCompression(p.Compression),
+		Total:       p.Total,
+		Copied:      p.Copied,
+		Sent:        p.Sent,
+		Elapsed:     p.Elapsed,
+		Done:        p.Done,
+	}
+}
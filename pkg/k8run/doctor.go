@@ -0,0 +1,93 @@
+package k8run
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/command"
+	"github.com/lucasvmiguel/k8run/internal/doctor"
+)
+
+// CheckStatus is the outcome of a check.
+type CheckStatus string
+
+// The outcomes of a check.
+const (
+	CheckPass CheckStatus = "pass"
+	// CheckWarn means k8run may still work, eg: the check couldn't be run or only some features are affected.
+	CheckWarn CheckStatus = "warn"
+	CheckFail CheckStatus = "fail"
+)
+
+// Check is a single readiness check of the cluster.
+type Check struct {
+	Name    string      `json:"name"`
+	Status  CheckStatus `json:"status"`
+	Message string      `json:"message,omitempty"`
+}
+
+// Report is the list of checks run by Doctor.
+type Report struct {
+	Checks []Check `json:"checks"`
+}
+
+// Failed returns true if any check failed.
+func (r *Report) Failed() bool {
+	return toReport(r).Failed()
+}
+
+// Write prints the report in a human readable form.
+func (r *Report) Write(w io.Writer) {
+	toReport(r).Write(w)
+}
+
+// WriteJSON prints the report as JSON, with the number of checks of each status.
+func (r *Report) WriteJSON(w io.Writer) error {
+	return toReport(r).WriteJSON(w)
+}
+
+// DoctorOptions represents where to check the cluster.
+type DoctorOptions struct {
+	Namespace string
+	// IngressClass is the ingress class deployments will use. Defaults to the default ingress class of the cluster.
+	IngressClass string
+	// Timeout is how long the checks may take, including pulling the test image.
+	Timeout time.Duration
+}
+
+// Doctor checks whether the cluster is ready for k8run: the permissions of the user, kubectl, the namespace, the
+// storage, the ingress and a test PVC and pod. Failed checks are in the report, not in the error.
+func (c *Client) Doctor(ctx context.Context, options DoctorOptions) (*Report, error) {
+	cmd := command.NewDoctorCommand(command.NewDoctorCommandParams{
+		Namespace:    options.Namespace,
+		IngressClass: options.IngressClass,
+		Timeout:      options.Timeout,
+		Kube:         c.kube,
+	})
+	if err := cmd.Validate(); err != nil {
+		return nil, command.Invalid(err)
+	}
+
+	report, err := cmd.Report(c.context(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return fromReport(report), nil
+}
+
+func toReport(r *Report) *doctor.Report {
+	checks := make([]doctor.Check, 0, len(r.Checks))
+	for _, check := range r.Checks {
+		checks = append(checks, doctor.Check{Name: check.Name, Status: doctor.Status(check.Status), Message: check.Message})
+	}
+	return &doctor.Report{Checks: checks}
+}
+
+func fromReport(r *doctor.Report) *Report {
+	checks := make([]Check, 0, len(r.Checks))
+	for _, check := range r.Checks {
+		checks = append(checks, Check{Name: check.Name, Status: CheckStatus(check.Status), Message: check.Message})
+	}
+	return &Report{Checks: checks}
+}
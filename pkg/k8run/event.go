@@ -0,0 +1,96 @@
+package k8run
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Event is a step of the client, eg: 'Waiting for deployment to be ready...'.
+type Event struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	// Attrs describe the event. eg: {"name": "foobar", "namespace": "default"}
+	Attrs map[string]string
+}
+
+// progressHandler turns log records into events.
+type progressHandler struct {
+	progress func(Event)
+	attrs    []slog.Attr
+}
+
+func (h *progressHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *progressHandler) Handle(_ context.Context, record slog.Record) error {
+	event := Event{Time: record.Time, Level: record.Level, Message: record.Message, Attrs: map[string]string{}}
+	for _, attr := range h.attrs {
+		event.Attrs[attr.Key] = attr.Value.String()
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		event.Attrs[attr.Key] = attr.Value.String()
+		return true
+	})
+
+	h.progress(event)
+	return nil
+}
+
+func (h *progressHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &progressHandler{progress: h.progress, attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)}
+}
+
+// WithGroup ignores groups, as the client doesn't log any.
+func (h *progressHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// discardHandler drops every log record.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// multiHandler hands every log record to each of its handlers, eg: a logger and a progress callback.
+type multiHandler []slog.Handler
+
+func (h multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h multiHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, handler := range h {
+		if handler.Enabled(ctx, record.Level) {
+			errs = append(errs, handler.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(multiHandler, 0, len(h))
+	for _, handler := range h {
+		handlers = append(handlers, handler.WithAttrs(attrs))
+	}
+	return handlers
+}
+
+func (h multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make(multiHandler, 0, len(h))
+	for _, handler := range h {
+		handlers = append(handlers, handler.WithGroup(name))
+	}
+	return handlers
+}
@@ -0,0 +1,54 @@
+package k8run
+
+import (
+	"context"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/command"
+)
+
+// ExportOptions represents which app to export and how.
+type ExportOptions struct {
+	Name      string
+	Namespace string
+	// Format is the format of the exported project: 'yaml', 'kustomize' or 'helm'.
+	Format string
+	// Out is the folder the project is written to.
+	Out string
+	// Dockerfile generates a Dockerfile baking Copy into an image, instead of using an init container and a PVC.
+	Dockerfile bool
+	// DockerfileImage is the image built from the Dockerfile. Defaults to '<Name>:latest'.
+	DockerfileImage string
+	// Copy is the folder baked into the image of the Dockerfile. Defaults to the copy of Render.
+	Copy    string
+	Timeout time.Duration
+	// Render, when set, renders the resources from the options instead of reading the live ones. Its name and
+	// namespace are the ones of the export.
+	Render *DeployOptions
+}
+
+// Export writes an app and its resources as plain YAML, a Kustomize base or a Helm chart.
+func (c *Client) Export(ctx context.Context, options ExportOptions) error {
+	var render *command.DeploymentCommand
+	if options.Render != nil {
+		render = c.deploymentCommand(*options.Render)
+	}
+
+	cmd := command.NewExportCommand(command.NewExportCommandParams{
+		Name:            options.Name,
+		Namespace:       options.Namespace,
+		Format:          options.Format,
+		Out:             options.Out,
+		Dockerfile:      options.Dockerfile,
+		DockerfileImage: options.DockerfileImage,
+		Copy:            options.Copy,
+		Timeout:         options.Timeout,
+		Render:          render,
+		Kube:            c.kube,
+	})
+	if err := cmd.Validate(); err != nil {
+		return command.Invalid(err)
+	}
+
+	return cmd.Run(c.context(ctx))
+}
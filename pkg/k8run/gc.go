@@ -0,0 +1,81 @@
+package k8run
+
+import (
+	"context"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/command"
+)
+
+// GCOptions represents where to collect.
+type GCOptions struct {
+	Namespace string
+	// AllNamespaces collects in every namespace instead of only Namespace.
+	AllNamespaces bool
+	// Timeout is the timeout of each app.
+	Timeout time.Duration
+}
+
+// GC destroys the apps whose TTL expired and the resources k8run left behind for apps that no longer exist, after
+// the prompter confirmed its plan.
+func (c *Client) GC(ctx context.Context, options GCOptions) error {
+	return c.run(ctx, c.gcCommand(options))
+}
+
+// GCPlan returns the changes GC would make with the same options, without making them.
+func (c *Client) GCPlan(ctx context.Context, options GCOptions) (*Plan, error) {
+	cmd := c.gcCommand(options)
+	if err := cmd.Validate(); err != nil {
+		return nil, command.Invalid(err)
+	}
+
+	return planner{cmd}.Plan(c.context(ctx))
+}
+
+func (c *Client) gcCommand(options GCOptions) *command.GCCommand {
+	return command.NewGCCommand(command.NewGCCommandParams{
+		Namespace:     options.Namespace,
+		AllNamespaces: options.AllNamespaces,
+		Timeout:       options.Timeout,
+		Kube:          c.kube,
+	})
+}
+
+// GCInstallOptions represents the CronJob running 'k8run gc' and where it collects.
+type GCInstallOptions struct {
+	// Namespace is where the CronJob runs and collects.
+	Namespace string
+	// AllNamespaces collects in every namespace instead of only Namespace.
+	AllNamespaces bool
+	// Schedule is the cron schedule of the CronJob. eg: '@daily'
+	Schedule string
+	// Image is the image with the k8run binary on its PATH. Defaults to downloading the release of Version.
+	Image   string
+	Version string
+	Timeout time.Duration
+}
+
+// InstallGC installs a CronJob running 'k8run gc' on a schedule, with a service account only allowed to find and
+// destroy apps, after the prompter confirmed its plan.
+func (c *Client) InstallGC(ctx context.Context, options GCInstallOptions) error {
+	return c.run(ctx, c.gcInstallCommand(options, false))
+}
+
+// UninstallGC uninstalls the CronJob installed by InstallGC and its service account, after the prompter confirmed
+// its plan.
+func (c *Client) UninstallGC(ctx context.Context, options GCInstallOptions) error {
+	return c.run(ctx, c.gcInstallCommand(options, true))
+}
+
+func (c *Client) gcInstallCommand(options GCInstallOptions, uninstall bool) *command.GCInstallCommand {
+	return command.NewGCInstallCommand(command.NewGCInstallCommandParams{
+		Namespace:     options.Namespace,
+		AllNamespaces: options.AllNamespaces,
+		Schedule:      options.Schedule,
+		Image:         options.Image,
+		Version:       options.Version,
+		Uninstall:     uninstall,
+		Timeout:       options.Timeout,
+		Kube:          c.kube,
+	})
+}
@@ -0,0 +1,621 @@
+// Package k8run deploys prototypes to Kubernetes the way the k8run CLI does, for tools embedding it.
+//
+// A Client deploys an image with local code copied into it, optionally exposed by a service and an ingress, and
+// destroys, lists and inspects the apps it deployed. It also runs every other command of the CLI, eg: Up, Build or GC:
+//
+//	client := k8run.New(restConfig, k8run.WithProgress(func(e k8run.Event) { fmt.Println(e.Message) }))
+//	result, err := client.Deploy(ctx, k8run.DeployOptions{
+//		Name:       "foobar",
+//		Image:      "node:22",
+//		Entrypoint: []string{"node", "index.js"},
+//		Copy:       "./foobar",
+//		Timeout:    time.Minute,
+//	})
+package k8run
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/command"
+	"github.com/lucasvmiguel/k8run/internal/guard"
+	"github.com/lucasvmiguel/k8run/internal/kube"
+	"github.com/lucasvmiguel/k8run/internal/logging"
+	"github.com/lucasvmiguel/k8run/internal/plan"
+
+	"k8s.io/client-go/rest"
+)
+
+// The kinds of errors returned by the client, matched with errors.Is.
+var (
+	ErrValidation = command.ErrValidation
+	ErrAuth       = command.ErrAuth
+	ErrTimeout    = command.ErrTimeout
+	ErrConflict   = command.ErrConflict
+	ErrAppCrash   = command.ErrAppCrash
+	// ErrAborted is returned when the prompter declines the changes.
+	ErrAborted = errors.New("aborted")
+)
+
+// ExitCode returns the code the k8run CLI exits with for the given error.
+func ExitCode(err error) int {
+	return command.ExitCode(err)
+}
+
+// Invalid marks err as a validation error, matched by ErrValidation, eg: the error of a prompter that can't ask
+// for a confirmation.
+func Invalid(err error) error {
+	return command.Invalid(err)
+}
+
+// Target is where the changes of a deploy or a destroy are made.
+type Target struct {
+	// Context is the kubeconfig context, or 'in-cluster' without one.
+	Context    string
+	Namespaces []string
+	// Unlisted are why the guard rails don't allow the target. The user must then confirm it explicitly,
+	// eg: by typing Context.
+	Unlisted []string
+}
+
+// Prompter asks the user to confirm the changes before they're made.
+type Prompter interface {
+	// Confirm shows the plan of the changes to the target and returns whether to proceed.
+	Confirm(ctx context.Context, target Target, p *Plan) (bool, error)
+}
+
+// Kubeconfig represents how to reach the cluster like kubectl does.
+type Kubeconfig struct {
+	// Path is the kubeconfig file to use instead of the KUBECONFIG env var or '~/.kube/config'.
+	Path string
+	// Context is the kubeconfig context to use instead of the current one.
+	Context string
+	// As is the user to impersonate.
+	As string
+	// AsGroups are the groups to impersonate.
+	AsGroups []string
+}
+
+// Client deploys and destroys apps on a cluster, like the commands of the k8run CLI.
+type Client struct {
+	kube       kube.Config
+	owner      string
+	copier     Copier
+	progress   func(CopyProgress)
+	prompter   Prompter
+	guardFiles []string
+	// handlers log the progress of the client, each of them getting every record.
+	handlers []slog.Handler
+	logger   *slog.Logger
+}
+
+// Option configures a client.
+type Option func(*Client)
+
+// New creates a client reaching the cluster with the given config. As kubectl can't be given it, the kubectl copy
+// transport uses the kubeconfig of kubectl.
+func New(config *rest.Config, options ...Option) *Client {
+	return newClient(kube.Config{REST: config}, options)
+}
+
+// NewFromKubeconfig creates a client reaching the cluster like kubectl does. Its zero value uses the files in
+// $KUBECONFIG, '~/.kube/config' or the in-cluster config.
+func NewFromKubeconfig(kubeconfig Kubeconfig, options ...Option) *Client {
+	return newClient(kube.Config{
+		Kubeconfig: kubeconfig.Path,
+		Context:    kubeconfig.Context,
+		As:         kubeconfig.As,
+		AsGroups:   kubeconfig.AsGroups,
+	}, options)
+}
+
+func newClient(config kube.Config, options []Option) *Client {
+	c := &Client{kube: config}
+	for _, option := range options {
+		option(c)
+	}
+
+	switch len(c.handlers) {
+	case 0:
+		c.logger = slog.New(discardHandler{})
+	case 1:
+		c.logger = slog.New(c.handlers[0])
+	default:
+		c.logger = slog.New(multiHandler(c.handlers))
+	}
+	return c
+}
+
+// WithOwner sets who deploys and destroys apps. Defaults to the git email or the kubeconfig user.
+func WithOwner(owner string) Option {
+	return func(c *Client) {
+		c.owner = owner
+	}
+}
+
+// WithCopier sets how the code is copied into the pods. Defaults to the copier of the copy transport.
+func WithCopier(copier Copier) Option {
+	return func(c *Client) {
+		c.copier = copier
+	}
+}
+
+// WithCopyTransport sets how the code is sent to the pods. Defaults to auto: WebSocket, falling back to SPDY when
+// the cluster rejects it.
+func WithCopyTransport(transport CopyTransport) Option {
+	return func(c *Client) {
+		c.kube.CopyTransport = string(transport)
+	}
+}
+
+// WithCopyCompression sets how the code sent to the pods is compressed. Defaults to auto: the best compression
+// the init container can decompress.
+func WithCopyCompression(compression CopyCompression) Option {
+	return func(c *Client) {
+		c.kube.CopyCompression = string(compression)
+	}
+}
+
+// WithCopyProgress calls progress with the progress of the copy of the code a few times per second, eg: to draw a
+// progress bar.
+func WithCopyProgress(progress func(CopyProgress)) Option {
+	return func(c *Client) {
+		c.progress = progress
+	}
+}
+
+// WithPrompter asks the prompter to confirm the changes of every method changing the cluster, eg: Deploy, Destroy
+// or GC. Without one, they're made right away.
+func WithPrompter(prompter Prompter) Option {
+	return func(c *Client) {
+		c.prompter = prompter
+	}
+}
+
+// WithGuardRails refuses the targets denied by the guard rails of the given files, and requires the prompter to
+// confirm the unlisted ones.
+func WithGuardRails(files ...string) Option {
+	return func(c *Client) {
+		c.guardFiles = files
+	}
+}
+
+// WithLogger logs the progress of the client with the logger. By default, it's discarded. It can be combined with
+// WithProgress and other loggers, each of them getting every step.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.handlers = append(c.handlers, logger.Handler())
+	}
+}
+
+// WithProgress calls progress with every step of the client, eg: 'Waiting for deployment to be ready...'.
+// It's called from the goroutines of the client, so it must be safe for concurrent use. It can be combined with
+// WithLogger, each of them getting every step.
+func WithProgress(progress func(Event)) Option {
+	return func(c *Client) {
+		c.handlers = append(c.handlers, &progressHandler{progress: progress})
+	}
+}
+
+// context returns ctx carrying the logger of the client.
+func (c *Client) context(ctx context.Context) context.Context {
+	return logging.NewContext(ctx, c.logger)
+}
+
+// Planner is implemented by what shows the changes it will make before they're confirmed.
+type Planner interface {
+	Plan(ctx context.Context) (*Plan, error)
+}
+
+// Confirm checks the target of the changes of p against the guard rails and asks the prompter to confirm them.
+// Every method changing the cluster confirms its changes with it.
+func (c *Client) Confirm(ctx context.Context, p Planner) (bool, error) {
+	return c.confirm(c.context(ctx), p)
+}
+
+func (c *Client) confirm(ctx context.Context, p Planner) (bool, error) {
+	if c.prompter == nil && len(c.guardFiles) == 0 {
+		return true, nil
+	}
+
+	guards, err := guard.Load(c.guardFiles...)
+	if err != nil {
+		return false, command.Invalid(err)
+	}
+
+	contextName, err := c.kube.ContextName()
+	if err != nil {
+		return false, err
+	}
+	target := Target{Context: cmp.Or(contextName, guard.InCluster)}
+
+	changes, err := p.Plan(ctx)
+	if err != nil {
+		return false, err
+	}
+	target.Namespaces = changes.Namespaces()
+
+	for _, namespace := range target.Namespaces {
+		verdict, reason := guards.Check(target.Context, namespace)
+		switch verdict {
+		case guard.Denied:
+			return false, command.Invalid(fmt.Errorf("Refusing to proceed: %s", reason))
+		case guard.Unlisted:
+			target.Unlisted = append(target.Unlisted, reason)
+		}
+	}
+
+	if c.prompter == nil {
+		if len(target.Unlisted) > 0 {
+			return false, command.Invalid(fmt.Errorf("Refusing to proceed without a prompter to confirm the target: %s", target.Unlisted[0]))
+		}
+		return true, nil
+	}
+	return c.prompter.Confirm(ctx, target, changes)
+}
+
+// runner is a command whose changes are confirmed before it runs.
+type runner interface {
+	Validate() error
+	Plan(ctx context.Context) (*plan.Plan, error)
+	Run(ctx context.Context) error
+}
+
+// run validates cmd and runs it once the prompter confirmed its plan.
+func (c *Client) run(ctx context.Context, cmd runner) error {
+	ctx = c.context(ctx)
+	if err := cmd.Validate(); err != nil {
+		return command.Invalid(err)
+	}
+
+	ok, err := c.confirm(ctx, planner{cmd})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAborted
+	}
+
+	return cmd.Run(ctx)
+}
+
+// Resources represents the compute resources requested by and limited for the app container.
+// eg: {"cpu": "100m", "memory": "128Mi"}
+type Resources struct {
+	Requests map[string]string
+	Limits   map[string]string
+}
+
+// NamespaceLimits represents the resource quota and the default container resources of an isolated namespace.
+type NamespaceLimits struct {
+	// Quota caps the resources of the namespace. eg: {"requests.cpu": "2", "pods": "10"}
+	Quota map[string]string
+	// DefaultRequests and DefaultLimits are the resources of the containers that don't set any.
+	DefaultRequests map[string]string
+	DefaultLimits   map[string]string
+}
+
+// CopyEntry is a file or folder copied into the container, with a destination and mode of its own.
+type CopyEntry struct {
+	// Source is the local file or folder copied. A '.tar', '.tar.gz', '.tgz' or '.zip' file is extracted, and '-'
+	// extracts a tar archive read from Stdin.
+	Source string
+	// Dest is where the source lands in the container. Defaults to a file or folder named after it in /app.
+	Dest string
+	// ReadOnly mounts the copy read-only in the app container.
+	ReadOnly bool
+}
+
+func toCopyEntries(copies []CopyEntry) []command.CopyEntry {
+	if copies == nil {
+		return nil
+	}
+	entries := make([]command.CopyEntry, 0, len(copies))
+	for _, entry := range copies {
+		entries = append(entries, command.CopyEntry(entry))
+	}
+	return entries
+}
+
+// DeployOptions represents what to deploy and how.
+type DeployOptions struct {
+	Name string
+	// Image is the image the code is copied into. eg: 'node:22'
+	Image string
+	// Entrypoint is the command of the container. eg: ["node", "index.js"]
+	Entrypoint []string
+	// Copy is the file or folder copied into the container.
+	Copy string
+	// Copies are files or folders copied along with Copy, each with a destination and mode of its own.
+	Copies []CopyEntry
+	// CopyGitRef ships the first copy as committed at the ref instead of the working tree. eg: 'main'
+	CopyGitRef string
+	// Stdin is the tar archive read by a '-' copy. Defaults to os.Stdin.
+	Stdin io.Reader
+	// GitRepo is cloned into the container by the init container, instead of copying local files.
+	GitRepo string
+	// GitRef is the branch, tag or commit of GitRepo cloned. Defaults to the default branch.
+	GitRef string
+	// GitSecret is the secret with the credentials of GitRepo: a deploy key in 'ssh-privatekey' or a token in
+	// 'password', with an optional 'username'.
+	GitSecret string
+	// NoCopy deploys the image as it is, without copying anything into the container.
+	NoCopy bool
+	// WorkDir is the working dir of the container. Defaults to /app, where the copies land.
+	WorkDir string
+	// Setup is run with sh once the code is copied, by an init container with the image of the app. eg: 'npm ci'
+	Setup string
+	// SetupCache are folders kept across releases for Setup, eg: 'node_modules', relative to the working dir. The
+	// setup is skipped while the lockfiles don't change.
+	SetupCache []string
+	// Auto infers the image, entrypoint, port and setup left unset from the files of Copy, logging what it
+	// inferred. A Go module is cross-compiled locally and its binary copied instead of its sources.
+	Auto bool
+	// NodeArch schedules the app on the nodes of the architecture, eg: 'arm64'. The binaries copied are checked
+	// against it, or against the architectures of the nodes, before deploying.
+	NodeArch string
+	// SkipBinaryCheck deploys binaries that can't run on the nodes or with the C library of the image.
+	SkipBinaryCheck bool
+	ContainerPort   int64
+	// Service exposes the app on Port with a service.
+	Service bool
+	Port    int64
+	// Ingress exposes the app with an ingress, on IngressHost if set.
+	Ingress      bool
+	IngressHost  string
+	IngressClass string
+	// Namespace defaults to the namespace of the kubeconfig context, or 'default' with a REST config.
+	Namespace string
+	Replicas  int32
+	// Timeout is how long the deploy may take, including the rollout.
+	Timeout   time.Duration
+	Env       map[string]string
+	Resources Resources
+	// CreateNamespace creates the namespace when it doesn't exist.
+	CreateNamespace bool
+	// Isolated deploys into a namespace of its own, named after the app and deleted when the app is destroyed.
+	Isolated        bool
+	NamespaceLimits NamespaceLimits
+	// TTL is how long the app lives before 'k8run gc' destroys it. Zero keeps it forever.
+	TTL time.Duration
+	// Takeover allows replacing an app deployed by someone else.
+	Takeover bool
+	// WaitForLock waits for another deploy or destroy of the app to finish instead of failing.
+	WaitForLock bool
+	// Preview names the app after the git branch of Copy, prefixed by Name if set.
+	Preview       bool
+	PreviewBranch string
+	// PreviewDomain exposes a preview app on '<name>.<domain>' with an ingress.
+	PreviewDomain string
+}
+
+func (c *Client) deploymentCommand(options DeployOptions) *command.DeploymentCommand {
+	return command.NewDeploymentCommand(command.NewDeploymentCommandParams{
+		Name:            options.Name,
+		Entrypoint:      options.Entrypoint,
+		Copy:            options.Copy,
+		Copies:          toCopyEntries(options.Copies),
+		CopyGitRef:      options.CopyGitRef,
+		Stdin:           options.Stdin,
+		GitRepo:         options.GitRepo,
+		GitRef:          options.GitRef,
+		GitSecret:       options.GitSecret,
+		ContainerPort:   options.ContainerPort,
+		Port:            options.Port,
+		Service:         options.Service,
+		Ingress:         options.Ingress,
+		IngressHost:     options.IngressHost,
+		IngressClass:    options.IngressClass,
+		Namespace:       options.Namespace,
+		Image:           options.Image,
+		Replicas:        options.Replicas,
+		Timeout:         options.Timeout,
+		Env:             options.Env,
+		Resources:       command.Resources(options.Resources),
+		NoCopy:          options.NoCopy,
+		WorkDir:         options.WorkDir,
+		Setup:           options.Setup,
+		SetupCache:      options.SetupCache,
+		Auto:            options.Auto,
+		NodeArch:        options.NodeArch,
+		SkipBinaryCheck: options.SkipBinaryCheck,
+		CreateNamespace: options.CreateNamespace,
+		Isolated:        options.Isolated,
+		NamespaceLimits: command.NamespaceLimits(options.NamespaceLimits),
+		TTL:             options.TTL,
+		Owner:           c.owner,
+		Takeover:        options.Takeover,
+		WaitForLock:     options.WaitForLock,
+		Preview:         options.Preview,
+		PreviewBranch:   options.PreviewBranch,
+		PreviewDomain:   options.PreviewDomain,
+		Copier:          toCopier(c.copier),
+		CopyProgress:    toCopyProgressFunc(c.progress),
+		Kube:            c.kube,
+	})
+}
+
+// Deploy deploys an app, after the prompter confirmed its plan. The result describes what was deployed, and what
+// was done before failing when it returns an error too. It's never nil, even when nothing was done.
+func (c *Client) Deploy(ctx context.Context, options DeployOptions) (*Result, error) {
+	ctx = c.context(ctx)
+	cmd := c.deploymentCommand(options)
+	result := &Result{Name: options.Name, Namespace: options.Namespace}
+	if err := cmd.Validate(); err != nil {
+		return result, command.Invalid(err)
+	}
+	logInferences(ctx, cmd)
+
+	ok, err := c.confirm(ctx, planner{cmd})
+	if err != nil {
+		return result, err
+	}
+	if !ok {
+		return result, ErrAborted
+	}
+
+	err = cmd.Run(ctx)
+	return fromResult(cmd.Result()), err
+}
+
+// logInferences logs what the deployment inferred from the copied folder, if anything.
+func logInferences(ctx context.Context, cmd *command.DeploymentCommand) {
+	for _, inference := range cmd.Inferences() {
+		logging.FromContext(ctx).With("field", inference.Field, "value", inference.Value, "from", inference.Source).Info("Inferred")
+	}
+}
+
+// Diff returns the changes Deploy would make with the same options, without making them.
+func (c *Client) Diff(ctx context.Context, options DeployOptions) (*Plan, error) {
+	ctx = c.context(ctx)
+	cmd := c.deploymentCommand(options)
+	if err := cmd.Validate(); err != nil {
+		return nil, command.Invalid(err)
+	}
+	logInferences(ctx, cmd)
+
+	return planner{cmd}.Plan(ctx)
+}
+
+// DestroyOptions represents which app to destroy.
+type DestroyOptions struct {
+	Name      string
+	Namespace string
+	// Timeout is how long the destroy may take, including waiting for the resources to be deleted.
+	Timeout time.Duration
+	// Isolated destroys an app deployed into a namespace of its own, including the namespace.
+	Isolated bool
+	// Takeover allows destroying an app deployed by someone else.
+	Takeover bool
+	// WaitForLock waits for another deploy or destroy of the app to finish instead of failing.
+	WaitForLock bool
+	// Preview destroys the preview app of the branch checked out in the current folder, prefixed by Name if set.
+	Preview       bool
+	PreviewBranch string
+}
+
+// Destroy destroys an app and the resources created with it, after the prompter confirmed its plan. Like with
+// Deploy, the result is never nil.
+func (c *Client) Destroy(ctx context.Context, options DestroyOptions) (*Result, error) {
+	ctx = c.context(ctx)
+	cmd := command.NewDestroyCommand(command.NewDestroyCommandParams{
+		Name:          options.Name,
+		Namespace:     options.Namespace,
+		Timeout:       options.Timeout,
+		Isolated:      options.Isolated,
+		Owner:         c.owner,
+		Takeover:      options.Takeover,
+		WaitForLock:   options.WaitForLock,
+		Preview:       options.Preview,
+		PreviewBranch: options.PreviewBranch,
+		Kube:          c.kube,
+	})
+	result := &Result{Name: options.Name, Namespace: options.Namespace}
+	if err := cmd.Validate(); err != nil {
+		return result, command.Invalid(err)
+	}
+
+	ok, err := c.confirm(ctx, planner{cmd})
+	if err != nil {
+		return result, err
+	}
+	if !ok {
+		return result, ErrAborted
+	}
+
+	err = cmd.Run(ctx)
+	return fromResult(cmd.Result()), err
+}
+
+// ListOptions represents where to list apps.
+type ListOptions struct {
+	Namespace string
+	// AllNamespaces lists the apps of every namespace instead of only Namespace.
+	AllNamespaces bool
+	Timeout       time.Duration
+}
+
+// List returns the apps deployed by k8run, sorted by namespace and name.
+func (c *Client) List(ctx context.Context, options ListOptions) ([]App, error) {
+	cmd := command.NewListCommand(command.NewListCommandParams{
+		Namespace:     options.Namespace,
+		AllNamespaces: options.AllNamespaces,
+		Timeout:       options.Timeout,
+		Kube:          c.kube,
+	})
+	if err := cmd.Validate(); err != nil {
+		return nil, command.Invalid(err)
+	}
+
+	apps, err := cmd.List(c.context(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return fromApps(apps), nil
+}
+
+// StatusOptions represents which app to inspect.
+type StatusOptions struct {
+	Name      string
+	Namespace string
+	// Isolated inspects an app deployed into a namespace of its own.
+	Isolated bool
+	Timeout  time.Duration
+}
+
+// Status returns the state of an app: its owner, replicas, expiry, service, ingress and pods.
+func (c *Client) Status(ctx context.Context, options StatusOptions) (*AppStatus, error) {
+	cmd := command.NewStatusCommand(command.NewStatusCommandParams{
+		Name:      options.Name,
+		Namespace: options.Namespace,
+		Isolated:  options.Isolated,
+		Timeout:   options.Timeout,
+		Kube:      c.kube,
+	})
+	if err := cmd.Validate(); err != nil {
+		return nil, command.Invalid(err)
+	}
+
+	status, err := cmd.Status(c.context(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return fromAppStatus(status), nil
+}
+
+// LogsOptions represents which logs to stream.
+type LogsOptions struct {
+	Name      string
+	Namespace string
+	// Isolated streams the logs of an app deployed into a namespace of its own.
+	Isolated bool
+	// Follow keeps streaming new logs until ctx is done.
+	Follow bool
+	// Since only streams the logs newer than this duration. Zero streams all of them.
+	Since time.Duration
+	// Tail only streams this number of lines from the end of the logs of each pod. Zero streams all of them.
+	Tail int64
+}
+
+// Logs writes the logs of every pod of an app to w, each line prefixed by its pod when there are several.
+func (c *Client) Logs(ctx context.Context, options LogsOptions, w io.Writer) error {
+	cmd := command.NewLogsCommand(command.NewLogsCommandParams{
+		Name:      options.Name,
+		Namespace: options.Namespace,
+		Isolated:  options.Isolated,
+		Follow:    options.Follow,
+		Since:     options.Since,
+		Tail:      options.Tail,
+		Kube:      c.kube,
+	})
+	if err := cmd.Validate(); err != nil {
+		return command.Invalid(err)
+	}
+
+	return cmd.Logs(c.context(ctx), w)
+}
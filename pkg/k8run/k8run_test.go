@@ -0,0 +1,208 @@
+package k8run_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lucasvmiguel/k8run/pkg/k8run"
+
+	"k8s.io/client-go/rest"
+)
+
+type planner struct {
+	plan *k8run.Plan
+}
+
+func (p planner) Plan(context.Context) (*k8run.Plan, error) {
+	return p.plan, nil
+}
+
+type prompter struct {
+	answer bool
+	target k8run.Target
+	asked  bool
+}
+
+func (p *prompter) Confirm(_ context.Context, target k8run.Target, _ *k8run.Plan) (bool, error) {
+	p.asked = true
+	p.target = target
+	return p.answer, nil
+}
+
+func writeGuard(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "guard.yaml")
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestClient_Confirm(t *testing.T) {
+	p := planner{plan: &k8run.Plan{Changes: []k8run.Change{{Kind: "Deployment", Name: "foobar", Namespace: "team-a"}}}}
+
+	t.Run("without a prompter nor guard rails", func(t *testing.T) {
+		ok, err := k8run.New(&rest.Config{}).Confirm(context.Background(), p)
+		if err != nil || !ok {
+			t.Fatalf("expected confirmation, got %v, %v", ok, err)
+		}
+	})
+
+	t.Run("asks the prompter", func(t *testing.T) {
+		for _, answer := range []bool{true, false} {
+			prompter := &prompter{answer: answer}
+			ok, err := k8run.New(&rest.Config{}, k8run.WithPrompter(prompter)).Confirm(context.Background(), p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != answer {
+				t.Errorf("expected %v, got %v", answer, ok)
+			}
+			if prompter.target.Context != "in-cluster" || !slices.Equal(prompter.target.Namespaces, []string{"team-a"}) {
+				t.Errorf("unexpected target: %+v", prompter.target)
+			}
+		}
+	})
+
+	t.Run("refuses denied targets", func(t *testing.T) {
+		prompter := &prompter{answer: true}
+		client := k8run.New(&rest.Config{},
+			k8run.WithPrompter(prompter),
+			k8run.WithGuardRails(writeGuard(t, "namespaces:\n  deny: [\"team-*\"]\n")),
+		)
+		if _, err := client.Confirm(context.Background(), p); !errors.Is(err, k8run.ErrValidation) {
+			t.Fatalf("expected a validation error, got %v", err)
+		}
+		if prompter.asked {
+			t.Error("expected the prompter not to be asked")
+		}
+	})
+
+	t.Run("unlisted targets", func(t *testing.T) {
+		guard := writeGuard(t, "contexts:\n  allow: [dev]\n")
+
+		prompter := &prompter{answer: true}
+		ok, err := k8run.New(&rest.Config{}, k8run.WithPrompter(prompter), k8run.WithGuardRails(guard)).Confirm(context.Background(), p)
+		if err != nil || !ok {
+			t.Fatalf("expected confirmation, got %v, %v", ok, err)
+		}
+		if len(prompter.target.Unlisted) != 1 {
+			t.Errorf("expected the target to be unlisted: %+v", prompter.target)
+		}
+
+		// without a prompter, nobody can confirm them
+		if _, err := k8run.New(&rest.Config{}, k8run.WithGuardRails(guard)).Confirm(context.Background(), p); !errors.Is(err, k8run.ErrValidation) {
+			t.Fatalf("expected a validation error, got %v", err)
+		}
+	})
+}
+
+func TestClient_Deploy(t *testing.T) {
+	t.Run("invalid options", func(t *testing.T) {
+		prompter := &prompter{answer: true}
+		result, err := k8run.New(&rest.Config{}, k8run.WithPrompter(prompter)).Deploy(context.Background(), k8run.DeployOptions{Namespace: "team-a"})
+		if !errors.Is(err, k8run.ErrValidation) {
+			t.Fatalf("expected a validation error, got %v", err)
+		}
+		// the result is still written as JSON with the error
+		if result == nil || result.Namespace != "team-a" {
+			t.Errorf("expected a result naming the app, got %+v", result)
+		}
+		if k8run.ExitCode(err) != 2 {
+			t.Errorf("expected exit code 2, got %d", k8run.ExitCode(err))
+		}
+		if prompter.asked {
+			t.Error("expected the prompter not to be asked")
+		}
+	})
+}
+
+func TestClient_LoggerAndProgress(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"scripts": {"start": "node index.js"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	var mu sync.Mutex
+	events := []k8run.Event{}
+	client := k8run.New(&rest.Config{},
+		k8run.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		k8run.WithProgress(func(e k8run.Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, e)
+		}),
+	)
+
+	err := client.Init(context.Background(), k8run.InitOptions{Copy: dir, File: filepath.Join(dir, "k8run.yaml")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// both get every step
+	if len(events) == 0 || !strings.Contains(logs.String(), events[len(events)-1].Message) {
+		t.Errorf("expected the logger and the progress to get the same steps, got %+v and %q", events, logs.String())
+	}
+	if strings.Count(logs.String(), "\n") != len(events) {
+		t.Errorf("expected %d log lines, got %q", len(events), logs.String())
+	}
+}
+
+func TestMemoryCopier(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.js"), []byte("console.log(1)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	progress := []k8run.CopyProgress{}
+	copier := &k8run.MemoryCopier{}
+	err := copier.Copy(context.Background(), k8run.CopyParams{
+		Sources:       []k8run.Source{{Path: dir, Name: "foobar"}},
+		ContainerPath: "/app",
+		Compression:   k8run.CopyCompressionGzip,
+		Progress:      func(p k8run.CopyProgress) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(copier.Files(), []string{"/app/foobar/index.js"}) {
+		t.Errorf("unexpected files: %v", copier.Files())
+	}
+	if content, ok := copier.File("/app/foobar/index.js"); !ok || string(content) != "console.log(1)" {
+		t.Errorf("unexpected content: %q", content)
+	}
+	if len(progress) == 0 || !progress[len(progress)-1].Done || progress[len(progress)-1].Compression != k8run.CopyCompressionGzip {
+		t.Errorf("expected a last progress of the gzip copy, got %+v", progress)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	p := &k8run.Plan{Changes: []k8run.Change{
+		{Kind: "Namespace", Name: "k8run-foobar", Action: k8run.ActionCreate},
+		{Kind: "Deployment", Name: "foobar", Namespace: "k8run-foobar", Action: k8run.ActionUpdate, Fields: []k8run.Field{{Path: "spec.replicas", Old: "1", New: "2"}}},
+		{Kind: "Service", Name: "foobar", Namespace: "default", Action: k8run.ActionUnchanged},
+	}}
+
+	if !slices.Equal(p.Namespaces(), []string{"default", "k8run-foobar"}) {
+		t.Errorf("unexpected namespaces: %v", p.Namespaces())
+	}
+	if !p.HasChanges() {
+		t.Error("expected changes")
+	}
+
+	var out bytes.Buffer
+	p.Write(&out)
+	if !strings.Contains(out.String(), "~ spec.replicas: 1 -> 2") || !strings.Contains(out.String(), "1 to create, 1 to update, 0 to delete, 1 unchanged.") {
+		t.Errorf("unexpected plan: %s", out.String())
+	}
+}
@@ -0,0 +1,124 @@
+package k8run
+
+import (
+	"context"
+	"io"
+
+	"github.com/lucasvmiguel/k8run/internal/plan"
+)
+
+// Action is what will happen to a resource.
+type Action string
+
+// The actions of a change.
+const (
+	ActionCreate    Action = "create"
+	ActionUpdate    Action = "update"
+	ActionDelete    Action = "delete"
+	ActionUnchanged Action = "unchanged"
+)
+
+// Field is a field that differs between the live and the desired resource. An empty Old means the field is added.
+type Field struct {
+	Path string `json:"path"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// Change is what will happen to a single resource.
+type Change struct {
+	Kind      string  `json:"kind"`
+	Name      string  `json:"name"`
+	Namespace string  `json:"namespace,omitempty"`
+	Action    Action  `json:"action"`
+	Fields    []Field `json:"fields,omitempty"`
+	// Recreate is set when an immutable field changes, so the resource must be deleted and created again.
+	Recreate bool `json:"recreate,omitempty"`
+	// Restart is set when the pods of the resource will be restarted.
+	Restart bool `json:"restart,omitempty"`
+	// Note explains anything else worth knowing before proceeding.
+	Note string `json:"note,omitempty"`
+}
+
+// Plan is the list of changes a command will make.
+type Plan struct {
+	Changes []Change
+}
+
+// Namespaces returns the namespaces of the planned resources, sorted. A planned namespace counts as its own
+// namespace.
+func (p *Plan) Namespaces() []string {
+	return toPlan(p).Namespaces()
+}
+
+// HasChanges returns true if any resource will be created, updated or deleted.
+func (p *Plan) HasChanges() bool {
+	return toPlan(p).HasChanges()
+}
+
+// Write prints the plan in a human readable form.
+func (p *Plan) Write(w io.Writer) {
+	toPlan(p).Write(w)
+}
+
+// WriteJSON prints the plan as JSON, with the number of changes of each action.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	return toPlan(p).WriteJSON(w)
+}
+
+// planner adapts a command to a Planner.
+type planner struct {
+	cmd interface {
+		Plan(ctx context.Context) (*plan.Plan, error)
+	}
+}
+
+func (p planner) Plan(ctx context.Context) (*Plan, error) {
+	changes, err := p.cmd.Plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return fromPlan(changes), nil
+}
+
+func toPlan(p *Plan) *plan.Plan {
+	changes := make([]plan.Change, 0, len(p.Changes))
+	for _, change := range p.Changes {
+		fields := make([]plan.Field, 0, len(change.Fields))
+		for _, field := range change.Fields {
+			fields = append(fields, plan.Field(field))
+		}
+		changes = append(changes, plan.Change{
+			Kind:      change.Kind,
+			Name:      change.Name,
+			Namespace: change.Namespace,
+			Action:    plan.Action(change.Action),
+			Fields:    fields,
+			Recreate:  change.Recreate,
+			Restart:   change.Restart,
+			Note:      change.Note,
+		})
+	}
+	return &plan.Plan{Changes: changes}
+}
+
+func fromPlan(p *plan.Plan) *Plan {
+	changes := make([]Change, 0, len(p.Changes))
+	for _, change := range p.Changes {
+		fields := make([]Field, 0, len(change.Fields))
+		for _, field := range change.Fields {
+			fields = append(fields, Field(field))
+		}
+		changes = append(changes, Change{
+			Kind:      change.Kind,
+			Name:      change.Name,
+			Namespace: change.Namespace,
+			Action:    Action(change.Action),
+			Fields:    fields,
+			Recreate:  change.Recreate,
+			Restart:   change.Restart,
+			Note:      change.Note,
+		})
+	}
+	return &Plan{Changes: changes}
+}
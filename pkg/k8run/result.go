@@ -0,0 +1,145 @@
+package k8run
+
+import (
+	"io"
+
+	"github.com/lucasvmiguel/k8run/internal/command"
+	"github.com/lucasvmiguel/k8run/internal/plan"
+)
+
+// Result is the outcome of a deploy or a destroy, written as the final document of '--output json'.
+type Result struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// Release is the release identifier of a deploy.
+	Release string `json:"release,omitempty"`
+	// Commit is the git commit released, copied from a git repository or cloned.
+	Commit string `json:"commit,omitempty"`
+	// Resources are the resources created, updated or deleted.
+	Resources []ResultResource `json:"resources"`
+	// Pods are the names of the pods of the release once it's ready.
+	Pods             []string `json:"pods,omitempty"`
+	ServiceClusterIP string   `json:"serviceClusterIP,omitempty"`
+	IngressURL       string   `json:"ingressURL,omitempty"`
+	// Copy is the copy of the code of a deploy.
+	Copy *CopyResult `json:"copy,omitempty"`
+	// Build is the image built in the cluster and deployed.
+	Build *BuildResult `json:"build,omitempty"`
+	// Steps are the steps run, in order, with how long each took.
+	Steps           []Step  `json:"steps"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// ResultResource is a resource changed by a deploy or a destroy.
+type ResultResource struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Action    Action `json:"action"`
+}
+
+// CopyResult is the size of the code copied into the pods.
+type CopyResult struct {
+	// SizeBytes is the size of the files, before leaving any out.
+	SizeBytes     int64 `json:"sizeBytes"`
+	FilteredBytes int64 `json:"filteredBytes"`
+	// SentBytes is the size of the compressed archive sent to the pod.
+	SentBytes   int64  `json:"sentBytes"`
+	Compression string `json:"compression,omitempty"`
+}
+
+// BuildResult is an image built in the cluster.
+type BuildResult struct {
+	// Image is the image deployed, by its digest. eg: 'ghcr.io/org/app@sha256:...'
+	Image           string  `json:"image"`
+	Digest          string  `json:"digest"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// Step is a step of a deploy or a destroy, eg: copying the code.
+type Step struct {
+	Name            string  `json:"name"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// WriteJSON prints the result as JSON, with the given error of the deploy or destroy, if any, its kind and the code
+// the k8run CLI exits with.
+func (r *Result) WriteJSON(w io.Writer, err error) error {
+	return toResult(r).WriteJSON(w, err)
+}
+
+func toResult(r *Result) *command.Result {
+	resources := make([]command.ResultResource, 0, len(r.Resources))
+	for _, resource := range r.Resources {
+		resources = append(resources, command.ResultResource{
+			Kind:      resource.Kind,
+			Name:      resource.Name,
+			Namespace: resource.Namespace,
+			Action:    plan.Action(resource.Action),
+		})
+	}
+	steps := make([]command.Step, 0, len(r.Steps))
+	for _, step := range r.Steps {
+		steps = append(steps, command.Step(step))
+	}
+
+	result := &command.Result{
+		Name:             r.Name,
+		Namespace:        r.Namespace,
+		Release:          r.Release,
+		Commit:           r.Commit,
+		Resources:        resources,
+		Pods:             r.Pods,
+		ServiceClusterIP: r.ServiceClusterIP,
+		IngressURL:       r.IngressURL,
+		Steps:            steps,
+		DurationSeconds:  r.DurationSeconds,
+	}
+	if r.Copy != nil {
+		copyResult := command.CopyResult(*r.Copy)
+		result.Copy = &copyResult
+	}
+	if r.Build != nil {
+		build := command.BuildResult(*r.Build)
+		result.Build = &build
+	}
+	return result
+}
+
+func fromResult(r *command.Result) *Result {
+	resources := make([]ResultResource, 0, len(r.Resources))
+	for _, resource := range r.Resources {
+		resources = append(resources, ResultResource{
+			Kind:      resource.Kind,
+			Name:      resource.Name,
+			Namespace: resource.Namespace,
+			Action:    Action(resource.Action),
+		})
+	}
+	steps := make([]Step, 0, len(r.Steps))
+	for _, step := range r.Steps {
+		steps = append(steps, Step(step))
+	}
+
+	result := &Result{
+		Name:             r.Name,
+		Namespace:        r.Namespace,
+		Release:          r.Release,
+		Commit:           r.Commit,
+		Resources:        resources,
+		Pods:             r.Pods,
+		ServiceClusterIP: r.ServiceClusterIP,
+		IngressURL:       r.IngressURL,
+		Steps:            steps,
+		DurationSeconds:  r.DurationSeconds,
+	}
+	if r.Copy != nil {
+		copyResult := CopyResult(*r.Copy)
+		result.Copy = &copyResult
+	}
+	if r.Build != nil {
+		build := BuildResult(*r.Build)
+		result.Build = &build
+	}
+	return result
+}
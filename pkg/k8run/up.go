@@ -0,0 +1,113 @@
+package k8run
+
+import (
+	"context"
+	"time"
+
+	"github.com/lucasvmiguel/k8run/internal/command"
+)
+
+// UpOptions represents the config file whose apps to deploy or destroy.
+type UpOptions struct {
+	// File is the k8run.yaml describing the apps.
+	File string
+	// Timeout is the timeout of each app that doesn't declare its own.
+	Timeout time.Duration
+	// Takeover allows replacing or destroying apps deployed by someone else.
+	Takeover bool
+	// WaitForLock waits for other deploys or destroys of the apps to finish instead of failing.
+	WaitForLock bool
+}
+
+// Up deploys every app described by a config file, after the prompter confirmed their plan. The apps are deployed
+// concurrently, each once the apps it depends on are ready.
+func (c *Client) Up(ctx context.Context, options UpOptions) error {
+	return c.run(ctx, command.NewUpCommand(command.NewUpCommandParams{
+		File:        options.File,
+		Timeout:     options.Timeout,
+		Owner:       c.owner,
+		Takeover:    options.Takeover,
+		WaitForLock: options.WaitForLock,
+		Kube:        c.kube,
+	}))
+}
+
+// Down destroys every app described by a config file, dependents first, after the prompter confirmed their plan.
+func (c *Client) Down(ctx context.Context, options UpOptions) error {
+	return c.run(ctx, command.NewDownCommand(command.NewDownCommandParams{
+		File:        options.File,
+		Timeout:     options.Timeout,
+		Owner:       c.owner,
+		Takeover:    options.Takeover,
+		WaitForLock: options.WaitForLock,
+		Kube:        c.kube,
+	}))
+}
+
+// ComposeOptions represents the docker-compose file whose services to deploy or destroy.
+type ComposeOptions struct {
+	File      string
+	Namespace string
+	// Timeout is the timeout of each service.
+	Timeout time.Duration
+	// Strict fails when the compose file uses keys that can't be translated, instead of logging them.
+	Strict bool
+	// Takeover allows replacing or destroying services deployed by someone else.
+	Takeover bool
+	// WaitForLock waits for other deploys or destroys of the services to finish instead of failing.
+	WaitForLock bool
+}
+
+// ComposeUp deploys every service of a docker-compose file, respecting depends_on, after the prompter confirmed
+// their plan.
+func (c *Client) ComposeUp(ctx context.Context, options ComposeOptions) error {
+	return c.run(ctx, c.composeCommand(options, false))
+}
+
+// ComposeDown destroys every service of a docker-compose file, dependents first, after the prompter confirmed their
+// plan.
+func (c *Client) ComposeDown(ctx context.Context, options ComposeOptions) error {
+	return c.run(ctx, c.composeCommand(options, true))
+}
+
+func (c *Client) composeCommand(options ComposeOptions, down bool) *command.ComposeCommand {
+	return command.NewComposeCommand(command.NewComposeCommandParams{
+		File:        options.File,
+		Namespace:   options.Namespace,
+		Timeout:     options.Timeout,
+		Strict:      options.Strict,
+		Down:        down,
+		Owner:       c.owner,
+		Takeover:    options.Takeover,
+		WaitForLock: options.WaitForLock,
+		Kube:        c.kube,
+	})
+}
+
+// InitOptions represents the folder of the app whose config file to write.
+type InitOptions struct {
+	// Name is the name of the app. Defaults to the name of the folder.
+	Name string
+	// Copy is the folder of the app. Defaults to the current folder.
+	Copy string
+	// File is the config file written. Defaults to k8run.yaml.
+	File string
+	// Force overwrites the config file if it exists.
+	Force bool
+}
+
+// Init writes a config file describing the app of a folder, with the image, entrypoint and port inferred from its
+// files, for Up to deploy. It doesn't reach the cluster.
+func (c *Client) Init(ctx context.Context, options InitOptions) error {
+	cmd := command.NewInitCommand(command.NewInitCommandParams{
+		Name:  options.Name,
+		Copy:  options.Copy,
+		File:  options.File,
+		Force: options.Force,
+	})
+	if err := cmd.Validate(); err != nil {
+		return command.Invalid(err)
+	}
+
+	return cmd.Run(c.context(ctx))
+}
@@ -0,0 +1,9 @@
+// Package schema publishes the JSON schema of the k8run.yaml config file.
+package schema
+
+import _ "embed"
+
+// Config is the JSON schema of the k8run.yaml config file.
+//
+//go:embed k8run.schema.json
+var Config []byte